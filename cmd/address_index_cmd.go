@@ -0,0 +1,100 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/cmd/utils"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/gosuri/uiprogress"
+	"github.com/urfave/cli"
+)
+
+var RebuildAddressIndexCommand = cli.Command{
+	Name:      "rebuildaddressindex",
+	Usage:     "Rebuild the address transaction index of DB",
+	ArgsUsage: "",
+	Action:    rebuildAddressIndex,
+	Flags: []cli.Flag{
+		utils.DataDirFlag,
+		utils.ConfigFlag,
+		utils.NetworkIdFlag,
+	},
+	Description: "Note that the ont and ong transfers are only indexed when the event log of the DB is enabled",
+}
+
+func rebuildAddressIndex(ctx *cli.Context) error {
+	log.InitLog(log.InfoLog)
+
+	cfg, err := SetDNAConfig(ctx)
+	if err != nil {
+		PrintErrorMsg("SetDNAConfig error:%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+
+	stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+	ledger.DefLedger, err = ledger.NewLedger(dbDir, stateHashHeight)
+	if err != nil {
+		return fmt.Errorf("NewLedger error:%s", err)
+	}
+	defer ledger.DefLedger.Close()
+	bookKeepers, err := config.DefConfig.GetBookkeepers()
+	if err != nil {
+		return fmt.Errorf("GetBookkeepers error:%s", err)
+	}
+	genesisConfig := config.DefConfig.Genesis
+	genesisBlock, err := genesis.BuildGenesisBlock(bookKeepers, genesisConfig)
+	if err != nil {
+		return fmt.Errorf("BuildGenesisBlock error %s", err)
+	}
+	err = ledger.DefLedger.Init(bookKeepers, genesisBlock)
+	if err != nil {
+		return fmt.Errorf("init ledger error:%s", err)
+	}
+
+	currBlockHeight := ledger.DefLedger.GetCurrentBlockHeight()
+	//the blocks before pruned height are not kept any more
+	prunedHeight := ledger.DefLedger.GetPrunedHeight()
+	//progress bar
+	uiprogress.Start()
+	bar := uiprogress.AddBar(int(currBlockHeight - prunedHeight + 1)).
+		AppendCompleted().
+		AppendElapsed().
+		PrependFunc(func(b *uiprogress.Bar) string {
+			return fmt.Sprintf("Block(%d/%d)", b.Current()+int(prunedHeight), int(currBlockHeight+1))
+		})
+
+	PrintInfoMsg("Start rebuild address index.")
+
+	err = ledger.DefLedger.RebuildAddressTxIndex(func(height uint32) {
+		bar.Incr()
+	})
+	uiprogress.Stop()
+	if err != nil {
+		return fmt.Errorf("RebuildAddressTxIndex error:%s", err)
+	}
+	PrintInfoMsg("Rebuild address index completed, block height:%d.", currBlockHeight)
+	return nil
+}
@@ -36,6 +36,10 @@ func SetDNAConfig(ctx *cli.Context) (*config.DNAConfig, error) {
 	}
 	setCommonConfig(ctx, cfg.Common)
 	setConsensusConfig(ctx, cfg.Consensus)
+	err = setTxPoolConfig(ctx, cfg.TxPool)
+	if err != nil {
+		return nil, fmt.Errorf("setTxPoolConfig error:%s", err)
+	}
 	setP2PNodeConfig(ctx, cfg.P2PNode)
 	setRpcConfig(ctx, cfg.Rpc)
 	setRestfulConfig(ctx, cfg.Restful)
@@ -49,6 +53,14 @@ func SetDNAConfig(ctx *cli.Context) (*config.DNAConfig, error) {
 		cfg.P2PNode.NetworkMagic = config.GetNetworkMagic(cfg.P2PNode.NetworkId)
 		cfg.Common.GasPrice = 0
 	}
+	if cfg.Common.LightMode {
+		if cfg.Genesis.ConsensusType == config.CONSENSUS_TYPE_SOLO {
+			return nil, fmt.Errorf("light mode is not supported by solo consensus")
+		}
+		if cfg.Consensus.EnableConsensus {
+			return nil, fmt.Errorf("light node cannot take part in consensus")
+		}
+	}
 	if cfg.P2PNode.NetworkId == config.NETWORK_ID_MAIN_NET ||
 		cfg.P2PNode.NetworkId == config.NETWORK_ID_POLARIS_NET {
 		defNetworkId, err := cfg.GetDefaultNetworkId()
@@ -107,6 +119,13 @@ func setGenesis(ctx *cli.Context, cfg *config.DNAConfig) error {
 		if cfg.Genesis.DBFT.GenBlockTime <= 0 {
 			cfg.Genesis.DBFT.GenBlockTime = config.DEFAULT_GEN_BLOCK_TIME
 		}
+	case config.CONSENSUS_TYPE_SBFT:
+		if cfg.Genesis.SBFT == nil || len(cfg.Genesis.SBFT.Bookkeepers) < config.SBFT_MIN_NODE_NUM {
+			return fmt.Errorf("SBFT consensus at least need %d bookkeepers in config", config.SBFT_MIN_NODE_NUM)
+		}
+		if cfg.Genesis.SBFT.GenBlockTime <= 0 {
+			cfg.Genesis.SBFT.GenBlockTime = config.DEFAULT_GEN_BLOCK_TIME
+		}
 	case config.CONSENSUS_TYPE_VBFT:
 		err = governance.CheckVBFTConfig(cfg.Genesis.VBFT)
 		if err != nil {
@@ -125,6 +144,12 @@ func setGenesis(ctx *cli.Context, cfg *config.DNAConfig) error {
 func setCommonConfig(ctx *cli.Context, cfg *config.CommonConfig) {
 	cfg.LogLevel = ctx.Uint(utils.GetFlagName(utils.LogLevelFlag))
 	cfg.EnableEventLog = !ctx.Bool(utils.GetFlagName(utils.DisableEventLogFlag))
+	cfg.EnableAddressIndex = ctx.Bool(utils.GetFlagName(utils.EnableAddressIndexFlag))
+	cfg.EnableArchive = ctx.Bool(utils.GetFlagName(utils.EnableArchiveFlag))
+	cfg.PruneBlocks = uint32(ctx.Uint(utils.GetFlagName(utils.PruneBlocksFlag)))
+	cfg.RollbackBlocks = uint32(ctx.Uint(utils.GetFlagName(utils.RollbackBlocksFlag)))
+	cfg.DBBackend = ctx.String(utils.GetFlagName(utils.DBBackendFlag))
+	cfg.LightMode = ctx.Bool(utils.GetFlagName(utils.LightModeFlag))
 	cfg.GasLimit = ctx.Uint64(utils.GetFlagName(utils.GasLimitFlag))
 	cfg.GasPrice = ctx.Uint64(utils.GetFlagName(utils.GasPriceFlag))
 	cfg.DataDir = ctx.String(utils.GetFlagName(utils.DataDirFlag))
@@ -133,6 +158,7 @@ func setCommonConfig(ctx *cli.Context, cfg *config.CommonConfig) {
 func setConsensusConfig(ctx *cli.Context, cfg *config.ConsensusConfig) {
 	cfg.EnableConsensus = ctx.Bool(utils.GetFlagName(utils.EnableConsensusFlag))
 	cfg.MaxTxInBlock = ctx.Uint(utils.GetFlagName(utils.MaxTxInBlockFlag))
+	cfg.FaultScenario = utils.GetFaultScenario(ctx)
 }
 
 func setP2PNodeConfig(ctx *cli.Context, cfg *config.P2PNodeConfig) {
@@ -145,6 +171,8 @@ func setP2PNodeConfig(ctx *cli.Context, cfg *config.P2PNodeConfig) {
 	cfg.MaxConnInBound = ctx.Uint(utils.GetFlagName(utils.MaxConnInBoundFlag))
 	cfg.MaxConnOutBound = ctx.Uint(utils.GetFlagName(utils.MaxConnOutBoundFlag))
 	cfg.MaxConnInBoundForSingleIP = ctx.Uint(utils.GetFlagName(utils.MaxConnInBoundForSingleIPFlag))
+	cfg.SeedAnnounceAddr = ctx.String(utils.GetFlagName(utils.SeedAnnounceAddrFlag))
+	cfg.EnableFastSync = ctx.Bool(utils.GetFlagName(utils.FastSyncFlag))
 
 	rsvfile := ctx.String(utils.GetFlagName(utils.ReservedPeersFileFlag))
 	if cfg.ReservedPeersOnly {
@@ -167,6 +195,27 @@ func setP2PNodeConfig(ctx *cli.Context, cfg *config.P2PNodeConfig) {
 
 }
 
+func setTxPoolConfig(ctx *cli.Context, cfg *config.TxPoolConfig) error {
+	cfg.MaxTxPerPayer = ctx.Uint(utils.GetFlagName(utils.TxpoolMaxTxPerPayerFlag))
+	cfg.MinReplaceBump = ctx.Uint(utils.GetFlagName(utils.TxpoolMinReplaceBumpFlag))
+	cfg.MaxTxs = ctx.Uint(utils.GetFlagName(utils.TxpoolMaxTxsFlag))
+	cfg.MaxBytes = ctx.Uint64(utils.GetFlagName(utils.TxpoolMaxBytesFlag))
+	cfg.TxTTL = uint32(ctx.Uint(utils.GetFlagName(utils.TxpoolTxTTLFlag)))
+
+	file := ctx.String(utils.GetFlagName(utils.TxpoolValidatorsFileFlag))
+	if file == "" {
+		return nil
+	}
+	err := utils.GetJsonObjectFromFile(file, &cfg.Validators)
+	if err != nil {
+		return err
+	}
+	for _, v := range cfg.Validators {
+		log.Infof("tx pool validator plugin: %s", v.Name)
+	}
+	return nil
+}
+
 func setRpcConfig(ctx *cli.Context, cfg *config.RpcConfig) {
 	cfg.EnableHttpJsonRpc = !ctx.Bool(utils.GetFlagName(utils.RPCDisabledFlag))
 	cfg.HttpJsonPort = ctx.Uint(utils.GetFlagName(utils.RPCPortFlag))
@@ -20,13 +20,19 @@ package cmd
 
 import (
 	"bufio"
+	"encoding/json"
 	"fmt"
+	"os"
+	"time"
+
 	"github.com/dnaproject2/DNA/cmd/utils"
-	"github.com/dnaproject2/DNA/common/serialization"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/smartcontract/event"
 	"github.com/gosuri/uiprogress"
 	"github.com/urfave/cli"
-	"os"
-	"time"
 )
 
 var ExportCommand = cli.Command{
@@ -40,25 +46,122 @@ var ExportCommand = cli.Command{
 		utils.ExportStartHeightFlag,
 		utils.ExportEndHeightFlag,
 		utils.ExportSpeedFlag,
+		utils.ExportCompressFlag,
+		utils.ExportEventFlag,
+		utils.DataDirFlag,
+		utils.ConfigFlag,
+		utils.NetworkIdFlag,
 	},
-	Description: "",
+	Description: "The blocks are exported from the running node by rpc, or from the DB of a stopped node if --data-dir is set. " +
+		"The event notifies can only be exported from DB",
+}
+
+//exportSource return the block data and the event notifies in json of the height
+type exportSource interface {
+	GetBlockCount() (uint32, error)
+	GetBlockData(height uint32) ([]byte, error)
+	GetEventData(height uint32) ([]byte, error)
+	Close()
+}
+
+type rpcExportSource struct{}
+
+func (this *rpcExportSource) GetBlockCount() (uint32, error) {
+	return utils.GetBlockCount()
+}
+
+func (this *rpcExportSource) GetBlockData(height uint32) ([]byte, error) {
+	return utils.GetBlockData(height)
+}
+
+func (this *rpcExportSource) GetEventData(height uint32) ([]byte, error) {
+	return nil, fmt.Errorf("event notifies cannot be exported by rpc")
+}
+
+func (this *rpcExportSource) Close() {}
+
+type ledgerExportSource struct {
+	ledger *ledger.Ledger
+}
+
+func (this *ledgerExportSource) GetBlockCount() (uint32, error) {
+	return this.ledger.GetCurrentBlockHeight() + 1, nil
+}
+
+func (this *ledgerExportSource) GetBlockData(height uint32) ([]byte, error) {
+	if height < this.ledger.GetPrunedHeight() {
+		return nil, fmt.Errorf("block is pruned")
+	}
+	block, err := this.ledger.GetBlockByHeight(height)
+	if err != nil {
+		return nil, err
+	}
+	return block.ToArray(), nil
+}
+
+func (this *ledgerExportSource) GetEventData(height uint32) ([]byte, error) {
+	notifies, err := this.ledger.GetEventNotifyByBlock(height)
+	if err == scom.ErrNotFound {
+		notifies = make([]*event.ExecuteNotify, 0)
+	} else if err != nil {
+		return nil, err
+	}
+	return json.Marshal(notifies)
+}
+
+func (this *ledgerExportSource) Close() {
+	this.ledger.Close()
 }
 
 func exportBlocks(ctx *cli.Context) error {
-	SetRpcPort(ctx)
 	exportFile := ctx.String(utils.GetFlagName(utils.ExportFileFlag))
 	if exportFile == "" {
 		PrintErrorMsg("Missing %s argument.", utils.ExportFileFlag.Name)
 		cli.ShowSubcommandHelp(ctx)
 		return nil
 	}
+	compressType, err := utils.GetCompressType(ctx.String(utils.GetFlagName(utils.ExportCompressFlag)))
+	if err != nil {
+		PrintErrorMsg("%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	withEvent := ctx.Bool(utils.GetFlagName(utils.ExportEventFlag))
+	offline := ctx.IsSet(utils.GetFlagName(utils.DataDirFlag))
+	if withEvent && !offline {
+		PrintErrorMsg("Missing %s argument, event notifies can only be exported from DB.", utils.DataDirFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+
+	var source exportSource
+	if offline {
+		log.InitLog(log.InfoLog)
+		cfg, err := SetDNAConfig(ctx)
+		if err != nil {
+			PrintErrorMsg("SetDNAConfig error:%s", err)
+			cli.ShowSubcommandHelp(ctx)
+			return nil
+		}
+		dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+		stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+		ldg, err := ledger.NewReadOnlyLedger(dbDir, stateHashHeight)
+		if err != nil {
+			return fmt.Errorf("NewReadOnlyLedger error:%s", err)
+		}
+		source = &ledgerExportSource{ledger: ldg}
+	} else {
+		SetRpcPort(ctx)
+		source = &rpcExportSource{}
+	}
+	defer source.Close()
 
 	startHeight := ctx.Uint(utils.GetFlagName(utils.ExportStartHeightFlag))
 	endHeight := ctx.Uint(utils.GetFlagName(utils.ExportEndHeightFlag))
 	if endHeight > 0 && startHeight > endHeight {
 		return fmt.Errorf("export error: start height should smaller than end height")
 	}
-	blockCount, err := utils.GetBlockCount()
+	blockCount, err := source.GetBlockCount()
 	if err != nil {
 		return fmt.Errorf("GetBlockCount error:%s", err)
 	}
@@ -73,17 +176,17 @@ func exportBlocks(ctx *cli.Context) error {
 
 	speed := ctx.String(utils.GetFlagName(utils.ExportSpeedFlag))
 	var sleepTime time.Duration
-	switch speed {
-	case "h":
+	switch {
+	case offline || speed == "h":
 		sleepTime = 0
-	case "m":
+	case speed == "m":
 		sleepTime = time.Millisecond * 2
 	default:
 		sleepTime = time.Millisecond * 5
 	}
 
 	exportFile = utils.GenExportBlocksFileName(exportFile, uint32(startHeight), uint32(endHeight))
-	ef, err := os.OpenFile(exportFile, os.O_RDWR|os.O_CREATE, 0664)
+	ef, err := os.OpenFile(exportFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664)
 	if err != nil {
 		return fmt.Errorf("open file:%s error:%s", exportFile, err)
 	}
@@ -91,8 +194,12 @@ func exportBlocks(ctx *cli.Context) error {
 	fWriter := bufio.NewWriter(ef)
 
 	metadata := utils.NewExportBlockMetadata()
+	metadata.CompressType = compressType
 	metadata.StartBlockHeight = uint32(startHeight)
 	metadata.EndBlockHeight = uint32(endHeight)
+	if withEvent {
+		metadata.Flags |= utils.EXPORT_FLAG_EVENT
+	}
 	err = metadata.Serialize(fWriter)
 	if err != nil {
 		return fmt.Errorf("write export metadata error:%s", err)
@@ -108,22 +215,36 @@ func exportBlocks(ctx *cli.Context) error {
 		})
 
 	PrintInfoMsg("Start export.")
+	chunkHeight := uint32(startHeight)
+	blocks := make([][]byte, 0, utils.EXPORT_CHUNK_BLOCK_COUNT)
+	var events [][]byte
 	for i := uint32(startHeight); i <= uint32(endHeight); i++ {
-		blockData, err := utils.GetBlockData(i)
+		blockData, err := source.GetBlockData(i)
 		if err != nil {
 			return fmt.Errorf("GetBlockData:%d error:%s", i, err)
 		}
-		data, err := utils.CompressBlockData(blockData, metadata.CompressType)
-		if err != nil {
-			return fmt.Errorf("CompressBlockData height:%d error:%s", i, err)
+		blocks = append(blocks, blockData)
+		if withEvent {
+			eventData, err := source.GetEventData(i)
+			if err != nil {
+				return fmt.Errorf("GetEventData:%d error:%s", i, err)
+			}
+			events = append(events, eventData)
 		}
-		err = serialization.WriteUint32(fWriter, uint32(len(data)))
-		if err != nil {
-			return fmt.Errorf("write block data height:%d len:%d error:%s", i, uint32(len(data)), err)
-		}
-		_, err = fWriter.Write(data)
-		if err != nil {
-			return fmt.Errorf("write block data height:%d error:%s", i, err)
+		if len(blocks) == utils.EXPORT_CHUNK_BLOCK_COUNT || i == uint32(endHeight) {
+			chunk, err := utils.NewExportChunk(chunkHeight, blocks, events, compressType)
+			if err != nil {
+				return fmt.Errorf("NewExportChunk height:%d error:%s", chunkHeight, err)
+			}
+			err = chunk.Serialize(fWriter)
+			if err != nil {
+				return fmt.Errorf("write chunk height:%d error:%s", chunkHeight, err)
+			}
+			chunkHeight = i + 1
+			blocks = blocks[:0]
+			if withEvent {
+				events = events[:0]
+			}
 		}
 		if sleepTime > 0 {
 			time.Sleep(sleepTime)
@@ -21,13 +21,13 @@ package cmd
 import (
 	"bufio"
 	"fmt"
-	"io"
 	"os"
+	"os/signal"
+	"syscall"
 
 	"github.com/dnaproject2/DNA/cmd/utils"
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
-	"github.com/dnaproject2/DNA/common/serialization"
 	"github.com/dnaproject2/DNA/core/genesis"
 	"github.com/dnaproject2/DNA/core/ledger"
 	"github.com/dnaproject2/DNA/core/types"
@@ -47,8 +47,13 @@ var ImportCommand = cli.Command{
 		utils.ConfigFlag,
 		utils.NetworkIdFlag,
 		utils.DisableEventLogFlag,
+		utils.EnableAddressIndexFlag,
+		utils.EnableArchiveFlag,
+		utils.PruneBlocksFlag,
+		utils.RollbackBlocksFlag,
 	},
-	Description: "Note that import cmd doesn't support testmode",
+	Description: "Note that import cmd doesn't support testmode. The blocks already in DB are skipped, so an interrupted import is " +
+		"resumed by running it again. The event notifies in export file are not imported, they are generated again by executing the blocks",
 }
 
 func importBlocks(ctx *cli.Context) error {
@@ -67,6 +72,7 @@ func importBlocks(ctx *cli.Context) error {
 	if err != nil {
 		return fmt.Errorf("NewLedger error:%s", err)
 	}
+	defer ledger.DefLedger.Close()
 	bookKeepers, err := config.DefConfig.GetBookkeepers()
 	if err != nil {
 		return fmt.Errorf("GetBookkeepers error:%s", err)
@@ -108,11 +114,11 @@ func importBlocks(ctx *cli.Context) error {
 	defer ifile.Close()
 	fReader := bufio.NewReader(ifile)
 
-	metadata := utils.NewExportBlockMetadata()
-	err = metadata.Deserialize(fReader)
+	blockReader, err := utils.NewExportBlockReader(fReader)
 	if err != nil {
 		return fmt.Errorf("block data file metadata deserialize error:%s", err)
 	}
+	metadata := blockReader.Metadata()
 	if metadata.EndBlockHeight <= currBlockHeight {
 		PrintWarnMsg("CurrentBlockHeight:%d larger than or equal to EndBlockHeight:%d, No blocks to import.", currBlockHeight, endBlockHeight)
 		return nil
@@ -125,9 +131,19 @@ func importBlocks(ctx *cli.Context) error {
 	if startBlockHeight > (currBlockHeight + 1) {
 		return fmt.Errorf("import block error: StartBlockHeight:%d larger than NextBlockHeight:%d", startBlockHeight, currBlockHeight+1)
 	}
+	//the imported blocks are skipped, so that an interrupted import is resumed from current block
+	err = blockReader.Skip(currBlockHeight + 1)
+	if err != nil {
+		return fmt.Errorf("skip imported blocks error:%s", err)
+	}
+	//stop import after the block in saving on interrupt
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(interrupt)
+
 	//progress bar
 	uiprogress.Start()
-	bar := uiprogress.AddBar(int(endBlockHeight - startBlockHeight + 1)).
+	bar := uiprogress.AddBar(int(endBlockHeight - currBlockHeight)).
 		AppendCompleted().
 		AppendElapsed().
 		PrependFunc(func(b *uiprogress.Bar) string {
@@ -136,24 +152,17 @@ func importBlocks(ctx *cli.Context) error {
 
 	PrintInfoMsg("Start import blocks.")
 
-	for i := uint32(startBlockHeight); i <= endBlockHeight; i++ {
-		size, err := serialization.ReadUint32(fReader)
-		if err != nil {
-			return fmt.Errorf("read block height:%d error:%s", i, err)
+	for i := currBlockHeight + 1; i <= endBlockHeight; i++ {
+		select {
+		case <-interrupt:
+			uiprogress.Stop()
+			PrintWarnMsg("Import interrupted, current block height:%d. Run import again to resume.", ledger.DefLedger.GetCurrentBlockHeight())
+			return nil
+		default:
 		}
-		compressData := make([]byte, size)
-		_, err = io.ReadFull(fReader, compressData)
+		_, blockData, _, err := blockReader.ReadBlock()
 		if err != nil {
-			return fmt.Errorf("read block data height:%d error:%s", i, err)
-		}
-
-		if i <= currBlockHeight {
-			continue
-		}
-
-		blockData, err := utils.DecompressBlockData(compressData, metadata.CompressType)
-		if err != nil {
-			return fmt.Errorf("block height:%d decompress error:%s", i, err)
+			return fmt.Errorf("read block height:%d error:%s", i, err)
 		}
 		block, err := types.BlockFromRawBytes(blockData)
 		if err != nil {
@@ -0,0 +1,99 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/cmd/utils"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/urfave/cli"
+)
+
+var RollbackCommand = cli.Command{
+	Name:      "rollback",
+	Usage:     "Roll back DB to a block height",
+	ArgsUsage: "",
+	Action:    rollbackLedger,
+	Flags: []cli.Flag{
+		utils.RollbackHeightFlag,
+		utils.DataDirFlag,
+		utils.ConfigFlag,
+		utils.NetworkIdFlag,
+		utils.EnableAddressIndexFlag,
+		utils.EnableArchiveFlag,
+		utils.PruneBlocksFlag,
+		utils.RollbackBlocksFlag,
+	},
+	Description: "Stop the node before rollback, the blocks above the height are dropped with their transactions, events and states. " +
+		"Run it again with the same height if it is interrupted",
+}
+
+func rollbackLedger(ctx *cli.Context) error {
+	log.InitLog(log.InfoLog)
+
+	cfg, err := SetDNAConfig(ctx)
+	if err != nil {
+		PrintErrorMsg("SetDNAConfig error:%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	if !ctx.IsSet(utils.GetFlagName(utils.RollbackHeightFlag)) {
+		PrintErrorMsg("Missing %s argument.", utils.RollbackHeightFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	height := uint32(ctx.Uint(utils.GetFlagName(utils.RollbackHeightFlag)))
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+
+	stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+	ledger.DefLedger, err = ledger.NewLedger(dbDir, stateHashHeight)
+	if err != nil {
+		return fmt.Errorf("NewLedger error:%s", err)
+	}
+	defer ledger.DefLedger.Close()
+	bookKeepers, err := config.DefConfig.GetBookkeepers()
+	if err != nil {
+		return fmt.Errorf("GetBookkeepers error:%s", err)
+	}
+	genesisConfig := config.DefConfig.Genesis
+	genesisBlock, err := genesis.BuildGenesisBlock(bookKeepers, genesisConfig)
+	if err != nil {
+		return fmt.Errorf("BuildGenesisBlock error %s", err)
+	}
+	err = ledger.DefLedger.Init(bookKeepers, genesisBlock)
+	if err != nil {
+		return fmt.Errorf("init ledger error:%s", err)
+	}
+
+	currHeight := ledger.DefLedger.GetCurrentBlockHeight()
+	PrintInfoMsg("Start rollback from block height:%d to %d.", currHeight, height)
+	err = ledger.DefLedger.Rollback(height)
+	if err != nil {
+		return fmt.Errorf("Rollback error:%s", err)
+	}
+	blockHash := ledger.DefLedger.GetCurrentBlockHash()
+	PrintInfoMsg("Rollback successfully.")
+	PrintInfoMsg("BlockHeight:%d", ledger.DefLedger.GetCurrentBlockHeight())
+	PrintInfoMsg("BlockHash:%s", blockHash.ToHexString())
+	return nil
+}
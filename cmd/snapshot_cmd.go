@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+
+	"github.com/dnaproject2/DNA/cmd/utils"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/urfave/cli"
+)
+
+var SnapshotCommand = cli.Command{
+	Name:  "snapshot",
+	Usage: "Create or load state snapshot of DB",
+	Subcommands: []cli.Command{
+		{
+			Action:    createSnapshot,
+			Name:      "create",
+			Usage:     "Write the state snapshot of the current block to file",
+			ArgsUsage: "",
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.ConfigFlag,
+				utils.NetworkIdFlag,
+				utils.SnapshotFileFlag,
+			},
+			Description: "Stop the node before creating snapshot, the DB cannot be opened by two processes",
+		},
+		{
+			Action:    loadSnapshot,
+			Name:      "load",
+			Usage:     "Initialize an empty DB from state snapshot file",
+			ArgsUsage: "",
+			Flags: []cli.Flag{
+				utils.DataDirFlag,
+				utils.ConfigFlag,
+				utils.NetworkIdFlag,
+				utils.SnapshotFileFlag,
+			},
+			Description: "The node started on the loaded DB syncs the blocks after the snapshot height only",
+		},
+	},
+	Description: "The snapshot contains the states, the block merkle tree and the current block pointer, but no historical blocks and events",
+}
+
+func createSnapshot(ctx *cli.Context) error {
+	log.InitLog(log.InfoLog)
+
+	cfg, err := SetDNAConfig(ctx)
+	if err != nil {
+		PrintErrorMsg("SetDNAConfig error:%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	snapshotFile := ctx.String(utils.GetFlagName(utils.SnapshotFileFlag))
+	if snapshotFile == "" {
+		PrintErrorMsg("Missing %s argument.", utils.SnapshotFileFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+
+	stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+	ledger.DefLedger, err = ledger.NewLedger(dbDir, stateHashHeight)
+	if err != nil {
+		return fmt.Errorf("NewLedger error:%s", err)
+	}
+	defer ledger.DefLedger.Close()
+	bookKeepers, err := config.DefConfig.GetBookkeepers()
+	if err != nil {
+		return fmt.Errorf("GetBookkeepers error:%s", err)
+	}
+	genesisConfig := config.DefConfig.Genesis
+	genesisBlock, err := genesis.BuildGenesisBlock(bookKeepers, genesisConfig)
+	if err != nil {
+		return fmt.Errorf("BuildGenesisBlock error %s", err)
+	}
+	err = ledger.DefLedger.Init(bookKeepers, genesisBlock)
+	if err != nil {
+		return fmt.Errorf("init ledger error:%s", err)
+	}
+
+	sf, err := os.OpenFile(snapshotFile, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return fmt.Errorf("open file:%s error:%s", snapshotFile, err)
+	}
+	defer sf.Close()
+	fWriter := bufio.NewWriter(sf)
+
+	PrintInfoMsg("Start create snapshot.")
+	height, err := ledger.DefLedger.SaveSnapshot(fWriter)
+	if err != nil {
+		return fmt.Errorf("SaveSnapshot error:%s", err)
+	}
+	err = fWriter.Flush()
+	if err != nil {
+		return fmt.Errorf("snapshot flush file error:%s", err)
+	}
+	PrintInfoMsg("Create snapshot successfully.")
+	PrintInfoMsg("BlockHeight:%d", height)
+	PrintInfoMsg("Snapshot file:%s", snapshotFile)
+	return nil
+}
+
+func loadSnapshot(ctx *cli.Context) error {
+	log.InitLog(log.InfoLog)
+
+	_, err := SetDNAConfig(ctx)
+	if err != nil {
+		PrintErrorMsg("SetDNAConfig error:%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	snapshotFile := ctx.String(utils.GetFlagName(utils.SnapshotFileFlag))
+	if snapshotFile == "" {
+		PrintErrorMsg("Missing %s argument.", utils.SnapshotFileFlag.Name)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+
+	sf, err := os.OpenFile(snapshotFile, os.O_RDONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("open file:%s error:%s", snapshotFile, err)
+	}
+	defer sf.Close()
+
+	PrintInfoMsg("Start load snapshot.")
+	height, err := ledger.LoadSnapshot(dbDir, bufio.NewReader(sf))
+	if err != nil {
+		return fmt.Errorf("LoadSnapshot error:%s", err)
+	}
+	PrintInfoMsg("Load snapshot successfully.")
+	PrintInfoMsg("BlockHeight:%d", height)
+	return nil
+}
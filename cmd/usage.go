@@ -102,6 +102,12 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.LogLevelFlag,
 			utils.DisableLogFileFlag,
 			utils.DisableEventLogFlag,
+			utils.EnableAddressIndexFlag,
+			utils.EnableArchiveFlag,
+			utils.PruneBlocksFlag,
+			utils.RollbackBlocksFlag,
+			utils.DBBackendFlag,
+			utils.LightModeFlag,
 			utils.DataDirFlag,
 		},
 	},
@@ -131,10 +137,10 @@ var AppHelpFlagGroups = []flagGroup{
 	},
 	{
 		Name: "CONSENSUS",
-		Flags: []cli.Flag{
+		Flags: append([]cli.Flag{
 			utils.EnableConsensusFlag,
 			utils.MaxTxInBlockFlag,
-		},
+		}, utils.FaultFlags...),
 	},
 	{
 		Name: "TXPOOL",
@@ -142,6 +148,13 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.GasPriceFlag,
 			utils.GasLimitFlag,
 			utils.TxpoolPreExecDisableFlag,
+			utils.TxpoolJournalDisableFlag,
+			utils.TxpoolValidatorsFileFlag,
+			utils.TxpoolMaxTxPerPayerFlag,
+			utils.TxpoolMinReplaceBumpFlag,
+			utils.TxpoolMaxTxsFlag,
+			utils.TxpoolMaxBytesFlag,
+			utils.TxpoolTxTTLFlag,
 			utils.DisableSyncVerifyTxFlag,
 			utils.DisableBroadcastNetTxFlag,
 		},
@@ -157,6 +170,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.MaxConnInBoundFlag,
 			utils.MaxConnOutBoundFlag,
 			utils.MaxConnInBoundForSingleIPFlag,
+			utils.SeedAnnounceAddrFlag,
+			utils.FastSyncFlag,
 		},
 	},
 	{
@@ -245,6 +260,8 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.ExportSpeedFlag,
 			utils.ExportStartHeightFlag,
 			utils.ExportEndHeightFlag,
+			utils.ExportCompressFlag,
+			utils.ExportEventFlag,
 		},
 	},
 	{
@@ -254,6 +271,25 @@ var AppHelpFlagGroups = []flagGroup{
 			utils.ImportEndHeightFlag,
 		},
 	},
+	{
+		Name: "SNAPSHOT",
+		Flags: []cli.Flag{
+			utils.SnapshotFileFlag,
+		},
+	},
+	{
+		Name: "ROLLBACK",
+		Flags: []cli.Flag{
+			utils.RollbackHeightFlag,
+		},
+	},
+	{
+		Name: "VERIFY",
+		Flags: []cli.Flag{
+			utils.VerifySnapshotFlag,
+			utils.VerifyEndHeightFlag,
+		},
+	},
 	{
 		Name: "MISC",
 	},
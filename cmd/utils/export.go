@@ -20,28 +20,58 @@ package utils
 
 import (
 	"bytes"
+	"compress/gzip"
 	"compress/zlib"
+	"crypto/sha256"
 	"fmt"
 	"github.com/dnaproject2/DNA/common/serialization"
 	"io"
 	"io/ioutil"
+	"strings"
 )
 
 const (
 	COMPRESS_TYPE_ZLIB = iota
+	COMPRESS_TYPE_GZIP
+	COMPRESS_TYPE_NONE
 )
 
 const (
-	DEFAULT_COMPRESS_TYPE         = COMPRESS_TYPE_ZLIB
-	EXPORT_BLOCK_METADATA_LEN     = 256
-	EXPORT_BLOCK_METADATA_VERSION = 1
+	DEFAULT_COMPRESS_TYPE            = COMPRESS_TYPE_ZLIB
+	EXPORT_BLOCK_METADATA_LEN        = 256
+	EXPORT_BLOCK_METADATA_VERSION_V1 = 1
+	EXPORT_BLOCK_METADATA_VERSION    = 2
+	EXPORT_CHUNK_BLOCK_COUNT         = 1000
 )
 
+//export flags of v2 export file
+const (
+	EXPORT_FLAG_EVENT = 1 << iota //event notifies of blocks are exported
+)
+
+var compressTypeNames = map[string]byte{
+	"zlib": COMPRESS_TYPE_ZLIB,
+	"gzip": COMPRESS_TYPE_GZIP,
+	"none": COMPRESS_TYPE_NONE,
+}
+
+//GetCompressType return the compress type of name
+func GetCompressType(name string) (byte, error) {
+	compressType, ok := compressTypeNames[strings.ToLower(name)]
+	if !ok {
+		return 0, fmt.Errorf("unknown compress type %s", name)
+	}
+	return compressType, nil
+}
+
+//ExportBlockMetadata is the header of export file. In version 1 the blocks are written one by one,
+//in version 2 the blocks are written in chunks with checksum, see ExportChunk
 type ExportBlockMetadata struct {
 	Version          byte
 	CompressType     byte
 	StartBlockHeight uint32
 	EndBlockHeight   uint32
+	Flags            byte //export flags, version 2 only
 }
 
 func NewExportBlockMetadata() *ExportBlockMetadata {
@@ -51,6 +81,11 @@ func NewExportBlockMetadata() *ExportBlockMetadata {
 	}
 }
 
+//HasEvent return whether the event notifies are exported with blocks
+func (this *ExportBlockMetadata) HasEvent() bool {
+	return this.Version >= EXPORT_BLOCK_METADATA_VERSION && this.Flags&EXPORT_FLAG_EVENT != 0
+}
+
 func (this *ExportBlockMetadata) Serialize(w io.Writer) error {
 	metadata := make([]byte, EXPORT_BLOCK_METADATA_LEN, EXPORT_BLOCK_METADATA_LEN)
 	buf := bytes.NewBuffer(nil)
@@ -70,6 +105,12 @@ func (this *ExportBlockMetadata) Serialize(w io.Writer) error {
 	if err != nil {
 		return err
 	}
+	if this.Version >= EXPORT_BLOCK_METADATA_VERSION {
+		err = serialization.WriteByte(buf, this.Flags)
+		if err != nil {
+			return err
+		}
+	}
 	data := buf.Bytes()
 	if len(data) > EXPORT_BLOCK_METADATA_LEN {
 		return fmt.Errorf("metata len size larger than %d", EXPORT_BLOCK_METADATA_LEN)
@@ -85,7 +126,7 @@ func (this *ExportBlockMetadata) Deserialize(r io.Reader) error {
 	if err != nil {
 		return err
 	}
-	if metadata[0] != EXPORT_BLOCK_METADATA_VERSION {
+	if metadata[0] != EXPORT_BLOCK_METADATA_VERSION_V1 && metadata[0] != EXPORT_BLOCK_METADATA_VERSION {
 		return fmt.Errorf("version unmatch")
 	}
 	reader := bytes.NewBuffer(metadata)
@@ -109,13 +150,264 @@ func (this *ExportBlockMetadata) Deserialize(r io.Reader) error {
 		return err
 	}
 	this.EndBlockHeight = height
+	this.Flags = 0
+	if this.Version >= EXPORT_BLOCK_METADATA_VERSION {
+		flags, err := serialization.ReadByte(reader)
+		if err != nil {
+			return err
+		}
+		this.Flags = flags
+	}
+	return nil
+}
+
+//ExportChunk is a run of blocks in version 2 export file. The blocks, and their event notifies if exported,
+//are compressed together, and the checksum covers the heights and the compressed data
+type ExportChunk struct {
+	StartHeight uint32
+	BlockCount  uint32
+	Data        []byte
+}
+
+//NewExportChunk compress the blocks and their event notifies to a chunk, events is nil if not exported
+func NewExportChunk(startHeight uint32, blocks [][]byte, events [][]byte, compressType byte) (*ExportChunk, error) {
+	if events != nil && len(events) != len(blocks) {
+		return nil, fmt.Errorf("events count %d mismatches blocks count %d", len(events), len(blocks))
+	}
+	buf := bytes.NewBuffer(nil)
+	for i, block := range blocks {
+		err := serialization.WriteVarBytes(buf, block)
+		if err != nil {
+			return nil, err
+		}
+		if events != nil {
+			err = serialization.WriteVarBytes(buf, events[i])
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	data, err := CompressBlockData(buf.Bytes(), compressType)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportChunk{
+		StartHeight: startHeight,
+		BlockCount:  uint32(len(blocks)),
+		Data:        data,
+	}, nil
+}
+
+func (this *ExportChunk) checksum() [sha256.Size]byte {
+	buf := bytes.NewBuffer(nil)
+	serialization.WriteUint32(buf, this.StartHeight)
+	serialization.WriteUint32(buf, this.BlockCount)
+	buf.Write(this.Data)
+	return sha256.Sum256(buf.Bytes())
+}
+
+func (this *ExportChunk) Serialize(w io.Writer) error {
+	err := serialization.WriteUint32(w, this.StartHeight)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteUint32(w, this.BlockCount)
+	if err != nil {
+		return err
+	}
+	err = serialization.WriteVarBytes(w, this.Data)
+	if err != nil {
+		return err
+	}
+	checksum := this.checksum()
+	_, err = w.Write(checksum[:])
+	return err
+}
+
+//Deserialize read the chunk and verify its checksum
+func (this *ExportChunk) Deserialize(r io.Reader) error {
+	var err error
+	this.StartHeight, err = serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	this.BlockCount, err = serialization.ReadUint32(r)
+	if err != nil {
+		return err
+	}
+	this.Data, err = serialization.ReadVarBytes(r)
+	if err != nil {
+		return err
+	}
+	var checksum [sha256.Size]byte
+	_, err = io.ReadFull(r, checksum[:])
+	if err != nil {
+		return err
+	}
+	if checksum != this.checksum() {
+		return fmt.Errorf("checksum of chunk at height %d mismatch", this.StartHeight)
+	}
+	return nil
+}
+
+//Decode decompress the blocks of the chunk, and their event notifies if exported
+func (this *ExportChunk) Decode(compressType byte, withEvent bool) ([][]byte, [][]byte, error) {
+	data, err := DecompressBlockData(this.Data, compressType)
+	if err != nil {
+		return nil, nil, err
+	}
+	reader := bytes.NewReader(data)
+	blocks := make([][]byte, 0, this.BlockCount)
+	var events [][]byte
+	if withEvent {
+		events = make([][]byte, 0, this.BlockCount)
+	}
+	for i := uint32(0); i < this.BlockCount; i++ {
+		block, err := serialization.ReadVarBytes(reader)
+		if err != nil {
+			return nil, nil, fmt.Errorf("read block height:%d error:%s", this.StartHeight+i, err)
+		}
+		blocks = append(blocks, block)
+		if withEvent {
+			evt, err := serialization.ReadVarBytes(reader)
+			if err != nil {
+				return nil, nil, fmt.Errorf("read event height:%d error:%s", this.StartHeight+i, err)
+			}
+			events = append(events, evt)
+		}
+	}
+	return blocks, events, nil
+}
+
+//ExportBlockReader read the blocks from export file of both versions
+type ExportBlockReader struct {
+	reader   io.Reader
+	metadata *ExportBlockMetadata
+	height   uint32 //height of next block
+	blocks   [][]byte
+	events   [][]byte
+}
+
+func NewExportBlockReader(r io.Reader) (*ExportBlockReader, error) {
+	metadata := NewExportBlockMetadata()
+	err := metadata.Deserialize(r)
+	if err != nil {
+		return nil, err
+	}
+	return &ExportBlockReader{
+		reader:   r,
+		metadata: metadata,
+		height:   metadata.StartBlockHeight,
+	}, nil
+}
+
+func (this *ExportBlockReader) Metadata() *ExportBlockMetadata {
+	return this.metadata
+}
+
+//Skip skip the blocks below the height, the chunks skipped entirely are not decompressed
+func (this *ExportBlockReader) Skip(height uint32) error {
+	for this.height < height && this.height <= this.metadata.EndBlockHeight {
+		if len(this.blocks) > 0 {
+			this.blocks = this.blocks[1:]
+			if this.events != nil {
+				this.events = this.events[1:]
+			}
+			this.height++
+			continue
+		}
+		if this.metadata.Version == EXPORT_BLOCK_METADATA_VERSION_V1 {
+			_, err := this.readV1Block()
+			if err != nil {
+				return err
+			}
+			this.height++
+			continue
+		}
+		chunk := &ExportChunk{}
+		err := chunk.Deserialize(this.reader)
+		if err != nil {
+			return fmt.Errorf("read chunk height:%d error:%s", this.height, err)
+		}
+		if chunk.StartHeight != this.height {
+			return fmt.Errorf("chunk start height %d mismatches height %d", chunk.StartHeight, this.height)
+		}
+		if height-this.height >= chunk.BlockCount {
+			this.height += chunk.BlockCount
+			continue
+		}
+		this.blocks, this.events, err = chunk.Decode(this.metadata.CompressType, this.metadata.HasEvent())
+		if err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+//ReadBlock return the next block and its event notifies in json, io.EOF after the end block
+func (this *ExportBlockReader) ReadBlock() (uint32, []byte, []byte, error) {
+	height := this.height
+	if height > this.metadata.EndBlockHeight {
+		return 0, nil, nil, io.EOF
+	}
+	if this.metadata.Version == EXPORT_BLOCK_METADATA_VERSION_V1 {
+		data, err := this.readV1Block()
+		if err != nil {
+			return 0, nil, nil, err
+		}
+		block, err := DecompressBlockData(data, this.metadata.CompressType)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("block height:%d decompress error:%s", height, err)
+		}
+		this.height++
+		return height, block, nil, nil
+	}
+	if len(this.blocks) == 0 {
+		chunk := &ExportChunk{}
+		err := chunk.Deserialize(this.reader)
+		if err != nil {
+			return 0, nil, nil, fmt.Errorf("read chunk height:%d error:%s", height, err)
+		}
+		if chunk.StartHeight != height || chunk.BlockCount == 0 {
+			return 0, nil, nil, fmt.Errorf("chunk start height %d mismatches height %d", chunk.StartHeight, height)
+		}
+		this.blocks, this.events, err = chunk.Decode(this.metadata.CompressType, this.metadata.HasEvent())
+		if err != nil {
+			return 0, nil, nil, err
+		}
+	}
+	block := this.blocks[0]
+	this.blocks = this.blocks[1:]
+	var evt []byte
+	if this.events != nil {
+		evt = this.events[0]
+		this.events = this.events[1:]
+	}
+	this.height++
+	return height, block, evt, nil
+}
+
+func (this *ExportBlockReader) readV1Block() ([]byte, error) {
+	size, err := serialization.ReadUint32(this.reader)
+	if err != nil {
+		return nil, fmt.Errorf("read block height:%d error:%s", this.height, err)
+	}
+	data := make([]byte, size)
+	_, err = io.ReadFull(this.reader, data)
+	if err != nil {
+		return nil, fmt.Errorf("read block data height:%d error:%s", this.height, err)
+	}
+	return data, nil
+}
+
 func CompressBlockData(data []byte, compressType byte) ([]byte, error) {
 	switch compressType {
 	case COMPRESS_TYPE_ZLIB:
 		return ZLibCompress(data)
+	case COMPRESS_TYPE_GZIP:
+		return GZipCompress(data)
+	case COMPRESS_TYPE_NONE:
+		return data, nil
 	default:
 		return nil, fmt.Errorf("unknown compress type")
 	}
@@ -125,6 +417,10 @@ func DecompressBlockData(data []byte, compressType byte) ([]byte, error) {
 	switch compressType {
 	case COMPRESS_TYPE_ZLIB:
 		return ZLibDecompress(data)
+	case COMPRESS_TYPE_GZIP:
+		return GZipDecompress(data)
+	case COMPRESS_TYPE_NONE:
+		return data, nil
 	default:
 		return nil, fmt.Errorf("unknown compress type")
 	}
@@ -151,3 +447,25 @@ func ZLibDecompress(data []byte) ([]byte, error) {
 
 	return ioutil.ReadAll(zlibReader)
 }
+
+func GZipCompress(data []byte) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	gzipWriter := gzip.NewWriter(buf)
+	_, err := gzipWriter.Write(data)
+	if err != nil {
+		return nil, fmt.Errorf("gzipWriter.Write error %s", err)
+	}
+	gzipWriter.Close()
+	return buf.Bytes(), nil
+}
+
+func GZipDecompress(data []byte) ([]byte, error) {
+	buf := bytes.NewReader(data)
+	gzipReader, err := gzip.NewReader(buf)
+	if err != nil {
+		return nil, fmt.Errorf("gzip.NewReader error %s", err)
+	}
+	defer gzipReader.Close()
+
+	return ioutil.ReadAll(gzipReader)
+}
@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/dnaproject2/DNA/common/serialization"
+	"github.com/stretchr/testify/assert"
+)
+
+func genExportFile(t *testing.T, metadata *ExportBlockMetadata, chunkSize int) []byte {
+	buf := bytes.NewBuffer(nil)
+	assert.Nil(t, metadata.Serialize(buf))
+	for start := metadata.StartBlockHeight; start <= metadata.EndBlockHeight; start += uint32(chunkSize) {
+		var blocks, events [][]byte
+		for h := start; h < start+uint32(chunkSize) && h <= metadata.EndBlockHeight; h++ {
+			blocks = append(blocks, []byte(fmt.Sprintf("block%d", h)))
+			if metadata.HasEvent() {
+				events = append(events, []byte(fmt.Sprintf("event%d", h)))
+			}
+		}
+		chunk, err := NewExportChunk(start, blocks, events, metadata.CompressType)
+		assert.Nil(t, err)
+		assert.Nil(t, chunk.Serialize(buf))
+	}
+	return buf.Bytes()
+}
+
+func TestExportBlockReader(t *testing.T) {
+	for _, compressType := range []byte{COMPRESS_TYPE_ZLIB, COMPRESS_TYPE_GZIP, COMPRESS_TYPE_NONE} {
+		metadata := NewExportBlockMetadata()
+		metadata.CompressType = compressType
+		metadata.StartBlockHeight = 5
+		metadata.EndBlockHeight = 30
+		metadata.Flags = EXPORT_FLAG_EVENT
+		data := genExportFile(t, metadata, 7)
+
+		reader, err := NewExportBlockReader(bytes.NewReader(data))
+		assert.Nil(t, err)
+		assert.Equal(t, metadata, reader.Metadata())
+		assert.Nil(t, reader.Skip(20))
+		for h := uint32(20); h <= 30; h++ {
+			height, block, evt, err := reader.ReadBlock()
+			assert.Nil(t, err)
+			assert.Equal(t, h, height)
+			assert.Equal(t, fmt.Sprintf("block%d", h), string(block))
+			assert.Equal(t, fmt.Sprintf("event%d", h), string(evt))
+		}
+		_, _, _, err = reader.ReadBlock()
+		assert.Equal(t, io.EOF, err)
+	}
+}
+
+func TestExportBlockReaderV1(t *testing.T) {
+	buf := bytes.NewBuffer(nil)
+	metadata := &ExportBlockMetadata{
+		Version:          EXPORT_BLOCK_METADATA_VERSION_V1,
+		CompressType:     COMPRESS_TYPE_ZLIB,
+		StartBlockHeight: 0,
+		EndBlockHeight:   10,
+	}
+	assert.Nil(t, metadata.Serialize(buf))
+	for h := 0; h <= 10; h++ {
+		data, err := ZLibCompress([]byte(fmt.Sprintf("block%d", h)))
+		assert.Nil(t, err)
+		assert.Nil(t, serialization.WriteUint32(buf, uint32(len(data))))
+		buf.Write(data)
+	}
+	reader, err := NewExportBlockReader(buf)
+	assert.Nil(t, err)
+	assert.False(t, reader.Metadata().HasEvent())
+	assert.Nil(t, reader.Skip(4))
+	for h := uint32(4); h <= 10; h++ {
+		height, block, evt, err := reader.ReadBlock()
+		assert.Nil(t, err)
+		assert.Equal(t, h, height)
+		assert.Equal(t, fmt.Sprintf("block%d", h), string(block))
+		assert.Nil(t, evt)
+	}
+	_, _, _, err = reader.ReadBlock()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestExportChunkChecksum(t *testing.T) {
+	metadata := NewExportBlockMetadata()
+	metadata.StartBlockHeight = 1
+	metadata.EndBlockHeight = 3
+	data := genExportFile(t, metadata, 10)
+	data[len(data)-40] ^= 1
+
+	reader, err := NewExportBlockReader(bytes.NewReader(data))
+	assert.Nil(t, err)
+	_, _, _, err = reader.ReadBlock()
+	assert.NotNil(t, err)
+}
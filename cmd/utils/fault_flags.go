@@ -0,0 +1,40 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build faults
+
+package utils
+
+import (
+	"github.com/urfave/cli"
+)
+
+var (
+	FaultScenarioFlag = cli.StringFlag{
+		Name:  "fault-scenario",
+		Usage: "Inject the byzantine faults of scenario `<file>` into consensus messages, for chaos tests only",
+	}
+)
+
+//FaultFlags is the flags of the fault injection, which are only defined by the binary built with the faults tag
+var FaultFlags = []cli.Flag{
+	FaultScenarioFlag,
+}
+
+//GetFaultScenario returns the scenario file of the faults injected into consensus
+func GetFaultScenario(ctx *cli.Context) string {
+	return ctx.String(GetFlagName(FaultScenarioFlag))
+}
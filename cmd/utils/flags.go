@@ -31,6 +31,7 @@ const (
 	DEFAULT_ABI_PATH      = "./abi"
 	DEFAULT_EXPORT_HEIGHT = 0
 	DEFAULT_WALLET_PATH   = "./executor_data"
+	DEFAULT_SNAPSHOT_FILE = "./DNASnapshot.dat"
 )
 
 var (
@@ -52,6 +53,33 @@ var (
 		Name:  "disable-event-log",
 		Usage: "Discard event log output by smart contract execution",
 	}
+	EnableAddressIndexFlag = cli.BoolFlag{
+		Name:  "enable-address-index",
+		Usage: "Index transactions by payer, signers and ont/ong transfer addresses",
+	}
+	EnableArchiveFlag = cli.BoolFlag{
+		Name:  "enable-archive",
+		Usage: "Keep the states of every block height for historical queries",
+	}
+	PruneBlocksFlag = cli.UintFlag{
+		Name:  "prune-blocks",
+		Usage: "Keep the full blocks and events of the latest `<number>` heights only, 0 disables pruning",
+		Value: config.DEFAULT_PRUNE_BLOCKS,
+	}
+	RollbackBlocksFlag = cli.UintFlag{
+		Name:  "rollback-blocks",
+		Usage: "Keep the undo states of the latest `<number>` blocks to roll back the ledger, 0 disables rollback",
+		Value: config.DEFAULT_ROLLBACK_BLOCKS,
+	}
+	DBBackendFlag = cli.StringFlag{
+		Name:  "db-backend",
+		Usage: "Key-value `<backend>` of ledger DB (leveldb|memory), the memory backend keeps nothing on disk",
+		Value: config.DEFAULT_DB_BACKEND,
+	}
+	LightModeFlag = cli.BoolFlag{
+		Name:  "light",
+		Usage: "Run a light node, which syncs and verifies the block headers only, and fetches the transactions with their proofs from full peers on demand",
+	}
 	ExecutorFileFlag = cli.StringFlag{
 		Name:  "executor,w",
 		Value: config.DEFAULT_WALLET_FILE_NAME,
@@ -145,6 +173,14 @@ var (
 		Usage: "Max connection `<number>` in bound for single ip",
 		Value: config.DEFAULT_MAX_CONN_IN_BOUND_FOR_SINGLE_IP,
 	}
+	SeedAnnounceAddrFlag = cli.StringFlag{
+		Name:  "seed-announce",
+		Usage: "Announce this node as a seed with public `<address>` (host:port), signed by the wallet account",
+	}
+	FastSyncFlag = cli.BoolFlag{
+		Name:  "fastsync",
+		Usage: "Sync the state snapshot of a recent block from peers instead of executing all blocks, for an empty ledger only",
+	}
 	// RPC settings
 	RPCDisabledFlag = cli.BoolFlag{
 		Name:  "disable-rpc",
@@ -456,6 +492,23 @@ var (
 		Usage: "Export `<file>` path",
 		Value: DEFAULT_EXPORT_FILE,
 	}
+	SnapshotFileFlag = cli.StringFlag{
+		Name:  "snapshot-file",
+		Usage: "State snapshot `<file>` path",
+		Value: DEFAULT_SNAPSHOT_FILE,
+	}
+	RollbackHeightFlag = cli.UintFlag{
+		Name:  "height",
+		Usage: "Block `<height>` to roll back to",
+	}
+	VerifySnapshotFlag = cli.StringFlag{
+		Name:  "snapshot-file",
+		Usage: "Trusted state snapshot `<file>` to re-execute the blocks from. If snapshot-file is empty, re-execute from genesis",
+	}
+	VerifyEndHeightFlag = cli.UintFlag{
+		Name:  "end-height",
+		Usage: "End block height `<number>` to verify. If end-height is 0, verify to current block height",
+	}
 	ExportStartHeightFlag = cli.UintFlag{
 		Name:  "start-height",
 		Usage: "Start block height `<number>` to export",
@@ -466,6 +519,15 @@ var (
 		Usage: "Stop block height `<number>` to export",
 		Value: DEFAULT_EXPORT_HEIGHT,
 	}
+	ExportCompressFlag = cli.StringFlag{
+		Name:  "compress",
+		Usage: "Compress `<type>` (zlib|gzip|none) of export blocks",
+		Value: "zlib",
+	}
+	ExportEventFlag = cli.BoolFlag{
+		Name:  "export-event",
+		Usage: "Export the event notifies of blocks, only supported with --data-dir",
+	}
 	ExportSpeedFlag = cli.StringFlag{
 		Name:  "export-speed",
 		Usage: "Export block speed `<level>` (h|m|l), h for high speed, m for middle speed and l for low speed",
@@ -478,6 +540,42 @@ var (
 		Usage: "Disable preExecute in tx pool",
 	}
 
+	TxpoolJournalDisableFlag = cli.BoolFlag{
+		Name:  "disable-tx-pool-journal",
+		Usage: "Disable persisting the transactions of tx pool across restarts",
+	}
+
+	TxpoolValidatorsFileFlag = cli.StringFlag{
+		Name:  "tx-pool-validators",
+		Usage: "Custom validator plugins of tx pool are configured in `<file>`",
+	}
+
+	TxpoolMaxTxPerPayerFlag = cli.UintFlag{
+		Name:  "tx-pool-max-per-payer",
+		Usage: "Max `<number>` of transactions of a payer in tx pool, 0 for no limit",
+		Value: config.DEFAULT_TXPOOL_MAX_TX_PER_PAYER,
+	}
+	TxpoolMinReplaceBumpFlag = cli.UintFlag{
+		Name:  "tx-pool-replace-bump",
+		Usage: "Min gas price bump `<percentage>` to replace a transaction with the same payer and nonce in tx pool",
+		Value: config.DEFAULT_TXPOOL_MIN_REPLACE_BUMP,
+	}
+	TxpoolMaxTxsFlag = cli.UintFlag{
+		Name:  "tx-pool-max-txs",
+		Usage: "Max `<number>` of transactions in tx pool, 0 for no limit",
+		Value: config.DEFAULT_TXPOOL_MAX_TXS,
+	}
+	TxpoolMaxBytesFlag = cli.Uint64Flag{
+		Name:  "tx-pool-max-bytes",
+		Usage: "Max total `<size>` in bytes of transactions in tx pool, 0 for no limit",
+		Value: config.DEFAULT_TXPOOL_MAX_BYTES,
+	}
+	TxpoolTxTTLFlag = cli.UintFlag{
+		Name:  "tx-pool-tx-ttl",
+		Usage: "Drop a transaction which stays in tx pool for `<number>` blocks, 0 for never",
+		Value: config.DEFAULT_TXPOOL_TX_TTL,
+	}
+
 	//local PreExecute switcher
 	DisableSyncVerifyTxFlag = cli.BoolFlag{
 		Name:  "disable-sync-verify-tx",
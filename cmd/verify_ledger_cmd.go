@@ -0,0 +1,106 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package cmd
+
+import (
+	"encoding/hex"
+	"fmt"
+
+	"github.com/dnaproject2/DNA/cmd/utils"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/gosuri/uiprogress"
+	"github.com/urfave/cli"
+)
+
+var VerifyLedgerCommand = cli.Command{
+	Name:      "verifyledger",
+	Usage:     "Re-execute blocks in DB and verify them",
+	ArgsUsage: "",
+	Action:    verifyLedger,
+	Flags: []cli.Flag{
+		utils.VerifySnapshotFlag,
+		utils.VerifyEndHeightFlag,
+		utils.DataDirFlag,
+		utils.ConfigFlag,
+		utils.NetworkIdFlag,
+	},
+	Description: "The DB is opened read only, stop the node before verify. The blocks are re-executed from genesis, or from " +
+		"a trusted state snapshot whose state root is committed by the headers. The header signatures, transaction roots, " +
+		"block roots, state merkle roots and state tree roots of the blocks are checked, the states are compared if the " +
+		"blocks are verified to the current height, and the first divergent block is reported",
+}
+
+func verifyLedger(ctx *cli.Context) error {
+	log.InitLog(log.InfoLog)
+
+	cfg, err := SetDNAConfig(ctx)
+	if err != nil {
+		PrintErrorMsg("SetDNAConfig error:%s", err)
+		cli.ShowSubcommandHelp(ctx)
+		return nil
+	}
+	dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+
+	stateHashHeight := config.GetStateHashCheckHeight(cfg.P2PNode.NetworkId)
+	ldg, err := ledger.NewReadOnlyLedger(dbDir, stateHashHeight)
+	if err != nil {
+		return fmt.Errorf("NewReadOnlyLedger error:%s", err)
+	}
+	defer ldg.Close()
+
+	snapshot := ctx.String(utils.GetFlagName(utils.VerifySnapshotFlag))
+	endHeight := uint32(ctx.Uint(utils.GetFlagName(utils.VerifyEndHeightFlag)))
+	currHeight := ldg.GetCurrentBlockHeight()
+	if endHeight == 0 || endHeight > currHeight {
+		endHeight = currHeight
+	}
+
+	//progress bar
+	uiprogress.Start()
+	bar := uiprogress.AddBar(int(endHeight)).
+		AppendCompleted().
+		AppendElapsed().
+		PrependFunc(func(b *uiprogress.Bar) string {
+			return fmt.Sprintf("Block(%d/%d)", b.Current(), int(endHeight))
+		})
+
+	PrintInfoMsg("Start verify.")
+	divergence, err := ldg.VerifyBlocks(snapshot, endHeight, func(height uint32) {
+		bar.Set(int(height))
+	})
+	uiprogress.Stop()
+	if err != nil {
+		return fmt.Errorf("VerifyBlocks error:%s", err)
+	}
+	if divergence == nil {
+		PrintInfoMsg("Verify blocks successfully.")
+		PrintInfoMsg("EndBlockHeight:%d", endHeight)
+		return nil
+	}
+	PrintErrorMsg("Block diverges at height:%d", divergence.Height)
+	PrintErrorMsg("Reason:%s", divergence.Reason)
+	for _, state := range divergence.States {
+		PrintErrorMsg("Key:%s", hex.EncodeToString(state.Key))
+		PrintErrorMsg("  Stored:%s", hex.EncodeToString(state.Stored))
+		PrintErrorMsg("  Executed:%s", hex.EncodeToString(state.Executed))
+	}
+	return nil
+}
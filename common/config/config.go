@@ -44,10 +44,12 @@ const (
 	DBFT_MIN_NODE_NUM        = 4 //min node number of dbft consensus
 	SOLO_MIN_NODE_NUM        = 1 //min node number of solo consensus
 	VBFT_MIN_NODE_NUM        = 4 //min node number of vbft consensus
+	SBFT_MIN_NODE_NUM        = 4 //min node number of sbft consensus
 
 	CONSENSUS_TYPE_DBFT = "dbft"
 	CONSENSUS_TYPE_SOLO = "solo"
 	CONSENSUS_TYPE_VBFT = "vbft"
+	CONSENSUS_TYPE_SBFT = "sbft"
 
 	DEFAULT_LOG_LEVEL                       = log.InfoLog
 	DEFAULT_MAX_LOG_SIZE                    = 100 //MByte
@@ -66,10 +68,22 @@ const (
 	DEFAULT_MAX_SYNC_HEADER                 = 500
 	DEFAULT_ENABLE_CONSENSUS                = true
 	DEFAULT_ENABLE_EVENT_LOG                = true
+	DEFAULT_ENABLE_ADDRESS_INDEX            = false
+	DEFAULT_ENABLE_ARCHIVE                  = false
+	DEFAULT_PRUNE_BLOCKS                    = 0
+	MIN_PRUNE_BLOCKS                        = 100
+	DEFAULT_ROLLBACK_BLOCKS                 = 10000
+	DEFAULT_DB_BACKEND                      = "leveldb"
+	DEFAULT_LIGHT_MODE                      = false
 	DEFAULT_CLI_RPC_PORT                    = uint(20000)
 	DEFUALT_CLI_RPC_ADDRESS                 = "127.0.0.1"
 	DEFAULT_GAS_LIMIT                       = 20000
 	DEFAULT_GAS_PRICE                       = 500
+	DEFAULT_TXPOOL_MAX_TX_PER_PAYER         = 1024
+	DEFAULT_TXPOOL_MIN_REPLACE_BUMP         = 10
+	DEFAULT_TXPOOL_MAX_TXS                  = 100140
+	DEFAULT_TXPOOL_MAX_BYTES                = 256 * 1024 * 1024
+	DEFAULT_TXPOOL_TX_TTL                   = 0
 
 	DEFAULT_DATA_DIR      = "./Chain"
 	DEFAULT_RESERVED_FILE = "./peers.rsv"
@@ -124,6 +138,17 @@ func GetOpcodeUpdateCheckHeight(id uint32) uint32 {
 	return OPCODE_UPDATE_CHECK_HEIGHT[id]
 }
 
+//Headers above the height commit the state root
+var STATE_ROOT_CHECK_HEIGHT = map[uint32]uint32{
+	NETWORK_ID_MAIN_NET:    constants.STATE_ROOT_HEIGHT_MAINNET, //Network main
+	NETWORK_ID_POLARIS_NET: constants.STATE_ROOT_HEIGHT_POLARIS, //Network polaris
+	NETWORK_ID_SOLO_NET:    0,                                   //Network solo
+}
+
+func GetStateRootCheckHeight(id uint32) uint32 {
+	return STATE_ROOT_CHECK_HEIGHT[id]
+}
+
 func GetNetworkName(id uint32) string {
 	name, ok := NETWORK_NAME[id]
 	if ok {
@@ -195,6 +220,7 @@ var PolarisConfig = &GenesisConfig{
 	},
 	DBFT: &DBFTConfig{},
 	SOLO: &SOLOConfig{},
+	SBFT: &SBFTConfig{},
 }
 
 var MainNetConfig = &GenesisConfig{
@@ -258,16 +284,22 @@ var MainNetConfig = &GenesisConfig{
 	},
 	DBFT: &DBFTConfig{},
 	SOLO: &SOLOConfig{},
+	SBFT: &SBFTConfig{},
 }
 
 var DefConfig = NewDNAConfig()
 
 type GenesisConfig struct {
 	SeedList      []string
+	SeedPubKeys   []string `json:",omitempty"` // keys allowed to announce seeds, bookkeepers if empty
 	ConsensusType string
 	VBFT          *VBFTConfig
 	DBFT          *DBFTConfig
 	SOLO          *SOLOConfig
+	SBFT          *SBFTConfig
+
+	TxPolicy         *PolicyConfig `json:",omitempty"`
+	BookkeeperPolicy *PolicyConfig `json:",omitempty"`
 }
 
 func NewGenesisConfig() *GenesisConfig {
@@ -277,6 +309,7 @@ func NewGenesisConfig() *GenesisConfig {
 		VBFT:          &VBFTConfig{},
 		DBFT:          &DBFTConfig{},
 		SOLO:          &SOLOConfig{},
+		SBFT:          &SBFTConfig{},
 	}
 }
 
@@ -487,19 +520,65 @@ type SOLOConfig struct {
 	Bookkeepers  []string
 }
 
+//
+// SBFT genesis config, a fixed validator set running pbft-style consensus
+//
+type SBFTConfig struct {
+	GenBlockTime uint
+	Bookkeepers  []string
+}
+
+//
+// Policy config, PolicyLevel is one of AllowAll(0), DenyAll(1),
+// AllowList(2) and DenyList(3), List holds base58 addresses.
+// MaxTxSize limits the bytes of a transaction and PayerQuota limits
+// the transactions of a payer in one block, 0 means unlimited
+//
+type PolicyConfig struct {
+	PolicyLevel byte
+	List        []string
+	MaxTxSize   uint32 `json:",omitempty"`
+	PayerQuota  uint32 `json:",omitempty"`
+}
+
 type CommonConfig struct {
-	LogLevel       uint
-	NodeType       string
-	EnableEventLog bool
-	SystemFee      map[string]int64
-	GasLimit       uint64
-	GasPrice       uint64
-	DataDir        string
+	LogLevel           uint
+	NodeType           string
+	EnableEventLog     bool
+	EnableAddressIndex bool
+	EnableArchive      bool
+	PruneBlocks        uint32
+	RollbackBlocks     uint32
+	DBBackend          string
+	LightMode          bool
+	SystemFee          map[string]int64
+	GasLimit           uint64
+	GasPrice           uint64
+	DataDir            string
 }
 
 type ConsensusConfig struct {
 	EnableConsensus bool
 	MaxTxInBlock    uint
+	FaultScenario   string `json:",omitempty"` //Scenario file of the faults injected into consensus, for chaos tests only
+}
+
+//
+// Validator plugin config, Name selects a registered plugin and Config
+// is passed to the plugin as is
+//
+type ValidatorPluginConfig struct {
+	Name   string
+	Config json.RawMessage `json:",omitempty"`
+}
+
+type TxPoolConfig struct {
+	Validators     []*ValidatorPluginConfig
+	MaxTxPerPayer  uint   // 0 for no limit
+	MinReplaceBump uint   // gas price bump in percentage to replace a tx with the same payer and nonce
+	MaxTxs         uint   // 0 for no limit
+	MaxBytes       uint64 // 0 for no limit
+	TxTTL          uint32 // blocks that a tx can stay in the pool, 0 for never expire
 }
 
 type P2PRsvConfig struct {
@@ -523,6 +602,8 @@ type P2PNodeConfig struct {
 	MaxConnInBound            uint
 	MaxConnOutBound           uint
 	MaxConnInBoundForSingleIP uint
+	SeedAnnounceAddr          string
+	EnableFastSync            bool
 }
 
 type RpcConfig struct {
@@ -550,6 +631,7 @@ type DNAConfig struct {
 	Genesis   *GenesisConfig
 	Common    *CommonConfig
 	Consensus *ConsensusConfig
+	TxPool    *TxPoolConfig
 	P2PNode   *P2PNodeConfig
 	Rpc       *RpcConfig
 	Restful   *RestfulConfig
@@ -560,16 +642,29 @@ func NewDNAConfig() *DNAConfig {
 	return &DNAConfig{
 		Genesis: MainNetConfig,
 		Common: &CommonConfig{
-			LogLevel:       DEFAULT_LOG_LEVEL,
-			EnableEventLog: DEFAULT_ENABLE_EVENT_LOG,
-			SystemFee:      make(map[string]int64),
-			GasLimit:       DEFAULT_GAS_LIMIT,
-			DataDir:        DEFAULT_DATA_DIR,
+			LogLevel:           DEFAULT_LOG_LEVEL,
+			EnableEventLog:     DEFAULT_ENABLE_EVENT_LOG,
+			EnableAddressIndex: DEFAULT_ENABLE_ADDRESS_INDEX,
+			EnableArchive:      DEFAULT_ENABLE_ARCHIVE,
+			PruneBlocks:        DEFAULT_PRUNE_BLOCKS,
+			RollbackBlocks:     DEFAULT_ROLLBACK_BLOCKS,
+			DBBackend:          DEFAULT_DB_BACKEND,
+			LightMode:          DEFAULT_LIGHT_MODE,
+			SystemFee:          make(map[string]int64),
+			GasLimit:           DEFAULT_GAS_LIMIT,
+			DataDir:            DEFAULT_DATA_DIR,
 		},
 		Consensus: &ConsensusConfig{
 			EnableConsensus: true,
 			MaxTxInBlock:    DEFAULT_MAX_TX_IN_BLOCK,
 		},
+		TxPool: &TxPoolConfig{
+			MaxTxPerPayer:  DEFAULT_TXPOOL_MAX_TX_PER_PAYER,
+			MinReplaceBump: DEFAULT_TXPOOL_MIN_REPLACE_BUMP,
+			MaxTxs:         DEFAULT_TXPOOL_MAX_TXS,
+			MaxBytes:       DEFAULT_TXPOOL_MAX_BYTES,
+			TxTTL:          DEFAULT_TXPOOL_TX_TTL,
+		},
 		P2PNode: &P2PNodeConfig{
 			ReservedCfg:               &P2PRsvConfig{},
 			ReservedPeersOnly:         false,
@@ -614,6 +709,8 @@ func (this *DNAConfig) GetBookkeepers() ([]keypair.PublicKey, error) {
 		bookKeepers = this.Genesis.DBFT.Bookkeepers
 	case CONSENSUS_TYPE_SOLO:
 		bookKeepers = this.Genesis.SOLO.Bookkeepers
+	case CONSENSUS_TYPE_SBFT:
+		bookKeepers = this.Genesis.SBFT.Bookkeepers
 	default:
 		return nil, fmt.Errorf("Does not support %s consensus", this.Genesis.ConsensusType)
 	}
@@ -661,6 +758,8 @@ func (this *DNAConfig) getDefNetworkIDFromGenesisConfig(genCfg *GenesisConfig) (
 		configData, err = json.Marshal(genCfg.VBFT)
 	case CONSENSUS_TYPE_DBFT:
 		configData, err = json.Marshal(genCfg.DBFT)
+	case CONSENSUS_TYPE_SBFT:
+		configData, err = json.Marshal(genCfg.SBFT)
 	case CONSENSUS_TYPE_SOLO:
 		return NETWORK_ID_SOLO_NET, nil
 	default:
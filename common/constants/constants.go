@@ -19,6 +19,7 @@
 package constants
 
 import (
+	"math"
 	"time"
 )
 
@@ -86,3 +87,7 @@ const STATE_HASH_HEIGHT_POLARIS = 850000
 // neovm opcode update check height
 const OPCODE_HEIGHT_UPDATE_FIRST_MAINNET = 6300000
 const OPCODE_HEIGHT_UPDATE_FIRST_POLARIS = 2100000
+
+// state root header check height, not scheduled yet
+const STATE_ROOT_HEIGHT_MAINNET = math.MaxUint32
+const STATE_ROOT_HEIGHT_POLARIS = math.MaxUint32
@@ -57,3 +57,45 @@ func ComputeMerkleRoot(hashes []Uint256) Uint256 {
 
 	return hashes[0]
 }
+
+//ComputeMerklePath return the sibling hashes on the path from the leaf of index to the root computed by ComputeMerkleRoot
+func ComputeMerklePath(hashes []Uint256, index int) []Uint256 {
+	if index < 0 || index >= len(hashes) {
+		return nil
+	}
+	level := make([]Uint256, len(hashes))
+	copy(level, hashes)
+	path := make([]Uint256, 0)
+	for len(level) > 1 {
+		if len(level)%2 == 1 {
+			level = append(level, level[len(level)-1])
+		}
+		path = append(path, level[index^1])
+		next := make([]Uint256, len(level)/2)
+		for i := range next {
+			next[i] = hashMerkleChildren(level[2*i], level[2*i+1])
+		}
+		level = next
+		index /= 2
+	}
+	return path
+}
+
+//VerifyMerklePath return whether the path of ComputeMerklePath leads the leaf of index to the root
+func VerifyMerklePath(leaf Uint256, index uint32, path []Uint256, root Uint256) bool {
+	hash := leaf
+	for _, sibling := range path {
+		if index%2 == 0 {
+			hash = hashMerkleChildren(hash, sibling)
+		} else {
+			hash = hashMerkleChildren(sibling, hash)
+		}
+		index /= 2
+	}
+	return index == 0 && hash == root
+}
+
+func hashMerkleChildren(left, right Uint256) Uint256 {
+	temp := sha256.Sum256(append(left[:], right[:]...))
+	return Uint256(sha256.Sum256(temp[:]))
+}
@@ -182,3 +182,22 @@ func computeMerkleRootOld(hashes []Uint256) Uint256 {
 	tree, _ := newMerkleTree(hashes)
 	return tree.Root.Hash
 }
+
+func TestMerklePath(t *testing.T) {
+	for n := 1; n < 40; n++ {
+		data := make([]Uint256, n)
+		for i := range data {
+			data[i] = Uint256(sha256.Sum256([]byte(fmt.Sprint(i))))
+		}
+		root := computeMerkleRootOld(data)
+		for i := range data {
+			path := ComputeMerklePath(data, i)
+			assert.True(t, VerifyMerklePath(data[i], uint32(i), path, root))
+			assert.False(t, VerifyMerklePath(data[i], uint32(i)+uint32(len(data))*2, path, root))
+			if i > 0 {
+				assert.False(t, VerifyMerklePath(data[i-1], uint32(i), path, root))
+			}
+		}
+	}
+	assert.Nil(t, ComputeMerklePath(nil, 0))
+}
@@ -51,6 +51,7 @@ type ConsensusContext struct {
 	ExpectedView    []byte
 
 	header *types.Block
+	ledger *ledger.Ledger
 
 	isBookkeeperChanged bool
 	nmChangedblkHeight  uint32
@@ -100,9 +101,13 @@ func (ctx *ConsensusContext) MakeHeader() *types.Block {
 			txHash = append(txHash, t.Hash())
 		}
 		txRoot := common.ComputeMerkleRoot(txHash)
-		blockRoot := ledger.DefLedger.GetBlockRootWithNewTxRoots(ctx.Height, []common.Uint256{txRoot})
+		blockRoot := ctx.ledger.GetBlockRootWithNewTxRoots(ctx.Height, []common.Uint256{txRoot})
+		stateRoot, err := ctx.ledger.GetHeaderStateRoot(ctx.Height)
+		if err != nil {
+			log.Errorf("GetHeaderStateRoot height:%d error:%s", ctx.Height, err)
+		}
 		header := &types.Header{
-			Version:          ContextVersion,
+			Version:          types.HeaderVersion(ctx.Height),
 			PrevBlockHash:    ctx.PrevHash,
 			TransactionsRoot: txRoot,
 			BlockRoot:        blockRoot,
@@ -110,6 +115,7 @@ func (ctx *ConsensusContext) MakeHeader() *types.Block {
 			Height:           ctx.Height,
 			ConsensusData:    ctx.Nonce,
 			NextBookkeeper:   ctx.NextBookkeeper,
+			StateRoot:        stateRoot,
 		}
 		ctx.header = &types.Block{
 			Header:       header,
@@ -190,8 +196,8 @@ func (ctx *ConsensusContext) GetStateDetail() string {
 }
 
 func (ctx *ConsensusContext) Reset(bkAccount *account.Account) {
-	preHash := ledger.DefLedger.GetCurrentBlockHash()
-	height := ledger.DefLedger.GetCurrentBlockHeight()
+	preHash := ctx.ledger.GetCurrentBlockHash()
+	height := ctx.ledger.GetCurrentBlockHeight()
 	header := ctx.MakeHeader()
 
 	if height != ctx.Height || header == nil || header.Hash() != preHash || len(ctx.NextBookkeepers) == 0 {
@@ -29,6 +29,7 @@ import (
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
 	actorTypes "github.com/dnaproject2/DNA/consensus/actor"
+	"github.com/dnaproject2/DNA/consensus/policy"
 	"github.com/dnaproject2/DNA/core/genesis"
 	"github.com/dnaproject2/DNA/core/ledger"
 	"github.com/dnaproject2/DNA/core/signature"
@@ -39,6 +40,7 @@ import (
 	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
 	"github.com/dnaproject2/DNA/validator/increment"
 	"github.com/ontio/ontology-eventbus/actor"
+	"github.com/ontio/ontology-eventbus/eventhub"
 )
 
 type DbftService struct {
@@ -50,6 +52,9 @@ type DbftService struct {
 	blockReceivedTime time.Time
 	started           bool
 	ledger            *ledger.Ledger
+	faults            faultState
+	txPolicy          *policy.Policy //Policy of the transactions, refreshed from the ledger
+	bookkeeperPolicy  *policy.Policy //Policy of the block proposers, refreshed from the ledger
 	incrValidator     *increment.IncrementValidator
 	poolActor         *actorTypes.TxPoolActor
 	p2p               *actorTypes.P2PActor
@@ -59,15 +64,38 @@ type DbftService struct {
 }
 
 func NewDbftService(bkAccount *account.Account, txpool, p2p *actor.PID) (*DbftService, error) {
+	return newDbftService(bkAccount, txpool, p2p, ledger.DefLedger, policy.DefaultPolicy, policy.BookkeeperPolicy,
+		"consensus_dbft", events.DefEvtHub)
+}
+
+//NewDbftServiceWithLedger return the dbft service which builds blocks on the ledger and receives the block complete
+//events from the event hub, so that several services can run in one process with their own ledgers. The service
+//has its own policies refreshed from the ledger
+func NewDbftServiceWithLedger(bkAccount *account.Account, txpool, p2p *actor.PID, ldg *ledger.Ledger,
+	evtHub *eventhub.EventHub) (*DbftService, error) {
+	genesis := config.DefConfig.Genesis
+	txPolicy := policy.NewPolicy(policy.TX_POLICY_NAME, genesis.TxPolicy)
+	bookkeeperPolicy := policy.NewPolicy(policy.BOOKKEEPER_POLICY_NAME, genesis.BookkeeperPolicy)
+	return newDbftService(bkAccount, txpool, p2p, ldg, txPolicy, bookkeeperPolicy, "", evtHub)
+}
+
+//newDbftService spawn the actor of dbft service with the name, an anonymous actor is spawned if the name is empty
+func newDbftService(bkAccount *account.Account, txpool, p2p *actor.PID, ldg *ledger.Ledger, txPolicy,
+	bookkeeperPolicy *policy.Policy, name string, evtHub *eventhub.EventHub) (*DbftService, error) {
 	service := &DbftService{
-		Account:       bkAccount,
-		timer:         time.NewTimer(time.Second * 15),
-		started:       false,
-		ledger:        ledger.DefLedger,
-		incrValidator: increment.NewIncrementValidator(20),
-		poolActor:     &actorTypes.TxPoolActor{Pool: txpool},
-		p2p:           &actorTypes.P2PActor{P2P: p2p},
-	}
+		Account:          bkAccount,
+		timer:            time.NewTimer(time.Second * 15),
+		started:          false,
+		ledger:           ldg,
+		txPolicy:         txPolicy,
+		bookkeeperPolicy: bookkeeperPolicy,
+		incrValidator:    increment.NewIncrementValidator(20),
+		poolActor:        &actorTypes.TxPoolActor{Pool: txpool},
+		p2p:              &actorTypes.P2PActor{P2P: p2p},
+	}
+	service.context.ledger = ldg
+	service.RefreshPolicy()
+	service.initFaults()
 
 	if !service.timer.Stop() {
 		<-service.timer.C
@@ -87,10 +115,16 @@ func NewDbftService(bkAccount *account.Account, txpool, p2p *actor.PID) (*DbftSe
 		return service
 	})
 
-	pid, err := actor.SpawnNamed(props, "consensus_dbft")
+	var pid *actor.PID
+	var err error
+	if name == "" {
+		pid = actor.Spawn(props)
+	} else {
+		pid, err = actor.SpawnNamed(props, name)
+	}
 	service.pid = pid
 
-	service.sub = events.NewActorSubscriber(pid)
+	service.sub = events.NewActorSubscriber(pid, evtHub)
 	return service, err
 }
 
@@ -148,6 +182,7 @@ func (self *DbftService) handleBlockPersistCompleted(block *types.Block) {
 	log.Infof("persist block: %x", block.Hash())
 	self.p2p.Broadcast(block.Hash())
 
+	self.RefreshPolicy()
 	self.InitializeConsensus(0)
 }
 
@@ -184,9 +219,14 @@ func (ds *DbftService) CheckExpectedView(viewNumber byte) {
 	}
 }
 
-func (ds *DbftService) CheckPolicy(transaction *types.Transaction) error {
-	//TODO: CheckPolicy
-
+//CheckPolicy checks the transactions of a block against the transaction policy
+func (ds *DbftService) CheckPolicy(transactions []*types.Transaction) error {
+	checker := ds.txPolicy.NewTxChecker()
+	for _, tx := range transactions {
+		if err := checker.Check(tx); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
@@ -397,6 +437,12 @@ func (ds *DbftService) PrepareRequestReceived(payload *p2pmsg.ConsensusPayload,
 		return
 	}
 
+	if !ds.bookkeeperPolicy.IsAllowed(types.AddressFromPubKey(ds.context.Bookkeepers[payload.BookkeeperIndex])) {
+		log.Warnf("PrepareRequestReceived bookkeeper %d denied by policy", payload.BookkeeperIndex)
+		ds.RequestChangeView()
+		return
+	}
+
 	header, err := ds.ledger.GetHeaderByHash(ds.context.PrevHash)
 	if err != nil {
 		log.Errorf("PrepareRequestReceived GetHeader failed with ds.context.PrevHash:%x", ds.context.PrevHash)
@@ -435,6 +481,13 @@ func (ds *DbftService) PrepareRequestReceived(payload *p2pmsg.ConsensusPayload,
 	ds.context.Signatures = make([][]byte, len(ds.context.Bookkeepers))
 	ds.context.Signatures[payload.BookkeeperIndex] = message.Signature
 
+	if err := ds.CheckPolicy(ds.context.Transactions); err != nil {
+		log.Warnf("PrepareRequestReceived transactions denied by policy: %s", err)
+		ds.context = backupContext
+		ds.RequestChangeView()
+		return
+	}
+
 	if len(ds.context.Transactions) > 0 {
 		height := ds.context.Height - 1
 		start, end := ds.incrValidator.BlockRange()
@@ -585,7 +638,16 @@ func (ds *DbftService) BlockSignaturesReceived(payload *p2pmsg.ConsensusPayload,
 	log.Info("BlockSignatures finished")
 }
 
+//RefreshPolicy reload the policies of the service from its ledger, called on each block
 func (ds *DbftService) RefreshPolicy() {
+	for _, p := range []*policy.Policy{ds.txPolicy, ds.bookkeeperPolicy} {
+		if p == nil {
+			continue
+		}
+		if err := p.Refresh(ds.ledger); err != nil {
+			log.Errorf("RefreshPolicy error: %s", err)
+		}
+	}
 }
 
 func (ds *DbftService) RequestChangeView() {
@@ -609,6 +671,10 @@ func (ds *DbftService) RequestChangeView() {
 }
 
 func (ds *DbftService) SignAndRelay(payload *p2pmsg.ConsensusPayload) {
+	ds.relay(payload)
+}
+
+func (ds *DbftService) signAndRelay(payload *p2pmsg.ConsensusPayload) {
 	buf := new(bytes.Buffer)
 	payload.SerializeUnsigned(buf)
 	payload.Signature, _ = signature.Sign(ds.Account, buf.Bytes())
@@ -679,11 +745,18 @@ func (ds *DbftService) Timeout() {
 			txs := ds.poolActor.GetTxnPool(true, validHeight)
 
 			transactions := make([]*types.Transaction, 0, len(txs))
+			checker := ds.txPolicy.NewTxChecker()
 			for _, txEntry := range txs {
 				// TODO optimize to use height in txentry
-				if err := ds.incrValidator.Verify(txEntry.Tx, validHeight); err == nil {
-					transactions = append(transactions, txEntry.Tx)
+				if err := ds.incrValidator.Verify(txEntry.Tx, validHeight); err != nil {
+					continue
+				}
+				// leave the txs over the policy limits to the next blocks
+				if err := checker.Check(txEntry.Tx); err != nil {
+					log.Debugf("[Timeout] skip tx: %s", err)
+					continue
 				}
+				transactions = append(transactions, txEntry.Tx)
 			}
 
 			ds.context.Transactions = transactions
@@ -698,6 +771,7 @@ func (ds *DbftService) Timeout() {
 				log.Error("[Timeout] GetBookkeeperAddress failed")
 				return
 			}
+			ds.context.NextBookkeeper = ds.invalidNextBookkeeper(ds.context.NextBookkeeper)
 			ds.context.header = nil
 			//build block and sign
 			block := ds.context.MakeHeader()
@@ -706,6 +780,7 @@ func (ds *DbftService) Timeout() {
 		}
 		payload := ds.context.MakePrepareRequest()
 		ds.SignAndRelay(payload)
+		ds.equivocatePrepareRequest()
 
 		ds.blockReceivedTime = time.Now()
 
@@ -0,0 +1,123 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build faults
+
+package dbft
+
+import (
+	"time"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/consensus/fault"
+	"github.com/dnaproject2/DNA/core/signature"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+)
+
+var msgTypeNames = map[ConsensusMessageType]string{
+	ChangeViewMsg:      "changeView",
+	PrepareRequestMsg:  "prepareRequest",
+	PrepareResponseMsg: "prepareResponse",
+	BlockSignaturesMsg: "blockSignatures",
+}
+
+// faultState keeps the injector of the faults of the msgs sent by the service
+type faultState struct {
+	injector *fault.Injector
+}
+
+// SetFaultInjector sets the injector of the faults of the service, nothing is injected if it is nil
+func (ds *DbftService) SetFaultInjector(injector *fault.Injector) {
+	ds.faults.injector = injector
+}
+
+// initFaults injects the faults of the scenario the node is started with
+func (ds *DbftService) initFaults() {
+	ds.SetFaultInjector(fault.DefInjector)
+}
+
+// relay signs and relays the payload, with the faults injected if the service has an injector
+func (ds *DbftService) relay(payload *p2pmsg.ConsensusPayload) {
+	if ds.faults.injector != nil {
+		ds.relayWithFaults(payload)
+		return
+	}
+	ds.signAndRelay(payload)
+}
+
+func payloadTypeName(payload *p2pmsg.ConsensusPayload) string {
+	if len(payload.Data) == 0 {
+		return ""
+	}
+	return msgTypeNames[ConsensusMessageType(payload.Data[0])]
+}
+
+// relayWithFaults signs and relays the payload, with the faults of the test scenario injected
+func (ds *DbftService) relayWithFaults(payload *p2pmsg.ConsensusPayload) {
+	injector := ds.faults.injector
+	msgType := payloadTypeName(payload)
+	if injector.Fire(fault.ACTION_DROP, msgType, payload.Height) != nil {
+		return
+	}
+	if injector.Fire(fault.ACTION_CORRUPT, msgType, payload.Height) != nil {
+		payload.Data = injector.Corrupt(payload.Data)
+	}
+	copies := 1
+	if rule := injector.Fire(fault.ACTION_DUPLICATE, msgType, payload.Height); rule != nil {
+		copies += rule.ExtraCopies()
+	}
+	send := func() {
+		for i := 0; i < copies; i++ {
+			ds.signAndRelay(payload)
+		}
+	}
+	if rule := injector.Fire(fault.ACTION_DELAY, msgType, payload.Height); rule != nil {
+		time.AfterFunc(rule.DelayDuration(), send)
+		return
+	}
+	send()
+}
+
+// equivocatePrepareRequest relays a prepare request of another block at the same height and view
+// if the scenario decides to equivocate, backups accept whichever request arrives first
+func (ds *DbftService) equivocatePrepareRequest() {
+	if ds.faults.injector.Fire(fault.ACTION_EQUIVOCATE, msgTypeNames[PrepareRequestMsg], ds.context.Height) == nil {
+		return
+	}
+	ctx := ds.context
+	ctx.Nonce = common.GetNonce()
+	ctx.header = nil
+	ctx.Signatures = make([][]byte, len(ds.context.Signatures))
+	blockHash := ctx.MakeHeader().Hash()
+	sig, err := signature.Sign(ds.Account, blockHash[:])
+	if err != nil {
+		log.Errorf("[equivocatePrepareRequest] sign block error: %s", err)
+		return
+	}
+	ctx.Signatures[ctx.BookkeeperIndex] = sig
+	ds.signAndRelay(ctx.MakePrepareRequest())
+}
+
+// invalidNextBookkeeper returns a wrong next bookkeeper of the block if the scenario decides to
+// propose an invalid block, which backups refuse to sign
+func (ds *DbftService) invalidNextBookkeeper(nextBookkeeper common.Address) common.Address {
+	if ds.faults.injector.Fire(fault.ACTION_INVALID_BLOCK, msgTypeNames[PrepareRequestMsg], ds.context.Height) == nil {
+		return nextBookkeeper
+	}
+	nextBookkeeper[0] ^= 0xff
+	return nextBookkeeper
+}
@@ -0,0 +1,39 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build !faults
+
+package dbft
+
+import (
+	"github.com/dnaproject2/DNA/common"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+)
+
+// faultState is empty, the faults are only injected by the binary built with the faults tag
+type faultState struct{}
+
+func (ds *DbftService) initFaults() {}
+
+func (ds *DbftService) relay(payload *p2pmsg.ConsensusPayload) {
+	ds.signAndRelay(payload)
+}
+
+func (ds *DbftService) equivocatePrepareRequest() {}
+
+func (ds *DbftService) invalidNextBookkeeper(nextBookkeeper common.Address) common.Address {
+	return nextBookkeeper
+}
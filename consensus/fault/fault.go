@@ -0,0 +1,213 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package fault injects byzantine faults into the consensus msgs sent by a node, which is only used
+// by chaos tests. The faults are described by a scenario file given to the node on start. The hooks of
+// consensus and the --fault-scenario flag are only built with the faults tag: go build -tags faults
+package fault
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dnaproject2/DNA/common/log"
+)
+
+// Action is the kind of fault injected into the msgs
+type Action string
+
+const (
+	ACTION_DELAY         Action = "delay"        // send the msg after a delay
+	ACTION_DROP          Action = "drop"         // never send the msg
+	ACTION_DUPLICATE     Action = "duplicate"    // send extra copies of the msg
+	ACTION_CORRUPT       Action = "corrupt"      // flip a random byte of the msg, dbft signs the flipped msg while vbft breaks its signature
+	ACTION_EQUIVOCATE    Action = "equivocate"   // send a conflicting proposal of the same height
+	ACTION_INVALID_BLOCK Action = "invalidBlock" // propose a block which honest nodes reject
+)
+
+var actions = map[Action]bool{
+	ACTION_DELAY:         true,
+	ACTION_DROP:          true,
+	ACTION_DUPLICATE:     true,
+	ACTION_CORRUPT:       true,
+	ACTION_EQUIVOCATE:    true,
+	ACTION_INVALID_BLOCK: true,
+}
+
+// Rule injects the fault into the msgs it matches
+type Rule struct {
+	Action      Action
+	MsgTypes    []string `json:",omitempty"` // names of the msg types matched, all types if empty
+	FromHeight  uint32   `json:",omitempty"` // lowest block height matched
+	ToHeight    uint32   `json:",omitempty"` // highest block height matched, unlimited if 0
+	Probability float64  `json:",omitempty"` // probability of the fault on a matched msg, always if 0
+	Limit       uint32   `json:",omitempty"` // max times of the fault, unlimited if 0
+	Delay       uint32   `json:",omitempty"` // milliseconds of the delay action
+	Copies      uint32   `json:",omitempty"` // extra copies of the duplicate action, 1 if 0
+
+	fired uint32
+}
+
+// DelayDuration returns the delay of the delay action
+func (self *Rule) DelayDuration() time.Duration {
+	return time.Duration(self.Delay) * time.Millisecond
+}
+
+// ExtraCopies returns the count of extra copies of the duplicate action
+func (self *Rule) ExtraCopies() int {
+	if self.Copies == 0 {
+		return 1
+	}
+	return int(self.Copies)
+}
+
+func (self *Rule) match(action Action, msgType string, height uint32) bool {
+	if self.Action != action || height < self.FromHeight || (self.ToHeight != 0 && height > self.ToHeight) {
+		return false
+	}
+	if self.Limit != 0 && self.fired >= self.Limit {
+		return false
+	}
+	if len(self.MsgTypes) == 0 {
+		return true
+	}
+	for _, t := range self.MsgTypes {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+// Scenario is the faults injected by a node
+type Scenario struct {
+	Seed  int64 // seed of the random decisions, a run can be reproduced with the same seed
+	Rules []*Rule
+}
+
+// LoadScenario reads the scenario from the json file
+func LoadScenario(path string) (*Scenario, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read scenario file error: %s", err)
+	}
+	scenario := &Scenario{}
+	if err := json.Unmarshal(data, scenario); err != nil {
+		return nil, fmt.Errorf("parse scenario file error: %s", err)
+	}
+	return scenario, nil
+}
+
+// Injector decides the faults of the msgs by the rules of scenario. All methods can be
+// called on a nil injector, which injects nothing
+type Injector struct {
+	lock  sync.Mutex
+	rand  *rand.Rand
+	rules []*Rule
+}
+
+// NewInjector checks the rules of scenario and creates the injector
+func NewInjector(scenario *Scenario) (*Injector, error) {
+	for i, rule := range scenario.Rules {
+		if !actions[rule.Action] {
+			return nil, fmt.Errorf("rule %d: unknown action %q", i, rule.Action)
+		}
+		if rule.Probability < 0 || rule.Probability > 1 {
+			return nil, fmt.Errorf("rule %d: invalid probability %v", i, rule.Probability)
+		}
+		if rule.ToHeight != 0 && rule.ToHeight < rule.FromHeight {
+			return nil, fmt.Errorf("rule %d: invalid height range [%d, %d]", i, rule.FromHeight, rule.ToHeight)
+		}
+	}
+	return &Injector{
+		rand:  rand.New(rand.NewSource(scenario.Seed)),
+		rules: scenario.Rules,
+	}, nil
+}
+
+// Fire returns the first rule of the action which matches the msg and decides to inject the fault,
+// or nil if the msg is sent as usual
+func (self *Injector) Fire(action Action, msgType string, height uint32) *Rule {
+	if self == nil {
+		return nil
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, rule := range self.rules {
+		if !rule.match(action, msgType, height) {
+			continue
+		}
+		if rule.Probability != 0 && self.rand.Float64() >= rule.Probability {
+			continue
+		}
+		rule.fired++
+		log.Warnf("fault injected: %s %s msg of height %d", action, msgType, height)
+		return rule
+	}
+	return nil
+}
+
+// Fired returns the times the faults of the action have been injected
+func (self *Injector) Fired(action Action) uint32 {
+	if self == nil {
+		return 0
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	var fired uint32
+	for _, rule := range self.rules {
+		if rule.Action == action {
+			fired += rule.fired
+		}
+	}
+	return fired
+}
+
+// Corrupt returns a copy of the data with a random byte flipped
+func (self *Injector) Corrupt(data []byte) []byte {
+	if self == nil || len(data) == 0 {
+		return data
+	}
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	corrupted := make([]byte, len(data))
+	copy(corrupted, data)
+	corrupted[self.rand.Intn(len(corrupted))] ^= byte(1 + self.rand.Intn(255))
+	return corrupted
+}
+
+// DefInjector injects the faults into consensus, nil unless the node is started with a scenario
+var DefInjector *Injector
+
+// Init loads the scenario file and sets the default injector
+func Init(path string) error {
+	scenario, err := LoadScenario(path)
+	if err != nil {
+		return err
+	}
+	injector, err := NewInjector(scenario)
+	if err != nil {
+		return err
+	}
+	DefInjector = injector
+	return nil
+}
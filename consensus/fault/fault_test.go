@@ -0,0 +1,140 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package fault
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadScenario(t *testing.T) {
+	dir, err := ioutil.TempDir("", "fault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "scenario.json")
+	data := `{"Seed": 7, "Rules": [
+		{"Action": "drop", "MsgTypes": ["endorse"], "FromHeight": 10, "ToHeight": 20},
+		{"Action": "delay", "Delay": 500, "Probability": 0.5}
+	]}`
+	if err := ioutil.WriteFile(path, []byte(data), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(path); err != nil {
+		t.Fatalf("Init error: %s", err)
+	}
+	defer func() { DefInjector = nil }()
+	if len(DefInjector.rules) != 2 || DefInjector.rules[1].DelayDuration().Seconds() != 0.5 {
+		t.Fatalf("unexpected rules %v", DefInjector.rules)
+	}
+
+	if err := ioutil.WriteFile(path, []byte(`{"Rules": [{"Action": "explode"}]}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := Init(path); err == nil {
+		t.Fatal("unknown action accepted")
+	}
+}
+
+func TestFire(t *testing.T) {
+	injector, err := NewInjector(&Scenario{Rules: []*Rule{
+		{Action: ACTION_DROP, MsgTypes: []string{"endorse"}, FromHeight: 10, ToHeight: 20, Limit: 2},
+		{Action: ACTION_DUPLICATE, Copies: 3},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if injector.Fire(ACTION_DROP, "endorse", 9) != nil || injector.Fire(ACTION_DROP, "endorse", 21) != nil {
+		t.Fatal("fired out of height range")
+	}
+	if injector.Fire(ACTION_DROP, "proposal", 10) != nil {
+		t.Fatal("fired on unmatched msg type")
+	}
+	if injector.Fire(ACTION_DROP, "endorse", 10) == nil || injector.Fire(ACTION_DROP, "endorse", 20) == nil {
+		t.Fatal("not fired on matched msg")
+	}
+	if injector.Fire(ACTION_DROP, "endorse", 15) != nil {
+		t.Fatal("fired over limit")
+	}
+	if fired := injector.Fired(ACTION_DROP); fired != 2 {
+		t.Fatalf("fired %d times, expected 2", fired)
+	}
+	if rule := injector.Fire(ACTION_DUPLICATE, "commit", 1); rule == nil || rule.ExtraCopies() != 3 {
+		t.Fatal("duplicate rule not fired")
+	}
+
+	var nilInjector *Injector
+	if nilInjector.Fire(ACTION_DROP, "endorse", 10) != nil {
+		t.Fatal("nil injector fired")
+	}
+}
+
+func TestProbability(t *testing.T) {
+	fired := func(seed int64) []bool {
+		injector, err := NewInjector(&Scenario{Seed: seed, Rules: []*Rule{{Action: ACTION_DROP, Probability: 0.3}}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		result := make([]bool, 1000)
+		for i := range result {
+			result[i] = injector.Fire(ACTION_DROP, "proposal", uint32(i)) != nil
+		}
+		return result
+	}
+	r1, r2 := fired(1), fired(1)
+	count := 0
+	for i := range r1 {
+		if r1[i] != r2[i] {
+			t.Fatal("decisions not reproduced with the same seed")
+		}
+		if r1[i] {
+			count++
+		}
+	}
+	if count < 200 || count > 400 {
+		t.Fatalf("fired %d of 1000 with probability 0.3", count)
+	}
+}
+
+func TestCorrupt(t *testing.T) {
+	injector, err := NewInjector(&Scenario{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := []byte("consensus message")
+	corrupted := injector.Corrupt(data)
+	if bytes.Equal(data, corrupted) || !bytes.Equal(data, []byte("consensus message")) {
+		t.Fatal("data not corrupted on a copy")
+	}
+	diff := 0
+	for i := range data {
+		if data[i] != corrupted[i] {
+			diff++
+		}
+	}
+	if diff != 1 {
+		t.Fatalf("%d bytes corrupted", diff)
+	}
+}
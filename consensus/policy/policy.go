@@ -0,0 +1,365 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/types"
+	cutils "github.com/dnaproject2/DNA/core/utils"
+	params "github.com/dnaproject2/DNA/smartcontract/service/native/global_params"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+const (
+	TX_POLICY_NAME         = "tx"
+	BOOKKEEPER_POLICY_NAME = "bookkeeper"
+)
+
+// the global params of a policy are its name followed by the suffixes
+var policyParamSuffixes = []string{"PolicyLevel", "PolicyList", "PolicyMaxTxSize", "PolicyPayerQuota"}
+
+// Policy decides whether an address is permitted. It is initialized from
+// the genesis config and can be overridden on chain by the global params
+// "<name>PolicyLevel" and "<name>PolicyList" (comma separated base58
+// addresses). The limits of transactions are overridden by the global params
+// "<name>PolicyMaxTxSize" and "<name>PolicyPayerQuota".
+type Policy struct {
+	sync.RWMutex
+	PolicyLevel PolicyLevel
+	List        []common.Address
+	MaxTxSize   uint32 // max bytes of a transaction, 0 means unlimited
+	PayerQuota  uint32 // max transactions of a payer in one block, 0 means unlimited
+
+	name string
+	cfg  *config.PolicyConfig
+}
+
+func NewPolicy(name string, cfg *config.PolicyConfig) *Policy {
+	return &Policy{
+		name: name,
+		cfg:  cfg,
+	}
+}
+
+// IsAllowed returns true if the address passes the policy
+func (p *Policy) IsAllowed(addr common.Address) bool {
+	if p == nil {
+		return true
+	}
+	p.RLock()
+	defer p.RUnlock()
+
+	switch p.PolicyLevel {
+	case AllowAll:
+		return true
+	case DenyAll:
+		return false
+	case AllowList:
+		return p.contains(addr)
+	case DenyList:
+		return !p.contains(addr)
+	}
+	return false
+}
+
+func (p *Policy) contains(addr common.Address) bool {
+	for _, a := range p.List {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// Refresh reloads the policy from config, and then from the global params
+// contract of the ledger if the ledger is not nil and the params are set
+func (p *Policy) Refresh(lgr *ledger.Ledger) error {
+	level, list, err := parsePolicyConfig(p.cfg)
+	if err != nil {
+		return err
+	}
+	var maxTxSize, payerQuota uint32
+	if p.cfg != nil {
+		maxTxSize, payerQuota = p.cfg.MaxTxSize, p.cfg.PayerQuota
+	}
+
+	if lgr != nil {
+		values, err := blockParams.get(lgr, lgr.GetCurrentBlockHeight(), p.name)
+		if err != nil {
+			log.Debugf("policy %s: %s", p.name, err)
+		} else {
+			if values[0] != "" {
+				lvl, err := strconv.ParseUint(values[0], 10, 8)
+				if err != nil {
+					return fmt.Errorf("invalid %s policy level %s: %s", p.name, values[0], err)
+				}
+				level = PolicyLevel(lvl)
+				list, err = parseAddressList(strings.Split(values[1], ","))
+				if err != nil {
+					return err
+				}
+			}
+			if maxTxSize, err = parseLimit(values[2], maxTxSize); err != nil {
+				return fmt.Errorf("invalid %s policy max tx size %s: %s", p.name, values[2], err)
+			}
+			if payerQuota, err = parseLimit(values[3], payerQuota); err != nil {
+				return fmt.Errorf("invalid %s policy payer quota %s: %s", p.name, values[3], err)
+			}
+		}
+	}
+	if level > DenyList {
+		return fmt.Errorf("invalid %s policy level %d", p.name, level)
+	}
+
+	p.Lock()
+	p.PolicyLevel = level
+	p.List = list
+	p.MaxTxSize = maxTxSize
+	p.PayerQuota = payerQuota
+	p.Unlock()
+	return nil
+}
+
+// parseLimit parses the limit of global param, the default value is
+// returned if the param is not set
+func parseLimit(value string, def uint32) (uint32, error) {
+	if value == "" {
+		return def, nil
+	}
+	limit, err := strconv.ParseUint(value, 10, 32)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(limit), nil
+}
+
+// TxChecker checks the transactions of a block one by one against the
+// policy, and counts the transactions of each payer
+type TxChecker struct {
+	policy *Policy
+	counts map[common.Address]uint32
+}
+
+// NewTxChecker returns the checker of the transactions of a new block
+func (p *Policy) NewTxChecker() *TxChecker {
+	return &TxChecker{
+		policy: p,
+		counts: make(map[common.Address]uint32),
+	}
+}
+
+// Check returns an error if the payer of the transaction is not allowed,
+// the transaction is too large, or the payer exceeds its quota in the block.
+// The transaction is counted if it passes the policy
+func (c *TxChecker) Check(tx *types.Transaction) error {
+	p := c.policy
+	if p == nil {
+		return nil
+	}
+	hash := tx.Hash()
+	if !p.IsAllowed(tx.Payer) {
+		return fmt.Errorf("payer %s of tx %s is not allowed", tx.Payer.ToBase58(), hash.ToHexString())
+	}
+	p.RLock()
+	maxTxSize, payerQuota := p.MaxTxSize, p.PayerQuota
+	p.RUnlock()
+
+	if maxTxSize != 0 && len(tx.Raw) > int(maxTxSize) {
+		return fmt.Errorf("size %d of tx %s exceeds %d", len(tx.Raw), hash.ToHexString(), maxTxSize)
+	}
+	if payerQuota != 0 && c.counts[tx.Payer] >= payerQuota {
+		return fmt.Errorf("payer %s exceeds quota %d of txs in block", tx.Payer.ToBase58(), payerQuota)
+	}
+	c.counts[tx.Payer]++
+	return nil
+}
+
+func parsePolicyConfig(cfg *config.PolicyConfig) (PolicyLevel, []common.Address, error) {
+	if cfg == nil {
+		return AllowAll, nil, nil
+	}
+	list, err := parseAddressList(cfg.List)
+	if err != nil {
+		return AllowAll, nil, err
+	}
+	return PolicyLevel(cfg.PolicyLevel), list, nil
+}
+
+func parseAddressList(strs []string) ([]common.Address, error) {
+	list := make([]common.Address, 0, len(strs))
+	for _, s := range strs {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		addr, err := common.AddressFromBase58(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid policy address %s: %s", s, err)
+		}
+		list = append(list, addr)
+	}
+	return list, nil
+}
+
+// paramsCache keeps the global params of all the policies at a block height
+// of a ledger, so that the consensus engines and the txpool refreshing the
+// policies on each block share one PreExecute of the global params contract
+type paramsCache struct {
+	sync.Mutex
+	query  func(lgr *ledger.Ledger, names ...string) ([]string, error)
+	ledger *ledger.Ledger
+	height uint32
+	values map[string]string
+}
+
+var blockParams = &paramsCache{query: getGlobalParams}
+
+// get returns the values of the global params of the policy at the block
+// height of the ledger, the params are queried once for each height
+func (c *paramsCache) get(lgr *ledger.Ledger, height uint32, name string) ([]string, error) {
+	c.Lock()
+	defer c.Unlock()
+	if c.values == nil || c.ledger != lgr || c.height != height {
+		names := make([]string, 0, 2*len(policyParamSuffixes))
+		for _, policy := range []string{TX_POLICY_NAME, BOOKKEEPER_POLICY_NAME} {
+			for _, suffix := range policyParamSuffixes {
+				names = append(names, policy+suffix)
+			}
+		}
+		values, err := c.query(lgr, names...)
+		if err != nil {
+			c.values = nil
+			return nil, err
+		}
+		c.values = make(map[string]string, len(names))
+		for i, name := range names {
+			c.values[name] = values[i]
+		}
+		c.ledger, c.height = lgr, height
+	}
+	values := make([]string, 0, len(policyParamSuffixes))
+	for _, suffix := range policyParamSuffixes {
+		values = append(values, c.values[name+suffix])
+	}
+	return values, nil
+}
+
+// getGlobalParams returns the values of the global params in the ledger, a
+// missing param has an empty value
+func getGlobalParams(lgr *ledger.Ledger, names ...string) ([]string, error) {
+	paramNames := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		paramNames = append(paramNames, name)
+	}
+	code, err := cutils.BuildNativeInvokeCode(nutils.ParamContractAddress, 0, params.GET_GLOBAL_PARAM_NAME,
+		[]interface{}{paramNames})
+	if err != nil {
+		return nil, fmt.Errorf("BuildNativeInvokeCode error:%s", err)
+	}
+	tx, err := cutils.NewInvokeTransaction(code).IntoImmutable()
+	if err != nil {
+		return nil, err
+	}
+	result, err := lgr.PreExecuteContract(tx)
+	if err != nil {
+		return nil, fmt.Errorf("PreExecuteContract failed %v", err)
+	}
+
+	queriedParams := new(params.Params)
+	data, err := hex.DecodeString(result.Result.(string))
+	if err != nil {
+		return nil, fmt.Errorf("decode result error %v", err)
+	}
+	err = queriedParams.Deserialize(bytes.NewBuffer(data))
+	if err != nil {
+		return nil, fmt.Errorf("deserialize result error %v", err)
+	}
+
+	values := make([]string, len(names))
+	for i, name := range names {
+		_, param := queriedParams.GetParam(name)
+		values[i] = param.Value
+	}
+	return values, nil
+}
+
+// DefaultPolicy checks the payers of transactions
+var DefaultPolicy *Policy
+
+// BookkeeperPolicy checks the proposers of blocks
+var BookkeeperPolicy *Policy
+
+func InitPolicy() {
+	genesis := config.DefConfig.Genesis
+	DefaultPolicy = NewPolicy(TX_POLICY_NAME, genesis.TxPolicy)
+	if err := DefaultPolicy.Refresh(ledger.DefLedger); err != nil {
+		log.Errorf("InitPolicy: refresh tx policy error: %s", err)
+	}
+	BookkeeperPolicy = NewPolicy(BOOKKEEPER_POLICY_NAME, genesis.BookkeeperPolicy)
+	if err := BookkeeperPolicy.Refresh(ledger.DefLedger); err != nil {
+		log.Errorf("InitPolicy: refresh bookkeeper policy error: %s", err)
+	}
+}
+
+// RefreshTxPolicy reloads the transaction policy, called on each block
+func RefreshTxPolicy() {
+	if DefaultPolicy == nil {
+		return
+	}
+	if err := DefaultPolicy.Refresh(ledger.DefLedger); err != nil {
+		log.Errorf("RefreshTxPolicy error: %s", err)
+	}
+}
+
+// RefreshBookkeeperPolicy reloads the bookkeeper policy, called on each block
+func RefreshBookkeeperPolicy() {
+	if BookkeeperPolicy == nil {
+		return
+	}
+	if err := BookkeeperPolicy.Refresh(ledger.DefLedger); err != nil {
+		log.Errorf("RefreshBookkeeperPolicy error: %s", err)
+	}
+}
+
+// CheckTransaction returns true if the payer of the transaction is allowed
+func CheckTransaction(tx *types.Transaction) bool {
+	return DefaultPolicy.IsAllowed(tx.Payer)
+}
+
+// NewBlockTxChecker returns the checker of the transactions of a new block
+// against the transaction policy
+func NewBlockTxChecker() *TxChecker {
+	return DefaultPolicy.NewTxChecker()
+}
+
+// CheckBookkeeper returns true if the bookkeeper is allowed to propose
+func CheckBookkeeper(pubKey keypair.PublicKey) bool {
+	return BookkeeperPolicy.IsAllowed(types.AddressFromPubKey(pubKey))
+}
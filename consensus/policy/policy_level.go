@@ -16,7 +16,7 @@
  * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
  */
 
-package consensus
+package policy
 
 type PolicyLevel byte
 
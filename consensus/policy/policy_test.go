@@ -0,0 +1,166 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package policy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPolicyLevels(t *testing.T) {
+	addr1 := common.Address{1}
+	addr2 := common.Address{2}
+	cfg := &config.PolicyConfig{
+		List: []string{addr1.ToBase58()},
+	}
+
+	cfg.PolicyLevel = byte(AllowAll)
+	p := NewPolicy(TX_POLICY_NAME, cfg)
+	assert.Nil(t, p.Refresh(nil))
+	assert.True(t, p.IsAllowed(addr1))
+	assert.True(t, p.IsAllowed(addr2))
+
+	cfg.PolicyLevel = byte(DenyAll)
+	assert.Nil(t, p.Refresh(nil))
+	assert.False(t, p.IsAllowed(addr1))
+	assert.False(t, p.IsAllowed(addr2))
+
+	cfg.PolicyLevel = byte(AllowList)
+	assert.Nil(t, p.Refresh(nil))
+	assert.True(t, p.IsAllowed(addr1))
+	assert.False(t, p.IsAllowed(addr2))
+
+	cfg.PolicyLevel = byte(DenyList)
+	assert.Nil(t, p.Refresh(nil))
+	assert.False(t, p.IsAllowed(addr1))
+	assert.True(t, p.IsAllowed(addr2))
+}
+
+func TestPolicyRefreshError(t *testing.T) {
+	p := NewPolicy(BOOKKEEPER_POLICY_NAME, &config.PolicyConfig{
+		PolicyLevel: byte(DenyList) + 1,
+	})
+	assert.NotNil(t, p.Refresh(nil))
+
+	p = NewPolicy(BOOKKEEPER_POLICY_NAME, &config.PolicyConfig{
+		PolicyLevel: byte(AllowList),
+		List:        []string{"invalid"},
+	})
+	assert.NotNil(t, p.Refresh(nil))
+}
+
+func TestNilPolicy(t *testing.T) {
+	var p *Policy
+	assert.True(t, p.IsAllowed(common.Address{1}))
+}
+
+func TestTxChecker(t *testing.T) {
+	addr1 := common.Address{1}
+	addr2 := common.Address{2}
+	p := NewPolicy(TX_POLICY_NAME, &config.PolicyConfig{
+		PolicyLevel: byte(DenyList),
+		List:        []string{addr2.ToBase58()},
+		MaxTxSize:   100,
+		PayerQuota:  2,
+	})
+	assert.Nil(t, p.Refresh(nil))
+	assert.Equal(t, uint32(100), p.MaxTxSize)
+	assert.Equal(t, uint32(2), p.PayerQuota)
+
+	checker := p.NewTxChecker()
+	assert.Nil(t, checker.Check(&types.Transaction{Payer: addr1, Raw: make([]byte, 100)}))
+	assert.NotNil(t, checker.Check(&types.Transaction{Payer: addr1, Raw: make([]byte, 101)}))
+	assert.NotNil(t, checker.Check(&types.Transaction{Payer: addr2, Raw: make([]byte, 10)}))
+	assert.Nil(t, checker.Check(&types.Transaction{Payer: addr1, Raw: make([]byte, 10)}))
+	assert.NotNil(t, checker.Check(&types.Transaction{Payer: addr1, Raw: make([]byte, 10)}))
+
+	// the quota is counted per block
+	checker = p.NewTxChecker()
+	assert.Nil(t, checker.Check(&types.Transaction{Payer: addr1, Raw: make([]byte, 10)}))
+
+	var nilPolicy *Policy
+	assert.Nil(t, nilPolicy.NewTxChecker().Check(&types.Transaction{Payer: addr2}))
+}
+
+func TestParseLimit(t *testing.T) {
+	limit, err := parseLimit("", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(5), limit)
+
+	limit, err = parseLimit("0", 5)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(0), limit)
+
+	_, err = parseLimit("-1", 5)
+	assert.NotNil(t, err)
+}
+
+func TestParamsCache(t *testing.T) {
+	queries := 0
+	fail := false
+	cache := &paramsCache{query: func(lgr *ledger.Ledger, names ...string) ([]string, error) {
+		queries++
+		if fail {
+			return nil, fmt.Errorf("query failed")
+		}
+		values := make([]string, len(names))
+		for i, name := range names {
+			values[i] = fmt.Sprintf("%s@%d", name, queries)
+		}
+		return values, nil
+	}}
+
+	// the params of both policies are queried once for each height
+	values, err := cache.get(nil, 1, TX_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"txPolicyLevel@1", "txPolicyList@1", "txPolicyMaxTxSize@1", "txPolicyPayerQuota@1"}, values)
+	values, err = cache.get(nil, 1, BOOKKEEPER_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, "bookkeeperPolicyLevel@1", values[0])
+	_, err = cache.get(nil, 1, TX_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, queries)
+
+	values, err = cache.get(nil, 2, TX_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, "txPolicyLevel@2", values[0])
+	assert.Equal(t, 2, queries)
+
+	// the failed query is not cached
+	fail = true
+	_, err = cache.get(nil, 3, TX_POLICY_NAME)
+	assert.NotNil(t, err)
+	fail = false
+	values, err = cache.get(nil, 3, TX_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, "txPolicyLevel@4", values[0])
+	assert.Equal(t, 4, queries)
+
+	// the params of another ledger at the same height are queried from it
+	values, err = cache.get(&ledger.Ledger{}, 3, TX_POLICY_NAME)
+	assert.Nil(t, err)
+	assert.Equal(t, "txPolicyLevel@5", values[0])
+	assert.Equal(t, 5, queries)
+}
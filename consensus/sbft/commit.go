@@ -0,0 +1,73 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+)
+
+// Commit is broadcast once a bookkeeper is prepared. The signature is made
+// over the block hash, so a quorum of commits forms the block signatures.
+type Commit struct {
+	msgData   ConsensusMessageData
+	BlockHash common.Uint256
+	Signature []byte
+}
+
+func (c *Commit) Serialization(sink *common.ZeroCopySink) {
+	c.msgData.Serialization(sink)
+	sink.WriteHash(c.BlockHash)
+	sink.WriteVarBytes(c.Signature)
+}
+
+func (c *Commit) Deserialization(source *common.ZeroCopySource) error {
+	err := c.msgData.Deserialization(source)
+	if err != nil {
+		return err
+	}
+
+	var eof, irregular bool
+	c.BlockHash, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	c.Signature, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func (c *Commit) Type() ConsensusMessageType {
+	return c.ConsensusMessageData().Type
+}
+
+func (c *Commit) ViewNumber() byte {
+	return c.msgData.ViewNumber
+}
+
+func (c *Commit) ConsensusMessageData() *ConsensusMessageData {
+	return &(c.msgData)
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/types"
+	msg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+const ContextVersion uint32 = 0
+
+type ConsensusContext struct {
+	State           ConsensusState
+	PrevHash        common.Uint256
+	Height          uint32
+	ViewNumber      byte
+	Bookkeepers     []keypair.PublicKey
+	Owner           keypair.PublicKey
+	BookkeeperIndex int
+	PrimaryIndex    uint32
+	Timestamp       uint32
+	Nonce           uint64
+	NextBookkeeper  common.Address
+	Transactions    []*types.Transaction
+	ExpectedView    []byte
+
+	// messages of the current view
+	Proposal *msg.ConsensusPayload
+	Prepares []*msg.ConsensusPayload
+
+	// messages of the current height, kept across view changes
+	Commits     []*Commit
+	ViewChanges []*msg.ConsensusPayload
+
+	// the highest proposal this node has been prepared on at current height
+	PreparedView     byte
+	PreparedProposal *msg.ConsensusPayload
+	PreparedProof    []*msg.ConsensusPayload
+
+	header *types.Block
+}
+
+func (ctx *ConsensusContext) M() int {
+	return len(ctx.Bookkeepers) - (len(ctx.Bookkeepers)-1)/3
+}
+
+func NewConsensusContext() *ConsensusContext {
+	return &ConsensusContext{}
+}
+
+func (ctx *ConsensusContext) GetPrimaryIndex(viewNum byte) uint32 {
+	return (ctx.Height + uint32(viewNum)) % uint32(len(ctx.Bookkeepers))
+}
+
+func (ctx *ConsensusContext) ChangeView(viewNum byte) {
+	ctx.State = Initial
+	ctx.ViewNumber = viewNum
+	ctx.PrimaryIndex = ctx.GetPrimaryIndex(viewNum)
+	ctx.Timestamp = 0
+	ctx.Nonce = 0
+	ctx.Transactions = nil
+	ctx.Proposal = nil
+	ctx.Prepares = make([]*msg.ConsensusPayload, len(ctx.Bookkeepers))
+	ctx.header = nil
+}
+
+func (ctx *ConsensusContext) BuildHeader(timestamp uint32, nonce uint64, nextBookkeeper common.Address,
+	txs []*types.Transaction) *types.Block {
+	txHash := make([]common.Uint256, 0, len(txs))
+	for _, t := range txs {
+		txHash = append(txHash, t.Hash())
+	}
+	txRoot := common.ComputeMerkleRoot(txHash)
+	blockRoot := ledger.DefLedger.GetBlockRootWithNewTxRoots(ctx.Height, []common.Uint256{txRoot})
+	stateRoot, err := ledger.DefLedger.GetHeaderStateRoot(ctx.Height)
+	if err != nil {
+		log.Errorf("GetHeaderStateRoot height:%d error:%s", ctx.Height, err)
+	}
+	header := &types.Header{
+		Version:          types.HeaderVersion(ctx.Height),
+		PrevBlockHash:    ctx.PrevHash,
+		TransactionsRoot: txRoot,
+		BlockRoot:        blockRoot,
+		Timestamp:        timestamp,
+		Height:           ctx.Height,
+		ConsensusData:    nonce,
+		NextBookkeeper:   nextBookkeeper,
+		StateRoot:        stateRoot,
+	}
+	return &types.Block{
+		Header:       header,
+		Transactions: []*types.Transaction{},
+	}
+}
+
+func (ctx *ConsensusContext) MakeHeader() *types.Block {
+	if ctx.header == nil {
+		ctx.header = ctx.BuildHeader(ctx.Timestamp, ctx.Nonce, ctx.NextBookkeeper, ctx.Transactions)
+	}
+	return ctx.header
+}
+
+func (ctx *ConsensusContext) MakePayload(message ConsensusMessage) *msg.ConsensusPayload {
+	message.ConsensusMessageData().ViewNumber = ctx.ViewNumber
+	sink := common.NewZeroCopySink(nil)
+	message.Serialization(sink)
+	return &msg.ConsensusPayload{
+		Version:         ContextVersion,
+		PrevHash:        ctx.PrevHash,
+		Height:          ctx.Height,
+		BookkeeperIndex: uint16(ctx.BookkeeperIndex),
+		Timestamp:       ctx.Timestamp,
+		Data:            sink.Bytes(),
+		Owner:           ctx.Owner,
+	}
+}
+
+func (ctx *ConsensusContext) MakePrePrepare(justify []*msg.ConsensusPayload) *msg.ConsensusPayload {
+	pp := &PrePrepare{
+		Timestamp:      ctx.Timestamp,
+		Nonce:          ctx.Nonce,
+		NextBookkeeper: ctx.NextBookkeeper,
+		Transactions:   ctx.Transactions,
+		Justify:        justify,
+	}
+	pp.msgData.Type = PrePrepareMsg
+	return ctx.MakePayload(pp)
+}
+
+func (ctx *ConsensusContext) MakePrepare(blockHash common.Uint256) *msg.ConsensusPayload {
+	p := &Prepare{
+		BlockHash: blockHash,
+	}
+	p.msgData.Type = PrepareMsg
+	return ctx.MakePayload(p)
+}
+
+func (ctx *ConsensusContext) MakeCommit(blockHash common.Uint256, signature []byte) (*Commit, *msg.ConsensusPayload) {
+	c := &Commit{
+		BlockHash: blockHash,
+		Signature: signature,
+	}
+	c.msgData.Type = CommitMsg
+	return c, ctx.MakePayload(c)
+}
+
+func (ctx *ConsensusContext) MakeViewChange() *msg.ConsensusPayload {
+	vc := &ViewChange{
+		NewViewNumber:    ctx.ExpectedView[ctx.BookkeeperIndex],
+		PreparedProposal: ctx.PreparedProposal,
+		PreparedProof:    ctx.PreparedProof,
+	}
+	vc.msgData.Type = ViewChangeMsg
+	return ctx.MakePayload(vc)
+}
+
+// GetPrepares returns the prepare payloads of current view voting for blockHash
+func (ctx *ConsensusContext) GetPrepares(blockHash common.Uint256) []*msg.ConsensusPayload {
+	prepares := make([]*msg.ConsensusPayload, 0, len(ctx.Prepares))
+	for _, payload := range ctx.Prepares {
+		if payload == nil {
+			continue
+		}
+		message, err := DeserializeMessage(payload.Data)
+		if err != nil {
+			continue
+		}
+		if p, ok := message.(*Prepare); ok && p.BlockHash == blockHash {
+			prepares = append(prepares, payload)
+		}
+	}
+	return prepares
+}
+
+// GetCommitSignatures returns the commit signatures for blockHash in bookkeeper order
+func (ctx *ConsensusContext) GetCommitSignatures(blockHash common.Uint256) [][]byte {
+	sigs := make([][]byte, 0, len(ctx.Commits))
+	for _, c := range ctx.Commits {
+		if c != nil && c.BlockHash == blockHash {
+			sigs = append(sigs, c.Signature)
+		}
+	}
+	return sigs
+}
+
+// GetViewChanges returns the view change payloads requesting viewNum
+func (ctx *ConsensusContext) GetViewChanges(viewNum byte) []*msg.ConsensusPayload {
+	payloads := make([]*msg.ConsensusPayload, 0, len(ctx.ViewChanges))
+	for i, payload := range ctx.ViewChanges {
+		if payload != nil && ctx.ExpectedView[i] == viewNum {
+			payloads = append(payloads, payload)
+		}
+	}
+	return payloads
+}
+
+func (ctx *ConsensusContext) GetStateDetail() string {
+
+	return fmt.Sprintf("Initial: %t, Primary: %t, Backup: %t, RequestSent: %t, RequestReceived: %t, Prepared: %t, CommitSent: %t, BlockGenerated: %t, ",
+		ctx.State.HasFlag(Initial),
+		ctx.State.HasFlag(Primary),
+		ctx.State.HasFlag(Backup),
+		ctx.State.HasFlag(RequestSent),
+		ctx.State.HasFlag(RequestReceived),
+		ctx.State.HasFlag(Prepared),
+		ctx.State.HasFlag(CommitSent),
+		ctx.State.HasFlag(BlockGenerated))
+
+}
+
+func (ctx *ConsensusContext) Reset(bkAccount *account.Account, bookkeepers []keypair.PublicKey) {
+	ctx.Bookkeepers = bookkeepers
+	ctx.PrevHash = ledger.DefLedger.GetCurrentBlockHash()
+	ctx.Height = ledger.DefLedger.GetCurrentBlockHeight() + 1
+	ctx.BookkeeperIndex = -1
+	ctx.Owner = nil
+	bookkeeperLen := len(ctx.Bookkeepers)
+	ctx.ExpectedView = make([]byte, bookkeeperLen)
+	ctx.Commits = make([]*Commit, bookkeeperLen)
+	ctx.ViewChanges = make([]*msg.ConsensusPayload, bookkeeperLen)
+	ctx.PreparedView = 0
+	ctx.PreparedProposal = nil
+	ctx.PreparedProof = nil
+	ctx.ChangeView(0)
+
+	log.Debugf("bookkeepers number: %d", bookkeeperLen)
+	for i := 0; i < bookkeeperLen; i++ {
+		if keypair.ComparePublicKey(bkAccount.PublicKey, ctx.Bookkeepers[i]) {
+			log.Debugf("this node is bookkeeper %d", i)
+			ctx.BookkeeperIndex = i
+			ctx.Owner = ctx.Bookkeepers[i]
+			break
+		}
+	}
+}
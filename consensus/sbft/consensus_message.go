@@ -0,0 +1,105 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"errors"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+)
+
+type ConsensusMessage interface {
+	Serialization(sink *common.ZeroCopySink)
+	Deserialization(source *common.ZeroCopySource) error
+	Type() ConsensusMessageType
+	ViewNumber() byte
+	ConsensusMessageData() *ConsensusMessageData
+}
+
+type ConsensusMessageData struct {
+	Type       ConsensusMessageType
+	ViewNumber byte
+}
+
+func DeserializeMessage(data []byte) (ConsensusMessage, error) {
+	if len(data) == 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	msgType := ConsensusMessageType(data[0])
+
+	source := common.NewZeroCopySource(data)
+	switch msgType {
+	case PrePrepareMsg:
+		ppMsg := &PrePrepare{}
+		err := ppMsg.Deserialization(source)
+		if err != nil {
+			log.Error("[DeserializeMessage] PrePrepareMsg Deserialize Error: ", err.Error())
+			return nil, err
+		}
+		return ppMsg, nil
+	case PrepareMsg:
+		pMsg := &Prepare{}
+		err := pMsg.Deserialization(source)
+		if err != nil {
+			log.Error("[DeserializeMessage] PrepareMsg Deserialize Error: ", err.Error())
+			return nil, err
+		}
+		return pMsg, nil
+	case CommitMsg:
+		cMsg := &Commit{}
+		err := cMsg.Deserialization(source)
+		if err != nil {
+			log.Error("[DeserializeMessage] CommitMsg Deserialize Error: ", err.Error())
+			return nil, err
+		}
+		return cMsg, nil
+	case ViewChangeMsg:
+		vc := &ViewChange{}
+		err := vc.Deserialization(source)
+		if err != nil {
+			log.Error("[DeserializeMessage] ViewChangeMsg Deserialize Error: ", err.Error())
+			return nil, err
+		}
+		return vc, nil
+	}
+
+	return nil, errors.New("The message is invalid.")
+}
+
+func (cd *ConsensusMessageData) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteByte(byte(cd.Type))
+	sink.WriteByte(byte(cd.ViewNumber))
+}
+
+// read data to reader
+func (cd *ConsensusMessageData) Deserialization(source *common.ZeroCopySource) error {
+	var eof bool
+	var temp byte
+	temp, eof = source.NextByte()
+	cd.Type = ConsensusMessageType(temp)
+	cd.ViewNumber, eof = source.NextByte()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
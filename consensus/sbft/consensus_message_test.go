@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/signature"
+	msg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func signedPayload(t *testing.T, acc *account.Account, index uint16, message ConsensusMessage) *msg.ConsensusPayload {
+	sink := common.NewZeroCopySink(nil)
+	message.Serialization(sink)
+	payload := &msg.ConsensusPayload{
+		Version:         ContextVersion,
+		Height:          10,
+		BookkeeperIndex: index,
+		Data:            sink.Bytes(),
+		Owner:           acc.PublicKey,
+	}
+	buf := new(bytes.Buffer)
+	payload.SerializeUnsigned(buf)
+	sig, err := signature.Sign(acc, buf.Bytes())
+	assert.Nil(t, err)
+	payload.Signature = sig
+	return payload
+}
+
+func TestPrePrepareSerialization(t *testing.T) {
+	acc := account.NewAccount("")
+	vc := &ViewChange{NewViewNumber: 1}
+	vc.msgData.Type = ViewChangeMsg
+	pp := &PrePrepare{
+		Timestamp:      12345,
+		Nonce:          678,
+		NextBookkeeper: acc.Address,
+		Justify:        []*msg.ConsensusPayload{signedPayload(t, acc, 2, vc)},
+	}
+	pp.msgData = ConsensusMessageData{Type: PrePrepareMsg, ViewNumber: 1}
+
+	sink := common.NewZeroCopySink(nil)
+	pp.Serialization(sink)
+	message, err := DeserializeMessage(sink.Bytes())
+	assert.Nil(t, err)
+	pp2, ok := message.(*PrePrepare)
+	assert.True(t, ok)
+	assert.Equal(t, byte(1), pp2.ViewNumber())
+	assert.Equal(t, pp.Timestamp, pp2.Timestamp)
+	assert.Equal(t, pp.Nonce, pp2.Nonce)
+	assert.Equal(t, pp.NextBookkeeper, pp2.NextBookkeeper)
+	assert.Equal(t, 1, len(pp2.Justify))
+	assert.Nil(t, pp2.Justify[0].Verify())
+	assert.Equal(t, uint16(2), pp2.Justify[0].BookkeeperIndex)
+}
+
+func TestViewChangeSerialization(t *testing.T) {
+	acc := account.NewAccount("")
+	hash := common.Uint256{1, 2, 3}
+
+	pp := &PrePrepare{Timestamp: 1}
+	pp.msgData.Type = PrePrepareMsg
+	p := &Prepare{BlockHash: hash}
+	p.msgData.Type = PrepareMsg
+	vc := &ViewChange{
+		NewViewNumber:    2,
+		PreparedProposal: signedPayload(t, acc, 0, pp),
+		PreparedProof:    []*msg.ConsensusPayload{signedPayload(t, acc, 0, p), signedPayload(t, acc, 1, p)},
+	}
+	vc.msgData = ConsensusMessageData{Type: ViewChangeMsg, ViewNumber: 1}
+
+	sink := common.NewZeroCopySink(nil)
+	vc.Serialization(sink)
+	message, err := DeserializeMessage(sink.Bytes())
+	assert.Nil(t, err)
+	vc2, ok := message.(*ViewChange)
+	assert.True(t, ok)
+	assert.Equal(t, byte(2), vc2.NewViewNumber)
+	assert.NotNil(t, vc2.PreparedProposal)
+	assert.Nil(t, vc2.PreparedProposal.Verify())
+	assert.Equal(t, 2, len(vc2.PreparedProof))
+
+	proof, err := DeserializeMessage(vc2.PreparedProof[1].Data)
+	assert.Nil(t, err)
+	assert.Equal(t, hash, proof.(*Prepare).BlockHash)
+
+	empty := &ViewChange{NewViewNumber: 3}
+	empty.msgData.Type = ViewChangeMsg
+	sink.Reset()
+	empty.Serialization(sink)
+	message, err = DeserializeMessage(sink.Bytes())
+	assert.Nil(t, err)
+	assert.Nil(t, message.(*ViewChange).PreparedProposal)
+}
+
+func TestCommitSerialization(t *testing.T) {
+	c := &Commit{BlockHash: common.Uint256{9}, Signature: []byte{1, 2, 3}}
+	c.msgData = ConsensusMessageData{Type: CommitMsg, ViewNumber: 4}
+
+	sink := common.NewZeroCopySink(nil)
+	c.Serialization(sink)
+	message, err := DeserializeMessage(sink.Bytes())
+	assert.Nil(t, err)
+	c2 := message.(*Commit)
+	assert.Equal(t, byte(4), c2.ViewNumber())
+	assert.Equal(t, c.BlockHash, c2.BlockHash)
+	assert.Equal(t, c.Signature, c2.Signature)
+}
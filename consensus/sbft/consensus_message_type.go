@@ -16,28 +16,13 @@
  * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
  */
 
-package consensus
+package sbft
 
-import (
-	"github.com/dnaproject2/DNA/common"
-)
-
-type Policy struct {
-	PolicyLevel PolicyLevel
-	List        []common.Address
-}
-
-func NewPolicy() *Policy {
-	return &Policy{}
-}
+type ConsensusMessageType byte
 
-func (p *Policy) Refresh() {
-	//TODO: Refresh
-}
-
-var DefaultPolicy *Policy
-
-func InitPolicy() {
-	DefaultPolicy := NewPolicy()
-	DefaultPolicy.Refresh()
-}
+const (
+	ViewChangeMsg ConsensusMessageType = 0x00
+	PrePrepareMsg ConsensusMessageType = 0x20
+	PrepareMsg    ConsensusMessageType = 0x21
+	CommitMsg     ConsensusMessageType = 0x22
+)
@@ -0,0 +1,124 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/types"
+	msg "github.com/dnaproject2/DNA/p2pserver/message/types"
+)
+
+// PrePrepare is sent by the primary of a view to propose the block of the
+// current height. For views above zero it carries the signed view change
+// payloads which justify the new view.
+type PrePrepare struct {
+	msgData        ConsensusMessageData
+	Timestamp      uint32
+	Nonce          uint64
+	NextBookkeeper common.Address
+	Transactions   []*types.Transaction
+	Justify        []*msg.ConsensusPayload
+}
+
+func (pp *PrePrepare) Serialization(sink *common.ZeroCopySink) {
+	pp.msgData.Serialization(sink)
+	sink.WriteUint32(pp.Timestamp)
+	sink.WriteVarUint(pp.Nonce)
+	sink.WriteAddress(pp.NextBookkeeper)
+	sink.WriteVarUint(uint64(len(pp.Transactions)))
+	for _, t := range pp.Transactions {
+		t.Serialization(sink)
+	}
+	sink.WriteVarUint(uint64(len(pp.Justify)))
+	for _, p := range pp.Justify {
+		p.Serialization(sink)
+	}
+}
+
+func (pp *PrePrepare) Deserialization(source *common.ZeroCopySource) error {
+	pp.msgData = ConsensusMessageData{}
+	err := pp.msgData.Deserialization(source)
+	if err != nil {
+		return err
+	}
+
+	var eof, irregular bool
+	pp.Timestamp, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	pp.Nonce, _, irregular, eof = source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	pp.NextBookkeeper, eof = source.NextAddress()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	length, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	for i := 0; i < int(length); i++ {
+		var t types.Transaction
+		if err := t.Deserialization(source); err != nil {
+			return fmt.Errorf("[PrePrepare] transactions deserialization failed: %s", err)
+		}
+		pp.Transactions = append(pp.Transactions, &t)
+	}
+
+	length, _, irregular, eof = source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	for i := 0; i < int(length); i++ {
+		p := &msg.ConsensusPayload{}
+		if err := p.Deserialization(source); err != nil {
+			return fmt.Errorf("[PrePrepare] justify deserialization failed: %s", err)
+		}
+		pp.Justify = append(pp.Justify, p)
+	}
+
+	return nil
+}
+
+func (pp *PrePrepare) Type() ConsensusMessageType {
+	return pp.ConsensusMessageData().Type
+}
+
+func (pp *PrePrepare) ViewNumber() byte {
+	return pp.msgData.ViewNumber
+}
+
+func (pp *PrePrepare) ConsensusMessageData() *ConsensusMessageData {
+	return &(pp.msgData)
+}
@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+)
+
+// Prepare is broadcast by every bookkeeper which accepted the proposal of
+// the current view.
+type Prepare struct {
+	msgData   ConsensusMessageData
+	BlockHash common.Uint256
+}
+
+func (p *Prepare) Serialization(sink *common.ZeroCopySink) {
+	p.msgData.Serialization(sink)
+	sink.WriteHash(p.BlockHash)
+}
+
+func (p *Prepare) Deserialization(source *common.ZeroCopySource) error {
+	err := p.msgData.Deserialization(source)
+	if err != nil {
+		return err
+	}
+
+	var eof bool
+	p.BlockHash, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+func (p *Prepare) Type() ConsensusMessageType {
+	return p.ConsensusMessageData().Type
+}
+
+func (p *Prepare) ViewNumber() byte {
+	return p.msgData.ViewNumber
+}
+
+func (p *Prepare) ConsensusMessageData() *ConsensusMessageData {
+	return &(p.msgData)
+}
@@ -18,13 +18,717 @@
 
 package sbft
 
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	actorTypes "github.com/dnaproject2/DNA/consensus/actor"
+	"github.com/dnaproject2/DNA/consensus/policy"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/signature"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/events"
+	"github.com/dnaproject2/DNA/events/message"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/dnaproject2/DNA/validator/increment"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/ontio/ontology-eventbus/actor"
+)
+
+/*
+*Simple BFT consensus for a fixed set of bookkeepers. Each height runs the
+*pbft three phases pre-prepare/prepare/commit, and a view change moves the
+*primary to the next bookkeeper when the current one fails to make progress.
+ */
 type SbftService struct {
+	context           ConsensusContext
+	Account           *account.Account
+	bookkeepers       []keypair.PublicKey
+	nextBookkeeper    common.Address
+	genBlockTime      time.Duration
+	timer             *time.Timer
+	timerHeight       uint32
+	timeView          byte
+	blockReceivedTime time.Time
+	started           bool
+	ledger            *ledger.Ledger
+	incrValidator     *increment.IncrementValidator
+	poolActor         *actorTypes.TxPoolActor
+	p2p               *actorTypes.P2PActor
+
+	pid *actor.PID
+	sub *events.ActorSubscriber
+}
+
+func NewSbftService(bkAccount *account.Account, txpool, p2p *actor.PID) (*SbftService, error) {
+	bookkeepers, err := config.DefConfig.GetBookkeepers()
+	if err != nil {
+		return nil, fmt.Errorf("GetBookkeepers error:%s", err)
+	}
+	if len(bookkeepers) == 0 {
+		return nil, fmt.Errorf("sbft bookkeepers is empty")
+	}
+	nextBookkeeper, err := types.AddressFromBookkeepers(bookkeepers)
+	if err != nil {
+		return nil, fmt.Errorf("GetBookkeeperAddress error:%s", err)
+	}
+	service := &SbftService{
+		Account:        bkAccount,
+		bookkeepers:    bookkeepers,
+		nextBookkeeper: nextBookkeeper,
+		genBlockTime:   time.Duration(config.DEFAULT_GEN_BLOCK_TIME) * time.Second,
+		timer:          time.NewTimer(time.Second * 15),
+		started:        false,
+		ledger:         ledger.DefLedger,
+		incrValidator:  increment.NewIncrementValidator(20),
+		poolActor:      &actorTypes.TxPoolActor{Pool: txpool},
+		p2p:            &actorTypes.P2PActor{P2P: p2p},
+	}
+
+	if !service.timer.Stop() {
+		<-service.timer.C
+	}
+
+	go func() {
+		for {
+			select {
+			case <-service.timer.C:
+				log.Debug("******Get a timeout notice")
+				service.pid.Tell(&actorTypes.TimeOut{})
+			}
+		}
+	}()
+
+	props := actor.FromProducer(func() actor.Actor {
+		return service
+	})
+
+	pid, err := actor.SpawnNamed(props, "consensus_sbft")
+	service.pid = pid
+
+	service.sub = events.NewActorSubscriber(pid)
+	return service, err
+}
+
+func (this *SbftService) Receive(context actor.Context) {
+	if _, ok := context.Message().(*actorTypes.StartConsensus); this.started == false && ok == false {
+		return
+	}
+
+	switch msg := context.Message().(type) {
+	case *actor.Restarting:
+		log.Warn("sbft actor restarting")
+	case *actor.Stopping:
+		log.Warn("sbft actor stopping")
+	case *actor.Stopped:
+		log.Warn("sbft actor stopped")
+	case *actor.Started:
+		log.Warn("sbft actor started")
+	case *actor.Restart:
+		log.Warn("sbft actor restart")
+	case *actorTypes.StartConsensus:
+		this.start()
+	case *actorTypes.StopConsensus:
+		this.incrValidator.Clean()
+		this.halt()
+	case *actorTypes.TimeOut:
+		log.Info("sbft receive timeout")
+		this.Timeout()
+	case *message.SaveBlockCompleteMsg:
+		log.Infof("sbft actor receives block complete event. block height=%d, numtx=%d",
+			msg.Block.Header.Height, len(msg.Block.Transactions))
+		this.incrValidator.AddBlock(msg.Block)
+		this.handleBlockPersistCompleted(msg.Block)
+	case *p2pmsg.ConsensusPayload:
+		this.NewConsensusPayload(msg)
+
+	default:
+		log.Info("sbft actor: Unknown msg ", msg, "type", reflect.TypeOf(msg))
+	}
+}
+
+func (this *SbftService) GetPID() *actor.PID {
+	return this.pid
+}
+
+func (this *SbftService) Start() error {
+	this.pid.Tell(&actorTypes.StartConsensus{})
+	return nil
+}
+
+func (this *SbftService) Halt() error {
+	this.pid.Tell(&actorTypes.StopConsensus{})
+	return nil
+}
+
+func (self *SbftService) handleBlockPersistCompleted(block *types.Block) {
+	log.Infof("persist block: %x", block.Hash())
+	self.p2p.Broadcast(block.Hash())
+
+	policy.RefreshBookkeeperPolicy()
+	self.InitializeConsensus(0)
 }
 
-func (ss *SbftService) Halt() error {
+func (ss *SbftService) start() {
+	ss.started = true
+
+	if config.DefConfig.Genesis.SBFT.GenBlockTime > config.MIN_GEN_BLOCK_TIME {
+		ss.genBlockTime = time.Duration(config.DefConfig.Genesis.SBFT.GenBlockTime) * time.Second
+	} else {
+		log.Warn("The Generate block time should be longer than 2 seconds, so set it to be default 6 seconds.")
+	}
+
+	ss.sub.Subscribe(message.TOPIC_SAVE_BLOCK_COMPLETE)
+
+	ss.InitializeConsensus(0)
+}
+
+func (ss *SbftService) halt() error {
+	log.Info("SBFT Stop")
+	if ss.timer != nil {
+		ss.timer.Stop()
+	}
+
+	if ss.started {
+		ss.sub.Unsubscribe(message.TOPIC_SAVE_BLOCK_COMPLETE)
+	}
 	return nil
 }
 
-func (ds *SbftService) Start() error {
+func (ss *SbftService) InitializeConsensus(viewNum byte) error {
+	log.Debugf("[InitializeConsensus] viewNum: %d", viewNum)
+
+	if viewNum == 0 {
+		ss.context.Reset(ss.Account, ss.bookkeepers)
+	} else {
+		if ss.context.State.HasFlag(BlockGenerated) {
+			return nil
+		}
+		ss.context.ChangeView(viewNum)
+	}
+
+	if ss.context.BookkeeperIndex < 0 {
+		log.Info("You aren't bookkeeper")
+		return nil
+	}
+
+	ss.timerHeight = ss.context.Height
+	ss.timeView = viewNum
+	ss.timer.Stop()
+	if ss.context.BookkeeperIndex == int(ss.context.PrimaryIndex) {
+		//primary peer
+		ss.context.State |= Primary
+		span := time.Now().Sub(ss.blockReceivedTime)
+		if viewNum > 0 || span > ss.genBlockTime {
+			ss.timer.Reset(0)
+		} else {
+			ss.timer.Reset(ss.genBlockTime - span)
+		}
+	} else {
+		//backup peer
+		ss.context.State |= Backup
+		ss.timer.Reset(ss.genBlockTime << (viewNum + 1))
+	}
 	return nil
 }
+
+func (ss *SbftService) Timeout() {
+	if ss.timerHeight != ss.context.Height || ss.timeView != ss.context.ViewNumber {
+		return
+	}
+
+	log.Info("Timeout: height: ", ss.timerHeight, " View: ", ss.timeView, " State: ", ss.context.GetStateDetail())
+
+	if ss.context.State.HasFlag(BlockGenerated) {
+		return
+	}
+	if ss.context.State.HasFlag(Primary) && !ss.context.State.HasFlag(RequestSent) {
+		if err := ss.sendPrePrepare(); err != nil {
+			log.Errorf("[Timeout] send pre-prepare failed: %s", err)
+		}
+		ss.timer.Stop()
+		ss.timer.Reset(ss.genBlockTime << (ss.timeView + 1))
+	} else if ss.context.State.HasFlag(Primary) || ss.context.State.HasFlag(Backup) {
+		ss.RequestChangeView()
+	}
+}
+
+// validHeight returns the height from which the increment validator can
+// check the transactions of the block being proposed
+func (ss *SbftService) validHeight() uint32 {
+	height := ss.context.Height - 1
+	validHeight := height
+
+	start, end := ss.incrValidator.BlockRange()
+	if height+1 == end {
+		validHeight = start
+	} else {
+		ss.incrValidator.Clean()
+		log.Infof("incr validator block height %v != ledger block height %v", int(end)-1, height)
+	}
+	return validHeight
+}
+
+func (ss *SbftService) sendPrePrepare() error {
+	var justify []*p2pmsg.ConsensusPayload
+	var prepared *PrePrepare
+	if ss.context.ViewNumber > 0 {
+		justify = ss.context.GetViewChanges(ss.context.ViewNumber)
+		var err error
+		prepared, err = ss.verifyJustify(ss.context.ViewNumber, justify)
+		if err != nil {
+			return fmt.Errorf("new view justification: %s", err)
+		}
+	}
+
+	if prepared != nil {
+		// a proposal may have been committed in a previous view, propose it again
+		ss.context.Timestamp = prepared.Timestamp
+		ss.context.Nonce = prepared.Nonce
+		ss.context.NextBookkeeper = prepared.NextBookkeeper
+		ss.context.Transactions = prepared.Transactions
+	} else {
+		header, err := ss.ledger.GetHeaderByHash(ss.context.PrevHash)
+		if err != nil {
+			return fmt.Errorf("GetHeader PrevHash:%x error:%s", ss.context.PrevHash, err)
+		}
+		if header == nil {
+			return fmt.Errorf("cannot GetHeaderByHash by PrevHash:%x", ss.context.PrevHash)
+		}
+		now := uint32(time.Now().Unix())
+		blockTime := header.Timestamp + 1
+		if blockTime > now {
+			ss.context.Timestamp = blockTime
+		} else {
+			ss.context.Timestamp = now
+		}
+		ss.context.Nonce = common.GetNonce()
+		ss.context.NextBookkeeper = ss.nextBookkeeper
+
+		validHeight := ss.validHeight()
+		txs := ss.poolActor.GetTxnPool(true, validHeight)
+		transactions := make([]*types.Transaction, 0, len(txs))
+		for _, txEntry := range txs {
+			if err := ss.incrValidator.Verify(txEntry.Tx, validHeight); err == nil {
+				transactions = append(transactions, txEntry.Tx)
+			}
+		}
+		ss.context.Transactions = transactions
+	}
+	ss.context.header = nil
+	blockHash := ss.context.MakeHeader().Hash()
+
+	log.Infof("Send pre-prepare: height: %d View: %d tx: %d", ss.context.Height, ss.context.ViewNumber,
+		len(ss.context.Transactions))
+	payload := ss.context.MakePrePrepare(justify)
+	ss.SignAndRelay(payload)
+	ss.context.Proposal = payload
+	ss.context.State |= RequestSent
+	ss.blockReceivedTime = time.Now()
+
+	ss.sendPrepare(blockHash)
+	return nil
+}
+
+func (ss *SbftService) sendPrepare(blockHash common.Uint256) {
+	payload := ss.context.MakePrepare(blockHash)
+	ss.SignAndRelay(payload)
+	ss.context.Prepares[ss.context.BookkeeperIndex] = payload
+	ss.checkPrepares()
+}
+
+func (ss *SbftService) NewConsensusPayload(payload *p2pmsg.ConsensusPayload) {
+	//if payload from current peer, ignore it
+	if int(payload.BookkeeperIndex) == ss.context.BookkeeperIndex {
+		return
+	}
+
+	if ss.context.State.HasFlag(BlockGenerated) {
+		log.Debug("has flag 'BlockGenerated'")
+		return
+	}
+
+	if err := ss.verifyPayloadOrigin(payload); err != nil {
+		log.Debugf("invalid consensus payload: %s", err)
+		return
+	}
+
+	message, err := DeserializeMessage(payload.Data)
+	if err != nil {
+		log.Errorf("DeserializeMessage failed: %s", err)
+		return
+	}
+
+	// commits sign the block hash only, so they stay valid across views
+	if message.ViewNumber() != ss.context.ViewNumber && message.Type() != ViewChangeMsg && message.Type() != CommitMsg {
+		return
+	}
+
+	switch message.Type() {
+	case ViewChangeMsg:
+		if vc, ok := message.(*ViewChange); ok {
+			ss.ViewChangeReceived(payload, vc)
+		}
+	case PrePrepareMsg:
+		if pp, ok := message.(*PrePrepare); ok {
+			ss.PrePrepareReceived(payload, pp)
+		}
+	case PrepareMsg:
+		if p, ok := message.(*Prepare); ok {
+			ss.PrepareReceived(payload, p)
+		}
+	case CommitMsg:
+		if c, ok := message.(*Commit); ok {
+			ss.CommitReceived(payload, c)
+		}
+	default:
+		log.Warn("unknown consensus message type")
+	}
+}
+
+// verifyPayloadOrigin checks that the payload belongs to the current round
+// and is signed by the bookkeeper it claims to be sent from
+func (ss *SbftService) verifyPayloadOrigin(payload *p2pmsg.ConsensusPayload) error {
+	if payload.Version != ContextVersion || payload.PrevHash != ss.context.PrevHash || payload.Height != ss.context.Height {
+		return fmt.Errorf("unmatched height %d", payload.Height)
+	}
+	if int(payload.BookkeeperIndex) >= len(ss.context.Bookkeepers) {
+		return fmt.Errorf("bookkeeper index %d out of range", payload.BookkeeperIndex)
+	}
+	if !keypair.ComparePublicKey(payload.Owner, ss.context.Bookkeepers[payload.BookkeeperIndex]) {
+		return fmt.Errorf("payload owner is not bookkeeper %d", payload.BookkeeperIndex)
+	}
+	return payload.Verify()
+}
+
+// verifyPreparedCertificate checks the prepared proposal attached to a view
+// change and returns it, or nil if the sender was not prepared
+func (ss *SbftService) verifyPreparedCertificate(vc *ViewChange) (*PrePrepare, error) {
+	if vc.PreparedProposal == nil {
+		return nil, nil
+	}
+	if err := ss.verifyPayloadOrigin(vc.PreparedProposal); err != nil {
+		return nil, fmt.Errorf("prepared proposal: %s", err)
+	}
+	message, err := DeserializeMessage(vc.PreparedProposal.Data)
+	if err != nil {
+		return nil, err
+	}
+	pp, ok := message.(*PrePrepare)
+	if !ok {
+		return nil, fmt.Errorf("prepared proposal is not a pre-prepare")
+	}
+	if pp.ViewNumber() >= vc.NewViewNumber {
+		return nil, fmt.Errorf("prepared view %d not lower than new view %d", pp.ViewNumber(), vc.NewViewNumber)
+	}
+	if uint32(vc.PreparedProposal.BookkeeperIndex) != ss.context.GetPrimaryIndex(pp.ViewNumber()) {
+		return nil, fmt.Errorf("prepared proposal not sent by primary of view %d", pp.ViewNumber())
+	}
+
+	blockHash := ss.context.BuildHeader(pp.Timestamp, pp.Nonce, pp.NextBookkeeper, pp.Transactions).Hash()
+	voted := make(map[uint16]bool)
+	for _, payload := range vc.PreparedProof {
+		if err := ss.verifyPayloadOrigin(payload); err != nil {
+			return nil, fmt.Errorf("prepared proof: %s", err)
+		}
+		message, err := DeserializeMessage(payload.Data)
+		if err != nil {
+			return nil, err
+		}
+		p, ok := message.(*Prepare)
+		if !ok || p.ViewNumber() != pp.ViewNumber() || p.BlockHash != blockHash {
+			return nil, fmt.Errorf("prepared proof does not match proposal")
+		}
+		voted[payload.BookkeeperIndex] = true
+	}
+	if len(voted) < ss.context.M() {
+		return nil, fmt.Errorf("prepared proof has %d votes, need %d", len(voted), ss.context.M())
+	}
+	return pp, nil
+}
+
+// verifyJustify checks that a quorum of bookkeepers asked for viewNum and
+// returns the proposal prepared in the highest view among them, if any
+func (ss *SbftService) verifyJustify(viewNum byte, justify []*p2pmsg.ConsensusPayload) (*PrePrepare, error) {
+	var highest *PrePrepare
+	voted := make(map[uint16]bool)
+	for _, payload := range justify {
+		if err := ss.verifyPayloadOrigin(payload); err != nil {
+			return nil, err
+		}
+		message, err := DeserializeMessage(payload.Data)
+		if err != nil {
+			return nil, err
+		}
+		vc, ok := message.(*ViewChange)
+		if !ok || vc.NewViewNumber != viewNum {
+			return nil, fmt.Errorf("justify is not a view change to view %d", viewNum)
+		}
+		pp, err := ss.verifyPreparedCertificate(vc)
+		if err != nil {
+			return nil, err
+		}
+		if pp != nil && (highest == nil || pp.ViewNumber() > highest.ViewNumber()) {
+			highest = pp
+		}
+		voted[payload.BookkeeperIndex] = true
+	}
+	if len(voted) < ss.context.M() {
+		return nil, fmt.Errorf("justify has %d view changes, need %d", len(voted), ss.context.M())
+	}
+	return highest, nil
+}
+
+func (ss *SbftService) PrePrepareReceived(payload *p2pmsg.ConsensusPayload, message *PrePrepare) {
+	log.Infof("PrePrepare Received: height=%d View=%d index=%d tx=%d", payload.Height, message.ViewNumber(),
+		payload.BookkeeperIndex, len(message.Transactions))
+
+	if !ss.context.State.HasFlag(Backup) || ss.context.State.HasFlag(RequestReceived) {
+		return
+	}
+
+	if uint32(payload.BookkeeperIndex) != ss.context.PrimaryIndex {
+		return
+	}
+
+	if !policy.CheckBookkeeper(ss.context.Bookkeepers[payload.BookkeeperIndex]) {
+		log.Warnf("PrePrepareReceived bookkeeper %d denied by policy", payload.BookkeeperIndex)
+		ss.RequestChangeView()
+		return
+	}
+
+	header, err := ss.ledger.GetHeaderByHash(ss.context.PrevHash)
+	if err != nil || header == nil {
+		log.Errorf("PrePrepareReceived cannot GetHeaderByHash by PrevHash:%x", ss.context.PrevHash)
+		return
+	}
+	if message.Timestamp <= header.Timestamp || message.Timestamp > uint32(time.Now().Add(time.Minute*10).Unix()) {
+		log.Infof("PrePrepareReceived: Timestamp incorrect: %d", message.Timestamp)
+		return
+	}
+	if message.NextBookkeeper != ss.nextBookkeeper {
+		log.Error("[PrePrepareReceived] Unmatched NextBookkeeper")
+		ss.RequestChangeView()
+		return
+	}
+
+	blockHash := ss.context.BuildHeader(message.Timestamp, message.Nonce, message.NextBookkeeper, message.Transactions).Hash()
+	if message.ViewNumber() > 0 {
+		prepared, err := ss.verifyJustify(message.ViewNumber(), message.Justify)
+		if err != nil {
+			log.Warnf("PrePrepareReceived invalid new view justification: %s", err)
+			ss.RequestChangeView()
+			return
+		}
+		if prepared != nil {
+			preparedHash := ss.context.BuildHeader(prepared.Timestamp, prepared.Nonce, prepared.NextBookkeeper,
+				prepared.Transactions).Hash()
+			if preparedHash != blockHash {
+				log.Warn("PrePrepareReceived proposal does not match the prepared proposal of previous view")
+				ss.RequestChangeView()
+				return
+			}
+		}
+	}
+
+	if len(message.Transactions) > 0 {
+		validHeight := ss.validHeight()
+		if err := ss.poolActor.VerifyBlock(message.Transactions, validHeight); err != nil {
+			log.Error("PrePrepareReceived new transaction verification failed, will not sent Prepare", err)
+			ss.RequestChangeView()
+			return
+		}
+
+		for _, tx := range message.Transactions {
+			if err := ss.incrValidator.Verify(tx, validHeight); err != nil {
+				log.Error("PrePrepareReceived new transaction increment verification failed, will not sent Prepare", err)
+				ss.RequestChangeView()
+				return
+			}
+		}
+	}
+
+	ss.context.State |= RequestReceived
+	ss.context.Timestamp = message.Timestamp
+	ss.context.Nonce = message.Nonce
+	ss.context.NextBookkeeper = message.NextBookkeeper
+	ss.context.Transactions = message.Transactions
+	ss.context.Proposal = payload
+	ss.context.header = nil
+	ss.blockReceivedTime = time.Now()
+
+	log.Info("send prepare")
+	ss.sendPrepare(blockHash)
+	ss.checkCommits()
+}
+
+func (ss *SbftService) PrepareReceived(payload *p2pmsg.ConsensusPayload, message *Prepare) {
+	log.Infof("Prepare Received: height=%d View=%d index=%d", payload.Height, message.ViewNumber(), payload.BookkeeperIndex)
+
+	if ss.context.Prepares[payload.BookkeeperIndex] != nil {
+		return
+	}
+	ss.context.Prepares[payload.BookkeeperIndex] = payload
+	ss.checkPrepares()
+}
+
+// checkPrepares moves to the prepared state once a quorum of the current
+// view agrees on the proposal, and broadcasts the commit
+func (ss *SbftService) checkPrepares() {
+	if ss.context.Proposal == nil || ss.context.State.HasFlag(Prepared) {
+		return
+	}
+	blockHash := ss.context.MakeHeader().Hash()
+	prepares := ss.context.GetPrepares(blockHash)
+	if len(prepares) < ss.context.M() {
+		return
+	}
+
+	log.Infof("Prepared: height=%d View=%d", ss.context.Height, ss.context.ViewNumber)
+	ss.context.State |= Prepared
+	ss.context.PreparedView = ss.context.ViewNumber
+	ss.context.PreparedProposal = ss.context.Proposal
+	ss.context.PreparedProof = prepares
+
+	sig, err := signature.Sign(ss.Account, blockHash[:])
+	if err != nil {
+		log.Error("[SbftService] signing failed")
+		return
+	}
+	commit, payload := ss.context.MakeCommit(blockHash, sig)
+	ss.SignAndRelay(payload)
+	ss.context.Commits[ss.context.BookkeeperIndex] = commit
+	ss.context.State |= CommitSent
+
+	ss.checkCommits()
+}
+
+func (ss *SbftService) CommitReceived(payload *p2pmsg.ConsensusPayload, message *Commit) {
+	log.Infof("Commit Received: height=%d View=%d index=%d", payload.Height, message.ViewNumber(), payload.BookkeeperIndex)
+
+	prev := ss.context.Commits[payload.BookkeeperIndex]
+	if prev != nil && prev.BlockHash == message.BlockHash {
+		return
+	}
+	err := signature.Verify(ss.context.Bookkeepers[payload.BookkeeperIndex], message.BlockHash[:], message.Signature)
+	if err != nil {
+		log.Warn("CommitReceived VerifySignature failed.", err)
+		return
+	}
+	ss.context.Commits[payload.BookkeeperIndex] = message
+	ss.checkCommits()
+}
+
+// checkCommits persists the proposal once a quorum of commit signatures
+// for it has been collected
+func (ss *SbftService) checkCommits() {
+	if ss.context.Proposal == nil || ss.context.State.HasFlag(BlockGenerated) {
+		return
+	}
+	block := ss.context.MakeHeader()
+	blockHash := block.Hash()
+	sigs := ss.context.GetCommitSignatures(blockHash)
+	if len(sigs) < ss.context.M() {
+		return
+	}
+
+	block.Header.Bookkeepers = ss.context.Bookkeepers
+	block.Header.SigData = sigs[:ss.context.M()]
+	block.Transactions = ss.context.Transactions
+
+	isExist, err := ss.ledger.IsContainBlock(blockHash)
+	if err != nil {
+		log.Errorf("DefLedger.IsContainBlock Hash:%x error:%s", blockHash, err)
+		return
+	}
+	ss.context.State |= BlockGenerated
+	if isExist {
+		return
+	}
+	result, err := ss.ledger.ExecuteBlock(block)
+	if err != nil {
+		log.Errorf("checkCommits ExecuteBlock Height:%d error:%s", block.Header.Height, err)
+		return
+	}
+	err = ss.ledger.SubmitBlock(block, result)
+	if err != nil {
+		log.Errorf("checkCommits SubmitBlock Height:%d error:%s", block.Header.Height, err)
+		return
+	}
+	log.Infof("Commit finished: height=%d View=%d", ss.context.Height, ss.context.ViewNumber)
+}
+
+func (ss *SbftService) ViewChangeReceived(payload *p2pmsg.ConsensusPayload, message *ViewChange) {
+	log.Infof("View Change Received: height=%d View=%d index=%d nv=%d", payload.Height, message.ViewNumber(),
+		payload.BookkeeperIndex, message.NewViewNumber)
+
+	if message.NewViewNumber <= ss.context.ExpectedView[payload.BookkeeperIndex] {
+		return
+	}
+	if _, err := ss.verifyPreparedCertificate(message); err != nil {
+		log.Warnf("ViewChangeReceived invalid prepared certificate: %s", err)
+		return
+	}
+
+	ss.context.ExpectedView[payload.BookkeeperIndex] = message.NewViewNumber
+	ss.context.ViewChanges[payload.BookkeeperIndex] = payload
+
+	ss.CheckExpectedView(message.NewViewNumber)
+}
+
+func (ss *SbftService) CheckExpectedView(viewNumber byte) {
+	if ss.context.State.HasFlag(BlockGenerated) {
+		return
+	}
+	if ss.context.ViewNumber == viewNumber {
+		return
+	}
+
+	//check the count for same view number
+	count := 0
+	for _, expectedViewNumber := range ss.context.ExpectedView {
+		if expectedViewNumber == viewNumber {
+			count++
+		}
+	}
+
+	if count >= ss.context.M() {
+		log.Infof("[CheckExpectedView] change to view %d.", viewNumber)
+		ss.InitializeConsensus(viewNumber)
+	}
+}
+
+func (ss *SbftService) RequestChangeView() {
+	if ss.context.State.HasFlag(BlockGenerated) {
+		return
+	}
+	if ss.context.ViewNumber >= ss.context.ExpectedView[ss.context.BookkeeperIndex] {
+		ss.context.ExpectedView[ss.context.BookkeeperIndex] = ss.context.ViewNumber + 1
+	} else {
+		ss.context.ExpectedView[ss.context.BookkeeperIndex] += 1
+	}
+	log.Infof("Request change view: height=%d View=%d nv=%d state=%s", ss.context.Height,
+		ss.context.ViewNumber, ss.context.ExpectedView[ss.context.BookkeeperIndex], ss.context.GetStateDetail())
+
+	ss.timer.Stop()
+	ss.timer.Reset(ss.genBlockTime << (ss.context.ExpectedView[ss.context.BookkeeperIndex] + 1))
+
+	payload := ss.context.MakeViewChange()
+	ss.SignAndRelay(payload)
+	ss.context.ViewChanges[ss.context.BookkeeperIndex] = payload
+	ss.CheckExpectedView(ss.context.ExpectedView[ss.context.BookkeeperIndex])
+}
+
+func (ss *SbftService) SignAndRelay(payload *p2pmsg.ConsensusPayload) {
+	buf := new(bytes.Buffer)
+	payload.SerializeUnsigned(buf)
+	payload.Signature, _ = signature.Sign(ss.Account, buf.Bytes())
+
+	ss.p2p.Broadcast(payload)
+}
@@ -0,0 +1,351 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	actorTypes "github.com/dnaproject2/DNA/consensus/actor"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/core/types"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	txpool "github.com/dnaproject2/DNA/txnpool/common"
+	"github.com/dnaproject2/DNA/validator/increment"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/ontio/ontology-eventbus/actor"
+	"github.com/stretchr/testify/assert"
+)
+
+// testNet runs the sbft services of all bookkeepers on one shared ledger.
+// The broadcast payloads are queued and delivered by the test, so every
+// phase of the protocol can be checked in between.
+type testNet struct {
+	services []*SbftService
+	queue    chan *p2pmsg.ConsensusPayload
+	ledger   *ledger.Ledger
+	pids     []*actor.PID
+	restore  func()
+}
+
+func newTestNet(t *testing.T, n int) *testNet {
+	dataDir, err := ioutil.TempDir("", "sbft")
+	assert.Nil(t, err)
+
+	accounts := make([]*account.Account, n)
+	keys := make([]string, n)
+	for i := range accounts {
+		accounts[i] = account.NewAccount("")
+		keys[i] = hex.EncodeToString(keypair.SerializePublicKey(accounts[i].PublicKey))
+	}
+	oldGenesis, oldCommon, oldLedger := *config.DefConfig.Genesis, *config.DefConfig.Common, ledger.DefLedger
+	config.DefConfig.Common.DBBackend = memstore.BACKEND_MEMORY
+	config.DefConfig.Genesis.ConsensusType = config.CONSENSUS_TYPE_SBFT
+	config.DefConfig.Genesis.SBFT = &config.SBFTConfig{Bookkeepers: keys}
+	bookkeepers, err := config.DefConfig.GetBookkeepers()
+	assert.Nil(t, err)
+
+	ldg, err := ledger.NewLedger(dataDir, 0)
+	assert.Nil(t, err)
+	block, err := genesis.BuildGenesisBlock(bookkeepers, config.DefConfig.Genesis)
+	assert.Nil(t, err)
+	assert.Nil(t, ldg.Init(bookkeepers, block))
+	ledger.DefLedger = ldg
+
+	net := &testNet{
+		queue:  make(chan *p2pmsg.ConsensusPayload, 1024),
+		ledger: ldg,
+	}
+	net.restore = func() {
+		ldg.Close()
+		os.RemoveAll(dataDir)
+		ledger.DefLedger = oldLedger
+		*config.DefConfig.Genesis = oldGenesis
+		*config.DefConfig.Common = oldCommon
+	}
+
+	pool := actor.Spawn(actor.FromFunc(func(ctx actor.Context) {
+		switch ctx.Message().(type) {
+		case *txpool.GetTxnPoolReq:
+			ctx.Sender().Request(&txpool.GetTxnPoolRsp{}, ctx.Self())
+		case *txpool.VerifyBlockReq:
+			ctx.Sender().Request(&txpool.VerifyBlockRsp{}, ctx.Self())
+		}
+	}))
+	p2p := actor.Spawn(actor.FromFunc(func(ctx actor.Context) {
+		if payload, ok := ctx.Message().(*p2pmsg.ConsensusPayload); ok {
+			net.queue <- payload
+		}
+	}))
+	net.pids = []*actor.PID{pool, p2p}
+
+	nextBookkeeper, err := types.AddressFromBookkeepers(bookkeepers)
+	assert.Nil(t, err)
+	for _, acct := range accounts {
+		net.services = append(net.services, &SbftService{
+			Account:        acct,
+			bookkeepers:    bookkeepers,
+			nextBookkeeper: nextBookkeeper,
+			genBlockTime:   time.Hour,
+			timer:          time.NewTimer(time.Hour),
+			ledger:         ldg,
+			incrValidator:  increment.NewIncrementValidator(20),
+			poolActor:      &actorTypes.TxPoolActor{Pool: pool},
+			p2p:            &actorTypes.P2PActor{P2P: p2p},
+		})
+	}
+	for _, service := range net.services {
+		service.InitializeConsensus(0)
+	}
+	return net
+}
+
+func (self *testNet) close() {
+	for _, service := range self.services {
+		service.timer.Stop()
+	}
+	for _, pid := range self.pids {
+		pid.Stop()
+	}
+	self.restore()
+}
+
+// service returns the service of the bookkeeper at index
+func (self *testNet) service(index uint32) *SbftService {
+	for _, service := range self.services {
+		if service.context.BookkeeperIndex == int(index) {
+			return service
+		}
+	}
+	return nil
+}
+
+// primary returns the service of the primary of current view
+func (self *testNet) primary() *SbftService {
+	return self.service(self.services[0].context.PrimaryIndex)
+}
+
+// drain collects the payloads broadcast so far
+func (self *testNet) drain() []*p2pmsg.ConsensusPayload {
+	var payloads []*p2pmsg.ConsensusPayload
+	for {
+		select {
+		case payload := <-self.queue:
+			payloads = append(payloads, payload)
+		case <-time.After(200 * time.Millisecond):
+			return payloads
+		}
+	}
+}
+
+// deliver broadcasts the queued payloads of the given types until no more
+// are produced, the payloads of other types are returned undelivered
+func (self *testNet) deliver(t *testing.T, msgTypes ...ConsensusMessageType) []*p2pmsg.ConsensusPayload {
+	var dropped []*p2pmsg.ConsensusPayload
+	for payloads := self.drain(); len(payloads) > 0; payloads = self.drain() {
+		for _, payload := range payloads {
+			message, err := DeserializeMessage(payload.Data)
+			assert.Nil(t, err)
+			if !hasMsgType(msgTypes, message.Type()) {
+				dropped = append(dropped, payload)
+				continue
+			}
+			for _, service := range self.services {
+				service.NewConsensusPayload(payload)
+			}
+		}
+	}
+	return dropped
+}
+
+func hasMsgType(msgTypes []ConsensusMessageType, msgType ConsensusMessageType) bool {
+	for _, t := range msgTypes {
+		if t == msgType {
+			return true
+		}
+	}
+	return false
+}
+
+func (self *testNet) proposalHash(t *testing.T, payload *p2pmsg.ConsensusPayload) common.Uint256 {
+	message, err := DeserializeMessage(payload.Data)
+	assert.Nil(t, err)
+	pp, ok := message.(*PrePrepare)
+	assert.True(t, ok)
+	return self.services[0].context.BuildHeader(pp.Timestamp, pp.Nonce, pp.NextBookkeeper, pp.Transactions).Hash()
+}
+
+func TestSbftCommitFlow(t *testing.T) {
+	net := newTestNet(t, 4)
+	defer net.close()
+
+	primary := net.primary()
+	assert.True(t, primary.context.State.HasFlag(Primary))
+	primary.Timeout()
+	assert.True(t, primary.context.State.HasFlag(RequestSent))
+
+	// pre-prepare: the backups accept the proposal and vote prepare
+	rest := net.deliver(t, PrePrepareMsg)
+	for _, service := range net.services {
+		if service != primary {
+			assert.True(t, service.context.State.HasFlag(RequestReceived))
+			assert.False(t, service.context.State.HasFlag(Prepared))
+		}
+	}
+	blockHash := primary.context.MakeHeader().Hash()
+
+	// prepare: a quorum of prepares makes every node prepared and send commit
+	for _, payload := range rest {
+		net.queue <- payload
+	}
+	rest = net.deliver(t, PrePrepareMsg, PrepareMsg)
+	for _, service := range net.services {
+		assert.True(t, service.context.State.HasFlag(Prepared))
+		assert.True(t, service.context.State.HasFlag(CommitSent))
+		assert.False(t, service.context.State.HasFlag(BlockGenerated))
+		assert.Equal(t, blockHash, net.proposalHash(t, service.context.PreparedProposal))
+		assert.True(t, len(service.context.PreparedProof) >= service.context.M())
+	}
+	assert.Equal(t, uint32(0), net.ledger.GetCurrentBlockHeight())
+
+	// commit: a quorum of commit signatures persists the block
+	for _, payload := range rest {
+		net.queue <- payload
+	}
+	net.deliver(t, CommitMsg)
+	for _, service := range net.services {
+		assert.True(t, service.context.State.HasFlag(BlockGenerated))
+	}
+	assert.Equal(t, uint32(1), net.ledger.GetCurrentBlockHeight())
+	assert.Equal(t, blockHash, net.ledger.GetCurrentBlockHash())
+	block, err := net.ledger.GetBlockByHash(blockHash)
+	assert.Nil(t, err)
+	assert.Equal(t, net.services[0].context.M(), len(block.Header.SigData))
+}
+
+func TestSbftChangeViewKeepsPrepared(t *testing.T) {
+	net := newTestNet(t, 4)
+	defer net.close()
+
+	ctx := &net.services[0].context
+	proposal := &p2pmsg.ConsensusPayload{Height: ctx.Height}
+	proof := []*p2pmsg.ConsensusPayload{proposal}
+	ctx.State |= Backup | RequestReceived | Prepared
+	ctx.Proposal = proposal
+	ctx.Prepares[1] = proposal
+	ctx.PreparedView = 0
+	ctx.PreparedProposal = proposal
+	ctx.PreparedProof = proof
+
+	ctx.ChangeView(1)
+	assert.Equal(t, Initial, ctx.State)
+	assert.Equal(t, byte(1), ctx.ViewNumber)
+	assert.Equal(t, ctx.GetPrimaryIndex(1), ctx.PrimaryIndex)
+	assert.Nil(t, ctx.Proposal)
+	for _, p := range ctx.Prepares {
+		assert.Nil(t, p)
+	}
+	assert.Equal(t, proposal, ctx.PreparedProposal)
+	assert.Equal(t, proof, ctx.PreparedProof)
+
+	// a new height drops the prepared proposal
+	ctx.Reset(net.services[0].Account, net.services[0].bookkeepers)
+	assert.Nil(t, ctx.PreparedProposal)
+	assert.Nil(t, ctx.PreparedProof)
+}
+
+func TestSbftViewChangeCarriesPrepared(t *testing.T) {
+	net := newTestNet(t, 4)
+	defer net.close()
+
+	// all nodes get prepared on the proposal of view 0, but the commits are lost
+	oldPrimary := net.primary()
+	oldPrimary.Timeout()
+	net.deliver(t, PrePrepareMsg, PrepareMsg)
+	blockHash := oldPrimary.context.MakeHeader().Hash()
+	for _, service := range net.services {
+		assert.True(t, service.context.State.HasFlag(Prepared))
+	}
+
+	// the view changes carry the prepared certificate
+	for _, service := range net.services {
+		service.RequestChangeView()
+	}
+	payloads := net.drain()
+	assert.Equal(t, len(net.services), len(payloads))
+	for _, payload := range payloads {
+		message, err := DeserializeMessage(payload.Data)
+		assert.Nil(t, err)
+		vc, ok := message.(*ViewChange)
+		assert.True(t, ok)
+		assert.Equal(t, byte(1), vc.NewViewNumber)
+		assert.NotNil(t, vc.PreparedProposal)
+		assert.Equal(t, blockHash, net.proposalHash(t, vc.PreparedProposal))
+		assert.True(t, len(vc.PreparedProof) >= net.services[0].context.M())
+		net.queue <- payload
+	}
+	net.deliver(t, ViewChangeMsg)
+	for _, service := range net.services {
+		assert.Equal(t, byte(1), service.context.ViewNumber)
+		assert.False(t, service.context.State.HasFlag(BlockGenerated))
+	}
+
+	// the primary of view 1 has to propose the prepared block again
+	primary := net.primary()
+	assert.NotEqual(t, oldPrimary, primary)
+	primary.Timeout()
+	payloads = net.drain()
+	assert.True(t, len(payloads) > 0)
+	assert.Equal(t, blockHash, net.proposalHash(t, payloads[0]))
+	for _, payload := range payloads {
+		net.queue <- payload
+	}
+	net.deliver(t, PrePrepareMsg, PrepareMsg, CommitMsg)
+	for _, service := range net.services {
+		assert.True(t, service.context.State.HasFlag(BlockGenerated))
+	}
+	assert.Equal(t, uint32(1), net.ledger.GetCurrentBlockHeight())
+	assert.Equal(t, blockHash, net.ledger.GetCurrentBlockHash())
+}
+
+func TestSbftMakeViewChangeUnprepared(t *testing.T) {
+	net := newTestNet(t, 4)
+	defer net.close()
+
+	service := net.services[0]
+	service.RequestChangeView()
+	payloads := net.drain()
+	assert.Equal(t, 1, len(payloads))
+	message, err := DeserializeMessage(payloads[0].Data)
+	assert.Nil(t, err)
+	vc := message.(*ViewChange)
+	assert.Equal(t, byte(1), vc.NewViewNumber)
+	assert.Nil(t, vc.PreparedProposal)
+	assert.Nil(t, vc.PreparedProof)
+	// one view change is not enough to move the view
+	assert.Equal(t, byte(0), service.context.ViewNumber)
+}
@@ -0,0 +1,110 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package sbft
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+	msg "github.com/dnaproject2/DNA/p2pserver/message/types"
+)
+
+// ViewChange asks to move to NewViewNumber. If the sender is prepared at
+// the current height it attaches the prepared proposal together with the
+// quorum of prepare payloads proving it.
+type ViewChange struct {
+	msgData          ConsensusMessageData
+	NewViewNumber    byte
+	PreparedProposal *msg.ConsensusPayload
+	PreparedProof    []*msg.ConsensusPayload
+}
+
+func (vc *ViewChange) Serialization(sink *common.ZeroCopySink) {
+	vc.msgData.Serialization(sink)
+	sink.WriteByte(vc.NewViewNumber)
+	if vc.PreparedProposal == nil {
+		sink.WriteBool(false)
+		return
+	}
+	sink.WriteBool(true)
+	vc.PreparedProposal.Serialization(sink)
+	sink.WriteVarUint(uint64(len(vc.PreparedProof)))
+	for _, p := range vc.PreparedProof {
+		p.Serialization(sink)
+	}
+}
+
+// read data to reader
+func (vc *ViewChange) Deserialization(source *common.ZeroCopySource) error {
+	err := vc.msgData.Deserialization(source)
+	if err != nil {
+		return err
+	}
+
+	viewNum, eof := source.NextByte()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	vc.NewViewNumber = viewNum
+
+	prepared, irregular, eof := source.NextBool()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if !prepared {
+		return nil
+	}
+
+	vc.PreparedProposal = &msg.ConsensusPayload{}
+	if err := vc.PreparedProposal.Deserialization(source); err != nil {
+		return fmt.Errorf("[ViewChange] prepared proposal deserialization failed: %s", err)
+	}
+	length, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	for i := 0; i < int(length); i++ {
+		p := &msg.ConsensusPayload{}
+		if err := p.Deserialization(source); err != nil {
+			return fmt.Errorf("[ViewChange] prepared proof deserialization failed: %s", err)
+		}
+		vc.PreparedProof = append(vc.PreparedProof, p)
+	}
+
+	return nil
+}
+
+func (vc *ViewChange) Type() ConsensusMessageType {
+	return vc.ConsensusMessageData().Type
+}
+
+func (vc *ViewChange) ViewNumber() byte {
+	return vc.msgData.ViewNumber
+}
+
+func (vc *ViewChange) ConsensusMessageData() *ConsensusMessageData {
+	return &(vc.msgData)
+}
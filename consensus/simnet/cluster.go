@@ -0,0 +1,337 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package simnet
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/consensus"
+	"github.com/dnaproject2/DNA/consensus/dbft"
+	"github.com/dnaproject2/DNA/consensus/vbft"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/events"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/ontio/ontology-eventbus/actor"
+)
+
+const (
+	DEFAULT_GEN_BLOCK_TIME = 500 * time.Millisecond
+	VBFT_MIN_NODES         = 7    // min count of peers of vbft genesis config
+	VBFT_MIN_MSG_DELAY     = 5000 // min milliseconds of the msg delays of vbft genesis config
+)
+
+// ClusterConfig is the config of the consensus nodes run in one process
+type ClusterConfig struct {
+	Nodes        int                // count of the consensus nodes
+	Accounts     []*account.Account // accounts of the nodes, new accounts are created if empty
+	Seed         int64              // seed of the random decisions of network
+	GenBlockTime time.Duration      // block interval, DEFAULT_GEN_BLOCK_TIME if 0
+	// policy of the block proposers in genesis config, all the bookkeepers are allowed if nil
+	BookkeeperPolicy *config.PolicyConfig
+}
+
+// Node is a consensus node of the cluster, with its own in-memory ledger
+type Node struct {
+	ID      uint64
+	Account *account.Account
+	Ledger  *ledger.Ledger
+	TxPool  *TxPool
+	Service consensus.ConsensusService
+}
+
+// Cluster is a group of consensus nodes connected by the simulated network.
+// The nodes share the process wide configs, so only one cluster can run at a time.
+type Cluster struct {
+	Network *Network
+	Nodes   []*Node
+	dataDir string
+	restore func() // restores the process wide configs changed by the cluster
+}
+
+// NewDbftCluster creates the dbft nodes, which are all bookkeepers of the genesis block
+func NewDbftCluster(cfg *ClusterConfig) (*Cluster, error) {
+	return newCluster(cfg, config.CONSENSUS_TYPE_DBFT)
+}
+
+// NewVbftCluster creates the vbft nodes, which are all consensus peers of the genesis block.
+// The governance contract requires at least VBFT_MIN_NODES peers
+func NewVbftCluster(cfg *ClusterConfig) (*Cluster, error) {
+	if cfg.Nodes < VBFT_MIN_NODES {
+		return nil, fmt.Errorf("vbft needs at least %d nodes, got %d", VBFT_MIN_NODES, cfg.Nodes)
+	}
+	return newCluster(cfg, config.CONSENSUS_TYPE_VBFT)
+}
+
+func newCluster(cfg *ClusterConfig, consensusType string) (*Cluster, error) {
+	if cfg.Nodes <= 0 {
+		return nil, fmt.Errorf("invalid node count %d", cfg.Nodes)
+	}
+	if len(cfg.Accounts) != 0 && len(cfg.Accounts) != cfg.Nodes {
+		return nil, fmt.Errorf("%d accounts given for %d nodes", len(cfg.Accounts), cfg.Nodes)
+	}
+	dataDir, err := ioutil.TempDir("", "simnet")
+	if err != nil {
+		return nil, err
+	}
+	accounts := cfg.Accounts
+	if len(accounts) == 0 {
+		accounts = make([]*account.Account, cfg.Nodes)
+		for i := range accounts {
+			accounts[i] = account.NewAccount("")
+		}
+	}
+
+	cluster := &Cluster{
+		Network: NewNetwork(cfg.Seed),
+		dataDir: dataDir,
+	}
+	bookkeepers, err := cluster.setupConfig(cfg, consensusType, accounts)
+	if err != nil {
+		cluster.Stop()
+		return nil, err
+	}
+	for i, acct := range accounts {
+		node, err := cluster.newNode(uint64(i), acct, bookkeepers)
+		if err != nil {
+			cluster.Stop()
+			return nil, fmt.Errorf("new node %d error: %s", i, err)
+		}
+		cluster.Nodes = append(cluster.Nodes, node)
+	}
+	return cluster, nil
+}
+
+// setupConfig makes the accounts the bookkeepers of genesis block, the ledgers keep data in memory
+// and the consensus run at the block interval. The changed configs are restored when the cluster
+// stops. It returns the sorted bookkeepers
+func (self *Cluster) setupConfig(cfg *ClusterConfig, consensusType string,
+	accounts []*account.Account) ([]keypair.PublicKey, error) {
+	backend, oldGenesis, genBlockTime := config.DefConfig.Common.DBBackend, config.DefConfig.Genesis, genesis.GenBlockTime
+	self.restore = func() {
+		config.DefConfig.Common.DBBackend = backend
+		config.DefConfig.Genesis = oldGenesis
+		genesis.GenBlockTime = genBlockTime
+	}
+
+	genesisCfg := config.NewGenesisConfig()
+	genesisCfg.ConsensusType = consensusType
+	genesisCfg.BookkeeperPolicy = cfg.BookkeeperPolicy
+	switch consensusType {
+	case config.CONSENSUS_TYPE_DBFT:
+		for _, acct := range accounts {
+			key := hex.EncodeToString(keypair.SerializePublicKey(acct.PublicKey))
+			genesisCfg.DBFT.Bookkeepers = append(genesisCfg.DBFT.Bookkeepers, key)
+		}
+		// dbft only overrides the block interval with the config above the min value
+		genesisCfg.DBFT.GenBlockTime = 0
+	case config.CONSENSUS_TYPE_VBFT:
+		genesisCfg.VBFT = vbftConfig(accounts)
+	default:
+		return nil, fmt.Errorf("unsupported consensus type %s", consensusType)
+	}
+	config.DefConfig.Common.DBBackend = memstore.BACKEND_MEMORY
+	config.DefConfig.Genesis = genesisCfg
+	genesis.GenBlockTime = cfg.GenBlockTime
+	if genesis.GenBlockTime == 0 {
+		genesis.GenBlockTime = DEFAULT_GEN_BLOCK_TIME
+	}
+	return config.DefConfig.GetBookkeepers()
+}
+
+// vbftConfig makes the accounts the consensus peers with equal stakes and the msg delays the
+// min values the governance contract accepts, the other params are those of the polaris test net.
+// A proposer doesn't wait for the delays if the tx pool has transactions
+func vbftConfig(accounts []*account.Account) *config.VBFTConfig {
+	cfg := *config.PolarisConfig.VBFT
+	cfg.N = uint32(len(accounts))
+	cfg.K = cfg.N
+	cfg.C = (cfg.N - 1) / 3
+	cfg.L = 16 * cfg.K
+	cfg.BlockMsgDelay = VBFT_MIN_MSG_DELAY
+	cfg.HashMsgDelay = VBFT_MIN_MSG_DELAY
+	cfg.Peers = make([]*config.VBFTPeerStakeInfo, 0, len(accounts))
+	for i, acct := range accounts {
+		cfg.Peers = append(cfg.Peers, &config.VBFTPeerStakeInfo{
+			Index:      uint32(i + 1),
+			PeerPubkey: hex.EncodeToString(keypair.SerializePublicKey(acct.PublicKey)),
+			Address:    acct.Address.ToBase58(),
+			InitPos:    uint64(cfg.MinInitStake),
+		})
+	}
+	return &cfg
+}
+
+func (self *Cluster) newNode(id uint64, acct *account.Account, bookkeepers []keypair.PublicKey) (*Node, error) {
+	dir := filepath.Join(self.dataDir, fmt.Sprintf("node%d", id))
+	ldg, err := ledger.NewLedger(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("NewLedger error: %s", err)
+	}
+	block, err := genesis.BuildGenesisBlock(bookkeepers, config.DefConfig.Genesis)
+	if err != nil {
+		ldg.Close()
+		return nil, fmt.Errorf("BuildGenesisBlock error: %s", err)
+	}
+	if err = ldg.Init(bookkeepers, block); err != nil {
+		ldg.Close()
+		return nil, fmt.Errorf("Init ledger error: %s", err)
+	}
+
+	// every node receives the block complete events of its own ledger only
+	evtHub := events.NewEventHub()
+	publisher := actor.Spawn(actor.FromFunc(func(ctx actor.Context) {}))
+	ldg.SetEventPublisher(events.NewActorPublisher(publisher, evtHub))
+
+	pool := newTxPool(ldg)
+	endpoint := self.Network.NewEndpoint(id)
+	var service consensus.ConsensusService
+	switch config.DefConfig.Genesis.ConsensusType {
+	case config.CONSENSUS_TYPE_VBFT:
+		service, err = vbft.NewVbftServerWithLedger(acct, pool.GetPID(), endpoint, ldg, evtHub,
+			filepath.Join(dir, vbft.WAL_FILE_NAME))
+	default:
+		service, err = dbft.NewDbftServiceWithLedger(acct, pool.GetPID(), endpoint, ldg, evtHub)
+	}
+	if err != nil {
+		ldg.Close()
+		return nil, fmt.Errorf("new %s service error: %s", config.DefConfig.Genesis.ConsensusType, err)
+	}
+	self.Network.SetReceiver(id, service.GetPID())
+
+	return &Node{
+		ID:      id,
+		Account: acct,
+		Ledger:  ldg,
+		TxPool:  pool,
+		Service: service,
+	}, nil
+}
+
+// Start starts the consensus of all the nodes
+func (self *Cluster) Start() error {
+	for _, node := range self.Nodes {
+		if err := node.Service.Start(); err != nil {
+			return fmt.Errorf("start node %d error: %s", node.ID, err)
+		}
+	}
+	return nil
+}
+
+// Stop closes the network, stops the consensus actors, closes the ledgers, removes the data
+// and restores the configs
+func (self *Cluster) Stop() {
+	self.Network.Close()
+	for _, node := range self.Nodes {
+		node.Service.Halt()
+		node.Service.GetPID().GracefulStop()
+		node.TxPool.GetPID().Stop()
+		node.Ledger.Close()
+	}
+	os.RemoveAll(self.dataDir)
+	if self.restore != nil {
+		self.restore()
+	}
+}
+
+// SubmitTx adds the transaction to the tx pools of all the nodes
+func (self *Cluster) SubmitTx(tx *types.Transaction) {
+	for _, node := range self.Nodes {
+		node.TxPool.Append(tx)
+	}
+}
+
+// WaitHeight waits until the ledgers of the nodes reach the height, all the nodes are waited if no id is given
+func (self *Cluster) WaitHeight(height uint32, timeout time.Duration, ids ...uint64) error {
+	nodes := self.Nodes
+	if len(ids) != 0 {
+		nodes = make([]*Node, 0, len(ids))
+		for _, id := range ids {
+			if id >= uint64(len(self.Nodes)) {
+				return fmt.Errorf("unknown node %d", id)
+			}
+			nodes = append(nodes, self.Nodes[id])
+		}
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var lagged *Node
+		for _, node := range nodes {
+			if node.Ledger.GetCurrentBlockHeight() < height {
+				lagged = node
+				break
+			}
+		}
+		if lagged == nil {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("node %d at height %d hasn't reached height %d in %v", lagged.ID,
+				lagged.Ledger.GetCurrentBlockHeight(), height, timeout)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// Heights returns the current block height of each node
+func (self *Cluster) Heights() []uint32 {
+	heights := make([]uint32, len(self.Nodes))
+	for i, node := range self.Nodes {
+		heights[i] = node.Ledger.GetCurrentBlockHeight()
+	}
+	return heights
+}
+
+// CheckSafety checks that no two nodes have saved different blocks at the same height
+func (self *Cluster) CheckSafety() error {
+	var max uint32
+	for _, height := range self.Heights() {
+		if height > max {
+			max = height
+		}
+	}
+	for height := uint32(1); height <= max; height++ {
+		var first *Node
+		for _, node := range self.Nodes {
+			if node.Ledger.GetCurrentBlockHeight() < height {
+				continue
+			}
+			if first == nil {
+				first = node
+				continue
+			}
+			hash1, hash2 := first.Ledger.GetBlockHash(height), node.Ledger.GetBlockHash(height)
+			if hash1 != hash2 {
+				return fmt.Errorf("node %d and node %d saved different blocks at height %d: %s, %s",
+					first.ID, node.ID, height, hash1.ToHexString(), hash2.ToHexString())
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build faults
+
+package simnet
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dnaproject2/DNA/consensus/dbft"
+	"github.com/dnaproject2/DNA/consensus/fault"
+)
+
+// TestDbftFaults makes node 0 the only byzantine member, which the 4 nodes tolerate
+func TestDbftFaults(t *testing.T) {
+	injector, err := fault.NewInjector(&fault.Scenario{Seed: 1, Rules: []*fault.Rule{
+		{Action: fault.ACTION_INVALID_BLOCK, FromHeight: 2, Limit: 1},
+		{Action: fault.ACTION_EQUIVOCATE, FromHeight: 2, Limit: 1},
+		{Action: fault.ACTION_DUPLICATE, MsgTypes: []string{"prepareResponse"}},
+		{Action: fault.ACTION_CORRUPT, MsgTypes: []string{"changeView"}, Probability: 0.5},
+	}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cluster, err := NewDbftCluster(&ClusterConfig{Nodes: 4, Seed: 1, GenBlockTime: 200 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("NewDbftCluster error: %s", err)
+	}
+	defer cluster.Stop()
+	cluster.Nodes[0].Service.(*dbft.DbftService).SetFaultInjector(injector)
+	if err = cluster.Start(); err != nil {
+		t.Fatalf("Start error: %s", err)
+	}
+
+	if err := cluster.WaitHeight(8, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+	for _, action := range []fault.Action{fault.ACTION_INVALID_BLOCK, fault.ACTION_EQUIVOCATE, fault.ACTION_DUPLICATE} {
+		if injector.Fired(action) == 0 {
+			t.Errorf("fault %s never injected by node 0", action)
+		}
+	}
+}
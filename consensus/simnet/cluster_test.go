@@ -0,0 +1,290 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package simnet
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/consensus/dbft"
+	"github.com/dnaproject2/DNA/consensus/policy"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+)
+
+func newTestCluster(t *testing.T, nodes int) *Cluster {
+	return startTestCluster(t, NewDbftCluster, &ClusterConfig{Nodes: nodes, Seed: 1, GenBlockTime: 200 * time.Millisecond})
+}
+
+func newTestVbftCluster(t *testing.T, nodes int) *Cluster {
+	return startTestCluster(t, NewVbftCluster, &ClusterConfig{Nodes: nodes, Seed: 1})
+}
+
+func startTestCluster(t *testing.T, newCluster func(*ClusterConfig) (*Cluster, error), cfg *ClusterConfig) *Cluster {
+	log.InitLog(log.WarnLog, log.Stdout)
+	cluster, err := newCluster(cfg)
+	if err != nil {
+		t.Fatalf("new cluster error: %s", err)
+	}
+	if err = cluster.Start(); err != nil {
+		cluster.Stop()
+		t.Fatalf("Start error: %s", err)
+	}
+	return cluster
+}
+
+func TestDbftLiveness(t *testing.T) {
+	cluster := newTestCluster(t, 4)
+	defer cluster.Stop()
+
+	if err := cluster.WaitHeight(3, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDbftLossyNetwork(t *testing.T) {
+	cluster := newTestCluster(t, 4)
+	defer cluster.Stop()
+
+	cluster.Network.SetDefaultLink(LinkConfig{Latency: 10 * time.Millisecond, Jitter: 40 * time.Millisecond, DropRate: 0.1})
+	if err := cluster.WaitHeight(3, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.Network.Stats().Dropped == 0 {
+		t.Fatal("no message dropped")
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDbftPartition(t *testing.T) {
+	cluster := newTestCluster(t, 4)
+	defer cluster.Stop()
+
+	if err := cluster.WaitHeight(1, 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	// no quorum in either half
+	cluster.Network.Partition([]uint64{0, 1}, []uint64{2, 3})
+	time.Sleep(500 * time.Millisecond)
+	halted := cluster.Heights()
+	time.Sleep(2 * time.Second)
+	for i, height := range cluster.Heights() {
+		if height != halted[i] {
+			t.Fatalf("node %d saved block %d without quorum", i, height)
+		}
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the majority goes on without node 3
+	cluster.Network.Partition([]uint64{0, 1, 2}, []uint64{3})
+	var max uint32
+	for _, height := range halted {
+		if height > max {
+			max = height
+		}
+	}
+	if err := cluster.WaitHeight(max+2, time.Minute, 0, 1, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDbftTransaction(t *testing.T) {
+	cluster := newTestCluster(t, 4)
+	defer cluster.Stop()
+
+	mutable := utils.BuildNativeTransaction(nutils.OntContractAddress, "name", []byte{})
+	tx, err := mutable.IntoImmutable()
+	if err != nil {
+		t.Fatalf("IntoImmutable error: %s", err)
+	}
+	cluster.SubmitTx(tx)
+
+	deadline := time.Now().Add(30 * time.Second)
+	for _, node := range cluster.Nodes {
+		for {
+			exist, err := node.Ledger.IsContainTransaction(tx.Hash())
+			if err != nil {
+				t.Fatalf("IsContainTransaction error: %s", err)
+			}
+			if exist {
+				break
+			}
+			if time.Now().After(deadline) {
+				t.Fatalf("transaction not saved by node %d", node.ID)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDbftBookkeeperDenied(t *testing.T) {
+	accounts := make([]*account.Account, 4)
+	for i := range accounts {
+		accounts[i] = account.NewAccount("")
+	}
+	cluster := startTestCluster(t, NewDbftCluster, &ClusterConfig{
+		Nodes:        len(accounts),
+		Accounts:     accounts,
+		Seed:         1,
+		GenBlockTime: 200 * time.Millisecond,
+		BookkeeperPolicy: &config.PolicyConfig{
+			PolicyLevel: byte(policy.DenyList),
+			List:        []string{accounts[0].Address.ToBase58()},
+		},
+	})
+	defer cluster.Stop()
+
+	// heights at which node 0 proposed a block, and at which the other nodes requested a view change
+	var lock sync.Mutex
+	proposed, changed := make(map[uint32]bool), make(map[uint32]bool)
+	cluster.Network.SetObserver(func(from uint64, payload *p2pmsg.ConsensusPayload) {
+		msg, err := dbft.DeserializeMessage(payload.Data)
+		if err != nil {
+			return
+		}
+		lock.Lock()
+		defer lock.Unlock()
+		switch {
+		case from == 0 && msg.Type() == dbft.PrepareRequestMsg:
+			proposed[payload.Height] = true
+		case from != 0 && msg.Type() == dbft.ChangeViewMsg:
+			changed[payload.Height] = true
+		}
+	})
+
+	// node 0 is the primary of view 0 at one of every 4 heights
+	if err := cluster.WaitHeight(6, time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+	lock.Lock()
+	defer lock.Unlock()
+	if len(proposed) == 0 {
+		t.Fatal("node 0 never proposed a block")
+	}
+	for height := range proposed {
+		if !changed[height] {
+			t.Errorf("the proposal of node 0 at height %d is not rejected by a view change", height)
+		}
+	}
+}
+
+func TestClusterRestoreConfig(t *testing.T) {
+	genesisCfg, backend, genBlockTime := config.DefConfig.Genesis, config.DefConfig.Common.DBBackend, genesis.GenBlockTime
+	cluster, err := NewDbftCluster(&ClusterConfig{Nodes: 4, GenBlockTime: time.Second})
+	if err != nil {
+		t.Fatalf("NewDbftCluster error: %s", err)
+	}
+	if config.DefConfig.Genesis == genesisCfg || genesis.GenBlockTime != time.Second {
+		t.Fatal("cluster config not set")
+	}
+	cluster.Stop()
+	if config.DefConfig.Genesis != genesisCfg || config.DefConfig.Common.DBBackend != backend ||
+		genesis.GenBlockTime != genBlockTime {
+		t.Fatal("config not restored by Stop")
+	}
+
+	if _, err := NewVbftCluster(&ClusterConfig{Nodes: 4}); err == nil {
+		t.Fatal("vbft cluster created with less than 7 nodes")
+	}
+}
+
+// newTestTx returns a transaction of the nonce, which is valid in any block
+func newTestTx(t *testing.T, nonce uint32) *types.Transaction {
+	mutable := utils.BuildNativeTransaction(nutils.OntContractAddress, "name", []byte{})
+	mutable.Nonce = nonce
+	tx, err := mutable.IntoImmutable()
+	if err != nil {
+		t.Fatalf("IntoImmutable error: %s", err)
+	}
+	return tx
+}
+
+// waitVbftHeight submits a transaction for each block up to the height, as vbft only proposes
+// an empty block after the long tx block timeout
+func waitVbftHeight(t *testing.T, cluster *Cluster, height uint32, ids ...uint64) {
+	start := cluster.Heights()[0]
+	if len(ids) != 0 {
+		start = cluster.Heights()[ids[0]]
+	}
+	for h := start + 1; h <= height; h++ {
+		cluster.SubmitTx(newTestTx(t, h))
+		if err := cluster.WaitHeight(h, time.Minute, ids...); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestVbftLiveness(t *testing.T) {
+	cluster := newTestVbftCluster(t, 7)
+	defer cluster.Stop()
+
+	waitVbftHeight(t, cluster, 3)
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestVbftPartition(t *testing.T) {
+	cluster := newTestVbftCluster(t, 7)
+	defer cluster.Stop()
+
+	waitVbftHeight(t, cluster, 1)
+	// the 5 nodes keep the quorum without the others
+	majority := []uint64{2, 3, 4, 5, 6}
+	cluster.Network.Partition(majority, []uint64{0}, []uint64{1})
+	waitVbftHeight(t, cluster, 3, majority...)
+	for _, id := range []uint64{0, 1} {
+		if height := cluster.Nodes[id].Ledger.GetCurrentBlockHeight(); height > 1 {
+			t.Fatalf("isolated node %d saved block %d", id, height)
+		}
+	}
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the isolated nodes catch up after the partition heals
+	cluster.Network.Heal()
+	waitVbftHeight(t, cluster, 5)
+	if err := cluster.CheckSafety(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,270 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package simnet runs several consensus services in one process over a simulated
+// network, which is used to write deterministic liveness and safety tests of consensus.
+package simnet
+
+import (
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	netActor "github.com/dnaproject2/DNA/p2pserver/actor/server"
+	p2pmsg "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/ontio/ontology-eventbus/actor"
+)
+
+// LinkConfig is the behaviour of the link which delivers messages from one node to another
+type LinkConfig struct {
+	Latency  time.Duration // base delay of delivering a message
+	Jitter   time.Duration // max random delay added to the latency
+	DropRate float64       // probability of dropping a message, in [0, 1]
+}
+
+type link struct {
+	from uint64
+	to   uint64
+}
+
+// NetworkStats counts the consensus messages passed through the network
+type NetworkStats struct {
+	Sent    uint64 // messages sent to a reachable node
+	Dropped uint64 // messages dropped by the link or the partition
+}
+
+// Network is an in-memory stand-in of the p2p network. Each node sends messages to the
+// endpoint actor returned by NewEndpoint, which delivers them to the consensus actors of
+// the other nodes according to the link configs and the partition.
+// The drop and jitter decisions are drawn from a seeded source, so a failing test can be
+// reproduced with the same seed.
+type Network struct {
+	lock        sync.Mutex
+	rand        *rand.Rand
+	defaultLink LinkConfig
+	links       map[link]LinkConfig
+	groups      map[uint64]int // partition group of nodes, nodes in different groups can't reach each other
+	nodes       []uint64
+	endpoints   map[uint64]*actor.PID
+	receivers   map[uint64]*actor.PID
+	stats       NetworkStats
+	observer    func(from uint64, payload *p2pmsg.ConsensusPayload)
+	closed      bool
+}
+
+// NewNetwork creates a fully connected network without latency and loss
+func NewNetwork(seed int64) *Network {
+	return &Network{
+		rand:      rand.New(rand.NewSource(seed)),
+		links:     make(map[link]LinkConfig),
+		groups:    make(map[uint64]int),
+		endpoints: make(map[uint64]*actor.PID),
+		receivers: make(map[uint64]*actor.PID),
+	}
+}
+
+// NewEndpoint spawns the actor which replaces the p2p actor of the node
+func (self *Network) NewEndpoint(id uint64) *actor.PID {
+	props := actor.FromFunc(func(ctx actor.Context) {
+		switch msg := ctx.Message().(type) {
+		case *p2pmsg.ConsensusPayload:
+			self.observe(id, msg)
+			self.broadcast(id, msg)
+		case *netActor.TransmitConsensusMsgReq:
+			if cons, ok := msg.Msg.(*p2pmsg.Consensus); ok {
+				self.observe(id, &cons.Cons)
+				self.send(id, msg.Target, &cons.Cons)
+			}
+		}
+	})
+	pid := actor.Spawn(props)
+
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	if _, present := self.endpoints[id]; !present {
+		self.nodes = append(self.nodes, id)
+	}
+	self.endpoints[id] = pid
+	return pid
+}
+
+// SetReceiver sets the consensus actor which receives the messages sent to the node
+func (self *Network) SetReceiver(id uint64, pid *actor.PID) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.receivers[id] = pid
+}
+
+// SetDefaultLink sets the config of the links which are not set by SetLink
+func (self *Network) SetDefaultLink(cfg LinkConfig) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.defaultLink = cfg
+}
+
+// SetLink sets the config of the link from one node to another
+func (self *Network) SetLink(from, to uint64, cfg LinkConfig) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.links[link{from: from, to: to}] = cfg
+}
+
+// ResetLinks drops all the configs set by SetLink
+func (self *Network) ResetLinks() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.links = make(map[link]LinkConfig)
+}
+
+// Partition splits the nodes into the groups, a node not in any group is isolated
+func (self *Network) Partition(groups ...[]uint64) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.groups = make(map[uint64]int)
+	for _, id := range self.nodes {
+		self.groups[id] = -1
+	}
+	for i, group := range groups {
+		for _, id := range group {
+			self.groups[id] = i
+		}
+	}
+}
+
+// Heal removes the partition
+func (self *Network) Heal() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.groups = make(map[uint64]int)
+}
+
+// SetObserver sets the function called with each message sent by a node, before the
+// message is passed to the links. The function must not block
+func (self *Network) SetObserver(observer func(from uint64, payload *p2pmsg.ConsensusPayload)) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.observer = observer
+}
+
+// Stats returns the message counters of the network
+func (self *Network) Stats() NetworkStats {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.stats
+}
+
+// Close stops delivering messages, the messages in flight are discarded
+func (self *Network) Close() {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.closed = true
+	for _, pid := range self.endpoints {
+		pid.Stop()
+	}
+}
+
+func (self *Network) reachable(from, to uint64) bool {
+	if len(self.groups) == 0 {
+		return true
+	}
+	g1, present1 := self.groups[from]
+	g2, present2 := self.groups[to]
+	if !present1 || !present2 {
+		return false
+	}
+	return g1 == g2 && g1 >= 0
+}
+
+func (self *Network) observe(from uint64, payload *p2pmsg.ConsensusPayload) {
+	self.lock.Lock()
+	observer := self.observer
+	self.lock.Unlock()
+	if observer != nil {
+		observer(from, payload)
+	}
+}
+
+func (self *Network) broadcast(from uint64, payload *p2pmsg.ConsensusPayload) {
+	self.lock.Lock()
+	nodes := make([]uint64, len(self.nodes))
+	copy(nodes, self.nodes)
+	self.lock.Unlock()
+
+	for _, to := range nodes {
+		if to != from {
+			self.send(from, to, payload)
+		}
+	}
+}
+
+func (self *Network) send(from, to uint64, payload *p2pmsg.ConsensusPayload) {
+	self.lock.Lock()
+	receiver := self.receivers[to]
+	if self.closed || receiver == nil {
+		self.lock.Unlock()
+		return
+	}
+	cfg, present := self.links[link{from: from, to: to}]
+	if !present {
+		cfg = self.defaultLink
+	}
+	if !self.reachable(from, to) || (cfg.DropRate > 0 && self.rand.Float64() < cfg.DropRate) {
+		self.stats.Dropped++
+		self.lock.Unlock()
+		return
+	}
+	delay := cfg.Latency
+	if cfg.Jitter > 0 {
+		delay += time.Duration(self.rand.Int63n(int64(cfg.Jitter)))
+	}
+	self.stats.Sent++
+	self.lock.Unlock()
+
+	msg, err := copyPayload(payload)
+	if err != nil {
+		log.Errorf("simnet: copy consensus payload from %d error: %s", from, err)
+		return
+	}
+	msg.PeerId = from
+	if delay == 0 {
+		receiver.Tell(msg)
+		return
+	}
+	time.AfterFunc(delay, func() {
+		self.lock.Lock()
+		closed := self.closed
+		self.lock.Unlock()
+		if !closed {
+			receiver.Tell(msg)
+		}
+	})
+}
+
+// copyPayload passes the payload through serialization as the real network does, so
+// that the nodes never share the message objects
+func copyPayload(payload *p2pmsg.ConsensusPayload) (*p2pmsg.ConsensusPayload, error) {
+	sink := common.NewZeroCopySink(nil)
+	payload.Serialization(sink)
+	msg := &p2pmsg.ConsensusPayload{}
+	if err := msg.Deserialization(common.NewZeroCopySource(sink.Bytes())); err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
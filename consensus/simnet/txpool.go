@@ -0,0 +1,87 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package simnet
+
+import (
+	"sync"
+
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/types"
+	txpool "github.com/dnaproject2/DNA/txnpool/common"
+	"github.com/ontio/ontology-eventbus/actor"
+)
+
+// TxPool is a minimal stand-in of the transaction pool actor. It offers all the pending
+// transactions not in the ledger yet, and accepts all the transactions of a proposal.
+type TxPool struct {
+	lock   sync.Mutex
+	ledger *ledger.Ledger
+	txs    []*types.Transaction
+	pid    *actor.PID
+}
+
+func newTxPool(ldg *ledger.Ledger) *TxPool {
+	pool := &TxPool{ledger: ldg}
+	pool.pid = actor.Spawn(actor.FromProducer(func() actor.Actor {
+		return pool
+	}))
+	return pool
+}
+
+// Append adds the transaction to the pending list
+func (self *TxPool) Append(tx *types.Transaction) {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.txs = append(self.txs, tx)
+}
+
+// GetPID returns the pid which replaces the tx pool actor of the node
+func (self *TxPool) GetPID() *actor.PID {
+	return self.pid
+}
+
+func (self *TxPool) Receive(ctx actor.Context) {
+	sender := ctx.Sender()
+	if sender == nil {
+		return
+	}
+	switch ctx.Message().(type) {
+	case *txpool.GetTxnPoolReq:
+		sender.Request(&txpool.GetTxnPoolRsp{TxnPool: self.pending()}, ctx.Self())
+	case *txpool.VerifyBlockReq:
+		sender.Request(&txpool.VerifyBlockRsp{}, ctx.Self())
+	}
+}
+
+// pending drops the transactions saved in ledger, and returns the others
+func (self *TxPool) pending() []*txpool.TXEntry {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	txs := self.txs[:0]
+	entries := make([]*txpool.TXEntry, 0, len(self.txs))
+	for _, tx := range self.txs {
+		if exist, err := self.ledger.IsContainTransaction(tx.Hash()); err == nil && exist {
+			continue
+		}
+		txs = append(txs, tx)
+		entries = append(entries, &txpool.TXEntry{Tx: tx})
+	}
+	self.txs = txs
+	return entries
+}
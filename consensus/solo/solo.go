@@ -198,8 +198,12 @@ func (self *SoloService) makeBlock() (*types.Block, error) {
 	txRoot := common.ComputeMerkleRoot(txHash)
 
 	blockRoot := ledger.DefLedger.GetBlockRootWithNewTxRoots(height+1, []common.Uint256{txRoot})
+	stateRoot, err := ledger.DefLedger.GetHeaderStateRoot(height + 1)
+	if err != nil {
+		return nil, fmt.Errorf("GetHeaderStateRoot error:%s", err)
+	}
 	header := &types.Header{
-		Version:          ContextVersion,
+		Version:          types.HeaderVersion(height + 1),
 		PrevBlockHash:    prevHash,
 		TransactionsRoot: txRoot,
 		BlockRoot:        blockRoot,
@@ -207,6 +211,7 @@ func (self *SoloService) makeBlock() (*types.Block, error) {
 		Height:           height + 1,
 		ConsensusData:    common.GetNonce(),
 		NextBookkeeper:   nextBookkeeper,
+		StateRoot:        stateRoot,
 	}
 	block := &types.Block{
 		Header:       header,
@@ -0,0 +1,120 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vconfig
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+)
+
+const (
+	ProposalStatement uint8 = 1
+	EndorseStatement  uint8 = 2
+)
+
+// statementPrefix separates the signed statements from the signed block hashes
+var statementPrefix = []byte("vbft-statement")
+
+// ConsensusStatement is signed by the proposer in block proposal and by the endorser in
+// endorse msg. The block hash signatures do not tell which height and round they are made for,
+// the statement binds them, so that two conflicting statements signed by one peer are the
+// evidence of its equivocation, which can be verified by others. The network id is signed too, so the
+// statements made on a test network can not be reported as evidence on another network.
+type ConsensusStatement struct {
+	NetworkId uint32
+	Type      uint8
+	BlockNum  uint32
+	Proposer  uint32
+	BlockHash common.Uint256
+	ForEmpty  bool
+}
+
+func (stmt *ConsensusStatement) Serialize() []byte {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteBytes(statementPrefix)
+	sink.WriteUint32(stmt.NetworkId)
+	sink.WriteUint8(stmt.Type)
+	sink.WriteUint32(stmt.BlockNum)
+	sink.WriteUint32(stmt.Proposer)
+	sink.WriteHash(stmt.BlockHash)
+	sink.WriteBool(stmt.ForEmpty)
+	return sink.Bytes()
+}
+
+func (stmt *ConsensusStatement) Deserialize(data []byte) error {
+	source := common.NewZeroCopySource(data)
+	prefix, eof := source.NextBytes(uint64(len(statementPrefix)))
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if string(prefix) != string(statementPrefix) {
+		return fmt.Errorf("invalid statement prefix")
+	}
+	var irregular bool
+	stmt.NetworkId, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	stmt.Type, eof = source.NextUint8()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	stmt.BlockNum, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	stmt.Proposer, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	stmt.BlockHash, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	stmt.ForEmpty, irregular, eof = source.NextBool()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if source.Len() != 0 {
+		return fmt.Errorf("unexpected data after statement")
+	}
+	return nil
+}
+
+// ConflictWith checks if the two statements from one peer are equivocating: proposing two blocks,
+// or endorsing two blocks at the same height of one network. Endorsing the empty block after endorsing a block
+// is allowed, as the endorser falls back to the empty block if the proposal can not be committed.
+func (stmt *ConsensusStatement) ConflictWith(other *ConsensusStatement) bool {
+	if stmt.NetworkId != other.NetworkId || stmt.Type != other.Type || stmt.BlockNum != other.BlockNum ||
+		stmt.BlockHash == other.BlockHash {
+		return false
+	}
+	switch stmt.Type {
+	case ProposalStatement:
+		return stmt.Proposer == other.Proposer
+	case EndorseStatement:
+		return !stmt.ForEmpty && !other.ForEmpty
+	}
+	return false
+}
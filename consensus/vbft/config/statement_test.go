@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vconfig
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+)
+
+func TestConsensusStatement(t *testing.T) {
+	stmt := &ConsensusStatement{
+		NetworkId: 3,
+		Type:      EndorseStatement,
+		BlockNum:  100,
+		Proposer:  3,
+		BlockHash: common.Uint256{1, 2, 3},
+		ForEmpty:  true,
+	}
+	stmt2 := &ConsensusStatement{}
+	if err := stmt2.Deserialize(stmt.Serialize()); err != nil {
+		t.Fatalf("deserialize statement failed: %v", err)
+	}
+	if *stmt2 != *stmt {
+		t.Fatalf("statement mismatch: %v, %v", stmt2, stmt)
+	}
+	if err := stmt2.Deserialize(stmt.BlockHash[:]); err == nil {
+		t.Fatalf("block hash deserialized as statement")
+	}
+}
+
+func TestConsensusStatementConflict(t *testing.T) {
+	proposal1 := &ConsensusStatement{Type: ProposalStatement, BlockNum: 10, Proposer: 1, BlockHash: common.Uint256{1}}
+	proposal2 := &ConsensusStatement{Type: ProposalStatement, BlockNum: 10, Proposer: 1, BlockHash: common.Uint256{2}}
+	endorse1 := &ConsensusStatement{Type: EndorseStatement, BlockNum: 10, Proposer: 1, BlockHash: common.Uint256{1}}
+	endorse2 := &ConsensusStatement{Type: EndorseStatement, BlockNum: 10, Proposer: 2, BlockHash: common.Uint256{2}}
+	emptyEndorse := &ConsensusStatement{Type: EndorseStatement, BlockNum: 10, Proposer: 2, BlockHash: common.Uint256{3}, ForEmpty: true}
+	nextEndorse := &ConsensusStatement{Type: EndorseStatement, BlockNum: 11, Proposer: 2, BlockHash: common.Uint256{2}}
+	otherNetwork := &ConsensusStatement{NetworkId: 1, Type: ProposalStatement, BlockNum: 10, Proposer: 1, BlockHash: common.Uint256{2}}
+
+	cases := []struct {
+		stmt1, stmt2 *ConsensusStatement
+		conflict     bool
+	}{
+		{proposal1, proposal2, true},
+		{proposal1, proposal1, false},
+		{proposal1, endorse2, false},
+		{endorse1, endorse2, true},
+		{endorse1, emptyEndorse, false},
+		{endorse1, nextEndorse, false},
+		{proposal1, otherNetwork, false},
+	}
+	for i, c := range cases {
+		if c.stmt1.ConflictWith(c.stmt2) != c.conflict {
+			t.Errorf("case %d: expected conflict %v", i, c.conflict)
+		}
+	}
+}
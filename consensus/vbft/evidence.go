@@ -0,0 +1,337 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	vconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
+	"github.com/dnaproject2/DNA/core/payload"
+	"github.com/dnaproject2/DNA/core/signature"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	gover "github.com/dnaproject2/DNA/smartcontract/service/native/governance"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/dnaproject2/DNA/vm/neovm"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+//
+// Equivocation detecting:
+// proposers and endorsers sign consensus statements of the block height and hash in proposals and
+// endorsements. The first statement of each peer in each round is kept, if a conflicting statement
+// is received from the same peer, the two statements are packed as evidence, and reported to the
+// governance contract by a system transaction in the next proposals, until the report is sealed.
+// Commitments are not covered, as the commit signatures are the bare block hash signatures.
+//
+
+type statementKey struct {
+	stmtType uint8
+	signer   string
+}
+
+// evidenceStructOps are the ops building the param struct in the invoke code of report transaction,
+// before the first field and after each field
+var (
+	evidenceStructPrefix = []byte{byte(neovm.PUSH0), byte(neovm.NEWSTRUCT), byte(neovm.TOALTSTACK)}
+	evidenceFieldSuffix  = []byte{byte(neovm.DUPFROMALTSTACK), byte(neovm.SWAP), byte(neovm.APPEND)}
+)
+
+type signedStatement struct {
+	stmt *vconfig.ConsensusStatement
+	sig  []byte
+}
+
+type evidence struct {
+	param    *gover.EquivocationEvidenceParam
+	blockNum uint32
+	code     []byte // invoke code of the report transaction
+}
+
+type EvidencePool struct {
+	lock       sync.Mutex
+	server     *Server
+	historyLen uint32
+	statements map[uint32]map[statementKey]*signedStatement // indexed by BlockNum
+	reported   map[uint32]map[string]bool                   // signers with evidence collected, indexed by BlockNum
+	pending    []*evidence
+}
+
+func newEvidencePool(server *Server, historyLen uint32) *EvidencePool {
+	return &EvidencePool{
+		server:     server,
+		historyLen: historyLen,
+		statements: make(map[uint32]map[statementKey]*signedStatement),
+		reported:   make(map[uint32]map[string]bool),
+		pending:    make([]*evidence, 0),
+	}
+}
+
+// addStatement keeps the first statement of the signer in the round, and collects the evidence
+// if the statement is conflicting with the kept one
+func (pool *EvidencePool) addStatement(signer string, stmt *vconfig.ConsensusStatement, sig []byte) {
+	if stmt.Type == vconfig.EndorseStatement && stmt.ForEmpty {
+		// endorsing empty block never conflicts
+		return
+	}
+	currentBlkNum := pool.server.GetCurrentBlockNo()
+	if stmt.BlockNum > currentBlkNum+pool.historyLen || stmt.BlockNum+pool.historyLen < currentBlkNum {
+		return
+	}
+
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	if pool.reported[stmt.BlockNum][signer] {
+		return
+	}
+	stmts := pool.statements[stmt.BlockNum]
+	if stmts == nil {
+		stmts = make(map[statementKey]*signedStatement)
+		pool.statements[stmt.BlockNum] = stmts
+	}
+	key := statementKey{stmtType: stmt.Type, signer: signer}
+	first := stmts[key]
+	if first == nil {
+		stmts[key] = &signedStatement{stmt: stmt, sig: sig}
+		return
+	}
+	if !first.stmt.ConflictWith(stmt) {
+		return
+	}
+
+	param := &gover.EquivocationEvidenceParam{
+		PeerPubkey: signer,
+		Statement1: first.stmt.Serialize(),
+		Sig1:       first.sig,
+		Statement2: stmt.Serialize(),
+		Sig2:       sig,
+	}
+	code, err := buildEvidenceCode(param)
+	if err != nil {
+		log.Errorf("failed to build equivocation evidence of %s: %s", signer, err)
+		return
+	}
+	pool.pending = append(pool.pending, &evidence{
+		param:    param,
+		blockNum: stmt.BlockNum,
+		code:     code,
+	})
+	if pool.reported[stmt.BlockNum] == nil {
+		pool.reported[stmt.BlockNum] = make(map[string]bool)
+	}
+	pool.reported[stmt.BlockNum][signer] = true
+	log.Warnf("server %d detected equivocation of %s in block %d, statement type %d",
+		pool.server.Index, signer, stmt.BlockNum, stmt.Type)
+}
+
+// getEvidenceTxs builds the transactions to report the pending evidences in proposal of blkNum
+func (pool *EvidencePool) getEvidenceTxs(blkNum uint32) ([]*types.Transaction, error) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	txs := make([]*types.Transaction, 0, len(pool.pending))
+	for _, e := range pool.pending {
+		mutable := utils.NewInvokeTransaction(e.code)
+		mutable.GasLimit = math.MaxUint64
+		mutable.Nonce = blkNum
+		tx, err := mutable.IntoImmutable()
+		if err != nil {
+			return nil, fmt.Errorf("failed to build evidence tx of %s: %s", e.param.PeerPubkey, err)
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// onBlockSealed drops the statements and reported signers of history rounds, and the evidences
+// reported in the sealed block
+func (pool *EvidencePool) onBlockSealed(block *Block) {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	blkNum := block.getBlockNum()
+	if blkNum > pool.historyLen {
+		for n := range pool.statements {
+			if n < blkNum-pool.historyLen {
+				delete(pool.statements, n)
+			}
+		}
+		for n := range pool.reported {
+			if n < blkNum-pool.historyLen {
+				delete(pool.reported, n)
+			}
+		}
+	}
+
+	pending := make([]*evidence, 0, len(pool.pending))
+	for _, e := range pool.pending {
+		if !containsInvokeCode(block.Block.Transactions, e.code) {
+			pending = append(pending, e)
+		}
+	}
+	pool.pending = pending
+}
+
+func (pool *EvidencePool) clean() {
+	pool.lock.Lock()
+	defer pool.lock.Unlock()
+
+	pool.statements = make(map[uint32]map[statementKey]*signedStatement)
+	pool.reported = make(map[uint32]map[string]bool)
+}
+
+// buildEvidenceCode builds the invoke code of the transaction reporting the evidence to governance
+func buildEvidenceCode(param *gover.EquivocationEvidenceParam) ([]byte, error) {
+	return utils.BuildNativeInvokeCode(nutils.GovernanceContractAddress, 0, gover.REPORT_EQUIVOCATION,
+		[]interface{}{param})
+}
+
+// isEvidenceTx returns whether the tx is a system transaction reporting evidence, which is built by
+// the proposer without payer signature
+func isEvidenceTx(tx *types.Transaction) bool {
+	invoke, ok := tx.Payload.(*payload.InvokeCode)
+	if !ok || len(tx.Sigs) != 0 {
+		return false
+	}
+	suffix, err := utils.BuildNativeInvokeCode(nutils.GovernanceContractAddress, 0, gover.REPORT_EQUIVOCATION,
+		[]interface{}{})
+	return err == nil && bytes.HasSuffix(invoke.Code, suffix)
+}
+
+// checkEvidenceTx checks the evidence report deterministically in place of the signature checks of user
+// transactions: the invoke code is exactly the one built for the evidence, and the evidence is valid
+func checkEvidenceTx(tx *types.Transaction) error {
+	invoke, ok := tx.Payload.(*payload.InvokeCode)
+	if !ok {
+		return fmt.Errorf("evidence tx is not invoke tx")
+	}
+	param, err := decodeEvidenceCode(invoke.Code)
+	if err != nil {
+		return fmt.Errorf("failed to decode evidence tx: %s", err)
+	}
+	code, err := buildEvidenceCode(param)
+	if err != nil {
+		return fmt.Errorf("failed to build evidence code: %s", err)
+	}
+	if !bytes.Equal(code, invoke.Code) {
+		return fmt.Errorf("invalid evidence code")
+	}
+	pub, err := vconfig.Pubkey(param.PeerPubkey)
+	if err != nil {
+		return fmt.Errorf("invalid evidence peer %s: %s", param.PeerPubkey, err)
+	}
+	stmt1, stmt2 := &vconfig.ConsensusStatement{}, &vconfig.ConsensusStatement{}
+	if err := stmt1.Deserialize(param.Statement1); err != nil {
+		return fmt.Errorf("invalid evidence statement1: %s", err)
+	}
+	if err := stmt2.Deserialize(param.Statement2); err != nil {
+		return fmt.Errorf("invalid evidence statement2: %s", err)
+	}
+	if !stmt1.ConflictWith(stmt2) {
+		return fmt.Errorf("evidence statements are not conflicting")
+	}
+	if err := verifyStatement(pub, stmt1, param.Sig1); err != nil {
+		return err
+	}
+	return verifyStatement(pub, stmt2, param.Sig2)
+}
+
+// decodeEvidenceCode reads the fields of the evidence param from the invoke code built by buildEvidenceCode,
+// the ops after the fields are not checked
+func decodeEvidenceCode(code []byte) (*gover.EquivocationEvidenceParam, error) {
+	if !bytes.HasPrefix(code, evidenceStructPrefix) {
+		return nil, fmt.Errorf("invalid param struct")
+	}
+	source := common.NewZeroCopySource(code[len(evidenceStructPrefix):])
+	fields := make([][]byte, 5)
+	for i := range fields {
+		data, err := readPushData(source)
+		if err != nil {
+			return nil, err
+		}
+		suffix, eof := source.NextBytes(uint64(len(evidenceFieldSuffix)))
+		if eof || !bytes.Equal(suffix, evidenceFieldSuffix) {
+			return nil, fmt.Errorf("invalid param field %d", i)
+		}
+		fields[i] = data
+	}
+	return &gover.EquivocationEvidenceParam{
+		PeerPubkey: string(fields[0]),
+		Statement1: fields[1],
+		Sig1:       fields[2],
+		Statement2: fields[3],
+		Sig2:       fields[4],
+	}, nil
+}
+
+// readPushData reads the data pushed by neovm.ParamsBuilder.EmitPushByteArray
+func readPushData(source *common.ZeroCopySource) ([]byte, error) {
+	op, eof := source.NextByte()
+	if eof {
+		return nil, io.ErrUnexpectedEOF
+	}
+	var size uint64
+	switch {
+	case op < byte(neovm.PUSHBYTES75):
+		size = uint64(op)
+	case op == byte(neovm.PUSHDATA1):
+		n, e := source.NextUint8()
+		size, eof = uint64(n), e
+	case op == byte(neovm.PUSHDATA2):
+		n, e := source.NextUint16()
+		size, eof = uint64(n), e
+	case op == byte(neovm.PUSHDATA4):
+		n, e := source.NextUint32()
+		size, eof = uint64(n), e
+	default:
+		return nil, fmt.Errorf("unexpected opcode %d", op)
+	}
+	if eof {
+		return nil, io.ErrUnexpectedEOF
+	}
+	data, eof := source.NextBytes(size)
+	if eof {
+		return nil, io.ErrUnexpectedEOF
+	}
+	return data, nil
+}
+
+func containsInvokeCode(txs []*types.Transaction, code []byte) bool {
+	for _, tx := range txs {
+		invoke, ok := tx.Payload.(*payload.InvokeCode)
+		if ok && bytes.Equal(invoke.Code, code) {
+			return true
+		}
+	}
+	return false
+}
+
+func verifyStatement(pub keypair.PublicKey, stmt *vconfig.ConsensusStatement, sig []byte) error {
+	if err := signature.Verify(pub, stmt.Serialize(), sig); err != nil {
+		return fmt.Errorf("failed to verify statement sig: %s", err)
+	}
+	return nil
+}
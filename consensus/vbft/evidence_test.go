@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/consensus/vbft/config"
+	"github.com/dnaproject2/DNA/core/payload"
+	"github.com/dnaproject2/DNA/core/signature"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	"github.com/dnaproject2/DNA/core/validation"
+	"github.com/dnaproject2/DNA/errors"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+func signTestStatement(t *testing.T, acc *account.Account, stmt *vconfig.ConsensusStatement) []byte {
+	sig, err := signature.Sign(acc, stmt.Serialize())
+	if err != nil {
+		t.Fatalf("sign statement failed: %v", err)
+	}
+	if err := verifyStatement(acc.PublicKey, stmt, sig); err != nil {
+		t.Fatalf("verify statement failed: %v", err)
+	}
+	return sig
+}
+
+func TestEvidencePool(t *testing.T) {
+	acc := account.NewAccount("SHA256withECDSA")
+	signer := vconfig.PubkeyID(acc.PublicKey)
+	server := constructServer()
+	server.currentBlockNum = 20
+	pool := newEvidencePool(server, 10)
+
+	endorse := func(hash byte, forEmpty bool) {
+		stmt := &vconfig.ConsensusStatement{
+			Type:      vconfig.EndorseStatement,
+			BlockNum:  20,
+			Proposer:  2,
+			BlockHash: common.Uint256{hash},
+			ForEmpty:  forEmpty,
+		}
+		pool.addStatement(signer, stmt, signTestStatement(t, acc, stmt))
+	}
+	endorse(1, false)
+	endorse(2, true)
+	endorse(1, false)
+	if len(pool.pending) != 0 {
+		t.Fatalf("unexpected evidence of %d", len(pool.pending))
+	}
+	endorse(3, false)
+	if len(pool.pending) != 1 {
+		t.Fatalf("expected evidence not collected")
+	}
+	endorse(4, false)
+	if len(pool.pending) != 1 {
+		t.Fatalf("duplicated evidence collected")
+	}
+
+	txs, err := pool.getEvidenceTxs(21)
+	if err != nil || len(txs) != 1 {
+		t.Fatalf("getEvidenceTxs failed: %v", err)
+	}
+	if txs[0].Nonce != 21 {
+		t.Fatalf("invalid nonce of evidence tx: %d", txs[0].Nonce)
+	}
+
+	block, err := constructBlock()
+	if err != nil {
+		t.Fatalf("constructBlock failed: %v", err)
+	}
+	block.Block.Transactions = []*types.Transaction{}
+	pool.onBlockSealed(block)
+	if len(pool.pending) != 1 {
+		t.Fatalf("evidence dropped before reported")
+	}
+	block.Block.Transactions = txs
+	pool.onBlockSealed(block)
+	if len(pool.pending) != 0 {
+		t.Fatalf("reported evidence not dropped")
+	}
+}
+
+func newSignedTestTx(t *testing.T, acc *account.Account) *types.Transaction {
+	mutable := utils.BuildNativeTransaction(nutils.OntContractAddress, "name", []byte{})
+	mutable.Payer = acc.Address
+	hash := mutable.Hash()
+	sig, err := signature.Sign(acc, hash.ToArray())
+	if err != nil {
+		t.Fatalf("sign tx failed: %v", err)
+	}
+	mutable.Sigs = []types.Sig{{
+		PubKeys: []keypair.PublicKey{acc.PublicKey},
+		M:       1,
+		SigData: [][]byte{sig},
+	}}
+	tx, err := mutable.IntoImmutable()
+	if err != nil {
+		t.Fatalf("IntoImmutable failed: %v", err)
+	}
+	return tx
+}
+
+func TestEvidenceTxValidation(t *testing.T) {
+	acc := account.NewAccount("SHA256withECDSA")
+	signer := vconfig.PubkeyID(acc.PublicKey)
+	server := constructServer()
+	server.currentBlockNum = 20
+	pool := newEvidencePool(server, 10)
+
+	endorse := func(blkNum uint32, hash byte) {
+		stmt := &vconfig.ConsensusStatement{
+			Type:      vconfig.EndorseStatement,
+			BlockNum:  blkNum,
+			Proposer:  2,
+			BlockHash: common.Uint256{hash},
+		}
+		pool.addStatement(signer, stmt, signTestStatement(t, acc, stmt))
+	}
+	endorse(20, 1)
+	endorse(20, 2)
+	evidenceTxs, err := pool.getEvidenceTxs(21)
+	if err != nil || len(evidenceTxs) != 1 {
+		t.Fatalf("getEvidenceTxs failed: %v", err)
+	}
+	evidenceTx := evidenceTxs[0]
+	if validation.VerifyTransaction(evidenceTx) == errors.ErrNoError {
+		t.Fatalf("unsigned evidence tx passed stateless validation")
+	}
+
+	userTx := newSignedTestTx(t, acc)
+	if code := validation.VerifyTransaction(userTx); code != errors.ErrNoError {
+		t.Fatalf("user tx failed stateless validation: %v", code)
+	}
+	userTxs, err := server.nonSysTxs([]*types.Transaction{evidenceTx, userTx}, 21)
+	if err != nil {
+		t.Fatalf("nonSysTxs failed: %v", err)
+	}
+	if len(userTxs) != 1 || userTxs[0] != userTx {
+		t.Fatalf("evidence tx not exempted from txpool verification")
+	}
+	for _, tx := range userTxs {
+		if code := validation.VerifyTransaction(tx); code != errors.ErrNoError {
+			t.Fatalf("proposal tx failed stateless validation: %v", code)
+		}
+	}
+
+	param, err := decodeEvidenceCode(evidenceTx.Payload.(*payload.InvokeCode).Code)
+	if err != nil {
+		t.Fatalf("decodeEvidenceCode failed: %v", err)
+	}
+	param.Sig2 = param.Sig1
+	code, err := buildEvidenceCode(param)
+	if err != nil {
+		t.Fatalf("buildEvidenceCode failed: %v", err)
+	}
+	mutable := utils.NewInvokeTransaction(code)
+	tampered, err := mutable.IntoImmutable()
+	if err != nil {
+		t.Fatalf("IntoImmutable failed: %v", err)
+	}
+	if _, err := server.nonSysTxs([]*types.Transaction{tampered, userTx}, 21); err == nil {
+		t.Fatalf("tampered evidence tx accepted")
+	}
+
+	// signers reported are expired with the statements
+	block, err := constructBlock()
+	if err != nil {
+		t.Fatalf("constructBlock failed: %v", err)
+	}
+	block.Block.Header.Height = 40
+	block.Block.Transactions = evidenceTxs
+	pool.onBlockSealed(block)
+	if len(pool.reported) != 0 {
+		t.Fatalf("reported signers not expired")
+	}
+	server.currentBlockNum = 41
+	endorse(40, 1)
+	endorse(40, 2)
+	if len(pool.pending) != 1 {
+		t.Fatalf("equivocation of later block not collected")
+	}
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build faults
+
+package vbft
+
+import (
+	"crypto/sha256"
+	"time"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/consensus/fault"
+	vconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
+	"github.com/dnaproject2/DNA/core/types"
+)
+
+//
+// Fault injection:
+// chaos tests start some nodes with a scenario of byzantine faults (see package fault).
+// The faults of msg delivery are injected when the msgs are sent in msgSendLoop, and the
+// equivocation and invalid proposals are injected when the proposer makes the proposal.
+//
+
+var msgTypeNames = map[MsgType]string{
+	BlockProposalMessage:      "proposal",
+	BlockEndorseMessage:       "endorse",
+	BlockCommitMessage:        "commit",
+	PeerHandshakeMessage:      "handshake",
+	PeerHeartbeatMessage:      "heartbeat",
+	BlockInfoFetchMessage:     "blockInfoFetch",
+	BlockInfoFetchRespMessage: "blockInfoFetchResp",
+	ProposalFetchMessage:      "proposalFetch",
+	BlockFetchMessage:         "blockFetch",
+	BlockFetchRespMessage:     "blockFetchResp",
+	BlockSubmitMessage:        "blockSubmit",
+}
+
+// faultState keeps the injector of the faults of the msgs sent by the server
+type faultState struct {
+	injector *fault.Injector
+}
+
+// SetFaultInjector sets the injector of the faults of the server, nothing is injected if it is nil
+func (self *Server) SetFaultInjector(injector *fault.Injector) {
+	self.faults.injector = injector
+}
+
+// initFaults injects the faults of the scenario the node is started with
+func (self *Server) initFaults() {
+	self.SetFaultInjector(fault.DefInjector)
+}
+
+// sendMsg sends the serialized msg of the event, with the faults of scenario injected
+func (self *Server) sendMsg(evt *SendMsgEvent, payload []byte) {
+	injector := self.faults.injector
+	if injector == nil {
+		self.xmitMsg(evt, payload)
+		return
+	}
+
+	msgType, blkNum := msgTypeNames[evt.Msg.Type()], evt.Msg.GetBlockNum()
+	if injector.Fire(fault.ACTION_DROP, msgType, blkNum) != nil {
+		return
+	}
+	if injector.Fire(fault.ACTION_CORRUPT, msgType, blkNum) != nil {
+		payload = injector.Corrupt(payload)
+	}
+	copies := 1
+	if rule := injector.Fire(fault.ACTION_DUPLICATE, msgType, blkNum); rule != nil {
+		copies += rule.ExtraCopies()
+	}
+	send := func() {
+		for i := 0; i < copies; i++ {
+			self.xmitMsg(evt, payload)
+		}
+	}
+	if rule := injector.Fire(fault.ACTION_DELAY, msgType, blkNum); rule != nil {
+		time.AfterFunc(rule.DelayDuration(), send)
+		return
+	}
+	send()
+}
+
+// equivocateProposal sends the proposal to half of the peers and a conflicting proposal of the same
+// block to the others if the scenario decides to equivocate. It returns false if nothing is sent
+func (self *Server) equivocateProposal(proposal *blockProposalMsg, sysTxs, userTxs []*types.Transaction,
+	cfg *vconfig.ChainConfig) bool {
+	blkNum := proposal.GetBlockNum()
+	if self.faults.injector.Fire(fault.ACTION_EQUIVOCATE, msgTypeNames[BlockProposalMessage], blkNum) == nil {
+		return false
+	}
+	// the block nonce makes the conflicting proposal different
+	conflict, err := self.constructProposalMsg(blkNum, sysTxs, userTxs, cfg)
+	if err != nil {
+		log.Errorf("server %d failed to construct conflicting proposal (%d): %s", self.Index, blkNum, err)
+		return false
+	}
+	n := 0
+	for _, p := range self.config.Peers {
+		if p.Index == self.Index {
+			continue
+		}
+		msg := proposal
+		if n%2 == 1 {
+			msg = conflict
+		}
+		self.msgSendC <- &SendMsgEvent{
+			ToPeer: p.Index,
+			Msg:    msg,
+		}
+		n++
+	}
+	return true
+}
+
+// invalidPrevBlockHash returns a wrong parent of the block if the scenario decides to propose an invalid
+// block, which honest endorsers refuse to endorse
+func (self *Server) invalidPrevBlockHash(blkNum uint32, prevBlkHash common.Uint256) common.Uint256 {
+	if self.faults.injector.Fire(fault.ACTION_INVALID_BLOCK, msgTypeNames[BlockProposalMessage], blkNum) == nil {
+		return prevBlkHash
+	}
+	return common.Uint256(sha256.Sum256(prevBlkHash[:]))
+}
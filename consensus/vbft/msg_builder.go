@@ -26,7 +26,6 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/consensus/vbft/config"
-	"github.com/dnaproject2/DNA/core/ledger"
 	"github.com/dnaproject2/DNA/core/signature"
 	"github.com/dnaproject2/DNA/core/types"
 	"github.com/ontio/ontology-crypto/keypair"
@@ -195,9 +194,15 @@ func (self *Server) constructBlock(blkNum uint32, prevBlkHash common.Uint256, tx
 	}
 
 	txRoot := common.ComputeMerkleRoot(txHash)
-	blockRoot := ledger.DefLedger.GetBlockRootWithNewTxRoots(lastBlock.Block.Header.Height, []common.Uint256{lastBlock.Block.Header.TransactionsRoot, txRoot})
+	blockRoot := self.ledger.GetBlockRootWithNewTxRoots(lastBlock.Block.Header.Height, []common.Uint256{lastBlock.Block.Header.TransactionsRoot, txRoot})
+	// the last block may be not submitted yet, but the one before it is
+	stateRoot, err := self.ledger.GetHeaderStateRoot(blkNum)
+	if err != nil {
+		return nil, fmt.Errorf("constructBlock GetHeaderStateRoot blknum:%d error: %s", blkNum, err)
+	}
 
 	blkHeader := &types.Header{
+		Version:          types.HeaderVersion(blkNum),
 		PrevBlockHash:    prevBlkHash,
 		TransactionsRoot: txRoot,
 		BlockRoot:        blockRoot,
@@ -205,6 +210,7 @@ func (self *Server) constructBlock(blkNum uint32, prevBlkHash common.Uint256, tx
 		Height:           uint32(blkNum),
 		ConsensusData:    common.GetNonce(),
 		ConsensusPayload: consensusPayload,
+		StateRoot:        stateRoot,
 	}
 	blk := &types.Block{
 		Header:       blkHeader,
@@ -227,6 +233,7 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 	if prevBlk == nil {
 		return nil, fmt.Errorf("failed to get prevBlock (%d)", blkNum-1)
 	}
+	prevBlkHash = self.invalidPrevBlockHash(blkNum, prevBlkHash)
 	blocktimestamp := uint32(time.Now().Unix())
 	if prevBlk.Block.Header.Timestamp >= blocktimestamp {
 		blocktimestamp = prevBlk.Block.Header.Timestamp + 1
@@ -277,6 +284,10 @@ func (self *Server) constructProposalMsg(blkNum uint32, sysTxs, userTxs []*types
 			PrevBlockMerkleRoot: merkleRoot,
 		},
 	}
+	msg.Block.StatementSig, err = signature.Sign(self.account, msg.Block.statement().Serialize())
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign proposal statement: %s", err)
+	}
 
 	return msg, nil
 }
@@ -315,6 +326,10 @@ func (self *Server) constructEndorseMsg(proposal *blockProposalMsg, forEmpty boo
 		ProposerSig:       proposerSig,
 		EndorserSig:       endorserSig,
 	}
+	msg.StatementSig, err = signature.Sign(self.account, msg.statement().Serialize())
+	if err != nil {
+		return nil, fmt.Errorf("endorser failed to sign statement of block %d, err: %s", msg.BlockNum, err)
+	}
 
 	return msg, nil
 }
@@ -25,6 +25,7 @@ import (
 	"fmt"
 
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/serialization"
 	vconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
 	"github.com/ontio/ontology-crypto/keypair"
@@ -96,6 +97,13 @@ func (msg *blockProposalMsg) Verify(pub keypair.PublicKey) error {
 		}
 	}
 
+	// verify statement, which is absent in proposals from old nodes
+	if len(msg.Block.StatementSig) > 0 {
+		if err := verifyStatement(pub, msg.Block.statement(), msg.Block.StatementSig); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -135,6 +143,7 @@ type blockEndorseMsg struct {
 	FaultyProposals   []*FaultyReport `json:"faulty_proposals"`
 	ProposerSig       []byte          `json:"proposer_sig"`
 	EndorserSig       []byte          `json:"endorser_sig"`
+	StatementSig      []byte          `json:"statement_sig,omitempty"`
 }
 
 func (msg *blockEndorseMsg) Type() MsgType {
@@ -150,9 +159,27 @@ func (msg *blockEndorseMsg) Verify(pub keypair.PublicKey) error {
 	if !signature.Verify(pub, hash[:], sig) {
 		return fmt.Errorf("failed to verify block sig")
 	}
+	// verify statement, which is absent in endorsements from old nodes
+	if len(msg.StatementSig) > 0 {
+		if err := verifyStatement(pub, msg.statement(), msg.StatementSig); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// statement returns the endorse statement signed by the endorser
+func (msg *blockEndorseMsg) statement() *vconfig.ConsensusStatement {
+	return &vconfig.ConsensusStatement{
+		NetworkId: config.DefConfig.P2PNode.NetworkId,
+		Type:      vconfig.EndorseStatement,
+		BlockNum:  msg.BlockNum,
+		Proposer:  msg.EndorsedProposer,
+		BlockHash: msg.EndorsedBlockHash,
+		ForEmpty:  msg.EndorseForEmpty,
+	}
+}
+
 func (msg *blockEndorseMsg) GetBlockNum() uint32 {
 	return msg.BlockNum
 }
@@ -25,7 +25,6 @@ import (
 
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/log"
-	"github.com/dnaproject2/DNA/core/ledger"
 )
 
 type SyncCheckReq struct {
@@ -179,7 +178,7 @@ func (self *Syncer) run() {
 			for self.nextReqBlkNum <= self.targetBlkNum {
 				// FIXME: compete with ledger syncing
 				var blk *Block
-				if self.nextReqBlkNum <= ledger.DefLedger.GetCurrentBlockHeight() {
+				if self.nextReqBlkNum <= self.server.ledger.GetCurrentBlockHeight() {
 					blk, _ = self.server.blockPool.getSealedBlock(self.nextReqBlkNum)
 				}
 				if blk == nil {
@@ -0,0 +1,43 @@
+// Copyright (C) 2018 The DNA Authors
+// This file is part of The DNA library.
+//
+// The DNA is free software: you can redistribute it and/or modify
+// it under the terms of the GNU Lesser General Public License as published by
+// the Free Software Foundation, either version 3 of the License, or
+// (at your option) any later version.
+//
+// The DNA is distributed in the hope that it will be useful,
+// but WITHOUT ANY WARRANTY; without even the implied warranty of
+// MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+// GNU Lesser General Public License for more details.
+//
+// You should have received a copy of the GNU Lesser General Public License
+// along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+
+// +build !faults
+
+package vbft
+
+import (
+	"github.com/dnaproject2/DNA/common"
+	vconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
+	"github.com/dnaproject2/DNA/core/types"
+)
+
+// faultState is empty, the faults are only injected by the binary built with the faults tag
+type faultState struct{}
+
+func (self *Server) initFaults() {}
+
+func (self *Server) sendMsg(evt *SendMsgEvent, payload []byte) {
+	self.xmitMsg(evt, payload)
+}
+
+func (self *Server) equivocateProposal(proposal *blockProposalMsg, sysTxs, userTxs []*types.Transaction,
+	cfg *vconfig.ChainConfig) bool {
+	return false
+}
+
+func (self *Server) invalidPrevBlockHash(blkNum uint32, prevBlkHash common.Uint256) common.Uint256 {
+	return prevBlkHash
+}
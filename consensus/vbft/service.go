@@ -22,14 +22,17 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"path/filepath"
 	"reflect"
 	"sync"
 	"time"
 
 	"github.com/dnaproject2/DNA/account"
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
 	actorTypes "github.com/dnaproject2/DNA/consensus/actor"
+	"github.com/dnaproject2/DNA/consensus/policy"
 	"github.com/dnaproject2/DNA/consensus/vbft/config"
 	"github.com/dnaproject2/DNA/core/ledger"
 	"github.com/dnaproject2/DNA/core/payload"
@@ -45,6 +48,7 @@ import (
 	"github.com/ontio/ontology-crypto/keypair"
 	"github.com/ontio/ontology-crypto/vrf"
 	"github.com/ontio/ontology-eventbus/actor"
+	"github.com/ontio/ontology-eventbus/eventhub"
 )
 
 type BftActionType uint8
@@ -93,6 +97,8 @@ type Server struct {
 	p2p           *actorTypes.P2PActor
 	ledger        *ledger.Ledger
 	incrValidator *increment.IncrementValidator
+	// policy of the block proposers, refreshed from the ledger
+	bookkeeperPolicy *policy.Policy
 	pid           *actor.PID
 
 	// some config
@@ -111,10 +117,11 @@ type Server struct {
 	config                   *vconfig.ChainConfig
 	currentParticipantConfig *BlockParticipantConfig
 
-	chainStore *ChainStore // block store
-	msgPool    *MsgPool    // consensus msg pool
-	blockPool  *BlockPool  // received block proposals
-	peerPool   *PeerPool   // consensus peers
+	chainStore *ChainStore   // block store
+	msgPool    *MsgPool      // consensus msg pool
+	blockPool  *BlockPool    // received block proposals
+	evidences  *EvidencePool // equivocation evidences of peers
+	peerPool   *PeerPool     // consensus peers
 	syncer     *Syncer
 	stateMgr   *StateMgr
 	timer      *EventTimer
@@ -124,32 +131,62 @@ type Server struct {
 	bftActionC chan *BftAction
 	msgSendC   chan *SendMsgEvent
 	sub        *events.ActorSubscriber
+	walPath    string // consensus wal is disabled if empty
+	wal        *ConsensusWAL
+	faults     faultState
 	quitC      chan struct{}
 	quit       bool
 	quitWg     sync.WaitGroup
 }
 
 func NewVbftServer(account *account.Account, txpool, p2p *actor.PID) (*Server, error) {
+	walPath := filepath.Join(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName, WAL_FILE_NAME)
+	return newVbftServer(account, txpool, p2p, ledger.DefLedger, policy.BookkeeperPolicy, walPath,
+		"consensus_vbft", events.DefEvtHub)
+}
+
+// NewVbftServerWithLedger returns the vbft server which builds blocks on the ledger and receives the block
+// complete events from the event hub, so that several servers can run in one process with their own ledgers.
+// The server has its own bookkeeper policy refreshed from the ledger, and keeps its wal at walPath if not empty
+func NewVbftServerWithLedger(account *account.Account, txpool, p2p *actor.PID, ldg *ledger.Ledger,
+	evtHub *eventhub.EventHub, walPath string) (*Server, error) {
+	bookkeeperPolicy := policy.NewPolicy(policy.BOOKKEEPER_POLICY_NAME, config.DefConfig.Genesis.BookkeeperPolicy)
+	return newVbftServer(account, txpool, p2p, ldg, bookkeeperPolicy, walPath, "", evtHub)
+}
+
+// newVbftServer spawns the actor of vbft server with the name, an anonymous actor is spawned if the name is empty
+func newVbftServer(account *account.Account, txpool, p2p *actor.PID, ldg *ledger.Ledger,
+	bookkeeperPolicy *policy.Policy, walPath, name string, evtHub *eventhub.EventHub) (*Server, error) {
 	server := &Server{
 		msgHistoryDuration: 64,
 		account:            account,
 		poolActor:          &actorTypes.TxPoolActor{Pool: txpool},
 		p2p:                &actorTypes.P2PActor{P2P: p2p},
-		ledger:             ledger.DefLedger,
+		ledger:             ldg,
+		bookkeeperPolicy:   bookkeeperPolicy,
 		incrValidator:      increment.NewIncrementValidator(20),
+		walPath:            walPath,
 	}
 	server.stateMgr = newStateMgr(server)
+	server.refreshPolicy()
+	server.initFaults()
 
 	props := actor.FromProducer(func() actor.Actor {
 		return server
 	})
 
-	pid, err := actor.SpawnNamed(props, "consensus_vbft")
-	if err != nil {
-		return nil, err
+	var pid *actor.PID
+	var err error
+	if name == "" {
+		pid = actor.Spawn(props)
+	} else {
+		pid, err = actor.SpawnNamed(props, name)
+		if err != nil {
+			return nil, err
+		}
 	}
 	server.pid = pid
-	server.sub = events.NewActorSubscriber(pid)
+	server.sub = events.NewActorSubscriber(pid, evtHub)
 
 	if err := server.initialize(); err != nil {
 		return nil, fmt.Errorf("vbft server start failed: %s", err)
@@ -212,6 +249,7 @@ func (self *Server) handleBlockPersistCompleted(block *types.Block) {
 	}
 	self.completedBlockNum = block.Header.Height
 	self.incrValidator.AddBlock(block)
+	self.refreshPolicy()
 	if self.nonConsensusNode() {
 		self.chainStore.ReloadFromLedger()
 		self.metaLock.Lock()
@@ -229,6 +267,16 @@ func (self *Server) handleBlockPersistCompleted(block *types.Block) {
 	}
 }
 
+// refreshPolicy reloads the bookkeeper policy from the ledger of the server
+func (self *Server) refreshPolicy() {
+	if self.bookkeeperPolicy == nil {
+		return
+	}
+	if err := self.bookkeeperPolicy.Refresh(self.ledger); err != nil {
+		log.Errorf("server %d refresh bookkeeper policy error: %s", self.Index, err)
+	}
+}
+
 func (self *Server) CheckSubmitBlock(blkNum uint32, stateRoot common.Uint256) bool {
 	cMsgs := self.msgPool.GetBlockSubmitMsgNums(blkNum)
 	var stateRootCnt uint32
@@ -443,6 +491,7 @@ func (self *Server) initialize() error {
 		return fmt.Errorf("init blockpool: %s", err)
 	}
 	self.msgPool = newMsgPool(self, self.msgHistoryDuration)
+	self.evidences = newEvidencePool(self, self.msgHistoryDuration)
 	self.peerPool = NewPeerPool(0, self) // FIXME: maxSize
 	self.timer = NewEventTimer(self)
 	self.syncer = newSyncer(self)
@@ -482,6 +531,16 @@ func (self *Server) initialize() error {
 	} else {
 		self.Index = math.MaxUint32
 	}
+
+	if self.walPath != "" {
+		wal, msgs, err := openConsensusWAL(self.walPath)
+		if err != nil {
+			return fmt.Errorf("failed to open consensus wal: %s", err)
+		}
+		self.wal = wal
+		self.replayWAL(msgs)
+		log.Infof("consensus wal opened, %d msgs replayed", len(msgs))
+	}
 	self.sub.Subscribe(message.TOPIC_SAVE_BLOCK_COMPLETE)
 	go self.syncer.run()
 	go self.stateMgr.run()
@@ -553,7 +612,11 @@ func (self *Server) stop() {
 	self.syncer.stop()
 	self.timer.stop()
 	self.msgPool.clean()
+	self.evidences.clean()
 	self.blockPool.clean()
+	if self.wal != nil {
+		self.wal.close()
+	}
 	self.chainStore.close()
 	self.peerPool.clean()
 }
@@ -772,6 +835,7 @@ func (self *Server) onConsensusMsg(peerIdx uint32, msg ConsensusMsg, msgHash com
 		log.Debugf("dup msg with msg type %d from %d", msg.Type(), peerIdx)
 		return
 	}
+	self.collectStatement(peerIdx, msg)
 
 	switch msg.Type() {
 	case BlockProposalMessage:
@@ -780,6 +844,12 @@ func (self *Server) onConsensusMsg(peerIdx uint32, msg ConsensusMsg, msgHash com
 			log.Error("invalid msg with proposal msg type")
 			return
 		}
+		if pk := self.peerPool.GetPeerPubKey(pMsg.Block.getProposer()); pk != nil &&
+			!self.bookkeeperPolicy.IsAllowed(types.AddressFromPubKey(pk)) {
+			log.Warnf("server %d drop proposal msg from %d, denied by policy",
+				self.Index, pMsg.Block.getProposer())
+			return
+		}
 
 		msgBlkNum := pMsg.GetBlockNum()
 		if msgBlkNum > self.GetCurrentBlockNo() {
@@ -1107,6 +1177,16 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 		log.Errorf("BlockPrposalMessage check MerkleRoot blocknum:%d,msg MerkleRoot:%s,self MerkleRoot:%s", msg.GetBlockNum(), msgMerkleRoot.ToHexString(), merkleRoot.ToHexString())
 		return
 	}
+	stateRoot, err := self.ledger.GetHeaderStateRoot(msgBlkNum)
+	if err != nil {
+		log.Errorf("failed to GetHeaderStateRoot: %s,blkNum:%d", err, msgBlkNum)
+		return
+	}
+	if header := msg.Block.Block.Header; header.Version != types.HeaderVersion(msgBlkNum) || header.StateRoot != stateRoot {
+		self.msgPool.DropMsg(msg)
+		log.Errorf("BlockPrposalMessage check StateRoot blocknum:%d,version:%d,msg StateRoot:%s,self StateRoot:%s", msgBlkNum, header.Version, header.StateRoot.ToHexString(), stateRoot.ToHexString())
+		return
+	}
 	cfg := vconfig.ChainConfig{}
 	if blk.getNewChainConfig() != nil {
 		cfg = *blk.getNewChainConfig()
@@ -1141,8 +1221,14 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 		return
 	}
 
-	txs := msg.Block.Block.Transactions
-	if len(txs) > 0 && self.nonSystxs(txs, msgBlkNum) {
+	txs, err := self.nonSysTxs(msg.Block.Block.Transactions, msgBlkNum)
+	if err != nil {
+		log.Errorf("server %d failed to verify system txs of block %d proposal from %d: %s",
+			self.Index, msgBlkNum, msg.Block.getProposer(), err)
+		self.msgPool.DropMsg(msg)
+		return
+	}
+	if len(txs) > 0 {
 		height := uint32(msgBlkNum) - 1
 		start, end := self.incrValidator.BlockRange()
 
@@ -1173,7 +1259,7 @@ func (self *Server) processProposalMsg(msg *blockProposalMsg) {
 			self.processConsensusMsg(msg)
 		}()
 	} else {
-		// empty block, process directly
+		// empty block or system txs only, process directly
 		self.processConsensusMsg(msg)
 	}
 }
@@ -1210,6 +1296,9 @@ func (self *Server) processMsgEvent() error {
 					log.Errorf("failed to add block proposal (%d): %s", msgBlkNum, err)
 					return nil
 				}
+				if err := self.writeWAL(pMsg); err != nil {
+					log.Errorf("failed to write block proposal (%d) to wal: %s", msgBlkNum, err)
+				}
 
 				if self.isProposer(msgBlkNum, pMsg.Block.getProposer()) {
 					// check if agreed on prev-blockhash
@@ -1380,15 +1469,7 @@ func (self *Server) actionLoop() {
 					continue
 				}
 
-				var proposal *blockProposalMsg
-				msgs := self.msgPool.GetProposalMsgs(blkNum)
-				for _, m := range msgs {
-					if p, ok := m.(*blockProposalMsg); ok && p.Block.getProposer() == self.Index {
-						proposal = p
-						break
-					}
-				}
-				if proposal == nil {
+				if !self.hasProposed(blkNum) {
 					if err := self.makeProposal(blkNum, action.forEmpty); err != nil {
 						log.Errorf("server %d failed to making proposal (%d): %s",
 							self.Index, blkNum, err)
@@ -1910,6 +1991,9 @@ func (self *Server) endorseBlock(proposal *blockProposalMsg, forEmpty bool) erro
 	if err != nil {
 		return fmt.Errorf("failed to construct endorse msg: %s", err)
 	}
+	if err := self.writeWAL(proposal, endorseMsg); err != nil {
+		return fmt.Errorf("failed to write endorse msg to wal: %s", err)
+	}
 
 	// set the block as self-endorsed-block
 	if err := self.blockPool.setProposalEndorsed(proposal, forEmpty); err != nil {
@@ -1978,6 +2062,9 @@ func (self *Server) commitBlock(proposal *blockProposalMsg, forEmpty bool) error
 	if err != nil {
 		return fmt.Errorf("failed to construct commit msg: %s", err)
 	}
+	if err := self.writeWAL(proposal, commitMsg); err != nil {
+		return fmt.Errorf("failed to write commit msg to wal: %s", err)
+	}
 
 	// set the block as committed-block
 	if err := self.blockPool.setProposalCommitted(proposal, forEmpty); err != nil {
@@ -2068,7 +2155,16 @@ func (self *Server) sealBlock(block *Block, empty bool, sigdata bool) error {
 	self.msgPool.onBlockSealed(sealedBlkNum)
 	self.blockPool.onBlockSealed(sealedBlkNum)
 
-	_, h := self.blockPool.getSealedBlock(sealedBlkNum)
+	if self.wal != nil {
+		if err := self.wal.onBlockSealed(sealedBlkNum); err != nil {
+			log.Errorf("server %d failed to drop wal records of block %d: %s", self.Index, sealedBlkNum, err)
+		}
+	}
+
+	sealedBlk, h := self.blockPool.getSealedBlock(sealedBlkNum)
+	if sealedBlk != nil {
+		self.evidences.onBlockSealed(sealedBlk)
+	}
 	prevBlkHash := block.getPrevBlockHash()
 	log.Infof("server %d, sealed block %d, proposer %d, prevhash: %s, hash: %s", self.Index,
 		sealedBlkNum, block.getProposer(), prevBlkHash.ToHexString(), h.ToHexString())
@@ -2100,17 +2196,7 @@ func (self *Server) msgSendLoop() {
 				log.Errorf("server %d failed to serialized msg (type: %d): %s", self.Index, evt.Msg.Type(), err)
 				continue
 			}
-			if evt.ToPeer == math.MaxUint32 {
-				// broadcast
-				if err := self.broadcastToAll(payload); err != nil {
-					log.Errorf("server %d xmit msg (type %d): %s",
-						self.Index, evt.Msg.Type(), err)
-				}
-			} else {
-				if err := self.sendToPeer(evt.ToPeer, payload); err != nil {
-					log.Errorf("server %d xmit to peer %d failed: %s", self.Index, evt.ToPeer, err)
-				}
-			}
+			self.sendMsg(evt, payload)
 
 		case <-self.quitC:
 			log.Infof("server %d msgSendLoop quit", self.Index)
@@ -2119,6 +2205,114 @@ func (self *Server) msgSendLoop() {
 	}
 }
 
+func (self *Server) xmitMsg(evt *SendMsgEvent, payload []byte) {
+	if evt.ToPeer == math.MaxUint32 {
+		// broadcast
+		if err := self.broadcastToAll(payload); err != nil {
+			log.Errorf("server %d xmit msg (type %d): %s",
+				self.Index, evt.Msg.Type(), err)
+		}
+	} else {
+		if err := self.sendToPeer(evt.ToPeer, payload); err != nil {
+			log.Errorf("server %d xmit to peer %d failed: %s", self.Index, evt.ToPeer, err)
+		}
+	}
+}
+
+// writeWAL appends the msgs to consensus wal before they take effect
+func (self *Server) writeWAL(msgs ...ConsensusMsg) error {
+	if self.wal == nil {
+		return nil
+	}
+	for _, msg := range msgs {
+		if err := self.wal.append(msg); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replayWAL restores the msgs of unsealed rounds from consensus wal to msg pool and block pool,
+// including the proposals endorsed and committed by self
+func (self *Server) replayWAL(msgs []ConsensusMsg) {
+	for _, msg := range msgs {
+		blkNum := msg.GetBlockNum()
+		if blkNum <= self.GetCommittedBlockNo() {
+			continue
+		}
+		h, err := HashMsg(msg)
+		if err != nil {
+			continue
+		}
+		if err := self.msgPool.AddMsg(msg, h); err != nil {
+			log.Errorf("server %d failed to replay msg of block %d: %s", self.Index, blkNum, err)
+			continue
+		}
+		switch m := msg.(type) {
+		case *blockProposalMsg:
+			if err := self.blockPool.newBlockProposal(m); err != nil {
+				log.Errorf("server %d failed to replay proposal of block %d: %s", self.Index, blkNum, err)
+			}
+		case *blockEndorseMsg:
+			self.blockPool.newBlockEndorsement(m)
+			if m.Endorser != self.Index {
+				continue
+			}
+			if proposal := self.findBlockProposal(blkNum, m.EndorsedProposer, m.EndorseForEmpty); proposal != nil {
+				if err := self.blockPool.setProposalEndorsed(proposal, m.EndorseForEmpty); err != nil {
+					log.Errorf("server %d failed to replay endorsement of block %d: %s", self.Index, blkNum, err)
+				}
+			}
+		case *blockCommitMsg:
+			if err := self.blockPool.newBlockCommitment(m); err != nil {
+				log.Errorf("server %d failed to replay commitment of block %d: %s", self.Index, blkNum, err)
+			}
+			if m.Committer != self.Index {
+				continue
+			}
+			if proposal := self.findBlockProposal(blkNum, m.BlockProposer, m.CommitForEmpty); proposal != nil {
+				if err := self.blockPool.setProposalCommitted(proposal, m.CommitForEmpty); err != nil {
+					log.Errorf("server %d failed to replay commitment of block %d: %s", self.Index, blkNum, err)
+				}
+			}
+		}
+	}
+}
+
+// hasProposed checks if the proposal of blkNum has been made by self, as proposing twice in one round
+// is equivocation. The proposal is written to the consensus wal before it's sent, and replayed to msg pool
+// on startup, so the check holds across restarts.
+func (self *Server) hasProposed(blkNum uint32) bool {
+	for _, m := range self.msgPool.GetProposalMsgs(blkNum) {
+		if p, ok := m.(*blockProposalMsg); ok && p.Block.getProposer() == self.Index {
+			return true
+		}
+	}
+	return false
+}
+
+// collectStatement passes the statement in verified proposal or endorsement to evidence pool
+func (self *Server) collectStatement(peerIdx uint32, msg ConsensusMsg) {
+	var stmt *vconfig.ConsensusStatement
+	var sig []byte
+	switch m := msg.(type) {
+	case *blockProposalMsg:
+		stmt, sig = m.Block.statement(), m.Block.StatementSig
+	case *blockEndorseMsg:
+		stmt, sig = m.statement(), m.StatementSig
+	default:
+		return
+	}
+	if len(sig) == 0 {
+		return
+	}
+	pk := self.peerPool.GetPeerPubKey(peerIdx)
+	if pk == nil {
+		return
+	}
+	self.evidences.addStatement(vconfig.PubkeyID(pk), stmt, sig)
+}
+
 //creategovernaceTransaction invoke governance native contract commit_pos
 func (self *Server) creategovernaceTransaction(blkNum uint32) (*types.Transaction, error) {
 	mutable := utils.BuildNativeTransaction(nutils.GovernanceContractAddress, gover.COMMIT_DPOS, []byte{})
@@ -2143,7 +2337,7 @@ func (self *Server) checkNeedUpdateChainConfig(blockNum uint32) bool {
 
 //checkUpdateChainConfig query leveldb check is force update
 func (self *Server) checkUpdateChainConfig(blkNum uint32) bool {
-	force, err := isUpdate(self.blockPool.getExecWriteSet(blkNum-1), self.config.View)
+	force, err := isUpdate(self.blockPool.getExecWriteSet(blkNum-1), self.ledger, self.config.View)
 	if err != nil {
 		log.Errorf("checkUpdateChainConfig err:%s", err)
 		return false
@@ -2164,18 +2358,28 @@ func (self *Server) validHeight(blkNum uint32) uint32 {
 	return validHeight
 }
 
-func (self *Server) nonSystxs(sysTxs []*types.Transaction, blkNum uint32) bool {
-	if self.checkNeedUpdateChainConfig(blkNum) && len(sysTxs) == 1 {
-		invoke := sysTxs[0].Payload.(*payload.InvokeCode)
-		if invoke == nil {
-			log.Errorf("nonSystxs invoke is nil,blocknum:%d", blkNum)
-			return true
+// nonSysTxs returns the user txs of proposal, which are verified by txpool.
+// System txs are unsigned, so they are checked deterministically here instead
+func (self *Server) nonSysTxs(txs []*types.Transaction, blkNum uint32) ([]*types.Transaction, error) {
+	userTxs := make([]*types.Transaction, 0, len(txs))
+	for i, tx := range txs {
+		if i == 0 && isCommitDposTx(tx) && self.checkNeedUpdateChainConfig(blkNum) {
+			continue
 		}
-		if bytes.Compare(invoke.Code, ninit.COMMIT_DPOS_BYTES) == 0 {
-			return false
+		if isEvidenceTx(tx) {
+			if err := checkEvidenceTx(tx); err != nil {
+				return nil, fmt.Errorf("invalid evidence tx %d: %s", i, err)
+			}
+			continue
 		}
+		userTxs = append(userTxs, tx)
 	}
-	return true
+	return userTxs, nil
+}
+
+func isCommitDposTx(tx *types.Transaction) bool {
+	invoke, ok := tx.Payload.(*payload.InvokeCode)
+	return ok && len(tx.Sigs) == 0 && bytes.Equal(invoke.Code, ninit.COMMIT_DPOS_BYTES)
 }
 
 func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
@@ -2184,6 +2388,10 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 			self.Index, blkNum, self.GetCurrentBlockNo())
 	}
 
+	if self.hasProposed(blkNum) {
+		return fmt.Errorf("server %d has proposed block %d", self.Index, blkNum)
+	}
+
 	validHeight := self.validHeight(blkNum)
 	sysTxs := make([]*types.Transaction, 0)
 	userTxs := make([]*types.Transaction, 0)
@@ -2192,7 +2400,7 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 	cfg := &vconfig.ChainConfig{}
 	cfg = nil
 	if self.checkNeedUpdateChainConfig(blkNum) || self.checkUpdateChainConfig(blkNum) {
-		chainconfig, err := getChainConfig(self.blockPool.getExecWriteSet(blkNum-1), blkNum)
+		chainconfig, err := getChainConfig(self.blockPool.getExecWriteSet(blkNum-1), self.ledger, blkNum)
 		if err != nil {
 			return fmt.Errorf("getChainConfig failed:%s", err)
 		}
@@ -2208,6 +2416,12 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 		forEmpty = true
 		cfg = chainconfig
 	}
+	//add transactions report equivocation evidences to governance
+	evidenceTxs, err := self.evidences.getEvidenceTxs(blkNum)
+	if err != nil {
+		return fmt.Errorf("construct evidence transactions error: %v", err)
+	}
+	sysTxs = append(sysTxs, evidenceTxs...)
 	if self.nonConsensusNode() {
 		return fmt.Errorf("%d quit consensus node", self.Index)
 	}
@@ -2226,11 +2440,16 @@ func (self *Server) makeProposal(blkNum uint32, forEmpty bool) error {
 
 	log.Infof("server %d make proposal for block %d", self.Index, blkNum)
 
+	if err := self.writeWAL(proposal); err != nil {
+		return fmt.Errorf("failed to write proposal to wal: %s", err)
+	}
 	// add proposal to self
 	h, _ := HashMsg(proposal)
 	self.msgPool.AddMsg(proposal, h)
 	self.processProposalMsg(proposal)
-	self.broadcast(proposal)
+	if !self.equivocateProposal(proposal, sysTxs, userTxs, cfg) {
+		self.broadcast(proposal)
+	}
 	return nil
 }
 
@@ -24,6 +24,7 @@ import (
 	"io"
 
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/consensus/vbft/config"
 	"github.com/dnaproject2/DNA/core/types"
 )
@@ -33,6 +34,7 @@ type Block struct {
 	EmptyBlock          *types.Block
 	Info                *vconfig.VbftBlockInfo
 	PrevBlockMerkleRoot common.Uint256
+	StatementSig        []byte
 }
 
 func (blk *Block) getProposer() uint32 {
@@ -70,6 +72,17 @@ func (blk *Block) getVrfProof() []byte {
 	return blk.Info.VrfProof
 }
 
+// statement returns the proposal statement signed by the proposer
+func (blk *Block) statement() *vconfig.ConsensusStatement {
+	return &vconfig.ConsensusStatement{
+		NetworkId: config.DefConfig.P2PNode.NetworkId,
+		Type:      vconfig.ProposalStatement,
+		BlockNum:  blk.getBlockNum(),
+		Proposer:  blk.getProposer(),
+		BlockHash: blk.Block.Hash(),
+	}
+}
+
 func (blk *Block) Serialize() ([]byte, error) {
 	sink := common.NewZeroCopySink(nil)
 	blk.Block.Serialization(sink)
@@ -84,6 +97,10 @@ func (blk *Block) Serialize() ([]byte, error) {
 		payload.WriteVarBytes(sink2.Bytes())
 	}
 	payload.WriteHash(blk.PrevBlockMerkleRoot)
+	// statement signature is appended at last, which is ignored by the old nodes
+	if len(blk.StatementSig) > 0 {
+		payload.WriteVarBytes(blk.StatementSig)
+	}
 	return payload.Bytes(), nil
 }
 
@@ -133,10 +150,18 @@ func (blk *Block) Deserialize(data []byte) error {
 	if eof {
 		return fmt.Errorf("block deserialize merkleRoot: %s", io.ErrUnexpectedEOF)
 	}
+	var stmtSig []byte
+	if source.Len() > 0 {
+		stmtSig, _, irregular, eof = source.NextVarBytes()
+		if irregular || eof {
+			return fmt.Errorf("read statement sig failed: %v, %v", irregular, eof)
+		}
+	}
 	blk.Block = block
 	blk.EmptyBlock = emptyBlock
 	blk.Info = info
 	blk.PrevBlockMerkleRoot = merkleRoot
+	blk.StatementSig = stmtSig
 	return nil
 }
 
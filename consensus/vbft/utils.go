@@ -126,16 +126,16 @@ func verifyVrf(pk keypair.PublicKey, blkNum uint32, prevVrf, newVrf, proof []byt
 	return nil
 }
 
-func GetVbftConfigInfo(memdb *overlaydb.MemDB) (*config.VBFTConfig, error) {
+func GetVbftConfigInfo(memdb *overlaydb.MemDB, backend *ledger.Ledger) (*config.VBFTConfig, error) {
 	//get governance view
-	goveranceview, err := GetGovernanceView(memdb)
+	goveranceview, err := GetGovernanceView(memdb, backend)
 	if err != nil {
 		return nil, err
 	}
 
 	//get preConfig
 	preCfg := new(gov.PreConfig)
-	data, err := GetStorageValue(memdb, ledger.DefLedger, nutils.GovernanceContractAddress, []byte(gov.PRE_CONFIG))
+	data, err := GetStorageValue(memdb, backend, nutils.GovernanceContractAddress, []byte(gov.PRE_CONFIG))
 	if err != nil && err != scommon.ErrNotFound {
 		return nil, err
 	}
@@ -159,7 +159,7 @@ func GetVbftConfigInfo(memdb *overlaydb.MemDB) (*config.VBFTConfig, error) {
 			MaxBlockChangeView:   uint32(preCfg.Configuration.MaxBlockChangeView),
 		}
 	} else {
-		data, err := GetStorageValue(memdb, ledger.DefLedger, nutils.GovernanceContractAddress, []byte(gov.VBFT_CONFIG))
+		data, err := GetStorageValue(memdb, backend, nutils.GovernanceContractAddress, []byte(gov.VBFT_CONFIG))
 		if err != nil {
 			return nil, err
 		}
@@ -182,8 +182,8 @@ func GetVbftConfigInfo(memdb *overlaydb.MemDB) (*config.VBFTConfig, error) {
 	return chainconfig, nil
 }
 
-func GetPeersConfig(memdb *overlaydb.MemDB) ([]*config.VBFTPeerStakeInfo, error) {
-	goveranceview, err := GetGovernanceView(memdb)
+func GetPeersConfig(memdb *overlaydb.MemDB, backend *ledger.Ledger) ([]*config.VBFTPeerStakeInfo, error) {
+	goveranceview, err := GetGovernanceView(memdb, backend)
 	if err != nil {
 		return nil, err
 	}
@@ -192,7 +192,7 @@ func GetPeersConfig(memdb *overlaydb.MemDB) ([]*config.VBFTPeerStakeInfo, error)
 		return nil, err
 	}
 	key := append([]byte(gov.PEER_POOL), viewBytes...)
-	data, err := GetStorageValue(memdb, ledger.DefLedger, nutils.GovernanceContractAddress, key)
+	data, err := GetStorageValue(memdb, backend, nutils.GovernanceContractAddress, key)
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +217,8 @@ func GetPeersConfig(memdb *overlaydb.MemDB) ([]*config.VBFTPeerStakeInfo, error)
 	return peerstakes, nil
 }
 
-func isUpdate(memdb *overlaydb.MemDB, view uint32) (bool, error) {
-	goveranceview, err := GetGovernanceView(memdb)
+func isUpdate(memdb *overlaydb.MemDB, backend *ledger.Ledger, view uint32) (bool, error) {
+	goveranceview, err := GetGovernanceView(memdb, backend)
 	if err != nil {
 		return false, err
 	}
@@ -252,8 +252,8 @@ func GetStorageValue(memdb *overlaydb.MemDB, backend *ledger.Ledger, addr common
 	return
 }
 
-func GetGovernanceView(memdb *overlaydb.MemDB) (*gov.GovernanceView, error) {
-	value, err := GetStorageValue(memdb, ledger.DefLedger, nutils.GovernanceContractAddress, []byte(gov.GOVERNANCE_VIEW))
+func GetGovernanceView(memdb *overlaydb.MemDB, backend *ledger.Ledger) (*gov.GovernanceView, error) {
+	value, err := GetStorageValue(memdb, backend, nutils.GovernanceContractAddress, []byte(gov.GOVERNANCE_VIEW))
 	if err != nil {
 		return nil, err
 	}
@@ -265,17 +265,17 @@ func GetGovernanceView(memdb *overlaydb.MemDB) (*gov.GovernanceView, error) {
 	return governanceView, nil
 }
 
-func getChainConfig(memdb *overlaydb.MemDB, blkNum uint32) (*vconfig.ChainConfig, error) {
-	config, err := GetVbftConfigInfo(memdb)
+func getChainConfig(memdb *overlaydb.MemDB, backend *ledger.Ledger, blkNum uint32) (*vconfig.ChainConfig, error) {
+	config, err := GetVbftConfigInfo(memdb, backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get chainconfig from leveldb: %s", err)
 	}
 
-	peersinfo, err := GetPeersConfig(memdb)
+	peersinfo, err := GetPeersConfig(memdb, backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get peersinfo from leveldb: %s", err)
 	}
-	goverview, err := GetGovernanceView(memdb)
+	goverview, err := GetGovernanceView(memdb, backend)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get governanceview failed:%s", err)
 	}
@@ -0,0 +1,315 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+)
+
+const (
+	WAL_FILE_NAME   = "vbft.wal"
+	WAL_SEGMENT_EXT = ".seg"
+)
+
+//
+// Consensus WAL:
+// the signed outgoing msgs and the accepted proposals of the unsealed rounds are appended to the wal
+// and synced to disk before they take effect. On startup, the msgs are replayed to msg pool and block
+// pool, so the node restores what it has proposed, endorsed and committed, and never signs conflicting
+// msgs after crash.
+//
+// The wal is a dir of segments, one for each round, named by the block num. The records of a round are
+// appended to its segment, and the segments of sealed rounds are removed, so the wal is never rewritten.
+//
+// Each record is: data length (uint32), crc32 of data (uint32), serialized consensus msg.
+// A broken record at the end of a segment is left by crash in writing, which is truncated on startup.
+//
+
+type ConsensusWAL struct {
+	lock     sync.Mutex
+	dir      string
+	closed   bool
+	segments map[uint32]*os.File       // block num => segment, nil if the segment is not opened for appending
+	hashes   map[common.Uint256]uint32 // for dup record checking
+}
+
+// openConsensusWAL opens the wal at path, and returns the msgs recorded in it ordered by block num
+func openConsensusWAL(path string) (*ConsensusWAL, []ConsensusMsg, error) {
+	wal := &ConsensusWAL{
+		dir:      path,
+		segments: make(map[uint32]*os.File),
+		hashes:   make(map[common.Uint256]uint32),
+	}
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, nil, fmt.Errorf("create wal dir: %s", err)
+	}
+
+	infos, err := ioutil.ReadDir(path)
+	if err != nil {
+		return nil, nil, fmt.Errorf("read wal dir: %s", err)
+	}
+	blkNums := make([]uint32, 0, len(infos))
+	for _, info := range infos {
+		if blkNum, ok := parseSegmentName(info.Name()); ok && !info.IsDir() {
+			blkNums = append(blkNums, blkNum)
+		}
+	}
+	sort.Slice(blkNums, func(i, j int) bool { return blkNums[i] < blkNums[j] })
+
+	msgs := make([]ConsensusMsg, 0)
+	for _, blkNum := range blkNums {
+		segMsgs, err := wal.loadSegment(blkNum)
+		if err != nil {
+			return nil, nil, err
+		}
+		wal.segments[blkNum] = nil
+		msgs = append(msgs, segMsgs...)
+	}
+	return wal, msgs, nil
+}
+
+func segmentName(blkNum uint32) string {
+	return strconv.FormatUint(uint64(blkNum), 10) + WAL_SEGMENT_EXT
+}
+
+func parseSegmentName(name string) (uint32, bool) {
+	if !strings.HasSuffix(name, WAL_SEGMENT_EXT) {
+		return 0, false
+	}
+	blkNum, err := strconv.ParseUint(strings.TrimSuffix(name, WAL_SEGMENT_EXT), 10, 32)
+	if err != nil {
+		return 0, false
+	}
+	return uint32(blkNum), true
+}
+
+func (wal *ConsensusWAL) segmentPath(blkNum uint32) string {
+	return filepath.Join(wal.dir, segmentName(blkNum))
+}
+
+// loadSegment reads the msgs of the segment, and truncates the broken records at the end of it
+func (wal *ConsensusWAL) loadSegment(blkNum uint32) ([]ConsensusMsg, error) {
+	path := wal.segmentPath(blkNum)
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read wal segment %d: %s", blkNum, err)
+	}
+	msgs, valid := readWALRecords(data)
+	for _, msg := range msgs {
+		if msg.GetBlockNum() != blkNum {
+			return nil, fmt.Errorf("msg of block %d in wal segment %d", msg.GetBlockNum(), blkNum)
+		}
+		h, err := HashMsg(msg)
+		if err != nil {
+			return nil, fmt.Errorf("hash wal msg: %s", err)
+		}
+		wal.hashes[h] = blkNum
+	}
+	if valid < len(data) {
+		log.Warnf("truncate broken wal records at %d of %d in segment %d", valid, len(data), blkNum)
+		file, err := os.OpenFile(path, os.O_WRONLY, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("open wal segment %d: %s", blkNum, err)
+		}
+		err = file.Truncate(int64(valid))
+		if err == nil {
+			err = file.Sync()
+		}
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("truncate wal segment %d: %s", blkNum, err)
+		}
+	}
+	return msgs, nil
+}
+
+// readWALRecords returns the msgs of the records in data, and the size of the valid records
+func readWALRecords(data []byte) ([]ConsensusMsg, int) {
+	msgs := make([]ConsensusMsg, 0)
+	source := common.NewZeroCopySource(data)
+	for source.Len() > 0 {
+		pos := source.Pos()
+		msgData, err := readWALRecord(source)
+		if err != nil {
+			log.Warnf("drop broken wal record at %d of %d: %s", pos, source.Size(), err)
+			return msgs, int(pos)
+		}
+		msg, err := DeserializeVbftMsg(msgData)
+		if err != nil {
+			log.Warnf("drop invalid wal record at %d: %s", pos, err)
+			return msgs, int(pos)
+		}
+		msgs = append(msgs, msg)
+	}
+	return msgs, len(data)
+}
+
+func readWALRecord(source *common.ZeroCopySource) ([]byte, error) {
+	size, eof := source.NextUint32()
+	if eof {
+		return nil, fmt.Errorf("read record size: unexpected eof")
+	}
+	sum, eof := source.NextUint32()
+	if eof {
+		return nil, fmt.Errorf("read record checksum: unexpected eof")
+	}
+	data, eof := source.NextBytes(uint64(size))
+	if eof {
+		return nil, fmt.Errorf("read record data: unexpected eof")
+	}
+	if crc32.ChecksumIEEE(data) != sum {
+		return nil, fmt.Errorf("record checksum mismatch")
+	}
+	return data, nil
+}
+
+func writeWALRecord(file *os.File, data []byte) error {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteUint32(uint32(len(data)))
+	sink.WriteUint32(crc32.ChecksumIEEE(data))
+	sink.WriteBytes(data)
+	_, err := file.Write(sink.Bytes())
+	return err
+}
+
+// syncDir syncs the dir entries, so that the created and removed segments survive crash
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
+
+// segmentLocked returns the segment of blkNum opened for appending, which is created if not exists
+func (wal *ConsensusWAL) segmentLocked(blkNum uint32) (*os.File, error) {
+	file, present := wal.segments[blkNum]
+	if file != nil {
+		return file, nil
+	}
+	file, err := os.OpenFile(wal.segmentPath(blkNum), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("open wal segment %d: %s", blkNum, err)
+	}
+	if !present {
+		if err := syncDir(wal.dir); err != nil {
+			file.Close()
+			return nil, fmt.Errorf("sync wal dir: %s", err)
+		}
+	}
+	wal.segments[blkNum] = file
+	return file, nil
+}
+
+// append records the msg and syncs it to disk
+func (wal *ConsensusWAL) append(msg ConsensusMsg) error {
+	data, err := SerializeVbftMsg(msg)
+	if err != nil {
+		return fmt.Errorf("serialize msg: %s", err)
+	}
+	blkNum := msg.GetBlockNum()
+	h := hashData(data)
+
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.closed {
+		return fmt.Errorf("wal closed")
+	}
+	if _, present := wal.hashes[h]; present {
+		return nil
+	}
+	file, err := wal.segmentLocked(blkNum)
+	if err != nil {
+		return err
+	}
+	if err := writeWALRecord(file, data); err != nil {
+		return fmt.Errorf("write wal: %s", err)
+	}
+	if err := file.Sync(); err != nil {
+		return fmt.Errorf("sync wal: %s", err)
+	}
+	wal.hashes[h] = blkNum
+	return nil
+}
+
+// onBlockSealed removes the segments of sealed rounds
+func (wal *ConsensusWAL) onBlockSealed(blkNum uint32) error {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	if wal.closed {
+		return nil
+	}
+	removed := false
+	for num, file := range wal.segments {
+		if num > blkNum {
+			continue
+		}
+		if file != nil {
+			file.Close()
+		}
+		if err := os.Remove(wal.segmentPath(num)); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("remove wal segment %d: %s", num, err)
+		}
+		delete(wal.segments, num)
+		removed = true
+	}
+	if !removed {
+		return nil
+	}
+	for h, num := range wal.hashes {
+		if num <= blkNum {
+			delete(wal.hashes, h)
+		}
+	}
+	if err := syncDir(wal.dir); err != nil {
+		return fmt.Errorf("sync wal dir: %s", err)
+	}
+	return nil
+}
+
+func (wal *ConsensusWAL) closeSegmentsLocked() {
+	for num, file := range wal.segments {
+		if file != nil {
+			file.Close()
+			wal.segments[num] = nil
+		}
+	}
+}
+
+func (wal *ConsensusWAL) close() {
+	wal.lock.Lock()
+	defer wal.lock.Unlock()
+
+	wal.closeSegmentsLocked()
+	wal.closed = true
+}
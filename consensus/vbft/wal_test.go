@@ -0,0 +1,108 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package vbft
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+)
+
+func TestConsensusWAL(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vbft-wal")
+	if err != nil {
+		t.Fatalf("create temp dir failed: %v", err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "chain", WAL_FILE_NAME)
+
+	acc := account.NewAccount("SHA256withECDSA")
+	proposal := constructProposalMsgTest(acc)
+	endorse, _ := constructEndorseMsg(acc, proposal, proposal.Block.Block.Hash())
+
+	wal, msgs, err := openConsensusWAL(path)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("open wal failed: %v, %d", err, len(msgs))
+	}
+	for _, msg := range []ConsensusMsg{proposal, endorse, proposal} {
+		if err := wal.append(msg); err != nil {
+			t.Fatalf("append wal failed: %v", err)
+		}
+	}
+	wal.close()
+
+	// broken record left by crash is truncated
+	segment := filepath.Join(path, segmentName(proposal.GetBlockNum()))
+	info, err := os.Stat(segment)
+	if err != nil {
+		t.Fatalf("stat wal segment failed: %v", err)
+	}
+	size := info.Size()
+	file, err := os.OpenFile(segment, os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		t.Fatalf("open wal file failed: %v", err)
+	}
+	file.Write([]byte{100, 0, 0, 0, 1, 2, 3})
+	file.Close()
+
+	wal, msgs, err = openConsensusWAL(path)
+	if err != nil {
+		t.Fatalf("reopen wal failed: %v", err)
+	}
+	if len(msgs) != 2 || msgs[0].Type() != BlockProposalMessage || msgs[1].Type() != BlockEndorseMessage {
+		t.Fatalf("unexpected msgs replayed: %d", len(msgs))
+	}
+	h1, _ := HashMsg(msgs[0])
+	h2, _ := HashMsg(proposal)
+	if h1 != h2 {
+		t.Fatalf("replayed proposal mismatch")
+	}
+	if info, err = os.Stat(segment); err != nil || info.Size() != size {
+		t.Fatalf("broken record not truncated: %v", err)
+	}
+	// replayed msgs are not appended again
+	if err := wal.append(endorse); err != nil {
+		t.Fatalf("append wal failed: %v", err)
+	}
+	if info, err = os.Stat(segment); err != nil || info.Size() != size {
+		t.Fatalf("dup record appended: %v", err)
+	}
+
+	if err := wal.onBlockSealed(proposal.GetBlockNum() - 1); err != nil {
+		t.Fatalf("drop wal records failed: %v", err)
+	}
+	if _, err := os.Stat(segment); err != nil {
+		t.Fatalf("segment of unsealed block removed: %v", err)
+	}
+	if err := wal.onBlockSealed(proposal.GetBlockNum()); err != nil {
+		t.Fatalf("drop wal records failed: %v", err)
+	}
+	if _, err := os.Stat(segment); !os.IsNotExist(err) {
+		t.Fatalf("segment of sealed block not removed: %v", err)
+	}
+	wal.close()
+	wal, msgs, err = openConsensusWAL(path)
+	if err != nil || len(msgs) != 0 {
+		t.Fatalf("records of sealed block not dropped: %v, %d", err, len(msgs))
+	}
+	wal.close()
+}
@@ -20,13 +20,18 @@ package ledger
 
 import (
 	"fmt"
+	"io"
+	"sync"
+
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/core/payload"
 	"github.com/dnaproject2/DNA/core/states"
 	"github.com/dnaproject2/DNA/core/store"
+	scom "github.com/dnaproject2/DNA/core/store/common"
 	"github.com/dnaproject2/DNA/core/store/ledgerstore"
 	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/events"
 	"github.com/dnaproject2/DNA/smartcontract/event"
 	cstate "github.com/dnaproject2/DNA/smartcontract/states"
 	"github.com/ontio/ontology-crypto/keypair"
@@ -34,7 +39,9 @@ import (
 
 var DefLedger *Ledger
 
+//Ledger wraps the ledger store, the store is accessed exclusively only when it's replaced by a state snapshot
 type Ledger struct {
+	lock     sync.RWMutex
 	ldgStore store.LedgerStore
 }
 
@@ -48,11 +55,36 @@ func NewLedger(dataDir string, stateHashHeight uint32) (*Ledger, error) {
 	}, nil
 }
 
+//NewReadOnlyLedger open the ledger in the data dir read only, which is used to verify the blocks offline
+func NewReadOnlyLedger(dataDir string, stateHashHeight uint32) (*Ledger, error) {
+	ldgStore, err := ledgerstore.NewReadOnlyLedgerStore(dataDir, stateHashHeight)
+	if err != nil {
+		return nil, fmt.Errorf("NewReadOnlyLedgerStore error %s", err)
+	}
+	return &Ledger{
+		ldgStore: ldgStore,
+	}, nil
+}
+
 func (self *Ledger) GetStore() store.LedgerStore {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore
 }
 
+//SetEventPublisher set the publisher of the block complete events of the ledger, which is used to run
+//several ledgers in one process
+func (self *Ledger) SetEventPublisher(publisher *events.ActorPublisher) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	if ldgStore, ok := self.ldgStore.(*ledgerstore.LedgerStoreImp); ok {
+		ldgStore.SetEventPublisher(publisher)
+	}
+}
+
 func (self *Ledger) Init(defaultBookkeeper []keypair.PublicKey, genesisBlock *types.Block) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	err := self.ldgStore.InitLedgerStoreWithGenesisBlock(genesisBlock, defaultBookkeeper)
 	if err != nil {
 		return fmt.Errorf("InitLedgerStoreWithGenesisBlock error %s", err)
@@ -61,10 +93,14 @@ func (self *Ledger) Init(defaultBookkeeper []keypair.PublicKey, genesisBlock *ty
 }
 
 func (self *Ledger) AddHeaders(headers []*types.Header) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.AddHeaders(headers)
 }
 
 func (self *Ledger) AddBlock(block *types.Block, stateMerkleRoot common.Uint256) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	err := self.ldgStore.AddBlock(block, stateMerkleRoot)
 	if err != nil {
 		log.Errorf("Ledger AddBlock BlockHeight:%d BlockHash:%x error:%s", block.Header.Height, block.Hash(), err)
@@ -73,86 +109,140 @@ func (self *Ledger) AddBlock(block *types.Block, stateMerkleRoot common.Uint256)
 }
 
 func (self *Ledger) ExecuteBlock(b *types.Block) (store.ExecuteResult, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.ExecuteBlock(b)
 }
 
 func (self *Ledger) SubmitBlock(b *types.Block, exec store.ExecuteResult) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.SubmitBlock(b, exec)
 }
 
 func (self *Ledger) GetStateMerkleRoot(height uint32) (result common.Uint256, err error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetStateMerkleRoot(height)
 }
 
+func (self *Ledger) GetHeaderStateRoot(height uint32) (common.Uint256, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetHeaderStateRoot(height)
+}
+
 func (self *Ledger) GetBlockRootWithNewTxRoots(startHeight uint32, txRoots []common.Uint256) common.Uint256 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetBlockRootWithNewTxRoots(startHeight, txRoots)
 }
 
 func (self *Ledger) GetBlockByHeight(height uint32) (*types.Block, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetBlockByHeight(height)
 }
 
 func (self *Ledger) GetBlockByHash(blockHash common.Uint256) (*types.Block, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetBlockByHash(blockHash)
 }
 
 func (self *Ledger) GetHeaderByHeight(height uint32) (*types.Header, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetHeaderByHeight(height)
 }
 
 func (self *Ledger) GetHeaderByHash(blockHash common.Uint256) (*types.Header, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetHeaderByHash(blockHash)
 }
 func (self *Ledger) GetRawHeaderByHash(blockHash common.Uint256) (*types.RawHeader, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetRawHeaderByHash(blockHash)
 }
 
 func (self *Ledger) GetBlockHash(height uint32) common.Uint256 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetBlockHash(height)
 }
 
 func (self *Ledger) GetTransaction(txHash common.Uint256) (*types.Transaction, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	tx, _, err := self.ldgStore.GetTransaction(txHash)
 	return tx, err
 }
 
 func (self *Ledger) GetTransactionWithHeight(txHash common.Uint256) (*types.Transaction, uint32, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetTransaction(txHash)
 }
 
+func (self *Ledger) GetTransactionProof(txHash common.Uint256) (*types.Transaction, *scom.TransactionProof, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetTransactionProof(txHash)
+}
+
 func (self *Ledger) GetCurrentBlockHeight() uint32 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetCurrentBlockHeight()
 }
 
 func (self *Ledger) GetCurrentBlockHash() common.Uint256 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetCurrentBlockHash()
 }
 
 func (self *Ledger) GetCurrentHeaderHeight() uint32 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetCurrentHeaderHeight()
 }
 
 func (self *Ledger) GetCurrentHeaderHash() common.Uint256 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetCurrentHeaderHash()
 }
 
 func (self *Ledger) IsContainTransaction(txHash common.Uint256) (bool, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.IsContainTransaction(txHash)
 }
 
 func (self *Ledger) IsContainBlock(blockHash common.Uint256) (bool, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.IsContainBlock(blockHash)
 }
 
 func (self *Ledger) GetCurrentStateRoot() (common.Uint256, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return common.Uint256{}, nil
 }
 
 func (self *Ledger) GetBookkeeperState() (*states.BookkeeperState, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetBookkeeperState()
 }
 
 func (self *Ledger) GetStorageItem(codeHash common.Address, key []byte) ([]byte, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	storageKey := &states.StorageKey{
 		ContractAddress: codeHash,
 		Key:             key,
@@ -167,26 +257,139 @@ func (self *Ledger) GetStorageItem(codeHash common.Address, key []byte) ([]byte,
 	return storageItem.Value, nil
 }
 
+func (self *Ledger) GetStorageItemAtHeight(codeHash common.Address, key []byte, height uint32) ([]byte, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	storageKey := &states.StorageKey{
+		ContractAddress: codeHash,
+		Key:             key,
+	}
+	storageItem, err := self.ldgStore.GetStorageItemAtHeight(storageKey, height)
+	if err != nil {
+		return nil, err
+	}
+	if storageItem == nil {
+		return nil, nil
+	}
+	return storageItem.Value, nil
+}
+
+func (self *Ledger) GetStorageProof(contract common.Address, key []byte, height uint32) (*scom.StorageProof, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetStorageProof(contract, key, height)
+}
+
 func (self *Ledger) GetContractState(contractHash common.Address) (*payload.DeployCode, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetContractState(contractHash)
 }
 
 func (self *Ledger) GetMerkleProof(proofHeight, rootHeight uint32) ([]common.Uint256, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetMerkleProof(proofHeight, rootHeight)
 }
 
+func (self *Ledger) GetConsistencyProof(oldHeight, newHeight uint32) ([]common.Uint256, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetConsistencyProof(oldHeight, newHeight)
+}
+
 func (self *Ledger) PreExecuteContract(tx *types.Transaction) (*cstate.PreExecResult, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.PreExecuteContract(tx)
 }
 
+func (self *Ledger) PreExecuteContractAtHeight(tx *types.Transaction, height uint32) (*cstate.PreExecResult, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.PreExecuteContractAtHeight(tx, height)
+}
+
 func (self *Ledger) GetEventNotifyByTx(tx common.Uint256) (*event.ExecuteNotify, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetEventNotifyByTx(tx)
 }
 
 func (self *Ledger) GetEventNotifyByBlock(height uint32) ([]*event.ExecuteNotify, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
 	return self.ldgStore.GetEventNotifyByBlock(height)
 }
 
+func (self *Ledger) GetEventNotifyByContract(contract common.Address, topic string, startHeight, endHeight, offset, limit uint32) ([]*event.ContractNotify, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetEventNotifyByContract(contract, topic, startHeight, endHeight, offset, limit)
+}
+
+func (self *Ledger) GetAddressTxs(addr common.Address, offset, limit uint32, desc bool) ([]*scom.AddressTx, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetAddressTxs(addr, offset, limit, desc)
+}
+
+func (self *Ledger) RebuildAddressTxIndex(progress func(height uint32)) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.RebuildAddressTxIndex(progress)
+}
+
+func (self *Ledger) GetPrunedHeight() uint32 {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetPrunedHeight()
+}
+
+func (self *Ledger) SaveSnapshot(w io.Writer) (uint32, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.SaveSnapshot(w)
+}
+
+func (self *Ledger) GetSnapshotInfo(height uint32) (*scom.SnapshotInfo, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetSnapshotInfo(height)
+}
+
+func (self *Ledger) GetCommittedStateRoot(height uint32) (common.Uint256, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.GetCommittedStateRoot(height)
+}
+
+//ApplySnapshot replace the empty ledger with the state snapshot file of the height, no one else can access the ledger meanwhile
+func (self *Ledger) ApplySnapshot(file string, height uint32) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	return self.ldgStore.ApplySnapshot(file, height)
+}
+
+func (self *Ledger) Rollback(height uint32) error {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.Rollback(height)
+}
+
+func (self *Ledger) VerifyBlocks(snapshot string, endHeight uint32, progress func(height uint32)) (*scom.LedgerDivergence, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.ldgStore.VerifyBlocks(snapshot, endHeight, progress)
+}
+
+//LoadSnapshot load the state snapshot to the empty ledger in the data dir
+func LoadSnapshot(dataDir string, r io.Reader) (uint32, error) {
+	return ledgerstore.LoadSnapshot(dataDir, r)
+}
+
 func (self *Ledger) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
 	return self.ldgStore.Close()
 }
@@ -0,0 +1,62 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package common
+
+import (
+	"fmt"
+	"sync"
+)
+
+//Backend is a key-value engine which the persist stores of ledger are opened with
+type Backend struct {
+	Open     func(path string) (PersistStore, error) //Open the store at the path
+	InMemory bool                                    //Whether the data are only kept in memory and lost on close
+}
+
+var backends = struct {
+	sync.RWMutex
+	entries map[string]*Backend
+}{entries: make(map[string]*Backend)}
+
+//RegisterBackend register a key-value backend with the name
+func RegisterBackend(name string, backend *Backend) {
+	backends.Lock()
+	defer backends.Unlock()
+	backends.entries[name] = backend
+}
+
+//GetBackend return the registered backend of the name
+func GetBackend(name string) (*Backend, error) {
+	backends.RLock()
+	defer backends.RUnlock()
+	backend, ok := backends.entries[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown db backend %s", name)
+	}
+	return backend, nil
+}
+
+//OpenStore open the persist store at the path with the named backend
+func OpenStore(name, path string) (PersistStore, error) {
+	backend, err := GetBackend(name)
+	if err != nil {
+		return nil, err
+	}
+	return backend.Open(path)
+}
@@ -0,0 +1,52 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package common
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackend(t *testing.T) {
+	_, err := GetBackend("test unknown")
+	assert.NotNil(t, err)
+	_, err = OpenStore("test unknown", "path")
+	assert.NotNil(t, err)
+
+	opened := ""
+	openErr := errors.New("open error")
+	RegisterBackend("test", &Backend{Open: func(path string) (PersistStore, error) {
+		opened = path
+		return nil, openErr
+	}})
+	backend, err := GetBackend("test")
+	assert.Nil(t, err)
+	assert.False(t, backend.InMemory)
+	_, err = OpenStore("test", "path")
+	assert.Equal(t, openErr, err)
+	assert.Equal(t, "path", opened)
+
+	// the backend registered later replaces the one of the same name
+	RegisterBackend("test", &Backend{InMemory: true})
+	backend, err = GetBackend("test")
+	assert.Nil(t, err)
+	assert.True(t, backend.InMemory)
+}
@@ -27,6 +27,8 @@ const (
 	DATA_HEADER                            = 0x01 //Block hash => block hash key prefix
 	DATA_TRANSACTION                       = 0x02 //Transction hash = > transaction key prefix
 	DATA_STATE_MERKLE_ROOT                 = 0x21 // block height => write set hash + state merkle root
+	DATA_STATE_TREE_ROOT                   = 0x24 // block height => state tree root
+	DATA_STATE_UNDO                        = 0x28 // block height => previous values of the states written by the block
 
 	// Transaction
 	ST_BOOKKEEPER DataEntryPrefix = 0x03 //BookKeeper state key prefix
@@ -35,6 +37,10 @@ const (
 	ST_VALIDATOR  DataEntryPrefix = 0x07 //no use
 	ST_VOTE       DataEntryPrefix = 0x08 //Vote state key prefix
 
+	ST_STATE_TREE_NODE  DataEntryPrefix = 0x22 //State tree node hash => state tree node key prefix
+	ST_STATE_TREE_VALUE DataEntryPrefix = 0x23 //State value hash => state value key prefix
+	ST_ARCHIVE          DataEntryPrefix = 0x25 //State key + block height => archived state value key prefix
+
 	IX_HEADER_HASH_LIST DataEntryPrefix = 0x09 //Block height => block hash key prefix
 
 	//SYSTEM
@@ -43,6 +49,11 @@ const (
 	SYS_CURRENT_STATE_ROOT DataEntryPrefix = 0x12 //no use
 	SYS_BLOCK_MERKLE_TREE  DataEntryPrefix = 0x13 // Block merkle tree root key prefix
 	SYS_STATE_MERKLE_TREE  DataEntryPrefix = 0x20 // state merkle tree root key prefix
+	SYS_ARCHIVE_HEIGHT     DataEntryPrefix = 0x26 // First archived block height key prefix
+	SYS_PRUNED_HEIGHT      DataEntryPrefix = 0x27 // First unpruned block height key prefix
 
-	EVENT_NOTIFY DataEntryPrefix = 0x14 //Event notify key prefix
+	EVENT_NOTIFY          DataEntryPrefix = 0x14 //Event notify key prefix
+	EVENT_NOTIFY_CONTRACT DataEntryPrefix = 0x15 //Contract + height + tx hash + index => event notify index key prefix
+	EVENT_NOTIFY_TOPIC    DataEntryPrefix = 0x16 //Contract + topic hash + height + tx hash + index => event notify index key prefix
+	EVENT_ADDRESS_TX      DataEntryPrefix = 0x17 //Address + height + tx hash => address transaction index key prefix
 )
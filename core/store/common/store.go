@@ -22,10 +22,13 @@ import (
 	"errors"
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/core/states"
+	"github.com/dnaproject2/DNA/merkle"
 	"github.com/dnaproject2/DNA/smartcontract/event"
 )
 
 var ErrNotFound = errors.New("not found")
+var ErrPruned = errors.New("pruned")
+var ErrLightMode = errors.New("not kept in light mode")
 
 //Store iterator for iterate store
 type StoreIterator interface {
@@ -40,6 +43,19 @@ type StoreIterator interface {
 	Error() error  // Error returns any accumulated error.
 }
 
+//SeekableIterator is a store iterator which can move to the first key not less than the given one
+type SeekableIterator interface {
+	StoreIterator
+	Seek(key []byte) bool //Seek key. If item available return true, otherwise return false
+}
+
+//ReversibleIterator is a store iterator which can iterate backward
+type ReversibleIterator interface {
+	StoreIterator
+	Last() bool //Last item. If item available return true, otherwise return false
+	Prev() bool //previous item. If item available return true, otherwise return false
+}
+
 //PersistStore of ledger
 type PersistStore interface {
 	Put(key []byte, value []byte) error      //Put the key-value pair to store
@@ -54,6 +70,23 @@ type PersistStore interface {
 	NewIterator(prefix []byte) StoreIterator //Return the iterator of store
 }
 
+//CompactableStore is a PersistStore whose underlying storage can be compacted
+type CompactableStore interface {
+	Compact(prefix []byte) error //Compact the storage of the keys with the prefix, nil prefix for all
+}
+
+//StoreSnapshot is a read only view of a PersistStore at the time it is taken
+type StoreSnapshot interface {
+	Get(key []byte) ([]byte, error)          //Get the value if key in snapshot
+	NewIterator(prefix []byte) StoreIterator //Return the iterator of snapshot
+	Release()                                //Release snapshot
+}
+
+//SnapshotableStore is a PersistStore which takes read only snapshots
+type SnapshotableStore interface {
+	GetSnapshot() (StoreSnapshot, error) //Return the snapshot of current store
+}
+
 //StateStore save result of smart contract execution, before commit to store
 type StateStore interface {
 	//Add key-value pair to store
@@ -88,12 +121,61 @@ type EventStore interface {
 	SaveEventNotifyByTx(txHash common.Uint256, notify *event.ExecuteNotify) error
 	//Save transaction hashes which have event notify gen
 	SaveEventNotifyByBlock(height uint32, txHashs []common.Uint256) error
+	//SaveEventNotifyIndex save the contract and topic index of event notify
+	SaveEventNotifyIndex(height uint32, notify *event.ExecuteNotify) error
 	//GetEventNotifyByTx return event notify by transaction hash
 	GetEventNotifyByTx(txHash common.Uint256) (*event.ExecuteNotify, error)
 	//Commit event notify to store
 	CommitTo() error
 }
 
+//AddressTx describe a transaction which involves an address
+type AddressTx struct {
+	Height uint32
+	TxHash common.Uint256
+}
+
+//SnapshotInfo identifies the state snapshot of a block height
+type SnapshotInfo struct {
+	Height          uint32
+	BlockHash       common.Uint256
+	StateRoot       common.Uint256 //State tree root of the block
+	StateMerkleRoot common.Uint256 //State merkle root of the block
+}
+
+//LedgerDivergence describes the first block whose check or re-execution diverges from the ledger
+type LedgerDivergence struct {
+	Height uint32
+	Reason string
+	States []*DivergentState //States whose values after the block differ
+}
+
+//DivergentState is a state key with its value saved in ledger and its value of re-execution,
+//nil value means the state doesn't exist
+type DivergentState struct {
+	Key      []byte
+	Stored   []byte
+	Executed []byte
+}
+
+//StorageProof proves the value of a state key at a block height against the state tree root,
+//Value is nil if the key doesn't exist
+type StorageProof struct {
+	Height uint32
+	Root   common.Uint256
+	Key    []byte
+	Value  []byte
+	Proof  *merkle.SparseMerkleProof
+}
+
+//TransactionProof proves a transaction is included in the block of the height by the merkle path
+//from the transaction hash to the transactions root of block header, Index is the transaction index in block
+type TransactionProof struct {
+	Height uint32
+	Index  uint32
+	Path   []common.Uint256
+}
+
 //State item type
 type ItemState byte
 
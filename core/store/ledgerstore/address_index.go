@@ -0,0 +1,135 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+	"github.com/dnaproject2/DNA/smartcontract/service/native/ont"
+	"github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+)
+
+//getTxAddresses return the addresses involved in the transaction: the payer, the signers,
+//and the from and to of the ont and ong transfers in the event notify
+func getTxAddresses(tx *types.Transaction, notify *event.ExecuteNotify) []common.Address {
+	addrs := make([]common.Address, 0)
+	exist := make(map[common.Address]bool)
+	add := func(addr common.Address) {
+		if !exist[addr] {
+			exist[addr] = true
+			addrs = append(addrs, addr)
+		}
+	}
+
+	add(tx.Payer)
+	signers, err := tx.GetSignatureAddresses()
+	if err != nil {
+		txHash := tx.Hash()
+		log.Warnf("getTxAddresses tx:%s GetSignatureAddresses error:%s", txHash.ToHexString(), err)
+	}
+	for _, signer := range signers {
+		add(signer)
+	}
+	if notify == nil {
+		return addrs
+	}
+	for _, n := range notify.Notify {
+		if n.ContractAddress != utils.OntContractAddress && n.ContractAddress != utils.OngContractAddress {
+			continue
+		}
+		states, ok := n.States.([]interface{})
+		if !ok || len(states) < 3 {
+			continue
+		}
+		if name, ok := states[0].(string); !ok || name != ont.TRANSFER_NAME {
+			continue
+		}
+		for _, state := range states[1:3] {
+			s, ok := state.(string)
+			if !ok {
+				continue
+			}
+			addr, err := common.AddressFromBase58(s)
+			if err != nil {
+				continue
+			}
+			add(addr)
+		}
+	}
+	return addrs
+}
+
+//saveAddressTxIndex index the transactions of the block by the addresses they involve
+func (this *LedgerStoreImp) saveAddressTxIndex(block *types.Block, notifies []*event.ExecuteNotify) {
+	txNotifies := make(map[common.Uint256]*event.ExecuteNotify, len(notifies))
+	for _, notify := range notifies {
+		txNotifies[notify.TxHash] = notify
+	}
+	for _, tx := range block.Transactions {
+		txHash := tx.Hash()
+		this.eventStore.SaveAddressTxIndex(block.Header.Height, txHash, getTxAddresses(tx, txNotifies[txHash]))
+	}
+}
+
+//RebuildAddressTxIndex drop the address transaction index and build it again from the saved blocks
+//and event notifies which are not pruned, progress is called after each block is indexed
+func (this *LedgerStoreImp) RebuildAddressTxIndex(progress func(height uint32)) error {
+	err := this.eventStore.ClearAddressTxIndex()
+	if err != nil {
+		return fmt.Errorf("ClearAddressTxIndex error %s", err)
+	}
+	currentHeight := this.GetCurrentBlockHeight()
+	for height := this.prunedHeight; height <= currentHeight; height++ {
+		block, err := this.GetBlockByHeight(height)
+		if err != nil {
+			return fmt.Errorf("GetBlockByHeight height:%d error:%s", height, err)
+		}
+		notifies := make([]*event.ExecuteNotify, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			notify, err := this.eventStore.GetEventNotifyByTx(tx.Hash())
+			if err != nil {
+				if err != scom.ErrNotFound {
+					return fmt.Errorf("GetEventNotifyByTx height:%d error:%s", height, err)
+				}
+				continue
+			}
+			notifies = append(notifies, notify)
+		}
+		this.eventStore.NewBatch()
+		this.saveAddressTxIndex(block, notifies)
+		err = this.eventStore.CommitTo()
+		if err != nil {
+			return fmt.Errorf("eventStore.CommitTo height:%d error %s", height, err)
+		}
+		if progress != nil {
+			progress(height)
+		}
+	}
+	return nil
+}
+
+//GetAddressTxs return the transactions which involve the address. Wrap function of EventStore.GetAddressTxs
+func (this *LedgerStoreImp) GetAddressTxs(addr common.Address, offset, limit uint32, desc bool) ([]*scom.AddressTx, error) {
+	return this.eventStore.GetAddressTxs(addr, offset, limit, desc)
+}
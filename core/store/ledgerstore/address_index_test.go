@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+	"github.com/dnaproject2/DNA/smartcontract/service/native/ont"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTxAddresses(t *testing.T) {
+	payer, from, to, other := common.Address{1}, common.Address{2}, common.Address{3}, common.Address{4}
+	tx := payTestContract(t, []byte{byte(0x51)}, payer)
+	assert.Equal(t, []common.Address{payer}, getTxAddresses(tx, nil))
+
+	notify := &event.ExecuteNotify{
+		TxHash: tx.Hash(),
+		Notify: []*event.NotifyEventInfo{
+			{
+				ContractAddress: nutils.OntContractAddress,
+				States:          []interface{}{ont.TRANSFER_NAME, from.ToBase58(), to.ToBase58(), uint64(1)},
+			},
+			{
+				ContractAddress: nutils.OngContractAddress,
+				States:          []interface{}{ont.TRANSFER_NAME, to.ToBase58(), payer.ToBase58(), uint64(1)},
+			},
+			// only the ont and ong transfers are indexed
+			{
+				ContractAddress: common.Address{5},
+				States:          []interface{}{ont.TRANSFER_NAME, other.ToBase58(), other.ToBase58(), uint64(1)},
+			},
+			{
+				ContractAddress: nutils.OntContractAddress,
+				States:          []interface{}{"approve", other.ToBase58(), other.ToBase58(), uint64(1)},
+			},
+		},
+	}
+	assert.Equal(t, []common.Address{payer, from, to}, getTxAddresses(tx, notify))
+}
+
+func TestGetAddressTxs(t *testing.T) {
+	store := &EventStore{store: memstore.NewMemStore()}
+	addr, other := common.Address{1}, common.Address{2}
+	store.NewBatch()
+	for height := uint32(1); height <= 5; height++ {
+		store.SaveAddressTxIndex(height, common.Uint256{byte(height)}, []common.Address{addr})
+	}
+	store.SaveAddressTxIndex(3, common.Uint256{0xff}, []common.Address{other})
+	assert.Nil(t, store.CommitTo())
+
+	heights := func(offset, limit uint32, desc bool) []uint32 {
+		txs, err := store.GetAddressTxs(addr, offset, limit, desc)
+		assert.Nil(t, err)
+		heights := make([]uint32, 0, len(txs))
+		for _, tx := range txs {
+			assert.Equal(t, common.Uint256{byte(tx.Height)}, tx.TxHash)
+			heights = append(heights, tx.Height)
+		}
+		return heights
+	}
+	assert.Equal(t, []uint32{1, 2, 3, 4, 5}, heights(0, 10, false))
+	assert.Equal(t, []uint32{5, 4, 3, 2, 1}, heights(0, 10, true))
+	assert.Equal(t, []uint32{2, 3}, heights(1, 2, false))
+	assert.Equal(t, []uint32{4, 3}, heights(1, 2, true))
+	assert.Equal(t, []uint32{}, heights(5, 2, true))
+
+	txs, err := store.GetAddressTxs(other, 0, 10, true)
+	assert.Nil(t, err)
+	assert.Equal(t, []*scom.AddressTx{{Height: 3, TxHash: common.Uint256{0xff}}}, txs)
+}
+
+func TestRebuildAddressTxIndex(t *testing.T) {
+	old := config.DefConfig.Common.EnableAddressIndex
+	defer func() { config.DefConfig.Common.EnableAddressIndex = old }()
+	config.DefConfig.Common.EnableAddressIndex = true
+	ledger := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer ledger.close()
+
+	payer := account.NewAccount("").Address
+	for i := 0; i < 3; i++ {
+		ledger.addBlocks(t, 1, payTestContract(t, []byte{byte(0x51), byte(i)}, payer))
+	}
+	assert.Equal(t, 3, ledger.addressTxCount(t, payer))
+
+	assert.Nil(t, ledger.eventStore.ClearAddressTxIndex())
+	assert.Equal(t, 0, ledger.addressTxCount(t, payer))
+
+	indexed := make([]uint32, 0)
+	assert.Nil(t, ledger.RebuildAddressTxIndex(func(height uint32) {
+		indexed = append(indexed, height)
+	}))
+	assert.Equal(t, []uint32{0, 1, 2, 3}, indexed)
+	txs, err := ledger.GetAddressTxs(payer, 0, 10, true)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(txs))
+	assert.Equal(t, uint32(3), txs[0].Height)
+}
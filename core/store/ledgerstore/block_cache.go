@@ -102,3 +102,13 @@ func (this *BlockCache) GetTransaction(txHash common.Uint256) (*types.Transactio
 func (this *BlockCache) ContainTransaction(txHash common.Uint256) bool {
 	return this.transactionCache.Contains(string(txHash.ToArray()))
 }
+
+//RemoveBlock remove the block from cache
+func (this *BlockCache) RemoveBlock(blockHash common.Uint256) {
+	this.blockCache.Remove(string(blockHash.ToArray()))
+}
+
+//RemoveTransaction remove the transaction from cache
+func (this *BlockCache) RemoveTransaction(txHash common.Uint256) {
+	this.transactionCache.Remove(string(txHash.ToArray()))
+}
@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+)
+
+const (
+	PRUNE_BLOCKS_PER_SAVE  = uint32(100)   //Max count of blocks pruned after saving a block
+	PRUNE_COMPACT_INTERVAL = uint32(10000) //Count of pruned blocks between two compactions of stores
+)
+
+//pruneBlocks prune the blocks below the latest keepBlocks heights, at most PRUNE_BLOCKS_PER_SAVE
+//blocks each time, and compacts the stores every PRUNE_COMPACT_INTERVAL pruned blocks
+func (this *LedgerStoreImp) pruneBlocks(currBlockHeight uint32) error {
+	if this.keepBlocks == 0 || currBlockHeight < this.keepBlocks {
+		return nil
+	}
+	keepHeight := currBlockHeight - this.keepBlocks + 1
+	if keepHeight <= this.prunedHeight {
+		// the ledger loaded from snapshot is pruned to the snapshot height
+		return nil
+	}
+	if keepHeight-this.prunedHeight > PRUNE_BLOCKS_PER_SAVE {
+		keepHeight = this.prunedHeight + PRUNE_BLOCKS_PER_SAVE
+	}
+	for height := this.prunedHeight; height < keepHeight; height++ {
+		err := this.pruneBlock(height)
+		if err != nil {
+			return fmt.Errorf("pruneBlock height:%d error:%s", height, err)
+		}
+	}
+	if this.prunedHeight-this.compactedHeight >= PRUNE_COMPACT_INTERVAL {
+		return this.compactStores()
+	}
+	return nil
+}
+
+//pruneBlock delete the transactions and the event notifies of the block, with their index. The event store is
+//committed first with its own pruned height, so that a prune interrupted before the block store commit skips
+//the event store when run again, rather than missing the deleted notifies which the address index needs
+func (this *LedgerStoreImp) pruneBlock(height uint32) error {
+	block, err := this.blockStore.GetBlock(this.GetBlockHash(height))
+	if err != nil && err != scom.ErrPruned {
+		return err
+	}
+	eventPrunedHeight, err := this.eventStore.GetPrunedHeight()
+	if err != nil {
+		return fmt.Errorf("eventStore.GetPrunedHeight error %s", err)
+	}
+	if block != nil && height >= eventPrunedHeight {
+		this.eventStore.NewBatch()
+		notifies := make([]*event.ExecuteNotify, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			txHash := tx.Hash()
+			notify, err := this.eventStore.GetEventNotifyByTx(txHash)
+			if err != nil && err != scom.ErrNotFound {
+				return fmt.Errorf("GetEventNotifyByTx error %s", err)
+			}
+			if notify != nil {
+				notifies = append(notifies, notify)
+			}
+			if config.DefConfig.Common.EnableAddressIndex {
+				this.eventStore.DeleteAddressTxIndex(height, txHash, getTxAddresses(tx, notify))
+			}
+		}
+		err = this.eventStore.PruneEventNotifyByBlock(height, notifies)
+		if err != nil {
+			return fmt.Errorf("PruneEventNotifyByBlock error %s", err)
+		}
+		this.eventStore.SavePrunedHeight(height + 1)
+		err = this.eventStore.CommitTo()
+		if err != nil {
+			return fmt.Errorf("eventStore.CommitTo error %s", err)
+		}
+	}
+	this.blockStore.NewBatch()
+	if block != nil {
+		this.blockStore.PruneBlock(block)
+	}
+	this.blockStore.SavePrunedHeight(height + 1)
+	err = this.blockStore.CommitTo()
+	if err != nil {
+		return fmt.Errorf("blockStore.CommitTo error %s", err)
+	}
+	this.prunedHeight = height + 1
+	return nil
+}
+
+//compactStores drop the state tree, the archived states and the undo states below the pruned height, and
+//compacts the block store and the event store in background
+func (this *LedgerStoreImp) compactStores() error {
+	height := this.prunedHeight
+	log.Infof("compact stores below height:%d", height)
+	err := this.stateStore.PruneStateTree(height)
+	if err != nil {
+		return fmt.Errorf("PruneStateTree error %s", err)
+	}
+	err = this.stateStore.PruneArchive(height)
+	if err != nil {
+		return fmt.Errorf("PruneArchive error %s", err)
+	}
+	err = this.stateStore.PruneUndoStates(height)
+	if err != nil {
+		return fmt.Errorf("PruneUndoStates error %s", err)
+	}
+	this.compactedHeight = height
+	go func() {
+		if err := this.eventStore.Compact(); err != nil {
+			log.Warnf("compact event store error %s", err)
+		}
+		if err := this.blockStore.Compact(); err != nil {
+			log.Warnf("compact block store error %s", err)
+		}
+	}()
+	return nil
+}
+
+//GetPrunedHeight return the first block height whose transactions and events are not pruned
+func (this *LedgerStoreImp) GetPrunedHeight() uint32 {
+	return this.prunedHeight
+}
@@ -0,0 +1,158 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+	"github.com/dnaproject2/DNA/smartcontract/service/native/ont"
+	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+// payTestContract returns a deploy transaction of the code paid by the payer
+func payTestContract(t *testing.T, code []byte, payer common.Address) *types.Transaction {
+	mutable := utils.NewDeployTransaction(code, "test", "1.0", "", "", "", false)
+	mutable.Payer = payer
+	tx, err := mutable.IntoImmutable()
+	assert.Nil(t, err)
+	return tx
+}
+
+// saveTransferNotify replaces the event notify of the tx with an ont transfer to the address, and indexes the tx by it
+func (self *testLedger) saveTransferNotify(t *testing.T, tx *types.Transaction, height uint32, to common.Address) {
+	notify := &event.ExecuteNotify{
+		TxHash: tx.Hash(),
+		State:  event.CONTRACT_STATE_SUCCESS,
+		Notify: []*event.NotifyEventInfo{{
+			ContractAddress: nutils.OntContractAddress,
+			States:          []interface{}{ont.TRANSFER_NAME, tx.Payer.ToBase58(), to.ToBase58(), uint64(1)},
+		}},
+	}
+	self.eventStore.NewBatch()
+	assert.Nil(t, self.eventStore.SaveEventNotifyByTx(tx.Hash(), notify))
+	self.eventStore.SaveAddressTxIndex(height, tx.Hash(), []common.Address{to})
+	assert.Nil(t, self.eventStore.CommitTo())
+}
+
+// addressTxCount returns the count of the indexed transactions which involve the address
+func (self *testLedger) addressTxCount(t *testing.T, addr common.Address) int {
+	txs, err := self.GetAddressTxs(addr, 0, 100, false)
+	assert.Nil(t, err)
+	return len(txs)
+}
+
+func TestPruneBlocks(t *testing.T) {
+	old := config.DefConfig.Common.EnableAddressIndex
+	defer func() { config.DefConfig.Common.EnableAddressIndex = old }()
+	config.DefConfig.Common.EnableAddressIndex = true
+	ledger := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer ledger.close()
+	ledger.keepBlocks = 2
+
+	payer, to := account.NewAccount("").Address, account.NewAccount("").Address
+	tx := payTestContract(t, []byte{byte(0x51)}, payer)
+	ledger.addBlocks(t, 1, tx)
+	ledger.saveTransferNotify(t, tx, 1, to)
+	assert.Equal(t, 1, ledger.addressTxCount(t, payer))
+	assert.Equal(t, 1, ledger.addressTxCount(t, to))
+
+	// the blocks below the latest keepBlocks ones are pruned as new blocks are saved
+	ledger.addBlocks(t, 1)
+	assert.Equal(t, uint32(1), ledger.prunedHeight)
+	_, _, err := ledger.GetTransaction(tx.Hash())
+	assert.Nil(t, err)
+	ledger.addBlocks(t, 1)
+	assert.Equal(t, uint32(2), ledger.prunedHeight)
+	_, height, err := ledger.GetTransaction(tx.Hash())
+	assert.Equal(t, scom.ErrPruned, err)
+	assert.Equal(t, uint32(1), height)
+	_, err = ledger.GetEventNotifyByTx(tx.Hash())
+	assert.Equal(t, scom.ErrNotFound, err)
+	assert.Equal(t, 0, ledger.addressTxCount(t, payer))
+	assert.Equal(t, 0, ledger.addressTxCount(t, to))
+	header, err := ledger.GetHeaderByHeight(1)
+	assert.Nil(t, err)
+	assert.Equal(t, ledger.GetBlockHash(1), header.Hash())
+	for _, store := range []interface {
+		GetPrunedHeight() (uint32, error)
+	}{ledger.blockStore, ledger.eventStore} {
+		prunedHeight, err := store.GetPrunedHeight()
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(2), prunedHeight)
+	}
+}
+
+func TestPruneBlockInterrupted(t *testing.T) {
+	old := config.DefConfig.Common.EnableAddressIndex
+	defer func() { config.DefConfig.Common.EnableAddressIndex = old }()
+	config.DefConfig.Common.EnableAddressIndex = true
+	ledger := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer ledger.close()
+
+	payer, to := account.NewAccount("").Address, account.NewAccount("").Address
+	tx := payTestContract(t, []byte{byte(0x51)}, payer)
+	ledger.addBlocks(t, 1, tx)
+	ledger.saveTransferNotify(t, tx, 1, to)
+	ledger.addBlocks(t, 2)
+	assert.Nil(t, ledger.pruneBlock(0))
+	assert.Nil(t, ledger.pruneBlock(1))
+
+	// the node crashed after the event store is committed, before the block store is
+	ledger.blockStore.NewBatch()
+	assert.Nil(t, ledger.blockStore.SaveTransaction(tx, 1))
+	ledger.blockStore.SavePrunedHeight(1)
+	assert.Nil(t, ledger.blockStore.CommitTo())
+	ledger.prunedHeight = 1
+	// the index entry left by the transfer notify, which is gone with the pruned notifies
+	ledger.eventStore.NewBatch()
+	ledger.eventStore.SaveAddressTxIndex(1, tx.Hash(), []common.Address{payer})
+	assert.Nil(t, ledger.eventStore.CommitTo())
+
+	// the prune run again only prunes the block store
+	assert.Nil(t, ledger.pruneBlock(1))
+	assert.Equal(t, uint32(2), ledger.prunedHeight)
+	_, _, err := ledger.GetTransaction(tx.Hash())
+	assert.Equal(t, scom.ErrPruned, err)
+	assert.Equal(t, 1, ledger.addressTxCount(t, payer))
+	assert.Equal(t, 0, ledger.addressTxCount(t, to))
+	prunedHeight, err := ledger.eventStore.GetPrunedHeight()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), prunedHeight)
+
+	// the rollback below the pruned height makes the event notifies of the blocks added again prunable
+	assert.Nil(t, ledger.pruneBlock(2))
+	assert.Nil(t, ledger.Rollback(1))
+	for _, store := range []interface {
+		GetPrunedHeight() (uint32, error)
+	}{ledger.blockStore, ledger.eventStore} {
+		prunedHeight, err := store.GetPrunedHeight()
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(2), prunedHeight)
+	}
+	assert.Equal(t, uint32(2), ledger.prunedHeight)
+}
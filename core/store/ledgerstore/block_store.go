@@ -26,7 +26,6 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/serialization"
 	scom "github.com/dnaproject2/DNA/core/store/common"
-	"github.com/dnaproject2/DNA/core/store/leveldbstore"
 	"github.com/dnaproject2/DNA/core/types"
 	"io"
 )
@@ -36,7 +35,7 @@ type BlockStore struct {
 	enableCache bool                       //Is enable lru cache
 	dbDir       string                     //The path of store file
 	cache       *BlockCache                //The cache of block, if have.
-	store       *leveldbstore.LevelDBStore //block store handler
+	store       scom.PersistStore          //block store handler
 }
 
 //NewBlockStore return the block store instance
@@ -50,7 +49,7 @@ func NewBlockStore(dbDir string, enableCache bool) (*BlockStore, error) {
 		}
 	}
 
-	store, err := leveldbstore.NewLevelDBStore(dbDir)
+	store, err := openStore(dbDir)
 	if err != nil {
 		return nil, err
 	}
@@ -123,6 +122,9 @@ func (this *BlockStore) GetBlock(blockHash common.Uint256) (*types.Block, error)
 	txList := make([]*types.Transaction, 0, len(txHashes))
 	for _, txHash := range txHashes {
 		tx, _, err := this.GetTransaction(txHash)
+		if err == scom.ErrPruned {
+			return nil, err
+		}
 		if err != nil {
 			return nil, fmt.Errorf("GetTransaction %s error %s", txHash.ToHexString(), err)
 		}
@@ -421,6 +423,9 @@ func (this *BlockStore) loadTransaction(txHash common.Uint256) (*types.Transacti
 	if eof {
 		return nil, 0, io.ErrUnexpectedEOF
 	}
+	if source.Len() == 0 {
+		return nil, height, scom.ErrPruned
+	}
 	tx = new(types.Transaction)
 	err = tx.Deserialization(source)
 	if err != nil {
@@ -429,6 +434,57 @@ func (this *BlockStore) loadTransaction(txHash common.Uint256) (*types.Transacti
 	return tx, height, nil
 }
 
+//SavePrunedTransaction replace the transaction with its block height only, so that the pruned
+//transaction is still contained in store
+func (this *BlockStore) SavePrunedTransaction(txHash common.Uint256, height uint32) {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, height)
+	this.store.BatchPut(this.getTransactionKey(txHash), value)
+}
+
+//PruneBlock prune the transactions of the block, and keeps the header
+func (this *BlockStore) PruneBlock(block *types.Block) {
+	for _, tx := range block.Transactions {
+		this.SavePrunedTransaction(tx.Hash(), block.Header.Height)
+		if this.enableCache {
+			this.cache.RemoveTransaction(tx.Hash())
+		}
+	}
+	if this.enableCache {
+		this.cache.RemoveBlock(block.Hash())
+	}
+}
+
+//GetPrunedHeight return the first block height whose transactions are not pruned
+func (this *BlockStore) GetPrunedHeight() (uint32, error) {
+	value, err := this.store.Get(this.getPrunedHeightKey())
+	if err != nil {
+		if err == scom.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(value) != 4 {
+		return 0, fmt.Errorf("invalid pruned height")
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+//SavePrunedHeight persist the first block height whose transactions are not pruned
+func (this *BlockStore) SavePrunedHeight(height uint32) {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, height)
+	this.store.BatchPut(this.getPrunedHeightKey(), value)
+}
+
+//Compact the underlying storage of block store
+func (this *BlockStore) Compact() error {
+	if store, ok := this.store.(scom.CompactableStore); ok {
+		return store.Compact(nil)
+	}
+	return nil
+}
+
 //IsContainTransaction return whether the transaction is in store
 func (this *BlockStore) ContainTransaction(txHash common.Uint256) (bool, error) {
 	key := this.getTransactionKey(txHash)
@@ -519,6 +575,10 @@ func (this *BlockStore) getBlockMerkleTreeKey() []byte {
 	return []byte{byte(scom.SYS_BLOCK_MERKLE_TREE)}
 }
 
+func (this *BlockStore) getPrunedHeightKey() []byte {
+	return []byte{byte(scom.SYS_PRUNED_HEIGHT)}
+}
+
 func (this *BlockStore) getVersionKey() []byte {
 	return []byte{byte(scom.SYS_VERSION)}
 }
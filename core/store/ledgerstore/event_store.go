@@ -20,6 +20,7 @@ package ledgerstore
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/binary"
 	"encoding/json"
 	"fmt"
@@ -27,19 +28,18 @@ import (
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/common/serialization"
 	scom "github.com/dnaproject2/DNA/core/store/common"
-	"github.com/dnaproject2/DNA/core/store/leveldbstore"
 	"github.com/dnaproject2/DNA/smartcontract/event"
 )
 
 //Saving event notifies gen by smart contract execution
 type EventStore struct {
 	dbDir string                     //Store path
-	store *leveldbstore.LevelDBStore //Store handler
+	store scom.PersistStore //Store handler
 }
 
 //NewEventStore return event store instance
 func NewEventStore(dbDir string) (*EventStore, error) {
-	store, err := leveldbstore.NewLevelDBStore(dbDir)
+	store, err := openStore(dbDir)
 	if err != nil {
 		return nil, err
 	}
@@ -88,6 +88,179 @@ func (this *EventStore) SaveEventNotifyByBlock(height uint32, txHashs []common.U
 	return nil
 }
 
+//SaveEventNotifyIndex persist the contract and topic index of event notify
+func (this *EventStore) SaveEventNotifyIndex(height uint32, notify *event.ExecuteNotify) error {
+	for i, n := range notify.Notify {
+		key := this.getEventNotifyByContractKey(n.ContractAddress, height, notify.TxHash, uint32(i))
+		this.store.BatchPut(key, []byte{})
+		if topic, ok := n.Topic(); ok {
+			key = this.getEventNotifyByTopicKey(n.ContractAddress, topic, height, notify.TxHash, uint32(i))
+			this.store.BatchPut(key, []byte{})
+		}
+	}
+	return nil
+}
+
+//PruneEventNotifyByBlock delete the event notifies of the block, with their contract and topic index
+func (this *EventStore) PruneEventNotifyByBlock(height uint32, notifies []*event.ExecuteNotify) error {
+	key, err := this.getEventNotifyByBlockKey(height)
+	if err != nil {
+		return err
+	}
+	this.store.BatchDelete(key)
+	for _, notify := range notifies {
+		this.store.BatchDelete(this.getEventNotifyByTxKey(notify.TxHash))
+		for i, n := range notify.Notify {
+			this.store.BatchDelete(this.getEventNotifyByContractKey(n.ContractAddress, height, notify.TxHash, uint32(i)))
+			if topic, ok := n.Topic(); ok {
+				this.store.BatchDelete(this.getEventNotifyByTopicKey(n.ContractAddress, topic, height, notify.TxHash, uint32(i)))
+			}
+		}
+	}
+	return nil
+}
+
+//GetEventNotifyByContract return the event notifies of the contract whose topic matches if not empty,
+//in the height range [startHeight, endHeight], skipping the first offset ones and returning limit ones at most
+func (this *EventStore) GetEventNotifyByContract(contract common.Address, topic string, startHeight, endHeight,
+	offset, limit uint32) ([]*event.ContractNotify, error) {
+	var prefix []byte
+	if topic == "" {
+		prefix = this.getEventNotifyByContractKey(contract, 0, common.UINT256_EMPTY, 0)
+	} else {
+		prefix = this.getEventNotifyByTopicKey(contract, topic, 0, common.UINT256_EMPTY, 0)
+	}
+	prefix = prefix[:len(prefix)-EVENT_NOTIFY_INDEX_SUFFIX_LEN]
+
+	start := make([]byte, len(prefix)+4)
+	copy(start, prefix)
+	binary.BigEndian.PutUint32(start[len(prefix):], startHeight)
+
+	notifies := make([]*event.ContractNotify, 0)
+	txNotifies := make(map[common.Uint256]*event.ExecuteNotify)
+	skipped := uint32(0)
+	iter := this.store.NewIterator(prefix)
+	for valid := seekIterator(iter, start); valid && uint32(len(notifies)) < limit; valid = iter.Next() {
+		suffix := iter.Key()[len(prefix):]
+		if len(suffix) != EVENT_NOTIFY_INDEX_SUFFIX_LEN {
+			continue
+		}
+		height := binary.BigEndian.Uint32(suffix[:4])
+		if height < startHeight {
+			continue
+		}
+		if height > endHeight {
+			break
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		var txHash common.Uint256
+		copy(txHash[:], suffix[4:4+common.UINT256_SIZE])
+		index := binary.BigEndian.Uint32(suffix[4+common.UINT256_SIZE:])
+
+		txNotify, ok := txNotifies[txHash]
+		if !ok {
+			var err error
+			txNotify, err = this.GetEventNotifyByTx(txHash)
+			if err != nil {
+				log.Errorf("GetEventNotifyByContract Height:%d by txhash:%s error:%s", height, txHash.ToHexString(), err)
+				continue
+			}
+			txNotifies[txHash] = txNotify
+		}
+		if index >= uint32(len(txNotify.Notify)) {
+			continue
+		}
+		notifies = append(notifies, &event.ContractNotify{
+			Height: height,
+			TxHash: txHash,
+			Notify: txNotify.Notify[index],
+		})
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return notifies, nil
+}
+
+//seekIterator move the iterator to the first key not less than the key, or to the first key
+//if the iterator can't seek
+func seekIterator(iter scom.StoreIterator, key []byte) bool {
+	if seeker, ok := iter.(scom.SeekableIterator); ok {
+		return seeker.Seek(key)
+	}
+	return iter.Next()
+}
+
+//SaveAddressTxIndex persist the index of the transaction for each of the addresses it involves
+func (this *EventStore) SaveAddressTxIndex(height uint32, txHash common.Uint256, addrs []common.Address) {
+	for _, addr := range addrs {
+		this.store.BatchPut(this.getAddressTxKey(addr, height, txHash), []byte{})
+	}
+}
+
+//DeleteAddressTxIndex delete the index of the transaction for each of the addresses it involves
+func (this *EventStore) DeleteAddressTxIndex(height uint32, txHash common.Uint256, addrs []common.Address) {
+	for _, addr := range addrs {
+		this.store.BatchDelete(this.getAddressTxKey(addr, height, txHash))
+	}
+}
+
+//GetAddressTxs return the transactions which involve the address in height order, or the reverse order
+//if desc, skipping the first offset ones and returning limit ones at most
+func (this *EventStore) GetAddressTxs(addr common.Address, offset, limit uint32, desc bool) ([]*scom.AddressTx, error) {
+	prefix := this.getAddressTxKey(addr, 0, common.UINT256_EMPTY)
+	prefix = prefix[:len(prefix)-ADDRESS_TX_SUFFIX_LEN]
+
+	txs := make([]*scom.AddressTx, 0)
+	skipped := uint32(0)
+	iter := this.store.NewIterator(prefix)
+	first, next := iter.First, iter.Next
+	if desc {
+		reversible, ok := iter.(scom.ReversibleIterator)
+		if !ok {
+			iter.Release()
+			return nil, fmt.Errorf("the iterator of event store can't iterate backward")
+		}
+		first, next = reversible.Last, reversible.Prev
+	}
+	for valid := first(); valid && uint32(len(txs)) < limit; valid = next() {
+		suffix := iter.Key()[len(prefix):]
+		if len(suffix) != ADDRESS_TX_SUFFIX_LEN {
+			continue
+		}
+		if skipped < offset {
+			skipped++
+			continue
+		}
+		tx := &scom.AddressTx{Height: binary.BigEndian.Uint32(suffix[:4])}
+		copy(tx.TxHash[:], suffix[4:])
+		txs = append(txs, tx)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	return txs, nil
+}
+
+//ClearAddressTxIndex delete all of the address transaction index
+func (this *EventStore) ClearAddressTxIndex() error {
+	this.NewBatch()
+	iter := this.store.NewIterator([]byte{byte(scom.EVENT_ADDRESS_TX)})
+	for iter.Next() {
+		this.store.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return this.CommitTo()
+}
+
 //GetEventNotifyByTx return event notify by trasanction hash
 func (this *EventStore) GetEventNotifyByTx(txHash common.Uint256) (*event.ExecuteNotify, error) {
 	key := this.getEventNotifyByTxKey(txHash)
@@ -139,6 +312,14 @@ func (this *EventStore) CommitTo() error {
 	return this.store.BatchCommit()
 }
 
+//Compact the underlying storage of event store
+func (this *EventStore) Compact() error {
+	if store, ok := this.store.(scom.CompactableStore); ok {
+		return store.Compact(nil)
+	}
+	return nil
+}
+
 //Close event store
 func (this *EventStore) Close() error {
 	return this.store.Close()
@@ -189,10 +370,36 @@ func (this *EventStore) GetCurrentBlock() (common.Uint256, uint32, error) {
 	return blockHash, height, nil
 }
 
+//GetPrunedHeight return the first block height whose event notifies are not pruned
+func (this *EventStore) GetPrunedHeight() (uint32, error) {
+	value, err := this.store.Get(this.getPrunedHeightKey())
+	if err != nil {
+		if err == scom.ErrNotFound {
+			return 0, nil
+		}
+		return 0, err
+	}
+	if len(value) != 4 {
+		return 0, fmt.Errorf("invalid pruned height")
+	}
+	return binary.LittleEndian.Uint32(value), nil
+}
+
+//SavePrunedHeight persist the first block height whose event notifies are not pruned
+func (this *EventStore) SavePrunedHeight(height uint32) {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, height)
+	this.store.BatchPut(this.getPrunedHeightKey(), value)
+}
+
 func (this *EventStore) getCurrentBlockKey() []byte {
 	return []byte{byte(scom.SYS_CURRENT_BLOCK)}
 }
 
+func (this *EventStore) getPrunedHeightKey() []byte {
+	return []byte{byte(scom.SYS_PRUNED_HEIGHT)}
+}
+
 func (this *EventStore) getEventNotifyByBlockKey(height uint32) ([]byte, error) {
 	key := make([]byte, 5, 5)
 	key[0] = byte(scom.EVENT_NOTIFY)
@@ -200,6 +407,45 @@ func (this *EventStore) getEventNotifyByBlockKey(height uint32) ([]byte, error)
 	return key, nil
 }
 
+//the index key ends with height + tx hash + notify index, the height is big endian to keep the order
+const EVENT_NOTIFY_INDEX_SUFFIX_LEN = 4 + common.UINT256_SIZE + 4
+
+func (this *EventStore) getEventNotifyIndexSuffix(height uint32, txHash common.Uint256, index uint32) []byte {
+	suffix := make([]byte, EVENT_NOTIFY_INDEX_SUFFIX_LEN)
+	binary.BigEndian.PutUint32(suffix, height)
+	copy(suffix[4:], txHash[:])
+	binary.BigEndian.PutUint32(suffix[4+common.UINT256_SIZE:], index)
+	return suffix
+}
+
+func (this *EventStore) getEventNotifyByContractKey(contract common.Address, height uint32, txHash common.Uint256, index uint32) []byte {
+	key := make([]byte, 0, 1+common.ADDR_LEN+EVENT_NOTIFY_INDEX_SUFFIX_LEN)
+	key = append(key, byte(scom.EVENT_NOTIFY_CONTRACT))
+	key = append(key, contract[:]...)
+	return append(key, this.getEventNotifyIndexSuffix(height, txHash, index)...)
+}
+
+func (this *EventStore) getEventNotifyByTopicKey(contract common.Address, topic string, height uint32, txHash common.Uint256, index uint32) []byte {
+	topicHash := sha256.Sum256([]byte(topic))
+	key := make([]byte, 0, 1+common.ADDR_LEN+len(topicHash)+EVENT_NOTIFY_INDEX_SUFFIX_LEN)
+	key = append(key, byte(scom.EVENT_NOTIFY_TOPIC))
+	key = append(key, contract[:]...)
+	key = append(key, topicHash[:]...)
+	return append(key, this.getEventNotifyIndexSuffix(height, txHash, index)...)
+}
+
+//the address index key ends with height + tx hash, the height is big endian to keep the order
+const ADDRESS_TX_SUFFIX_LEN = 4 + common.UINT256_SIZE
+
+func (this *EventStore) getAddressTxKey(addr common.Address, height uint32, txHash common.Uint256) []byte {
+	key := make([]byte, 1+common.ADDR_LEN+ADDRESS_TX_SUFFIX_LEN)
+	key[0] = byte(scom.EVENT_ADDRESS_TX)
+	copy(key[1:], addr[:])
+	binary.BigEndian.PutUint32(key[1+common.ADDR_LEN:], height)
+	copy(key[1+common.ADDR_LEN+4:], txHash[:])
+	return key
+}
+
 func (this *EventStore) getEventNotifyByTxKey(txHash common.Uint256) []byte {
 	data := txHash.ToArray()
 	key := make([]byte, 1+len(data))
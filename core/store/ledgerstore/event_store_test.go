@@ -0,0 +1,115 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStore counts the keys visited by the iterators of store
+type countingStore struct {
+	scom.PersistStore
+	visited int
+}
+
+func (this *countingStore) NewIterator(prefix []byte) scom.StoreIterator {
+	return &countingIterator{SeekableIterator: this.PersistStore.NewIterator(prefix).(scom.SeekableIterator), store: this}
+}
+
+type countingIterator struct {
+	scom.SeekableIterator
+	store *countingStore
+}
+
+func (this *countingIterator) Next() bool {
+	this.store.visited++
+	return this.SeekableIterator.Next()
+}
+
+func (this *countingIterator) Seek(key []byte) bool {
+	this.store.visited++
+	return this.SeekableIterator.Seek(key)
+}
+
+// saveTestNotifies saves a transaction for each height in [1, count], which notifies the
+// topics of the contract
+func saveTestNotifies(t *testing.T, store *EventStore, contract common.Address, count uint32, topics ...string) {
+	store.NewBatch()
+	for height := uint32(1); height <= count; height++ {
+		notify := &event.ExecuteNotify{TxHash: common.Uint256{contract[0], byte(height), byte(height >> 8)}}
+		for _, topic := range topics {
+			notify.Notify = append(notify.Notify, &event.NotifyEventInfo{
+				ContractAddress: contract,
+				States:          []interface{}{topic, height},
+			})
+		}
+		assert.Nil(t, store.SaveEventNotifyByTx(notify.TxHash, notify))
+		assert.Nil(t, store.SaveEventNotifyIndex(height, notify))
+	}
+	assert.Nil(t, store.CommitTo())
+}
+
+func TestGetEventNotifyByContract(t *testing.T) {
+	store := &EventStore{store: memstore.NewMemStore()}
+	contract := common.Address{1}
+	other := common.Address{2}
+	saveTestNotifies(t, store, contract, 10, "transfer", "approve")
+	saveTestNotifies(t, store, other, 10, "transfer")
+
+	notifies, err := store.GetEventNotifyByContract(contract, "", 3, 5, 0, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 6, len(notifies))
+	for i, notify := range notifies {
+		assert.Equal(t, uint32(3+i/2), notify.Height)
+		assert.Equal(t, contract, notify.Notify.ContractAddress)
+	}
+
+	notifies, err = store.GetEventNotifyByContract(contract, "approve", 3, 10, 2, 3)
+	assert.Nil(t, err)
+	assert.Equal(t, 3, len(notifies))
+	for i, notify := range notifies {
+		assert.Equal(t, uint32(5+i), notify.Height)
+		topic, _ := notify.Notify.Topic()
+		assert.Equal(t, "approve", topic)
+	}
+
+	notifies, err = store.GetEventNotifyByContract(other, "approve", 0, 10, 0, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 0, len(notifies))
+}
+
+func TestGetEventNotifyByContractSeek(t *testing.T) {
+	counting := &countingStore{PersistStore: memstore.NewMemStore()}
+	store := &EventStore{store: counting}
+	contract := common.Address{1}
+	saveTestNotifies(t, store, contract, 1000, "transfer")
+
+	// the keys below the start height are not visited
+	notifies, err := store.GetEventNotifyByContract(contract, "transfer", 991, 1000, 0, 100)
+	assert.Nil(t, err)
+	assert.Equal(t, 10, len(notifies))
+	assert.Equal(t, uint32(991), notifies[0].Height)
+	assert.True(t, counting.visited <= 11)
+}
@@ -46,6 +46,7 @@ import (
 	"github.com/dnaproject2/DNA/errors"
 	"github.com/dnaproject2/DNA/events"
 	"github.com/dnaproject2/DNA/events/message"
+	"github.com/dnaproject2/DNA/merkle"
 	"github.com/dnaproject2/DNA/smartcontract"
 	scommon "github.com/dnaproject2/DNA/smartcontract/common"
 	"github.com/dnaproject2/DNA/smartcontract/event"
@@ -59,6 +60,7 @@ import (
 
 const (
 	SYSTEM_VERSION          = byte(1)      //Version of ledger store
+	LIGHT_SYSTEM_VERSION    = byte(0x81)   //Version of ledger store in light mode
 	HEADER_INDEX_BATCH_SIZE = uint32(2000) //Bath size of saving header index
 )
 
@@ -72,6 +74,7 @@ var (
 
 //LedgerStoreImp is main store struct fo ledger
 type LedgerStoreImp struct {
+	dataDir              string                           //Directory of the stores
 	blockStore           *BlockStore                      //BlockStore for saving block & transaction data
 	stateStore           *StateStore                      //StateStore for saving state data, like balance, smart contract execution result, and so on.
 	eventStore           *EventStore                      //EventStore for saving log those gen after smart contract executed.
@@ -86,11 +89,17 @@ type LedgerStoreImp struct {
 	vbftPeerInfoblock    map[string]uint32 //pubInfo save pubkey,peerindex
 	lock                 sync.RWMutex
 	stateHashCheckHeight uint32
+	keepBlocks           uint32                 //Count of the latest blocks not pruned, 0 means pruning is disabled
+	prunedHeight         uint32                 //First block height not pruned
+	compactedHeight      uint32                 //Pruned height of the last compaction of stores
+	light                bool                   //Only the verified headers are kept in light mode
+	publisher            *events.ActorPublisher //Publisher of the block complete events, the default one is used if nil
 }
 
 //NewLedgerStore return LedgerStoreImp instance
 func NewLedgerStore(dataDir string, stateHashHeight uint32) (*LedgerStoreImp, error) {
 	ledgerStore := &LedgerStoreImp{
+		dataDir:              dataDir,
 		headerIndex:          make(map[uint32]common.Uint256),
 		headerCache:          make(map[common.Uint256]*types.Header, 0),
 		vbftPeerInfoheader:   make(map[string]uint32),
@@ -99,31 +108,67 @@ func NewLedgerStore(dataDir string, stateHashHeight uint32) (*LedgerStoreImp, er
 		stateHashCheckHeight: stateHashHeight,
 	}
 
-	blockStore, err := NewBlockStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirBlock), true)
+	ledgerStore.keepBlocks = config.DefConfig.Common.PruneBlocks
+	if ledgerStore.keepBlocks != 0 && ledgerStore.keepBlocks < config.MIN_PRUNE_BLOCKS {
+		log.Warnf("prune blocks %d is less than %d, use %d instead", ledgerStore.keepBlocks,
+			config.MIN_PRUNE_BLOCKS, config.MIN_PRUNE_BLOCKS)
+		ledgerStore.keepBlocks = config.MIN_PRUNE_BLOCKS
+	}
+	ledgerStore.light = config.DefConfig.Common.LightMode
+
+	err := ledgerStore.openStores()
+	if err != nil {
+		return nil, err
+	}
+	return ledgerStore, nil
+}
+
+//openStore open the persist store at the path with the db backend of config
+func openStore(path string) (scom.PersistStore, error) {
+	return scom.OpenStore(config.DefConfig.Common.DBBackend, path)
+}
+
+//isInMemoryBackend return whether the db backend of config keeps nothing on disk
+func isInMemoryBackend() bool {
+	backend, err := scom.GetBackend(config.DefConfig.Common.DBBackend)
+	return err == nil && backend.InMemory
+}
+
+//openStores open the block store, the state store and the event store in the data dir
+func (this *LedgerStoreImp) openStores() error {
+	blockStore, err := NewBlockStore(fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), DBDirBlock), true)
 	if err != nil {
-		return nil, fmt.Errorf("NewBlockStore error %s", err)
+		return fmt.Errorf("NewBlockStore error %s", err)
 	}
-	ledgerStore.blockStore = blockStore
+	this.blockStore = blockStore
 
-	dbPath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirState)
-	merklePath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), MerkleTreeStorePath)
-	stateStore, err := NewStateStore(dbPath, merklePath, stateHashHeight)
+	this.prunedHeight, err = blockStore.GetPrunedHeight()
 	if err != nil {
-		return nil, fmt.Errorf("NewStateStore error %s", err)
+		return fmt.Errorf("GetPrunedHeight error %s", err)
 	}
-	ledgerStore.stateStore = stateStore
+	this.compactedHeight = this.prunedHeight
 
-	eventState, err := NewEventStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirEvent))
+	dbPath := fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), DBDirState)
+	merklePath := fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), MerkleTreeStorePath)
+	stateStore, err := NewStateStore(dbPath, merklePath, this.stateHashCheckHeight)
 	if err != nil {
-		return nil, fmt.Errorf("NewEventStore error %s", err)
+		return fmt.Errorf("NewStateStore error %s", err)
 	}
-	ledgerStore.eventStore = eventState
+	this.stateStore = stateStore
 
-	return ledgerStore, nil
+	eventState, err := NewEventStore(fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), DBDirEvent))
+	if err != nil {
+		return fmt.Errorf("NewEventStore error %s", err)
+	}
+	this.eventStore = eventState
+	return nil
 }
 
 //InitLedgerStoreWithGenesisBlock init the ledger store with genesis block. It's the first operation after NewLedgerStore.
 func (this *LedgerStoreImp) InitLedgerStoreWithGenesisBlock(genesisBlock *types.Block, defaultBookkeeper []keypair.PublicKey) error {
+	if this.light {
+		return this.initLightLedger(genesisBlock)
+	}
 	hasInit, err := this.hasAlreadyInitGenesisBlock()
 	if err != nil {
 		return fmt.Errorf("hasAlreadyInit error %s", err)
@@ -179,46 +224,66 @@ func (this *LedgerStoreImp) InitLedgerStoreWithGenesisBlock(genesisBlock *types.
 			return fmt.Errorf("init error %s", err)
 		}
 	}
-	//load vbft peerInfo
+	err = this.loadVbftPeerInfo()
+	if err != nil {
+		return err
+	}
+	// check and fix imcompatible states
+	err = this.stateStore.CheckStorage()
+	return err
+}
+
+//loadVbftPeerInfo load the vbft peers of the current block
+func (this *LedgerStoreImp) loadVbftPeerInfo() error {
+	peerInfo, err := this.getVbftPeerInfo(this.currBlockHash)
+	if err != nil {
+		return err
+	}
+	if peerInfo != nil {
+		this.lock.Lock()
+		this.vbftPeerInfoheader = peerInfo
+		this.vbftPeerInfoblock = peerInfo
+		this.lock.Unlock()
+	}
+	return nil
+}
+
+//getVbftPeerInfo return the vbft peers of the block, nil if the consensus is not vbft
+func (this *LedgerStoreImp) getVbftPeerInfo(blockHash common.Uint256) (map[string]uint32, error) {
 	consensusType := strings.ToLower(config.DefConfig.Genesis.ConsensusType)
-	if consensusType == "vbft" {
-		header, err := this.GetHeaderByHash(this.currBlockHash)
+	if consensusType != "vbft" {
+		return nil, nil
+	}
+	header, err := this.GetHeaderByHash(blockHash)
+	if err != nil {
+		return nil, err
+	}
+	blkInfo, err := vconfig.VbftBlock(header)
+	if err != nil {
+		return nil, err
+	}
+	var cfg *vconfig.ChainConfig
+	if blkInfo.NewChainConfig != nil {
+		cfg = blkInfo.NewChainConfig
+	} else {
+		cfgHeader, err := this.GetHeaderByHeight(blkInfo.LastConfigBlockNum)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		blkInfo, err := vconfig.VbftBlock(header)
+		Info, err := vconfig.VbftBlock(cfgHeader)
 		if err != nil {
-			return err
+			return nil, err
 		}
-		var cfg *vconfig.ChainConfig
-		if blkInfo.NewChainConfig != nil {
-			cfg = blkInfo.NewChainConfig
-		} else {
-			cfgHeader, err := this.GetHeaderByHeight(blkInfo.LastConfigBlockNum)
-			if err != nil {
-				return err
-			}
-			Info, err := vconfig.VbftBlock(cfgHeader)
-			if err != nil {
-				return err
-			}
-			if Info.NewChainConfig == nil {
-				return fmt.Errorf("getNewChainConfig error block num:%d", blkInfo.LastConfigBlockNum)
-			}
-			cfg = Info.NewChainConfig
+		if Info.NewChainConfig == nil {
+			return nil, fmt.Errorf("getNewChainConfig error block num:%d", blkInfo.LastConfigBlockNum)
 		}
-		this.lock.Lock()
-		this.vbftPeerInfoheader = make(map[string]uint32)
-		this.vbftPeerInfoblock = make(map[string]uint32)
-		for _, p := range cfg.Peers {
-			this.vbftPeerInfoheader[p.ID] = p.Index
-			this.vbftPeerInfoblock[p.ID] = p.Index
-		}
-		this.lock.Unlock()
+		cfg = Info.NewChainConfig
 	}
-	// check and fix imcompatible states
-	err = this.stateStore.CheckStorage()
-	return err
+	peerInfo := make(map[string]uint32)
+	for _, p := range cfg.Peers {
+		peerInfo[p.ID] = p.Index
+	}
+	return peerInfo, nil
 }
 
 func (this *LedgerStoreImp) hasAlreadyInitGenesisBlock() (bool, error) {
@@ -226,6 +291,9 @@ func (this *LedgerStoreImp) hasAlreadyInitGenesisBlock() (bool, error) {
 	if err != nil && err != scom.ErrNotFound {
 		return false, fmt.Errorf("GetVersion error %s", err)
 	}
+	if version == LIGHT_SYSTEM_VERSION {
+		return false, fmt.Errorf("the ledger keeps block headers only, run in light mode or use another data dir")
+	}
 	return version == SYSTEM_VERSION, nil
 }
 
@@ -310,7 +378,7 @@ func (this *LedgerStoreImp) recoverStore() error {
 		if err != nil {
 			return fmt.Errorf("save to state store height:%d error:%s", i, err)
 		}
-		err = this.saveBlockToEventStore(block)
+		err = this.saveBlockToEventStore(block, result.Notify)
 		if err != nil {
 			return fmt.Errorf("save to event store height:%d error:%s", i, err)
 		}
@@ -388,6 +456,11 @@ func (this *LedgerStoreImp) GetCurrentBlockHash() common.Uint256 {
 	return this.currBlockHash
 }
 
+//SetEventPublisher set the publisher of the block complete events instead of the default one
+func (this *LedgerStoreImp) SetEventPublisher(publisher *events.ActorPublisher) {
+	this.publisher = publisher
+}
+
 //GetCurrentBlockHeight return the current block height
 func (this *LedgerStoreImp) GetCurrentBlockHeight() uint32 {
 	this.lock.RLock()
@@ -496,13 +569,20 @@ func (this *LedgerStoreImp) AddHeader(header *types.Header) error {
 	if header.Height != nextHeaderHeight {
 		return fmt.Errorf("header height %d not equal next header height %d", header.Height, nextHeaderHeight)
 	}
-	var err error
-	this.vbftPeerInfoheader, err = this.verifyHeader(header, this.vbftPeerInfoheader)
+	vbftPeerInfo, err := this.verifyHeader(header, this.vbftPeerInfoheader)
 	if err != nil {
 		return fmt.Errorf("verifyHeader error %s", err)
 	}
-	this.addHeaderCache(header)
-	this.setHeaderIndex(header.Height, header.Hash())
+	if this.light {
+		err = this.saveHeader(header)
+		if err != nil {
+			return err
+		}
+	} else {
+		this.addHeaderCache(header)
+		this.setHeaderIndex(header.Height, header.Hash())
+	}
+	this.vbftPeerInfoheader = vbftPeerInfo
 	return nil
 }
 
@@ -525,7 +605,47 @@ func (this *LedgerStoreImp) GetStateMerkleRoot(height uint32) (common.Uint256, e
 	return this.stateStore.GetStateMerkleRoot(height)
 }
 
+//GetHeaderStateRoot return the state root to commit in the header of the block height, which is the
+//state tree root of the height types.STATE_ROOT_DELAY blocks before, empty if the header commits none
+func (this *LedgerStoreImp) GetHeaderStateRoot(height uint32) (common.Uint256, error) {
+	if height < types.STATE_ROOT_DELAY || types.HeaderVersion(height) < types.HEADER_VERSION_STATE_ROOT {
+		return common.UINT256_EMPTY, nil
+	}
+	return this.stateStore.GetStateTreeRoot(height - types.STATE_ROOT_DELAY)
+}
+
+//verifyStateRoot check the header version and the state root committed by the header. The state root check
+//is skipped if the state root of the height is not kept, like the heights below the state snapshot which
+//the ledger is loaded from
+func (this *LedgerStoreImp) verifyStateRoot(header *types.Header) error {
+	if header.Height == 0 {
+		return nil
+	}
+	if version := types.HeaderVersion(header.Height); header.Version != version {
+		return fmt.Errorf("wrong header version at height:%d, expected:%d, got:%d", header.Height, version, header.Version)
+	}
+	if header.Version < types.HEADER_VERSION_STATE_ROOT {
+		return nil
+	}
+	stateRoot, err := this.GetHeaderStateRoot(header.Height)
+	if err == scom.ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("GetHeaderStateRoot height:%d error %s", header.Height, err)
+	}
+	if stateRoot != header.StateRoot {
+		return fmt.Errorf("wrong state root at height:%d, expected:%s, got:%s",
+			header.Height, stateRoot.ToHexString(), header.StateRoot.ToHexString())
+	}
+	return nil
+}
+
 func (this *LedgerStoreImp) ExecuteBlock(block *types.Block) (result store.ExecuteResult, err error) {
+	if this.light {
+		err = scom.ErrLightMode
+		return
+	}
 	this.getSavingBlockLock()
 	defer this.releaseSavingBlockLock()
 	currBlockHeight := this.GetCurrentBlockHeight()
@@ -545,6 +665,9 @@ func (this *LedgerStoreImp) ExecuteBlock(block *types.Block) (result store.Execu
 }
 
 func (this *LedgerStoreImp) SubmitBlock(block *types.Block, result store.ExecuteResult) error {
+	if this.light {
+		return scom.ErrLightMode
+	}
 	this.getSavingBlockLock()
 	defer this.releaseSavingBlockLock()
 	if this.closing {
@@ -576,6 +699,9 @@ func (this *LedgerStoreImp) SubmitBlock(block *types.Block, result store.Execute
 //AddBlock add the block to store.
 //When the block is not the next block, it will be cache. until the missing block arrived
 func (this *LedgerStoreImp) AddBlock(block *types.Block, stateMerkleRoot common.Uint256) error {
+	if this.light {
+		return scom.ErrLightMode
+	}
 	currBlockHeight := this.GetCurrentBlockHeight()
 	blockHeight := block.Header.Height
 	if blockHeight <= currBlockHeight {
@@ -621,7 +747,14 @@ func (this *LedgerStoreImp) saveBlockToBlockStore(block *types.Block) error {
 }
 
 func (this *LedgerStoreImp) executeBlock(block *types.Block) (result store.ExecuteResult, err error) {
-	overlay := this.stateStore.NewOverlayDB()
+	return this.executeBlockOn(block, this.stateStore.store, this.stateStore.deltaMerkleTree)
+}
+
+//executeBlockOn execute the block on the states in store, the state merkle root is calculated
+//with the state merkle tree of the previous block
+func (this *LedgerStoreImp) executeBlockOn(block *types.Block, states scom.PersistStore,
+	deltaMerkleTree *merkle.CompactMerkleTree) (result store.ExecuteResult, err error) {
+	overlay := overlaydb.NewOverlayDB(states)
 	if block.Header.Height != 0 {
 		config := &smartcontract.Config{
 			Time:   block.Header.Timestamp,
@@ -629,7 +762,7 @@ func (this *LedgerStoreImp) executeBlock(block *types.Block) (result store.Execu
 			Tx:     &types.Transaction{},
 		}
 
-		err = refreshGlobalParam(config, storage.NewCacheDB(this.stateStore.NewOverlayDB()), this)
+		err = refreshGlobalParam(config, storage.NewCacheDB(overlaydb.NewOverlayDB(states)), this)
 		if err != nil {
 			return
 		}
@@ -661,7 +794,7 @@ func (this *LedgerStoreImp) executeBlock(block *types.Block) (result store.Execu
 		result.MerkleRoot = res
 		result.Hash = result.MerkleRoot
 	} else {
-		result.MerkleRoot = this.stateStore.GetStateMerkleRootWithNewHash(result.Hash)
+		result.MerkleRoot = deltaMerkleTree.GetRootWithNewLeaf(result.Hash)
 	}
 
 	return
@@ -702,7 +835,7 @@ func (this *LedgerStoreImp) saveBlockToStateStore(block *types.Block, result sto
 	blockHeight := block.Header.Height
 
 	for _, notify := range result.Notify {
-		SaveNotify(this.eventStore, notify.TxHash, notify)
+		SaveNotify(this.eventStore, blockHeight, notify.TxHash, notify)
 	}
 
 	err := this.stateStore.AddStateMerkleTreeRoot(blockHeight, result.Hash)
@@ -710,6 +843,18 @@ func (this *LedgerStoreImp) saveBlockToStateStore(block *types.Block, result sto
 		return fmt.Errorf("AddBlockMerkleTreeRoot error %s", err)
 	}
 
+	err = this.stateStore.AddStateTreeRoot(blockHeight, result.WriteSet)
+	if err != nil {
+		return fmt.Errorf("AddStateTreeRoot error %s", err)
+	}
+
+	this.stateStore.AddArchiveStates(blockHeight, result.WriteSet)
+
+	err = this.stateStore.AddUndoStates(blockHeight, result.WriteSet)
+	if err != nil {
+		return fmt.Errorf("AddUndoStates error %s", err)
+	}
+
 	err = this.stateStore.AddBlockMerkleTreeRoot(block.Header.TransactionsRoot)
 	if err != nil {
 		return fmt.Errorf("AddBlockMerkleTreeRoot error %s", err)
@@ -733,7 +878,7 @@ func (this *LedgerStoreImp) saveBlockToStateStore(block *types.Block, result sto
 	return nil
 }
 
-func (this *LedgerStoreImp) saveBlockToEventStore(block *types.Block) error {
+func (this *LedgerStoreImp) saveBlockToEventStore(block *types.Block, notifies []*event.ExecuteNotify) error {
 	blockHash := block.Hash()
 	blockHeight := block.Header.Height
 	txs := make([]common.Uint256, 0)
@@ -747,6 +892,9 @@ func (this *LedgerStoreImp) saveBlockToEventStore(block *types.Block) error {
 			return fmt.Errorf("SaveEventNotifyByBlock error %s", err)
 		}
 	}
+	if config.DefConfig.Common.EnableAddressIndex {
+		this.saveAddressTxIndex(block, notifies)
+	}
 	err := this.eventStore.SaveCurrentBlock(blockHeight, blockHash)
 	if err != nil {
 		return fmt.Errorf("SaveCurrentBlock error %s", err)
@@ -785,11 +933,15 @@ func (this *LedgerStoreImp) submitBlock(block *types.Block, result store.Execute
 		return fmt.Errorf("wrong block root at height:%d, expected:%s, got:%s",
 			block.Header.Height, blockRoot.ToHexString(), block.Header.BlockRoot.ToHexString())
 	}
+	err := this.verifyStateRoot(block.Header)
+	if err != nil {
+		return err
+	}
 
 	this.blockStore.NewBatch()
 	this.stateStore.NewBatch()
 	this.eventStore.NewBatch()
-	err := this.saveBlockToBlockStore(block)
+	err = this.saveBlockToBlockStore(block)
 	if err != nil {
 		return fmt.Errorf("save to block store height:%d error:%s", blockHeight, err)
 	}
@@ -797,7 +949,7 @@ func (this *LedgerStoreImp) submitBlock(block *types.Block, result store.Execute
 	if err != nil {
 		return fmt.Errorf("save to state store height:%d error:%s", blockHeight, err)
 	}
-	err = this.saveBlockToEventStore(block)
+	err = this.saveBlockToEventStore(block, result.Notify)
 	if err != nil {
 		return fmt.Errorf("save to event store height:%d error:%s", blockHeight, err)
 	}
@@ -816,8 +968,17 @@ func (this *LedgerStoreImp) submitBlock(block *types.Block, result store.Execute
 	}
 	this.setCurrentBlock(blockHeight, blockHash)
 
-	if events.DefActorPublisher != nil {
-		events.DefActorPublisher.Publish(
+	err = this.pruneBlocks(blockHeight)
+	if err != nil {
+		log.Errorf("pruneBlocks height:%d error %s", blockHeight, err)
+	}
+
+	publisher := this.publisher
+	if publisher == nil {
+		publisher = events.DefActorPublisher
+	}
+	if publisher != nil {
+		publisher.Publish(
 			message.TOPIC_SAVE_BLOCK_COMPLETE,
 			&message.SaveBlockCompleteMsg{
 				Block: block,
@@ -973,6 +1134,9 @@ func (this *LedgerStoreImp) GetTransaction(txHash common.Uint256) (*types.Transa
 
 //GetBlockByHash return block by block hash. Wrap function of BlockStore.GetBlockByHash
 func (this *LedgerStoreImp) GetBlockByHash(blockHash common.Uint256) (*types.Block, error) {
+	if this.light {
+		return nil, scom.ErrLightMode
+	}
 	return this.blockStore.GetBlock(blockHash)
 }
 
@@ -996,6 +1160,11 @@ func (this *LedgerStoreImp) GetMerkleProof(proofHeight, rootHeight uint32) ([]co
 	return this.stateStore.GetMerkleProof(proofHeight, rootHeight)
 }
 
+//GetConsistencyProof return the block merkle consistency proof. Wrap function of StateStore.GetConsistencyProof
+func (this *LedgerStoreImp) GetConsistencyProof(oldHeight, newHeight uint32) ([]common.Uint256, error) {
+	return this.stateStore.GetConsistencyProof(oldHeight, newHeight)
+}
+
 //GetContractState return contract by contract address. Wrap function of StateStore.GetContractState
 func (this *LedgerStoreImp) GetContractState(contractHash common.Address) (*payload.DeployCode, error) {
 	return this.stateStore.GetContractState(contractHash)
@@ -1006,6 +1175,14 @@ func (this *LedgerStoreImp) GetStorageItem(key *states.StorageKey) (*states.Stor
 	return this.stateStore.GetStorageState(key)
 }
 
+//GetStorageItemAtHeight return the storage value of the key in smart contract at the block height. Wrap function of StateStore.GetStorageStateAt
+func (this *LedgerStoreImp) GetStorageItemAtHeight(key *states.StorageKey, height uint32) (*states.StorageItem, error) {
+	if height > this.GetCurrentBlockHeight() {
+		return nil, fmt.Errorf("height %d is higher than current block height", height)
+	}
+	return this.stateStore.GetStorageStateAt(key, height)
+}
+
 //GetEventNotifyByTx return the events notify gen by executing of smart contract.  Wrap function of EventStore.GetEventNotifyByTx
 func (this *LedgerStoreImp) GetEventNotifyByTx(tx common.Uint256) (*event.ExecuteNotify, error) {
 	return this.eventStore.GetEventNotifyByTx(tx)
@@ -1016,19 +1193,62 @@ func (this *LedgerStoreImp) GetEventNotifyByBlock(height uint32) ([]*event.Execu
 	return this.eventStore.GetEventNotifyByBlock(height)
 }
 
+//GetStorageProof return the storage item of the contract at the block height with its proof against the state tree root,
+//which is committed by the header of the height types.STATE_ROOT_DELAY blocks later
+func (this *LedgerStoreImp) GetStorageProof(contract common.Address, key []byte, height uint32) (*scom.StorageProof, error) {
+	if height > this.GetCurrentBlockHeight() {
+		return nil, fmt.Errorf("height %d is higher than current block height", height)
+	}
+	storeKey, err := this.stateStore.getStorageKey(&states.StorageKey{ContractAddress: contract, Key: key})
+	if err != nil {
+		return nil, err
+	}
+	return this.stateStore.GetStateProof(storeKey, height)
+}
+
+//GetEventNotifyByContract return the event notifies of the contract in the height range. Wrap function of EventStore.GetEventNotifyByContract
+func (this *LedgerStoreImp) GetEventNotifyByContract(contract common.Address, topic string, startHeight, endHeight, offset, limit uint32) ([]*event.ContractNotify, error) {
+	return this.eventStore.GetEventNotifyByContract(contract, topic, startHeight, endHeight, offset, limit)
+}
+
 //PreExecuteContract return the result of smart contract execution without commit to store
 func (this *LedgerStoreImp) PreExecuteContract(tx *types.Transaction) (*sstate.PreExecResult, error) {
 	height := this.GetCurrentBlockHeight()
-	stf := &sstate.PreExecResult{State: event.CONTRACT_STATE_FAIL, Gas: neovm.MIN_TRANSACTION_GAS, Result: nil}
-
 	config := &smartcontract.Config{
 		Time:      uint32(time.Now().Unix()),
 		Height:    height + 1,
 		Tx:        tx,
 		BlockHash: this.GetBlockHash(height),
 	}
+	return this.preExecuteContract(tx, config, this.stateStore.NewOverlayDB())
+}
+
+//PreExecuteContractAtHeight return the result of smart contract execution on the states of the block height without commit to store
+func (this *LedgerStoreImp) PreExecuteContractAtHeight(tx *types.Transaction, height uint32) (*sstate.PreExecResult, error) {
+	stf := &sstate.PreExecResult{State: event.CONTRACT_STATE_FAIL, Gas: neovm.MIN_TRANSACTION_GAS, Result: nil}
+	if height > this.GetCurrentBlockHeight() {
+		return stf, fmt.Errorf("height %d is higher than current block height", height)
+	}
+	header, err := this.GetHeaderByHeight(height)
+	if err != nil {
+		return stf, fmt.Errorf("GetHeaderByHeight error %s", err)
+	}
+	overlay, err := this.stateStore.NewOverlayDBAt(height)
+	if err != nil {
+		return stf, err
+	}
+	config := &smartcontract.Config{
+		Time:      header.Timestamp,
+		Height:    height + 1,
+		Tx:        tx,
+		BlockHash: header.Hash(),
+	}
+	return this.preExecuteContract(tx, config, overlay)
+}
+
+func (this *LedgerStoreImp) preExecuteContract(tx *types.Transaction, config *smartcontract.Config, overlay *overlaydb.OverlayDB) (*sstate.PreExecResult, error) {
+	stf := &sstate.PreExecResult{State: event.CONTRACT_STATE_FAIL, Gas: neovm.MIN_TRANSACTION_GAS, Result: nil}
 
-	overlay := this.stateStore.NewOverlayDB()
 	cache := storage.NewCacheDB(overlay)
 	preGas, err := this.getPreGas(config, cache)
 	if err != nil {
@@ -1120,7 +1340,10 @@ func (this *LedgerStoreImp) Close() error {
 	defer this.releaseSavingBlockLock()
 
 	this.closing = true
+	return this.closeStores()
+}
 
+func (this *LedgerStoreImp) closeStores() error {
 	err := this.blockStore.Close()
 	if err != nil {
 		return fmt.Errorf("blockStore close error %s", err)
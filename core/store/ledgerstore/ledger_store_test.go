@@ -0,0 +1,325 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/core/genesis"
+	"github.com/dnaproject2/DNA/core/signature"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/merkle"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/stretchr/testify/assert"
+)
+
+// testLedger is a solo ledger store with one bookkeeper in a temp dir
+type testLedger struct {
+	*LedgerStoreImp
+	dir     string
+	account *account.Account
+	restore func()
+}
+
+// newTestLedger creates the ledger store with the db backend, and saves the genesis block
+func newTestLedger(t *testing.T, backend string) *testLedger {
+	return newTestLedgerWithAccount(t, backend, account.NewAccount(""))
+}
+
+// newTestLedgerWithAccount creates the ledger store whose bookkeeper is the account, the ledgers of
+// the same account share the genesis block
+func newTestLedgerWithAccount(t *testing.T, backend string, acct *account.Account) *testLedger {
+	return newTestLedgerWithMode(t, backend, acct, false)
+}
+
+// newTestLedgerWithMode creates the ledger store of the account, in light mode if light
+func newTestLedgerWithMode(t *testing.T, backend string, acct *account.Account, light bool) *testLedger {
+	dir, err := ioutil.TempDir("", "ledgerstore")
+	assert.Nil(t, err)
+	oldGenesis, oldCommon := *config.DefConfig.Genesis, *config.DefConfig.Common
+	oldNetworkId := config.DefConfig.P2PNode.NetworkId
+	config.DefConfig.P2PNode.NetworkId = config.NETWORK_ID_SOLO_NET
+	config.DefConfig.Common.DBBackend = backend
+	config.DefConfig.Genesis.ConsensusType = config.CONSENSUS_TYPE_SOLO
+	config.DefConfig.Genesis.SOLO = &config.SOLOConfig{
+		Bookkeepers: []string{hex.EncodeToString(keypair.SerializePublicKey(acct.PublicKey))},
+	}
+	ledger := &testLedger{dir: dir, account: acct}
+	ledger.restore = func() {
+		*config.DefConfig.Genesis = oldGenesis
+		*config.DefConfig.Common = oldCommon
+		config.DefConfig.P2PNode.NetworkId = oldNetworkId
+		os.RemoveAll(dir)
+	}
+
+	if err := ledger.open(light); !assert.Nil(t, err) {
+		ledger.restore()
+		t.FailNow()
+	}
+	return ledger
+}
+
+// open opens the ledger store in the dir and inits it with the genesis block, in light mode if light
+func (self *testLedger) open(light bool) error {
+	config.DefConfig.Common.LightMode = light
+	store, err := NewLedgerStore(self.dir, 0)
+	if err != nil {
+		return err
+	}
+	bookkeepers := []keypair.PublicKey{self.account.PublicKey}
+	block, err := genesis.BuildGenesisBlock(bookkeepers, config.DefConfig.Genesis)
+	if err == nil {
+		err = store.InitLedgerStoreWithGenesisBlock(block, bookkeepers)
+	}
+	if err != nil {
+		store.Close()
+		return err
+	}
+	self.LedgerStoreImp = store
+	return nil
+}
+
+func (self *testLedger) close() {
+	self.Close()
+	self.restore()
+}
+
+// makeBlock returns the next block with the transactions, signed by the bookkeeper
+func (self *testLedger) makeBlock(t *testing.T, txs []*types.Transaction) *types.Block {
+	height := self.GetCurrentBlockHeight() + 1
+	prev, err := self.GetHeaderByHeight(height - 1)
+	assert.Nil(t, err)
+	txHashes := make([]common.Uint256, 0, len(txs))
+	for _, tx := range txs {
+		txHashes = append(txHashes, tx.Hash())
+	}
+	txRoot := common.ComputeMerkleRoot(txHashes)
+	stateRoot, err := self.GetHeaderStateRoot(height)
+	assert.Nil(t, err)
+	nextBookkeeper, err := types.AddressFromBookkeepers([]keypair.PublicKey{self.account.PublicKey})
+	assert.Nil(t, err)
+	header := &types.Header{
+		Version:          types.HeaderVersion(height),
+		PrevBlockHash:    prev.Hash(),
+		TransactionsRoot: txRoot,
+		BlockRoot:        self.GetBlockRootWithNewTxRoots(height, []common.Uint256{txRoot}),
+		Timestamp:        prev.Timestamp + 1,
+		Height:           height,
+		NextBookkeeper:   nextBookkeeper,
+		StateRoot:        stateRoot,
+	}
+	self.signBlock(t, header)
+	return &types.Block{Header: header, Transactions: txs}
+}
+
+func (self *testLedger) signBlock(t *testing.T, header *types.Header) {
+	header.Bookkeepers = []keypair.PublicKey{self.account.PublicKey}
+	hash := header.Hash()
+	sig, err := signature.Sign(self.account, hash[:])
+	assert.Nil(t, err)
+	header.SigData = [][]byte{sig}
+}
+
+// addBlocks executes and submits count blocks with the transactions in the first one
+func (self *testLedger) addBlocks(t *testing.T, count int, txs ...*types.Transaction) {
+	for i := 0; i < count; i++ {
+		block := self.makeBlock(t, txs)
+		txs = nil
+		result, err := self.ExecuteBlock(block)
+		assert.Nil(t, err)
+		assert.Nil(t, self.SubmitBlock(block, result))
+	}
+}
+
+// firstStorageKey returns the contract and the key of a storage state in store
+func (self *testLedger) firstStorageKey(t *testing.T) (common.Address, []byte) {
+	iter := self.stateStore.store.NewIterator([]byte{byte(scom.ST_STORAGE)})
+	defer iter.Release()
+	assert.True(t, iter.Next())
+	key := iter.Key()
+	contract, err := common.AddressParseFromBytes(key[1 : 1+common.ADDR_LEN])
+	assert.Nil(t, err)
+	return contract, append([]byte{}, key[1+common.ADDR_LEN:]...)
+}
+
+func TestHeaderStateRoot(t *testing.T) {
+	ledger := newTestLedger(t, memstore.BACKEND_MEMORY)
+	defer ledger.close()
+	ledger.addBlocks(t, 4)
+
+	for h := uint32(1); h <= 4; h++ {
+		header, err := ledger.GetHeaderByHeight(h)
+		assert.Nil(t, err)
+		assert.Equal(t, types.HEADER_VERSION_STATE_ROOT, header.Version)
+		rootHeight, ok := header.StateRootHeight()
+		if h < types.STATE_ROOT_DELAY {
+			assert.False(t, ok)
+			assert.Equal(t, common.UINT256_EMPTY, header.StateRoot)
+			continue
+		}
+		assert.True(t, ok)
+		assert.Equal(t, h-types.STATE_ROOT_DELAY, rootHeight)
+		root, err := ledger.stateStore.GetStateTreeRoot(rootHeight)
+		assert.Nil(t, err)
+		assert.Equal(t, root, header.StateRoot)
+	}
+
+	// the state root is part of the signed header
+	block := ledger.makeBlock(t, nil)
+	hash := block.Hash()
+	raw := block.Header.ToArray()
+	header, err := types.HeaderFromRawBytes(raw)
+	assert.Nil(t, err)
+	assert.Equal(t, block.Header.StateRoot, header.StateRoot)
+	assert.Equal(t, hash, header.Hash())
+
+	block.Header = &types.Header{
+		Version:          block.Header.Version,
+		PrevBlockHash:    block.Header.PrevBlockHash,
+		TransactionsRoot: block.Header.TransactionsRoot,
+		BlockRoot:        block.Header.BlockRoot,
+		Timestamp:        block.Header.Timestamp,
+		Height:           block.Header.Height,
+		NextBookkeeper:   block.Header.NextBookkeeper,
+		StateRoot:        common.Uint256{1},
+	}
+	assert.NotEqual(t, hash, block.Header.Hash())
+	ledger.signBlock(t, block.Header)
+	result, err := ledger.ExecuteBlock(block)
+	assert.Nil(t, err)
+	err = ledger.SubmitBlock(block, result)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "wrong state root")
+	assert.Equal(t, uint32(4), ledger.GetCurrentBlockHeight())
+}
+
+func TestHeaderVersion0(t *testing.T) {
+	header := &types.Header{Height: 10, StateRoot: common.Uint256{1}}
+	_, ok := header.StateRootHeight()
+	assert.False(t, ok)
+	decoded, err := types.HeaderFromRawBytes(header.ToArray())
+	assert.Nil(t, err)
+	assert.Equal(t, common.UINT256_EMPTY, decoded.StateRoot)
+	header.StateRoot = common.UINT256_EMPTY
+	assert.Equal(t, header.Hash(), decoded.Hash())
+}
+
+func TestHeaderVersionHeight(t *testing.T) {
+	old := config.STATE_ROOT_CHECK_HEIGHT[config.NETWORK_ID_SOLO_NET]
+	defer func() { config.STATE_ROOT_CHECK_HEIGHT[config.NETWORK_ID_SOLO_NET] = old }()
+	config.STATE_ROOT_CHECK_HEIGHT[config.NETWORK_ID_SOLO_NET] = 3
+
+	ledger := newTestLedger(t, memstore.BACKEND_MEMORY)
+	defer ledger.close()
+	ledger.addBlocks(t, 3)
+	for h := uint32(1); h <= 3; h++ {
+		header, err := ledger.GetHeaderByHeight(h)
+		assert.Nil(t, err)
+		assert.Equal(t, uint32(0), header.Version)
+		assert.Equal(t, common.UINT256_EMPTY, header.StateRoot)
+	}
+
+	// version 0 headers are rejected above the check height
+	block := ledger.makeBlock(t, nil)
+	assert.Equal(t, types.HEADER_VERSION_STATE_ROOT, block.Header.Version)
+	assert.NotEqual(t, common.UINT256_EMPTY, block.Header.StateRoot)
+	block.Header = &types.Header{
+		PrevBlockHash:    block.Header.PrevBlockHash,
+		TransactionsRoot: block.Header.TransactionsRoot,
+		BlockRoot:        block.Header.BlockRoot,
+		Timestamp:        block.Header.Timestamp,
+		Height:           block.Header.Height,
+		NextBookkeeper:   block.Header.NextBookkeeper,
+	}
+	ledger.signBlock(t, block.Header)
+	result, err := ledger.ExecuteBlock(block)
+	assert.Nil(t, err)
+	err = ledger.SubmitBlock(block, result)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "wrong header version")
+
+	ledger.addBlocks(t, 1)
+	header, err := ledger.GetHeaderByHeight(4)
+	assert.Nil(t, err)
+	assert.Equal(t, types.HEADER_VERSION_STATE_ROOT, header.Version)
+}
+
+func TestStorageProof(t *testing.T) {
+	ledger := newTestLedger(t, memstore.BACKEND_MEMORY)
+	defer ledger.close()
+	ledger.addBlocks(t, 3)
+	contract, key := ledger.firstStorageKey(t)
+
+	proof, err := ledger.GetStorageProof(contract, key, 1)
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), proof.Height)
+	assert.NotNil(t, proof.Value)
+	assert.Nil(t, merkle.VerifySparseMerkleProof(proof.Root, proof.Key, proof.Value, proof.Proof))
+	tampered := append(append([]byte{}, proof.Value...), 0)
+	assert.NotNil(t, merkle.VerifySparseMerkleProof(proof.Root, proof.Key, tampered, proof.Proof))
+	assert.NotNil(t, merkle.VerifySparseMerkleProof(proof.Root, proof.Key, nil, proof.Proof))
+
+	// the root is committed by the consensus signed header STATE_ROOT_DELAY blocks later
+	header, err := ledger.GetHeaderByHeight(1 + types.STATE_ROOT_DELAY)
+	assert.Nil(t, err)
+	assert.Equal(t, header.StateRoot, proof.Root)
+
+	absent, err := ledger.GetStorageProof(contract, []byte("absent key"), 1)
+	assert.Nil(t, err)
+	assert.Nil(t, absent.Value)
+	assert.Nil(t, merkle.VerifySparseMerkleProof(absent.Root, absent.Key, nil, absent.Proof))
+	assert.NotNil(t, merkle.VerifySparseMerkleProof(absent.Root, absent.Key, proof.Value, absent.Proof))
+
+	_, err = ledger.GetStorageProof(contract, key, 4)
+	assert.NotNil(t, err)
+}
+
+func TestConsistencyProof(t *testing.T) {
+	ledger := newTestLedger(t, memstore.BACKEND_MEMORY)
+	defer ledger.close()
+	ledger.addBlocks(t, 5)
+
+	// the tree of genesis has the transactions root as the only leaf
+	blockRoot := func(height uint32) common.Uint256 {
+		header, err := ledger.GetHeaderByHeight(height)
+		assert.Nil(t, err)
+		if height == 0 {
+			return header.TransactionsRoot
+		}
+		return header.BlockRoot
+	}
+	for old := uint32(0); old <= 5; old++ {
+		for new := old; new <= 5; new++ {
+			proof, err := ledger.GetConsistencyProof(old, new)
+			assert.Nil(t, err)
+			assert.Nil(t, merkle.VerifyBlockRootConsistency(old, new, blockRoot(old), blockRoot(new), proof))
+		}
+	}
+	proof, err := ledger.GetConsistencyProof(2, 5)
+	assert.Nil(t, err)
+	assert.NotNil(t, merkle.VerifyBlockRootConsistency(2, 5, blockRoot(1), blockRoot(5), proof))
+}
@@ -0,0 +1,418 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/store"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/merkle"
+)
+
+const (
+	MAX_DIVERGENT_STATES = 100 //Max number of the divergent states reported
+)
+
+// The blocks are re-executed forward on a scratch state store in a temp dir, starting from the genesis
+// block or from a state snapshot whose state root is committed by the headers. Nothing but the signed
+// blocks is trusted, the states, undo states and roots in the ledger are only compared with the results.
+
+//NewReadOnlyLedgerStore open the stores in the data dir read only, the ledger can be queried and
+//verified, but no block can be saved
+func NewReadOnlyLedgerStore(dataDir string, stateHashHeight uint32) (*LedgerStoreImp, error) {
+	ledgerStore := &LedgerStoreImp{
+		dataDir:              dataDir,
+		headerIndex:          make(map[uint32]common.Uint256),
+		headerCache:          make(map[common.Uint256]*types.Header, 0),
+		vbftPeerInfoheader:   make(map[string]uint32),
+		vbftPeerInfoblock:    make(map[string]uint32),
+		savingBlockSemaphore: make(chan bool, 1),
+		stateHashCheckHeight: stateHashHeight,
+	}
+
+	dbPath := fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirBlock)
+	store, err := leveldbstore.NewReadOnlyLevelDBStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open block store error %s", err)
+	}
+	ledgerStore.blockStore = &BlockStore{dbDir: dbPath, store: store}
+	ledgerStore.prunedHeight, err = ledgerStore.blockStore.GetPrunedHeight()
+	if err != nil {
+		return nil, fmt.Errorf("GetPrunedHeight error %s", err)
+	}
+
+	dbPath = fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirState)
+	store, err = leveldbstore.NewReadOnlyLevelDBStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open state store error %s", err)
+	}
+	stateStore := &StateStore{
+		dbDir:                dbPath,
+		store:                store,
+		merklePath:           fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), MerkleTreeStorePath),
+		stateHashCheckHeight: stateHashHeight,
+		stateTree:            merkle.NewSparseMerkleTree(&stateTreeStore{store: store}),
+		readOnly:             true,
+	}
+	ledgerStore.stateStore = stateStore
+	_, height, err := stateStore.GetCurrentBlock()
+	if err != nil {
+		return nil, fmt.Errorf("GetCurrentBlock error %s", err)
+	}
+	err = stateStore.init(height)
+	if err != nil {
+		return nil, fmt.Errorf("init state store error %s", err)
+	}
+	if stateStore.merkleHashStore == nil {
+		return nil, fmt.Errorf("block merkle tree file is inconsistent with state store")
+	}
+
+	dbPath = fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirEvent)
+	store, err = leveldbstore.NewReadOnlyLevelDBStore(dbPath)
+	if err != nil {
+		return nil, fmt.Errorf("open event store error %s", err)
+	}
+	ledgerStore.eventStore = &EventStore{dbDir: dbPath, store: store}
+
+	err = ledgerStore.loadCurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	err = ledgerStore.loadHeaderIndexList()
+	if err != nil {
+		return nil, err
+	}
+	return ledgerStore, nil
+}
+
+//VerifyBlocks re-execute the blocks to the end height, from genesis if the snapshot file is empty, or else from
+//the state snapshot, which is trusted only if its state root is committed by the headers. The header signatures,
+//transaction roots, block roots, state merkle roots and state tree roots of the blocks are checked against the
+//ledger, and the states are compared key by key if the end height is the current block. Return the first
+//divergence found, or nil if all of the blocks are verified
+func (this *LedgerStoreImp) VerifyBlocks(snapshot string, endHeight uint32, progress func(height uint32)) (*scom.LedgerDivergence, error) {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	_, stateHeight, err := this.stateStore.GetCurrentBlock()
+	if err != nil {
+		return nil, fmt.Errorf("GetCurrentBlock error %s", err)
+	}
+	if endHeight > stateHeight {
+		return nil, fmt.Errorf("end height %d is higher than current block height %d", endHeight, stateHeight)
+	}
+
+	dir, err := ioutil.TempDir("", "verifyledger")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	var states *StateStore
+	if snapshot == "" {
+		states, err = this.executeGenesis(dir)
+	} else {
+		states, err = this.loadTrustedSnapshot(dir, snapshot)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer states.Close()
+	_, startHeight, err := states.GetCurrentBlock()
+	if err != nil {
+		return nil, err
+	}
+	if startHeight >= endHeight {
+		return nil, fmt.Errorf("end height %d is not higher than start height %d", endHeight, startHeight)
+	}
+	if startHeight < this.prunedHeight {
+		return nil, fmt.Errorf("blocks below height %d are pruned, verify from a snapshot", this.prunedHeight)
+	}
+	vbftPeerInfo, err := this.getVbftPeerInfo(this.GetBlockHash(startHeight))
+	if err != nil {
+		return nil, err
+	}
+
+	log.Infof("re-execute blocks from height:%d", startHeight+1)
+	for height := startHeight + 1; height <= endHeight; height++ {
+		block, err := this.blockStore.GetBlock(this.GetBlockHash(height))
+		if err != nil {
+			return nil, fmt.Errorf("get block of height %d error %s", height, err)
+		}
+		if block.Hash() != this.GetBlockHash(height) {
+			return divergence(height, "block hash mismatches the block index"), nil
+		}
+		vbftPeerInfo, err = this.verifyHeader(block.Header, vbftPeerInfo)
+		if err != nil {
+			return divergence(height, "verify header error %s", err), nil
+		}
+		txHashes := make([]common.Uint256, 0, len(block.Transactions))
+		for _, tx := range block.Transactions {
+			txHashes = append(txHashes, tx.Hash())
+		}
+		txRoot := common.ComputeMerkleRoot(txHashes)
+		if txRoot != block.Header.TransactionsRoot {
+			return divergence(height, "transactions root %s mismatches header %s", txRoot.ToHexString(),
+				block.Header.TransactionsRoot.ToHexString()), nil
+		}
+		blockRoot := states.merkleTree.GetRootWithNewLeaf(txRoot)
+		if blockRoot != block.Header.BlockRoot {
+			return divergence(height, "block root %s mismatches header %s", blockRoot.ToHexString(),
+				block.Header.BlockRoot.ToHexString()), nil
+		}
+
+		result, err := this.executeBlockOn(block, states.store, states.deltaMerkleTree)
+		if err != nil {
+			return divergence(height, "execute block error %s", err), nil
+		}
+		if height >= this.stateHashCheckHeight {
+			writeSetHash, err := this.stateStore.getWriteSetHash(height)
+			if err != nil {
+				return nil, err
+			}
+			stateMerkleRoot, err := this.stateStore.GetStateMerkleRoot(height)
+			if err != nil {
+				return nil, err
+			}
+			if writeSetHash != result.Hash || stateMerkleRoot != result.MerkleRoot {
+				return divergence(height, "state merkle root %s mismatches ledger %s", result.MerkleRoot.ToHexString(),
+					stateMerkleRoot.ToHexString()), nil
+			}
+		}
+		err = saveExecutedBlock(states, block, result)
+		if err != nil {
+			return nil, err
+		}
+		diverged, err := this.checkStateTreeRoot(height, states.stateTreeRoot, result)
+		if err != nil || diverged != nil {
+			return diverged, err
+		}
+		if progress != nil {
+			progress(height)
+		}
+	}
+
+	if endHeight != stateHeight {
+		return nil, nil
+	}
+	diffs, count, err := diffStates(this.stateStore.store, states.store)
+	if err != nil {
+		return nil, err
+	}
+	if count != 0 {
+		result := divergence(endHeight, "%d states mismatch", count)
+		result.States = diffs
+		return result, nil
+	}
+	return nil, nil
+}
+
+//executeGenesis execute the genesis block of the ledger on an empty state store in the dir, the storage is
+//checked after the genesis block as the ledger is opened
+func (this *LedgerStoreImp) executeGenesis(dir string) (*StateStore, error) {
+	states, err := NewStateStore(fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), DBDirState),
+		fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), MerkleTreeStorePath), this.stateHashCheckHeight)
+	if err != nil {
+		return nil, fmt.Errorf("NewStateStore error %s", err)
+	}
+	block, err := this.blockStore.GetBlock(this.GetBlockHash(0))
+	if err != nil {
+		states.Close()
+		return nil, fmt.Errorf("get genesis block error %s", err)
+	}
+	result, err := this.executeBlockOn(block, states.store, states.deltaMerkleTree)
+	if err == nil {
+		err = saveExecutedBlock(states, block, result)
+	}
+	if err == nil {
+		err = states.CheckStorage()
+	}
+	if err != nil {
+		states.Close()
+		return nil, fmt.Errorf("execute genesis block error %s", err)
+	}
+	return states, nil
+}
+
+//loadTrustedSnapshot load the state snapshot file to the dir, the snapshot must match the headers of the ledger
+//and the state root committed by the header STATE_ROOT_DELAY blocks above it
+func (this *LedgerStoreImp) loadTrustedSnapshot(dir, file string) (*StateStore, error) {
+	reader, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	height, err := LoadSnapshot(dir, bufio.NewReader(reader))
+	reader.Close()
+	if err != nil {
+		return nil, fmt.Errorf("LoadSnapshot error %s", err)
+	}
+	stateRoot, err := this.GetCommittedStateRoot(height)
+	if err != nil {
+		return nil, err
+	}
+	err = this.verifySnapshot(dir, height, stateRoot)
+	if err != nil {
+		return nil, err
+	}
+	states, err := NewStateStore(fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), DBDirState),
+		fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), MerkleTreeStorePath), this.stateHashCheckHeight)
+	if err != nil {
+		return nil, fmt.Errorf("NewStateStore error %s", err)
+	}
+	return states, nil
+}
+
+//saveExecutedBlock save the execution result of the block to the scratch state store, like saveBlockToStateStore
+func saveExecutedBlock(states *StateStore, block *types.Block, result store.ExecuteResult) error {
+	height := block.Header.Height
+	states.NewBatch()
+	err := states.AddStateMerkleTreeRoot(height, result.Hash)
+	if err != nil {
+		return err
+	}
+	err = states.AddStateTreeRoot(height, result.WriteSet)
+	if err != nil {
+		return err
+	}
+	err = states.AddBlockMerkleTreeRoot(block.Header.TransactionsRoot)
+	if err != nil {
+		return err
+	}
+	err = states.SaveCurrentBlock(height, block.Hash())
+	if err != nil {
+		return err
+	}
+	result.WriteSet.ForEach(func(key, val []byte) {
+		if len(val) == 0 {
+			states.BatchDeleteRawKey(key)
+		} else {
+			states.BatchPutRawKeyVal(key, val)
+		}
+	})
+	return states.CommitTo()
+}
+
+//checkStateTreeRoot compare the executed state tree root with the root committed by the header STATE_ROOT_DELAY
+//blocks above, and the root saved in ledger if any. The written states of the block are compared with the
+//states proved by the saved root
+func (this *LedgerStoreImp) checkStateTreeRoot(height uint32, root common.Uint256,
+	result store.ExecuteResult) (*scom.LedgerDivergence, error) {
+	if committed, err := this.GetCommittedStateRoot(height); err == nil && committed != root {
+		return divergence(height, "state tree root %s mismatches committed %s", root.ToHexString(),
+			committed.ToHexString()), nil
+	}
+	stored, err := this.stateStore.GetStateTreeRoot(height)
+	if err == scom.ErrNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if stored == root {
+		return nil, nil
+	}
+	diverged := divergence(height, "state tree root %s mismatches ledger %s", root.ToHexString(), stored.ToHexString())
+	result.WriteSet.ForEach(func(key, val []byte) {
+		if err != nil || !isStateTreeKey(key) || len(diverged.States) >= MAX_DIVERGENT_STATES {
+			return
+		}
+		var proof *scom.StorageProof
+		proof, err = this.stateStore.GetStateProof(key, height)
+		if err == nil && !bytes.Equal(proof.Value, val) {
+			diverged.States = append(diverged.States, newDivergentState(key, proof.Value, val))
+		}
+	})
+	if err != nil {
+		log.Warnf("get states of height %d error %s", height, err)
+	}
+	return diverged, nil
+}
+
+func divergence(height uint32, format string, args ...interface{}) *scom.LedgerDivergence {
+	return &scom.LedgerDivergence{
+		Height: height,
+		Reason: fmt.Sprintf(format, args...),
+	}
+}
+
+//diffStates compare the states authenticated by the state tree in the ledger with the executed states, return the
+//first MAX_DIVERGENT_STATES divergent states and the number of them
+func diffStates(stored, executed scom.PersistStore) ([]*scom.DivergentState, int, error) {
+	diffs := make([]*scom.DivergentState, 0)
+	count := 0
+	add := func(key, stored, executed []byte) {
+		if count++; len(diffs) < MAX_DIVERGENT_STATES {
+			diffs = append(diffs, newDivergentState(key, stored, executed))
+		}
+	}
+	for _, prefix := range []scom.DataEntryPrefix{scom.ST_CONTRACT, scom.ST_STORAGE} {
+		storedIter := stored.NewIterator([]byte{byte(prefix)})
+		executedIter := executed.NewIterator([]byte{byte(prefix)})
+		hasStored, hasExecuted := storedIter.Next(), executedIter.Next()
+		for hasStored || hasExecuted {
+			cmp := 0
+			if !hasStored {
+				cmp = 1
+			} else if !hasExecuted {
+				cmp = -1
+			} else {
+				cmp = bytes.Compare(storedIter.Key(), executedIter.Key())
+			}
+			switch {
+			case cmp < 0:
+				add(storedIter.Key(), storedIter.Value(), nil)
+				hasStored = storedIter.Next()
+			case cmp > 0:
+				add(executedIter.Key(), nil, executedIter.Value())
+				hasExecuted = executedIter.Next()
+			default:
+				if !bytes.Equal(storedIter.Value(), executedIter.Value()) {
+					add(storedIter.Key(), storedIter.Value(), executedIter.Value())
+				}
+				hasStored, hasExecuted = storedIter.Next(), executedIter.Next()
+			}
+		}
+		storedIter.Release()
+		executedIter.Release()
+		if err := storedIter.Error(); err != nil {
+			return nil, 0, err
+		}
+		if err := executedIter.Error(); err != nil {
+			return nil, 0, err
+		}
+	}
+	return diffs, count, nil
+}
+
+func newDivergentState(key, stored, executed []byte) *scom.DivergentState {
+	state := &scom.DivergentState{Key: append([]byte{}, key...)}
+	if len(stored) != 0 {
+		state.Stored = append([]byte{}, stored...)
+	}
+	if len(executed) != 0 {
+		state.Executed = append([]byte{}, executed...)
+	}
+	return state
+}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"path/filepath"
+	"testing"
+
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/stretchr/testify/assert"
+)
+
+// verifyTestLedger verifies the closed ledger in the dir read only, and returns the verified heights
+func verifyTestLedger(t *testing.T, dir, snapshot string, endHeight uint32) (*scom.LedgerDivergence, []uint32, error) {
+	store, err := NewReadOnlyLedgerStore(dir, 0)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	defer store.Close()
+	heights := make([]uint32, 0)
+	divergence, err := store.VerifyBlocks(snapshot, endHeight, func(height uint32) {
+		heights = append(heights, height)
+	})
+	return divergence, heights, err
+}
+
+// tamperTestLedger overwrites the value of the key in the state store of the closed ledger in the dir
+func tamperTestLedger(t *testing.T, dir string, key, value []byte) {
+	store, err := leveldbstore.NewLevelDBStore(filepath.Join(dir, DBDirState))
+	assert.Nil(t, err)
+	assert.Nil(t, store.Put(key, value))
+	assert.Nil(t, store.Close())
+}
+
+func TestVerifyBlocks(t *testing.T) {
+	ledger := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer ledger.restore()
+	ledger.addBlocks(t, 3)
+	good := ledger.saveTestSnapshot(t, "good.snapshot")
+
+	// a snapshot with a forged state, whose checksum is still valid
+	contract, key := ledger.firstStorageKey(t)
+	stateKey := append(append([]byte{byte(scom.ST_STORAGE)}, contract[:]...), key...)
+	value, err := ledger.stateStore.store.Get(stateKey)
+	assert.Nil(t, err)
+	assert.Nil(t, ledger.stateStore.store.Put(stateKey, append(append([]byte{}, value...), 1)))
+	forged := ledger.saveTestSnapshot(t, "forged.snapshot")
+	assert.Nil(t, ledger.stateStore.store.Put(stateKey, value))
+	ledger.addBlocks(t, 2)
+	ledger.Close()
+
+	divergence, heights, err := verifyTestLedger(t, ledger.dir, "", 5)
+	assert.Nil(t, err)
+	assert.Nil(t, divergence)
+	assert.Equal(t, []uint32{1, 2, 3, 4, 5}, heights)
+	divergence, heights, err = verifyTestLedger(t, ledger.dir, "", 4)
+	assert.Nil(t, err)
+	assert.Nil(t, divergence)
+	assert.Equal(t, []uint32{1, 2, 3, 4}, heights)
+	_, _, err = verifyTestLedger(t, ledger.dir, "", 6)
+	assert.NotNil(t, err)
+
+	// the snapshot is trusted only if its state root is committed by the headers
+	divergence, heights, err = verifyTestLedger(t, ledger.dir, good, 5)
+	assert.Nil(t, err)
+	assert.Nil(t, divergence)
+	assert.Equal(t, []uint32{4, 5}, heights)
+	_, _, err = verifyTestLedger(t, ledger.dir, forged, 5)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "mismatches the committed")
+
+	// the current states are compared with the executed ones
+	tamperTestLedger(t, ledger.dir, stateKey, []byte("tampered"))
+	divergence, _, err = verifyTestLedger(t, ledger.dir, "", 5)
+	assert.Nil(t, err)
+	if assert.NotNil(t, divergence) {
+		assert.Equal(t, uint32(5), divergence.Height)
+		assert.Equal(t, 1, len(divergence.States))
+		assert.Equal(t, stateKey, divergence.States[0].Key)
+		assert.Equal(t, []byte("tampered"), divergence.States[0].Stored)
+		assert.Equal(t, value, divergence.States[0].Executed)
+	}
+	divergence, _, err = verifyTestLedger(t, ledger.dir, "", 4)
+	assert.Nil(t, err)
+	assert.Nil(t, divergence)
+
+	// the first divergent block is reported
+	stateMerkleRootKey := (&StateStore{}).genStateMerkleRootKey(2)
+	tamperTestLedger(t, ledger.dir, stateMerkleRootKey, make([]byte, 64))
+	divergence, heights, err = verifyTestLedger(t, ledger.dir, "", 5)
+	assert.Nil(t, err)
+	if assert.NotNil(t, divergence) {
+		assert.Equal(t, uint32(2), divergence.Height)
+		assert.Contains(t, divergence.Reason, "state merkle root")
+	}
+	assert.Equal(t, []uint32{1}, heights)
+}
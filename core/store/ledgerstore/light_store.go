@@ -0,0 +1,197 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"fmt"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/errors"
+)
+
+// In light mode, the ledger keeps the verified headers without transactions, events and states.
+// A header is saved to the block store as a block without transactions and becomes the current
+// block, and its transactions root is appended to the block merkle tree in the state store, so
+// the block roots of headers are checked, and the merkle proofs are served as in full mode.
+
+//initLightLedger init the light ledger with the genesis header, or load the saved headers
+func (this *LedgerStoreImp) initLightLedger(genesisBlock *types.Block) error {
+	version, err := this.blockStore.GetVersion()
+	if err != nil && err != scom.ErrNotFound {
+		return fmt.Errorf("GetVersion error %s", err)
+	}
+	switch version {
+	case SYSTEM_VERSION:
+		return fmt.Errorf("the ledger keeps full blocks, run in full mode or use another data dir")
+	case LIGHT_SYSTEM_VERSION:
+		genesisHash := genesisBlock.Hash()
+		exist, err := this.blockStore.ContainBlock(genesisHash)
+		if err != nil {
+			return fmt.Errorf("HashBlockExist error %s", err)
+		}
+		if !exist {
+			return fmt.Errorf("GenesisBlock arenot init correctly")
+		}
+		err = this.loadCurrentBlock()
+		if err != nil {
+			return fmt.Errorf("loadCurrentBlock error %s", err)
+		}
+		err = this.loadHeaderIndexList()
+		if err != nil {
+			return fmt.Errorf("loadHeaderIndexList error %s", err)
+		}
+		err = this.recoverLightStore()
+		if err != nil {
+			return fmt.Errorf("recoverLightStore error %s", err)
+		}
+	default:
+		err = this.blockStore.ClearAll()
+		if err != nil {
+			return fmt.Errorf("blockStore.ClearAll error %s", err)
+		}
+		err = this.stateStore.ClearAll()
+		if err != nil {
+			return fmt.Errorf("stateStore.ClearAll error %s", err)
+		}
+		err = this.eventStore.ClearAll()
+		if err != nil {
+			return fmt.Errorf("eventStore.ClearAll error %s", err)
+		}
+		err = this.saveHeader(genesisBlock.Header)
+		if err != nil {
+			return fmt.Errorf("save genesis header error %s", err)
+		}
+		err = this.blockStore.SaveVersion(LIGHT_SYSTEM_VERSION)
+		if err != nil {
+			return fmt.Errorf("init error %s", err)
+		}
+		genHash := genesisBlock.Hash()
+		log.Infof("GenesisBlock init success in light mode. GenesisBlock hash:%s\n", genHash.ToHexString())
+	}
+	return this.loadVbftPeerInfo()
+}
+
+//recoverLightStore append the transactions roots of the headers saved to block store but not to state store
+func (this *LedgerStoreImp) recoverLightStore() error {
+	blockHeight := this.GetCurrentBlockHeight()
+	_, stateHeight, err := this.stateStore.GetCurrentBlock()
+	if err != nil {
+		return fmt.Errorf("stateStore.GetCurrentBlock error %s", err)
+	}
+	for height := stateHeight + 1; height <= blockHeight; height++ {
+		header, err := this.GetHeaderByHeight(height)
+		if err != nil {
+			return fmt.Errorf("GetHeaderByHeight height:%d error:%s", height, err)
+		}
+		this.stateStore.NewBatch()
+		err = this.stateStore.AddBlockMerkleTreeRoot(header.TransactionsRoot)
+		if err != nil {
+			return fmt.Errorf("AddBlockMerkleTreeRoot error %s", err)
+		}
+		err = this.stateStore.SaveCurrentBlock(height, header.Hash())
+		if err != nil {
+			return fmt.Errorf("SaveCurrentBlock error %s", err)
+		}
+		err = this.stateStore.CommitTo()
+		if err != nil {
+			return fmt.Errorf("stateStore.CommitTo height:%d error %s", height, err)
+		}
+	}
+	return nil
+}
+
+//saveHeader save the verified header as the current block in light mode
+func (this *LedgerStoreImp) saveHeader(header *types.Header) error {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return errors.NewErr("save header error: ledger is closing")
+	}
+	blockHash := header.Hash()
+	blockHeight := header.Height
+	blockRoot := this.GetBlockRootWithNewTxRoots(blockHeight, []common.Uint256{header.TransactionsRoot})
+	if blockHeight != 0 && blockRoot != header.BlockRoot {
+		return fmt.Errorf("wrong block root at height:%d, expected:%s, got:%s",
+			blockHeight, blockRoot.ToHexString(), header.BlockRoot.ToHexString())
+	}
+
+	this.blockStore.NewBatch()
+	this.stateStore.NewBatch()
+	this.setHeaderIndex(blockHeight, blockHash)
+	err := this.saveHeaderIndexList()
+	if err != nil {
+		return fmt.Errorf("saveHeaderIndexList error %s", err)
+	}
+	err = this.blockStore.SaveCurrentBlock(blockHeight, blockHash)
+	if err != nil {
+		return fmt.Errorf("SaveCurrentBlock error %s", err)
+	}
+	this.blockStore.SaveBlockHash(blockHeight, blockHash)
+	err = this.blockStore.SaveHeader(&types.Block{Header: header}, 0)
+	if err != nil {
+		return fmt.Errorf("SaveHeader height %d hash %s error %s", blockHeight, blockHash.ToHexString(), err)
+	}
+	err = this.stateStore.AddBlockMerkleTreeRoot(header.TransactionsRoot)
+	if err != nil {
+		return fmt.Errorf("AddBlockMerkleTreeRoot error %s", err)
+	}
+	err = this.stateStore.SaveCurrentBlock(blockHeight, blockHash)
+	if err != nil {
+		return fmt.Errorf("SaveCurrentBlock error %s", err)
+	}
+	err = this.blockStore.CommitTo()
+	if err != nil {
+		return fmt.Errorf("blockStore.CommitTo height:%d error %s", blockHeight, err)
+	}
+	err = this.stateStore.CommitTo()
+	if err != nil {
+		return fmt.Errorf("stateStore.CommitTo height:%d error %s", blockHeight, err)
+	}
+	this.setCurrentBlock(blockHeight, blockHash)
+	return nil
+}
+
+//GetTransactionProof return the transaction with the merkle path from its hash to the transactions root of
+//block header, which is served to the light nodes
+func (this *LedgerStoreImp) GetTransactionProof(txHash common.Uint256) (*types.Transaction, *scom.TransactionProof, error) {
+	if this.light {
+		return nil, nil, scom.ErrLightMode
+	}
+	tx, height, err := this.blockStore.GetTransaction(txHash)
+	if err != nil {
+		return nil, nil, err
+	}
+	_, txHashes, err := this.blockStore.loadHeaderWithTx(this.GetBlockHash(height))
+	if err != nil {
+		return nil, nil, fmt.Errorf("load block of height:%d error %s", height, err)
+	}
+	for index, hash := range txHashes {
+		if hash == txHash {
+			return tx, &scom.TransactionProof{
+				Height: height,
+				Index:  uint32(index),
+				Path:   common.ComputeMerklePath(txHashes, index),
+			}, nil
+		}
+	}
+	return nil, nil, fmt.Errorf("transaction %s not found in block of height:%d", txHash.ToHexString(), height)
+}
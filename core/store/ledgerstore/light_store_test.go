@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLightLedger creates the light ledger store sharing the genesis block with the full ledger
+func newTestLightLedger(t *testing.T, full *testLedger) *testLedger {
+	return newTestLedgerWithMode(t, leveldbstore.BACKEND_LEVELDB, full.account, true)
+}
+
+// syncHeaders adds the headers of the full ledger to the light ledger up to the height
+func (self *testLedger) syncHeaders(t *testing.T, full *testLedger, height uint32) {
+	for h := self.GetCurrentBlockHeight() + 1; h <= height; h++ {
+		header, err := full.GetHeaderByHeight(h)
+		assert.Nil(t, err)
+		assert.Nil(t, self.AddHeader(header))
+	}
+}
+
+// saveHeaderToBlockStore commits the header to the block store only, as the crash of saveHeader
+// before the state store is committed
+func (self *testLedger) saveHeaderToBlockStore(t *testing.T, header *types.Header) {
+	hash := header.Hash()
+	self.blockStore.NewBatch()
+	self.setHeaderIndex(header.Height, hash)
+	assert.Nil(t, self.saveHeaderIndexList())
+	assert.Nil(t, self.blockStore.SaveCurrentBlock(header.Height, hash))
+	self.blockStore.SaveBlockHash(header.Height, hash)
+	assert.Nil(t, self.blockStore.SaveHeader(&types.Block{Header: header}, 0))
+	assert.Nil(t, self.blockStore.CommitTo())
+}
+
+func TestLightLedgerInit(t *testing.T) {
+	full := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer full.close()
+	full.addBlocks(t, 2)
+
+	light := newTestLightLedger(t, full)
+	defer light.close()
+	assert.Equal(t, uint32(0), light.GetCurrentBlockHeight())
+	assert.Equal(t, full.GetBlockHash(0), light.GetBlockHash(0))
+	light.syncHeaders(t, full, 2)
+
+	// the data dirs are kept for the mode they are inited in
+	assert.Nil(t, full.Close())
+	err := full.open(true)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "full blocks")
+	assert.Nil(t, full.open(false))
+	assert.Equal(t, uint32(2), full.GetCurrentBlockHeight())
+
+	assert.Nil(t, light.Close())
+	err = light.open(false)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "block headers only")
+	assert.Nil(t, light.open(true))
+	assert.Equal(t, uint32(2), light.GetCurrentBlockHeight())
+	assert.Equal(t, full.GetBlockHash(2), light.GetBlockHash(2))
+}
+
+func TestLightLedgerSaveHeader(t *testing.T) {
+	full := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer full.close()
+	full.addBlocks(t, 1, deployTestContract(t, []byte{1}))
+	light := newTestLightLedger(t, full)
+	defer light.close()
+	light.syncHeaders(t, full, 1)
+
+	block := full.makeBlock(t, []*types.Transaction{deployTestContract(t, []byte{2})})
+	header := *block.Header
+	header.BlockRoot = common.Uint256{1}
+	full.signBlock(t, &header)
+	err := light.AddHeader(&header)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "wrong block root")
+	assert.Equal(t, uint32(1), light.GetCurrentBlockHeight())
+	assert.Equal(t, uint32(1), light.GetCurrentHeaderHeight())
+
+	result, err := full.ExecuteBlock(block)
+	assert.Nil(t, err)
+	assert.Nil(t, full.SubmitBlock(block, result))
+	light.syncHeaders(t, full, 2)
+	assert.Equal(t, full.GetBlockRootWithNewTxRoots(3, nil), light.GetBlockRootWithNewTxRoots(3, nil))
+}
+
+func TestLightLedgerRecover(t *testing.T) {
+	full := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer full.close()
+	full.addBlocks(t, 3)
+	light := newTestLightLedger(t, full)
+	defer light.close()
+	light.syncHeaders(t, full, 1)
+
+	header, err := full.GetHeaderByHeight(2)
+	assert.Nil(t, err)
+	light.saveHeaderToBlockStore(t, header)
+	_, stateHeight, err := light.stateStore.GetCurrentBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(1), stateHeight)
+
+	assert.Nil(t, light.Close())
+	assert.Nil(t, light.open(true))
+	assert.Equal(t, uint32(2), light.GetCurrentBlockHeight())
+	_, stateHeight, err = light.stateStore.GetCurrentBlock()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(2), stateHeight)
+	// the block root of the next header is checked against the recovered merkle tree
+	light.syncHeaders(t, full, 3)
+}
+
+func TestLightLedgerTransactionProof(t *testing.T) {
+	full := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer full.close()
+	txs := []*types.Transaction{
+		deployTestContract(t, []byte{1}),
+		deployTestContract(t, []byte{2}),
+		deployTestContract(t, []byte{3}),
+	}
+	full.addBlocks(t, 2, txs...)
+	light := newTestLightLedger(t, full)
+	defer light.close()
+	light.syncHeaders(t, full, 2)
+
+	for _, tx := range txs {
+		proofTx, proof, err := full.GetTransactionProof(tx.Hash())
+		assert.Nil(t, err)
+		assert.Equal(t, tx.Hash(), proofTx.Hash())
+		header, err := light.GetHeaderByHeight(proof.Height)
+		assert.Nil(t, err)
+		assert.True(t, common.VerifyMerklePath(tx.Hash(), proof.Index, proof.Path, header.TransactionsRoot))
+		assert.False(t, common.VerifyMerklePath(common.Uint256{1}, proof.Index, proof.Path, header.TransactionsRoot))
+	}
+	_, _, err := light.GetTransactionProof(txs[0].Hash())
+	assert.Equal(t, scom.ErrLightMode, err)
+}
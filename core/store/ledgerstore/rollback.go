@@ -0,0 +1,429 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/overlaydb"
+	"github.com/dnaproject2/DNA/errors"
+	"github.com/dnaproject2/DNA/merkle"
+	"github.com/dnaproject2/DNA/smartcontract/event"
+)
+
+// Each block saves the undo states, as DATA_STATE_UNDO + block height => list of var bytes
+// state key and the value before the block, an empty value means the state did not exist.
+// Only the undo states of the latest Common.RollbackBlocks blocks are kept, saving a block
+// deletes the ones falling out of the window, so the ledger rolls back at most that many blocks.
+// Rolling back a block writes the undo states back, the stores are rolled back in the order of
+// event store, state store and block store, each from its own current block, so that an
+// interrupted rollback is completed by running it again.
+
+//Rollback revert the ledger to the block height, dropping the blocks above it with their
+//transactions, events and states
+func (this *LedgerStoreImp) Rollback(height uint32) error {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return errors.NewErr("rollback error: ledger is closing")
+	}
+	currBlockHeight := this.GetCurrentBlockHeight()
+	if height >= currBlockHeight {
+		return fmt.Errorf("rollback height %d is not below current block height %d", height, currBlockHeight)
+	}
+	blockHash, err := this.blockStore.GetBlockHash(height)
+	if err != nil {
+		return fmt.Errorf("GetBlockHash height:%d error %s", height, err)
+	}
+	err = this.stateStore.checkUndoStates(height)
+	if err != nil {
+		return err
+	}
+
+	log.Infof("rollback ledger from height:%d to height:%d", currBlockHeight, height)
+	err = this.rollbackEventStore(height, blockHash)
+	if err != nil {
+		return fmt.Errorf("rollback event store error %s", err)
+	}
+	err = this.stateStore.RollbackStates(height, blockHash)
+	if err != nil {
+		return fmt.Errorf("rollback state store error %s", err)
+	}
+	err = this.rollbackBlockStore(height, blockHash)
+	if err != nil {
+		return fmt.Errorf("rollback block store error %s", err)
+	}
+
+	vbftPeerInfo, err := this.getVbftPeerInfo(blockHash)
+	if err != nil {
+		return err
+	}
+	this.lock.Lock()
+	this.currBlockHeight = height
+	this.currBlockHash = blockHash
+	for h := range this.headerIndex {
+		if h > height {
+			delete(this.headerIndex, h)
+		}
+	}
+	for hash, header := range this.headerCache {
+		if header.Height > height {
+			delete(this.headerCache, hash)
+		}
+	}
+	if vbftPeerInfo != nil {
+		this.vbftPeerInfoheader = vbftPeerInfo
+		this.vbftPeerInfoblock = vbftPeerInfo
+	}
+	this.lock.Unlock()
+	return nil
+}
+
+//rollbackEventStore delete the event notifies and the index of the blocks above the height
+func (this *LedgerStoreImp) rollbackEventStore(height uint32, blockHash common.Uint256) error {
+	_, currHeight, err := this.eventStore.GetCurrentBlock()
+	if err != nil {
+		return fmt.Errorf("GetCurrentBlock error %s", err)
+	}
+	this.eventStore.NewBatch()
+	for h := currHeight; h > height; h-- {
+		_, txHashes, err := this.blockStore.loadHeaderWithTx(this.GetBlockHash(h))
+		if err != nil {
+			return fmt.Errorf("load block height:%d error %s", h, err)
+		}
+		notifies := make([]*event.ExecuteNotify, 0, len(txHashes))
+		for _, txHash := range txHashes {
+			notify, err := this.eventStore.GetEventNotifyByTx(txHash)
+			if err != nil && err != scom.ErrNotFound {
+				return fmt.Errorf("GetEventNotifyByTx error %s", err)
+			}
+			if notify != nil {
+				notifies = append(notifies, notify)
+			}
+			if !config.DefConfig.Common.EnableAddressIndex {
+				continue
+			}
+			tx, _, err := this.blockStore.GetTransaction(txHash)
+			if err != nil && err != scom.ErrPruned {
+				return fmt.Errorf("GetTransaction error %s", err)
+			}
+			// the index of the pruned transaction is deleted with it
+			if tx != nil {
+				this.eventStore.DeleteAddressTxIndex(h, txHash, getTxAddresses(tx, notify))
+			}
+		}
+		err = this.eventStore.PruneEventNotifyByBlock(h, notifies)
+		if err != nil {
+			return fmt.Errorf("PruneEventNotifyByBlock error %s", err)
+		}
+	}
+	prunedHeight, err := this.eventStore.GetPrunedHeight()
+	if err != nil {
+		return fmt.Errorf("GetPrunedHeight error %s", err)
+	}
+	if prunedHeight > height+1 {
+		this.eventStore.SavePrunedHeight(height + 1)
+	}
+	err = this.eventStore.SaveCurrentBlock(height, blockHash)
+	if err != nil {
+		return fmt.Errorf("SaveCurrentBlock error %s", err)
+	}
+	return this.eventStore.CommitTo()
+}
+
+//rollbackBlockStore delete the blocks above the height with their transactions, and the header
+//index lists containing them
+func (this *LedgerStoreImp) rollbackBlockStore(height uint32, blockHash common.Uint256) error {
+	currHeight := this.GetCurrentBlockHeight()
+	this.blockStore.NewBatch()
+	for h := currHeight; h > height; h-- {
+		err := this.blockStore.DeleteBlock(this.GetBlockHash(h), h)
+		if err != nil {
+			return fmt.Errorf("DeleteBlock height:%d error %s", h, err)
+		}
+	}
+	storedIndexCount := this.storedIndexCount
+	for storedIndexCount > height+1 {
+		storedIndexCount -= HEADER_INDEX_BATCH_SIZE
+		this.blockStore.DeleteHeaderIndexList(storedIndexCount)
+	}
+	prunedHeight := this.prunedHeight
+	if prunedHeight > height+1 {
+		prunedHeight = height + 1
+		this.blockStore.SavePrunedHeight(prunedHeight)
+	}
+	err := this.blockStore.SaveCurrentBlock(height, blockHash)
+	if err != nil {
+		return fmt.Errorf("SaveCurrentBlock error %s", err)
+	}
+	err = this.blockStore.CommitTo()
+	if err != nil {
+		return err
+	}
+	this.lock.Lock()
+	this.storedIndexCount = storedIndexCount
+	this.lock.Unlock()
+	this.prunedHeight = prunedHeight
+	if this.compactedHeight > prunedHeight {
+		this.compactedHeight = prunedHeight
+	}
+	return nil
+}
+
+//AddUndoStates save the values before the block of the states written by the block to the batch,
+//and delete the undo states of the block falling out of the rollback window
+func (self *StateStore) AddUndoStates(blockHeight uint32, writeSet *overlaydb.MemDB) error {
+	if self.undoBlocks == 0 {
+		return nil
+	}
+	if blockHeight >= self.undoBlocks {
+		self.store.BatchDelete(genStateUndoKey(blockHeight - self.undoBlocks))
+	}
+	sink := common.NewZeroCopySink(nil)
+	var err error
+	writeSet.ForEach(func(key, val []byte) {
+		if err != nil {
+			return
+		}
+		var prev []byte
+		prev, err = self.store.Get(key)
+		if err == scom.ErrNotFound {
+			prev, err = nil, nil
+		}
+		sink.WriteVarBytes(key)
+		sink.WriteVarBytes(prev)
+	})
+	if err != nil {
+		return err
+	}
+	self.store.BatchPut(genStateUndoKey(blockHeight), sink.Bytes())
+	return nil
+}
+
+//undoState is a state key with its value before a block
+type undoState struct {
+	key  []byte
+	prev []byte
+}
+
+//getUndoStates return the undo states of the block height
+func (self *StateStore) getUndoStates(height uint32) ([]*undoState, error) {
+	value, err := self.store.Get(genStateUndoKey(height))
+	if err != nil {
+		return nil, fmt.Errorf("get undo states of height %d error %s", height, err)
+	}
+	undoStates := make([]*undoState, 0)
+	source := common.NewZeroCopySource(value)
+	for source.Len() > 0 {
+		key, _, irregular, eof := source.NextVarBytes()
+		if irregular || eof {
+			return nil, fmt.Errorf("invalid undo states of height %d", height)
+		}
+		prev, _, irregular, eof := source.NextVarBytes()
+		if irregular || eof {
+			return nil, fmt.Errorf("invalid undo states of height %d", height)
+		}
+		undoStates = append(undoStates, &undoState{key: key, prev: prev})
+	}
+	return undoStates, nil
+}
+
+//checkUndoStates return error if any block above the height has no undo states
+func (self *StateStore) checkUndoStates(height uint32) error {
+	_, currHeight, err := self.GetCurrentBlock()
+	if err != nil {
+		return err
+	}
+	for h := height + 1; h <= currHeight; h++ {
+		_, err := self.store.Get(genStateUndoKey(h))
+		if err == scom.ErrNotFound {
+			return fmt.Errorf("undo states of height %d not found, cannot rollback below it", h)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//RollbackStates write back the undo states of the blocks above the height, and reverts the
+//block merkle tree, the state merkle tree and the state tree to the height
+func (self *StateStore) RollbackStates(height uint32, blockHash common.Uint256) error {
+	_, currHeight, err := self.GetCurrentBlock()
+	if err != nil {
+		return err
+	}
+	if currHeight <= height {
+		return nil
+	}
+	self.NewBatch()
+	// the undo states are written from the newest block, so that each state ends with
+	// its value before the oldest rolled back block
+	for h := currHeight; h > height; h-- {
+		undoStates, err := self.getUndoStates(h)
+		if err != nil {
+			return err
+		}
+		for _, state := range undoStates {
+			if len(state.prev) == 0 {
+				self.store.BatchDelete(state.key)
+			} else {
+				self.store.BatchPut(state.key, state.prev)
+			}
+			if self.archive {
+				self.store.BatchDelete(genArchiveKey(state.key, h))
+			}
+		}
+		self.store.BatchDelete(genStateUndoKey(h))
+		self.store.BatchDelete(genStateTreeRootKey(h))
+		self.store.BatchDelete(self.genStateMerkleRootKey(h))
+	}
+
+	err = self.rollbackStateMerkleTree(height)
+	if err != nil {
+		return err
+	}
+	// the hashes above the height are kept in file until overwritten, which is safe if the batch is lost
+	err = self.merkleTree.Truncate(height + 1)
+	if err != nil {
+		return fmt.Errorf("truncate block merkle tree error %s", err)
+	}
+	self.store.BatchPut(self.genBlockMerkleTreeKey(), serializeMerkleTree(self.merkleTree))
+
+	// the archive starting above the height has no versions of the height
+	rebuildArchive := self.archive && height < self.archiveHeight
+	if rebuildArchive {
+		self.store.BatchDelete(genArchiveHeightKey())
+	}
+	self.SaveCurrentBlock(height, blockHash)
+	err = self.CommitTo()
+	if err != nil {
+		return err
+	}
+
+	self.stateTree.Reset()
+	err = self.initStateTree(height)
+	if err != nil {
+		return fmt.Errorf("initStateTree error %s", err)
+	}
+	if rebuildArchive {
+		return self.initArchive(height, true)
+	}
+	return nil
+}
+
+//rollbackStateMerkleTree revert the state merkle tree to the height
+func (self *StateStore) rollbackStateMerkleTree(height uint32) error {
+	tree, err := self.getStateMerkleTreeAt(height)
+	if err != nil {
+		return err
+	}
+	self.deltaMerkleTree = tree
+	if tree == nil {
+		self.store.BatchDelete(self.genStateMerkleTreeKey())
+	} else {
+		self.store.BatchPut(self.genStateMerkleTreeKey(), serializeMerkleTree(tree))
+	}
+	return nil
+}
+
+//getStateMerkleTreeAt rebuild the state merkle tree of the height from the write set hashes of blocks,
+//return nil if the height is below the state hash check height
+func (self *StateStore) getStateMerkleTreeAt(height uint32) (*merkle.CompactMerkleTree, error) {
+	if height < self.stateHashCheckHeight {
+		return nil, nil
+	}
+	tree := merkle.NewTree(0, nil, nil)
+	for h := self.stateHashCheckHeight; h <= height; h++ {
+		writeSetHash, err := self.getWriteSetHash(h)
+		if err != nil {
+			return nil, err
+		}
+		tree.AppendHash(writeSetHash)
+	}
+	return tree, nil
+}
+
+//getWriteSetHash return the write set hash of the block height saved with the state merkle root
+func (self *StateStore) getWriteSetHash(height uint32) (common.Uint256, error) {
+	value, err := self.store.Get(self.genStateMerkleRootKey(height))
+	if err != nil {
+		return common.Uint256{}, fmt.Errorf("get state merkle root of height %d error %s", height, err)
+	}
+	writeSetHash, eof := common.NewZeroCopySource(value).NextHash()
+	if eof {
+		return common.Uint256{}, io.ErrUnexpectedEOF
+	}
+	return writeSetHash, nil
+}
+
+//PruneUndoStates delete the undo states below the block height
+func (self *StateStore) PruneUndoStates(height uint32) error {
+	self.NewBatch()
+	iter := self.store.NewIterator([]byte{byte(scom.DATA_STATE_UNDO)})
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) == 5 && binary.LittleEndian.Uint32(key[1:]) < height {
+			self.store.BatchDelete(key)
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return self.CommitTo()
+}
+
+func genStateUndoKey(height uint32) []byte {
+	key := make([]byte, 5, 5)
+	key[0] = byte(scom.DATA_STATE_UNDO)
+	binary.LittleEndian.PutUint32(key[1:], height)
+	return key
+}
+
+//DeleteBlock delete the block with its transactions and height index from store
+func (this *BlockStore) DeleteBlock(blockHash common.Uint256, height uint32) error {
+	_, txHashes, err := this.loadHeaderWithTx(blockHash)
+	if err != nil {
+		return err
+	}
+	for _, txHash := range txHashes {
+		this.store.BatchDelete(this.getTransactionKey(txHash))
+		if this.enableCache {
+			this.cache.RemoveTransaction(txHash)
+		}
+	}
+	this.store.BatchDelete(this.getHeaderKey(blockHash))
+	this.store.BatchDelete(this.getBlockHashKey(height))
+	if this.enableCache {
+		this.cache.RemoveBlock(blockHash)
+	}
+	return nil
+}
+
+//DeleteHeaderIndexList delete the header index list starting at the height
+func (this *BlockStore) DeleteHeaderIndexList(startIndex uint32) {
+	this.store.BatchDelete(this.getHeaderIndexListKey(startIndex))
+}
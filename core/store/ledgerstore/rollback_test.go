@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/core/genesis"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/stretchr/testify/assert"
+)
+
+// newTestLedgerWithUndo creates the test ledger keeping the undo states of the latest undoBlocks blocks
+func newTestLedgerWithUndo(t *testing.T, backend string, undoBlocks uint32) *testLedger {
+	old := config.DefConfig.Common.RollbackBlocks
+	config.DefConfig.Common.RollbackBlocks = undoBlocks
+	ledger := newTestLedger(t, backend)
+	restore := ledger.restore
+	ledger.restore = func() {
+		restore()
+		config.DefConfig.Common.RollbackBlocks = old
+	}
+	return ledger
+}
+
+// reopen opens the closed ledger again as a restarted node
+func (self *testLedger) reopen(t *testing.T) {
+	store, err := NewLedgerStore(self.dir, 0)
+	if !assert.Nil(t, err) {
+		t.FailNow()
+	}
+	bookkeepers := []keypair.PublicKey{self.account.PublicKey}
+	block, err := genesis.BuildGenesisBlock(bookkeepers, config.DefConfig.Genesis)
+	assert.Nil(t, err)
+	assert.Nil(t, store.InitLedgerStoreWithGenesisBlock(block, bookkeepers))
+	self.LedgerStoreImp = store
+}
+
+// deployTestContract returns a deploy transaction of the code, which needs no gas
+func deployTestContract(t *testing.T, code []byte) *types.Transaction {
+	tx, err := utils.NewDeployTransaction(code, "test", "1.0", "", "", "", false).IntoImmutable()
+	assert.Nil(t, err)
+	return tx
+}
+
+// undoHeights returns the block heights having the undo states in store
+func (self *testLedger) undoHeights(t *testing.T) []uint32 {
+	heights := make([]uint32, 0)
+	for h := uint32(0); h <= self.GetCurrentBlockHeight(); h++ {
+		_, err := self.stateStore.store.Get(genStateUndoKey(h))
+		if err == nil {
+			heights = append(heights, h)
+		} else {
+			assert.Equal(t, scom.ErrNotFound, err)
+		}
+	}
+	return heights
+}
+
+func testRollback(t *testing.T, backend string) {
+	ledger := newTestLedgerWithUndo(t, backend, 3)
+	defer ledger.close()
+	ledger.addBlocks(t, 2)
+	blockHash := ledger.GetCurrentBlockHash()
+	blockRoot := ledger.stateStore.merkleTree.Root()
+	stateRoot, err := ledger.stateStore.GetStateTreeRoot(2)
+	assert.Nil(t, err)
+	stateMerkleRoot, err := ledger.GetStateMerkleRoot(2)
+	assert.Nil(t, err)
+
+	first, second := []byte{byte(0x51)}, []byte{byte(0x52)}
+	ledger.addBlocks(t, 1, deployTestContract(t, first))
+	root3, err := ledger.stateStore.GetStateTreeRoot(3)
+	assert.Nil(t, err)
+	ledger.addBlocks(t, 1, deployTestContract(t, second))
+	_, err = ledger.GetContractState(common.AddressFromVmCode(first))
+	assert.Nil(t, err)
+
+	// the states, the merkle trees and the blocks above the height are reverted
+	assert.Nil(t, ledger.Rollback(2))
+	assert.Equal(t, uint32(2), ledger.GetCurrentBlockHeight())
+	assert.Equal(t, blockHash, ledger.GetCurrentBlockHash())
+	assert.Equal(t, common.UINT256_EMPTY, ledger.GetBlockHash(3))
+	assert.Equal(t, blockRoot, ledger.stateStore.merkleTree.Root())
+	root, err := ledger.stateStore.GetStateTreeRoot(2)
+	assert.Nil(t, err)
+	assert.Equal(t, stateRoot, root)
+	_, err = ledger.stateStore.GetStateTreeRoot(3)
+	assert.NotNil(t, err)
+	root, err = ledger.GetStateMerkleRoot(2)
+	assert.Nil(t, err)
+	assert.Equal(t, stateMerkleRoot, root)
+	for _, code := range [][]byte{first, second} {
+		_, err = ledger.GetContractState(common.AddressFromVmCode(code))
+		assert.Equal(t, scom.ErrNotFound, err)
+	}
+	assert.NotNil(t, ledger.Rollback(2))
+
+	// the blocks are added again on the rolled back ledger
+	ledger.addBlocks(t, 1, deployTestContract(t, first))
+	root, err = ledger.stateStore.GetStateTreeRoot(3)
+	assert.Nil(t, err)
+	assert.Equal(t, root3, root)
+	_, err = ledger.GetContractState(common.AddressFromVmCode(first))
+	assert.Nil(t, err)
+
+	// only the undo states of the latest blocks in the window are kept
+	ledger.addBlocks(t, 4)
+	assert.Equal(t, []uint32{5, 6, 7}, ledger.undoHeights(t))
+	err = ledger.Rollback(3)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "undo states of height 4 not found")
+	assert.Equal(t, uint32(7), ledger.GetCurrentBlockHeight())
+	assert.Nil(t, ledger.Rollback(4))
+	assert.Equal(t, uint32(4), ledger.GetCurrentBlockHeight())
+	assert.Equal(t, []uint32{}, ledger.undoHeights(t))
+	ledger.addBlocks(t, 1)
+	assert.Equal(t, []uint32{5}, ledger.undoHeights(t))
+}
+
+func TestRollback(t *testing.T) {
+	testRollback(t, leveldbstore.BACKEND_LEVELDB)
+	testRollback(t, memstore.BACKEND_MEMORY)
+}
+
+func TestRollbackWindow(t *testing.T) {
+	ledger := newTestLedgerWithUndo(t, leveldbstore.BACKEND_LEVELDB, 3)
+	defer ledger.restore()
+	ledger.addBlocks(t, 4)
+	assert.Equal(t, []uint32{2, 3, 4}, ledger.undoHeights(t))
+	ledger.Close()
+
+	// a narrowed window drops the undo states out of it on open
+	config.DefConfig.Common.RollbackBlocks = 1
+	ledger.reopen(t)
+	assert.Equal(t, []uint32{4}, ledger.undoHeights(t))
+	assert.NotNil(t, ledger.Rollback(2))
+	assert.Nil(t, ledger.Rollback(3))
+	ledger.Close()
+
+	// no undo states are kept if the rollback is disabled
+	config.DefConfig.Common.RollbackBlocks = 0
+	ledger.reopen(t)
+	defer ledger.Close()
+	ledger.addBlocks(t, 2)
+	assert.Equal(t, []uint32{}, ledger.undoHeights(t))
+	err := ledger.Rollback(4)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "undo states of height 5 not found")
+}
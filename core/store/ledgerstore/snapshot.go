@@ -0,0 +1,618 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/common/serialization"
+	"github.com/dnaproject2/DNA/consensus/vbft/config"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/types"
+)
+
+const (
+	SNAPSHOT_VERSION    = byte(1) //Version of state snapshot
+	SNAPSHOT_BATCH_SIZE = 10000   //Count of states written in a batch when loading snapshot
+)
+
+var (
+	DBDirSnapshot = "snapshot" //Staging dir of the state snapshot to apply
+)
+
+// A state snapshot of height H is made of, in order:
+//   version, H, the block hashes of height 0 to H,
+//   the headers of height 0 to H,
+//   the transactions of the blocks needed to continue the chain: genesis, H and the vbft config block of H,
+//   and the block height only of the other transactions,
+//   the block merkle tree file, the states,
+//   and the sha256 of all of the above.
+// The headers, the transactions and the states are lists of var bytes key and value as saved
+// in store, ended by an empty key. The archived states, the undo states and the state tree are not in
+// the snapshot, the state tree is rebuilt from the states when the loaded ledger is opened.
+
+//SaveSnapshot write the state snapshot of the current block to the writer, return the height of snapshot.
+//The block saving is blocked only while the snapshots of the stores are taken, the snapshot is written from them
+func (this *LedgerStoreImp) SaveSnapshot(writer io.Writer) (uint32, error) {
+	height, blockHeights, blockStore, stateStore, merkleFile, err := this.getStoresSnapshot()
+	if err != nil {
+		return 0, err
+	}
+	defer blockStore.Release()
+	defer stateStore.Release()
+	hasher := sha256.New()
+	w := io.MultiWriter(writer, hasher)
+
+	err = serialization.WriteByte(w, SNAPSHOT_VERSION)
+	if err != nil {
+		return 0, err
+	}
+	err = serialization.WriteUint32(w, height)
+	if err != nil {
+		return 0, err
+	}
+	blockHashes := make([]common.Uint256, height+1)
+	for h := range blockHashes {
+		value, err := blockStore.Get(this.blockStore.getBlockHashKey(uint32(h)))
+		if err != nil {
+			return 0, fmt.Errorf("get block hash of height %d error %s", h, err)
+		}
+		blockHashes[h], err = common.Uint256ParseFromBytes(value)
+		if err != nil {
+			return 0, fmt.Errorf("invalid block hash of height %d", h)
+		}
+		if _, err = w.Write(blockHashes[h][:]); err != nil {
+			return 0, err
+		}
+	}
+
+	for h, blockHash := range blockHashes {
+		value, err := blockStore.Get(this.blockStore.getHeaderKey(blockHash))
+		if err != nil {
+			return 0, fmt.Errorf("get header of height %d error %s", h, err)
+		}
+		if err = serialization.WriteVarBytes(w, blockHash[:]); err != nil {
+			return 0, err
+		}
+		if err = serialization.WriteVarBytes(w, value); err != nil {
+			return 0, err
+		}
+	}
+	if err = serialization.WriteVarBytes(w, nil); err != nil {
+		return 0, err
+	}
+	iter := blockStore.NewIterator([]byte{byte(scom.DATA_TRANSACTION)})
+	err = writeSnapshotEntries(w, iter, func(key, value []byte) ([]byte, []byte) {
+		if len(value) < 4 {
+			return nil, nil
+		}
+		if !blockHeights[binary.LittleEndian.Uint32(value)] {
+			value = value[:4]
+		}
+		return key[1:], value
+	})
+	if err != nil {
+		return 0, fmt.Errorf("write transactions error %s", err)
+	}
+
+	err = serialization.WriteVarBytes(w, merkleFile)
+	if err != nil {
+		return 0, fmt.Errorf("write merkle tree file error %s", err)
+	}
+	iter = stateStore.NewIterator(nil)
+	err = writeSnapshotEntries(w, iter, func(key, value []byte) ([]byte, []byte) {
+		if !isSnapshotStateKey(key) {
+			return nil, nil
+		}
+		return key, value
+	})
+	if err != nil {
+		return 0, fmt.Errorf("write states error %s", err)
+	}
+	_, err = writer.Write(hasher.Sum(nil))
+	return height, err
+}
+
+//getStoresSnapshot return the current block height, the heights of the blocks whose transactions are in the state
+//snapshot, the snapshots of the block store and the state store, and the content of the block merkle tree file,
+//all of which are taken when no block is being saved, so that they are consistent
+func (this *LedgerStoreImp) getStoresSnapshot() (uint32, map[uint32]bool, scom.StoreSnapshot, scom.StoreSnapshot,
+	[]byte, error) {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return 0, nil, nil, nil, nil, fmt.Errorf("ledger is closing")
+	}
+	height := this.GetCurrentBlockHeight()
+	blockHeights, err := this.snapshotBlockHeights(height)
+	if err != nil {
+		return 0, nil, nil, nil, nil, err
+	}
+	merkleFile, err := ioutil.ReadFile(this.stateStore.merklePath)
+	if err != nil {
+		return 0, nil, nil, nil, nil, fmt.Errorf("read merkle tree file error %s", err)
+	}
+	blockStore, err := getStoreSnapshot(this.blockStore.store)
+	if err != nil {
+		return 0, nil, nil, nil, nil, err
+	}
+	stateStore, err := getStoreSnapshot(this.stateStore.store)
+	if err != nil {
+		blockStore.Release()
+		return 0, nil, nil, nil, nil, err
+	}
+	return height, blockHeights, blockStore, stateStore, merkleFile, nil
+}
+
+//getStoreSnapshot return the snapshot of the store if the db backend supports
+func getStoreSnapshot(store scom.PersistStore) (scom.StoreSnapshot, error) {
+	snapshotable, ok := store.(scom.SnapshotableStore)
+	if !ok {
+		return nil, fmt.Errorf("db backend %s takes no snapshot", config.DefConfig.Common.DBBackend)
+	}
+	return snapshotable.GetSnapshot()
+}
+
+//snapshotBlockHeights return the heights of the blocks whose transactions are in snapshot
+func (this *LedgerStoreImp) snapshotBlockHeights(height uint32) (map[uint32]bool, error) {
+	heights := map[uint32]bool{0: true, height: true}
+	if strings.ToLower(config.DefConfig.Genesis.ConsensusType) == "vbft" {
+		header, err := this.GetHeaderByHeight(height)
+		if err != nil {
+			return nil, err
+		}
+		blkInfo, err := vconfig.VbftBlock(header)
+		if err != nil {
+			return nil, err
+		}
+		if blkInfo.NewChainConfig == nil {
+			heights[blkInfo.LastConfigBlockNum] = true
+		}
+	}
+	return heights, nil
+}
+
+//isSnapshotStateKey return whether the key of state store is written to snapshot
+func isSnapshotStateKey(key []byte) bool {
+	switch scom.DataEntryPrefix(key[0]) {
+	case scom.ST_ARCHIVE, scom.SYS_ARCHIVE_HEIGHT, scom.ST_STATE_TREE_NODE, scom.ST_STATE_TREE_VALUE, scom.DATA_STATE_TREE_ROOT,
+		scom.DATA_STATE_UNDO:
+		return false
+	}
+	return true
+}
+
+func writeSnapshotEntries(w io.Writer, iter scom.StoreIterator, convert func(key, value []byte) ([]byte, []byte)) error {
+	defer iter.Release()
+	for iter.Next() {
+		key, value := convert(iter.Key(), iter.Value())
+		if len(key) == 0 {
+			continue
+		}
+		if err := serialization.WriteVarBytes(w, key); err != nil {
+			return err
+		}
+		if err := serialization.WriteVarBytes(w, value); err != nil {
+			return err
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	return serialization.WriteVarBytes(w, nil)
+}
+
+//LoadSnapshot write the state snapshot read from the reader to the empty ledger in the data dir,
+//return the height of snapshot. The blocks below the height are pruned in the loaded ledger.
+func LoadSnapshot(dataDir string, reader io.Reader) (uint32, error) {
+	if isInMemoryBackend() {
+		return 0, fmt.Errorf("state snapshot cannot be loaded to the in-memory db backend")
+	}
+	blockStore, err := NewBlockStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirBlock), false)
+	if err != nil {
+		return 0, fmt.Errorf("NewBlockStore error %s", err)
+	}
+	defer blockStore.Close()
+	_, err = blockStore.GetVersion()
+	if err != scom.ErrNotFound {
+		return 0, fmt.Errorf("ledger in %s is not empty", dataDir)
+	}
+	stateStore, err := openStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirState))
+	if err != nil {
+		return 0, fmt.Errorf("open state store error %s", err)
+	}
+	defer stateStore.Close()
+	eventStore, err := NewEventStore(fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), DBDirEvent))
+	if err != nil {
+		return 0, fmt.Errorf("NewEventStore error %s", err)
+	}
+	defer eventStore.Close()
+	// drop the data left by the failed loading if any
+	if err = blockStore.ClearAll(); err != nil {
+		return 0, err
+	}
+	if err = eventStore.ClearAll(); err != nil {
+		return 0, err
+	}
+	stateStore.NewBatch()
+	iter := stateStore.NewIterator(nil)
+	for iter.Next() {
+		stateStore.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	if err = iter.Error(); err != nil {
+		return 0, err
+	}
+	if err = stateStore.BatchCommit(); err != nil {
+		return 0, err
+	}
+
+	hasher := sha256.New()
+	r := io.TeeReader(reader, hasher)
+	version, err := serialization.ReadByte(r)
+	if err != nil {
+		return 0, err
+	}
+	if version != SNAPSHOT_VERSION {
+		return 0, fmt.Errorf("unsupported snapshot version %d", version)
+	}
+	height, err := serialization.ReadUint32(r)
+	if err != nil {
+		return 0, err
+	}
+
+	blockStore.NewBatch()
+	blockHashes := make([]common.Uint256, height+1)
+	for h := range blockHashes {
+		if _, err = io.ReadFull(r, blockHashes[h][:]); err != nil {
+			return 0, fmt.Errorf("read block hash error %s", err)
+		}
+		blockStore.SaveBlockHash(uint32(h), blockHashes[h])
+	}
+	for start := uint32(0); height-start >= HEADER_INDEX_BATCH_SIZE; start += HEADER_INDEX_BATCH_SIZE {
+		err = blockStore.SaveHeaderIndexList(start, blockHashes[start:start+HEADER_INDEX_BATCH_SIZE])
+		if err != nil {
+			return 0, err
+		}
+	}
+	headers := make([]common.Uint256, 0)
+	err = readSnapshotEntries(r, func(key, value []byte) error {
+		blockHash, err := common.Uint256ParseFromBytes(key)
+		if err != nil {
+			return err
+		}
+		blockStore.store.BatchPut(blockStore.getHeaderKey(blockHash), value)
+		headers = append(headers, blockHash)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read headers error %s", err)
+	}
+	err = readSnapshotEntries(r, func(key, value []byte) error {
+		txHash, err := common.Uint256ParseFromBytes(key)
+		if err != nil || len(value) < 4 {
+			return fmt.Errorf("invalid transaction")
+		}
+		blockStore.store.BatchPut(blockStore.getTransactionKey(txHash), value)
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read transactions error %s", err)
+	}
+	err = blockStore.CommitTo()
+	if err != nil {
+		return 0, err
+	}
+	hasHeaders := make([]bool, height+1)
+	for _, blockHash := range headers {
+		header, err := blockStore.GetHeader(blockHash)
+		if err != nil {
+			return 0, fmt.Errorf("GetHeader %s error %s", blockHash.ToHexString(), err)
+		}
+		if header.Height > height || header.Hash() != blockHashes[header.Height] {
+			return 0, fmt.Errorf("header of height %d mismatches the block hash", header.Height)
+		}
+		hasHeaders[header.Height] = true
+	}
+	for h, has := range hasHeaders {
+		if !has {
+			return 0, fmt.Errorf("header of height %d not found", h)
+		}
+	}
+
+	err = readSnapshotFile(r, fmt.Sprintf("%s%s%s", dataDir, string(os.PathSeparator), MerkleTreeStorePath))
+	if err != nil {
+		return 0, fmt.Errorf("read merkle tree file error %s", err)
+	}
+	stateStore.NewBatch()
+	states := 0
+	err = readSnapshotEntries(r, func(key, value []byte) error {
+		if !isSnapshotStateKey(key) {
+			return fmt.Errorf("unexpected state key %x", key)
+		}
+		stateStore.BatchPut(key, value)
+		if states++; states%SNAPSHOT_BATCH_SIZE == 0 {
+			if err := stateStore.BatchCommit(); err != nil {
+				return err
+			}
+			stateStore.NewBatch()
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("read states error %s", err)
+	}
+	err = stateStore.BatchCommit()
+	if err != nil {
+		return 0, err
+	}
+
+	checksum := make([]byte, sha256.Size)
+	if _, err = io.ReadFull(reader, checksum); err != nil {
+		return 0, fmt.Errorf("read checksum error %s", err)
+	}
+	if !bytes.Equal(checksum, hasher.Sum(nil)) {
+		return 0, fmt.Errorf("checksum mismatch")
+	}
+
+	// the ledger is initialized only if the whole snapshot is loaded
+	blockHash := blockHashes[height]
+	eventStore.NewBatch()
+	eventStore.SaveCurrentBlock(height, blockHash)
+	err = eventStore.CommitTo()
+	if err != nil {
+		return 0, err
+	}
+	blockStore.NewBatch()
+	blockStore.SavePrunedHeight(height)
+	blockStore.SaveCurrentBlock(height, blockHash)
+	err = blockStore.CommitTo()
+	if err != nil {
+		return 0, err
+	}
+	return height, blockStore.SaveVersion(SYSTEM_VERSION)
+}
+
+func readSnapshotEntries(r io.Reader, handle func(key, value []byte) error) error {
+	for {
+		key, err := serialization.ReadVarBytes(r)
+		if err != nil {
+			return err
+		}
+		if len(key) == 0 {
+			return nil
+		}
+		value, err := serialization.ReadVarBytes(r)
+		if err != nil {
+			return err
+		}
+		if err = handle(key, value); err != nil {
+			return err
+		}
+	}
+}
+
+func readSnapshotFile(r io.Reader, path string) error {
+	size, err := serialization.ReadVarUint(r, 0)
+	if err != nil {
+		return err
+	}
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0755)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_, err = io.CopyN(file, r, int64(size))
+	if err != nil {
+		return err
+	}
+	return file.Sync()
+}
+
+//GetSnapshotInfo return the block hash and the state roots of the block height
+func (this *LedgerStoreImp) GetSnapshotInfo(height uint32) (*scom.SnapshotInfo, error) {
+	if height > this.GetCurrentBlockHeight() {
+		return nil, fmt.Errorf("height %d is higher than current block height", height)
+	}
+	stateRoot, err := this.stateStore.GetStateTreeRoot(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateTreeRoot height:%d error %s", height, err)
+	}
+	stateMerkleRoot, err := this.stateStore.GetStateMerkleRoot(height)
+	if err != nil {
+		return nil, fmt.Errorf("GetStateMerkleRoot height:%d error %s", height, err)
+	}
+	return &scom.SnapshotInfo{
+		Height:          height,
+		BlockHash:       this.GetBlockHash(height),
+		StateRoot:       stateRoot,
+		StateMerkleRoot: stateMerkleRoot,
+	}, nil
+}
+
+//GetCommittedStateRoot return the state tree root of the height committed by the synced header STATE_ROOT_DELAY
+//blocks above it. The header is signed by the consensus, so the state root is trusted without executing the blocks
+func (this *LedgerStoreImp) GetCommittedStateRoot(height uint32) (common.Uint256, error) {
+	header, err := this.GetHeaderByHeight(height + types.STATE_ROOT_DELAY)
+	if err != nil {
+		return common.UINT256_EMPTY, err
+	}
+	if header == nil {
+		return common.UINT256_EMPTY, fmt.Errorf("header of height %d not synced", height+types.STATE_ROOT_DELAY)
+	}
+	rootHeight, ok := header.StateRootHeight()
+	if !ok || rootHeight != height {
+		return common.UINT256_EMPTY, fmt.Errorf("header of height %d commits no state root of height %d", header.Height, height)
+	}
+	return header.StateRoot, nil
+}
+
+//ApplySnapshot replace the ledger which has the genesis block only with the state snapshot file of the height. The
+//snapshot is loaded to a staging dir first, and applied only if it matches the synced headers and the state root
+//committed by the header STATE_ROOT_DELAY blocks above it, so the peers serving the snapshot need not be trusted.
+//The state merkle root isn't committed by headers, it's taken from the snapshot as is
+func (this *LedgerStoreImp) ApplySnapshot(file string, height uint32) error {
+	this.getSavingBlockLock()
+	defer this.releaseSavingBlockLock()
+	if this.closing {
+		return fmt.Errorf("ledger is closing")
+	}
+	if this.stateStore.inMemory {
+		return fmt.Errorf("state snapshot cannot be applied to the in-memory db backend")
+	}
+	if this.GetCurrentBlockHeight() != 0 {
+		return fmt.Errorf("ledger is not empty")
+	}
+	if height == 0 {
+		return fmt.Errorf("invalid snapshot height 0")
+	}
+	stateRoot, err := this.GetCommittedStateRoot(height)
+	if err != nil {
+		return err
+	}
+
+	stageDir := fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), DBDirSnapshot)
+	err = os.RemoveAll(stageDir)
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(stageDir)
+	reader, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	loaded, err := LoadSnapshot(stageDir, bufio.NewReader(reader))
+	reader.Close()
+	if err != nil {
+		return fmt.Errorf("LoadSnapshot error %s", err)
+	}
+	if loaded != height {
+		return fmt.Errorf("snapshot height %d mismatches %d", loaded, height)
+	}
+	err = this.verifySnapshot(stageDir, height, stateRoot)
+	if err != nil {
+		return err
+	}
+
+	// the synced headers above the snapshot are kept in memory
+	this.lock.RLock()
+	headerIndex := make(map[uint32]common.Uint256, len(this.headerIndex))
+	for h, hash := range this.headerIndex {
+		headerIndex[h] = hash
+	}
+	this.lock.RUnlock()
+
+	err = this.closeStores()
+	if err != nil {
+		return err
+	}
+	for _, name := range []string{DBDirBlock, DBDirState, DBDirEvent, MerkleTreeStorePath} {
+		path := fmt.Sprintf("%s%s%s", this.dataDir, string(os.PathSeparator), name)
+		if err = os.RemoveAll(path); err != nil {
+			return err
+		}
+		err = os.Rename(fmt.Sprintf("%s%s%s", stageDir, string(os.PathSeparator), name), path)
+		if err != nil {
+			return err
+		}
+	}
+	err = this.openStores()
+	if err != nil {
+		return err
+	}
+	err = this.init()
+	if err != nil {
+		return fmt.Errorf("init error %s", err)
+	}
+	this.lock.Lock()
+	for h, hash := range headerIndex {
+		if h > height {
+			this.headerIndex[h] = hash
+		}
+	}
+	for hash, header := range this.headerCache {
+		if header.Height <= height {
+			delete(this.headerCache, hash)
+		}
+	}
+	this.lock.Unlock()
+	peerInfo, err := this.getVbftPeerInfo(this.currBlockHash)
+	if err != nil {
+		return err
+	}
+	if peerInfo != nil {
+		this.lock.Lock()
+		this.vbftPeerInfoblock = peerInfo
+		this.lock.Unlock()
+	}
+	log.Infof("apply state snapshot of height %d", height)
+	return nil
+}
+
+//verifySnapshot check the loaded snapshot in the dir against the synced headers and the committed state root
+func (this *LedgerStoreImp) verifySnapshot(dir string, height uint32, committedRoot common.Uint256) error {
+	blockStore, err := NewBlockStore(fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), DBDirBlock), false)
+	if err != nil {
+		return fmt.Errorf("NewBlockStore error %s", err)
+	}
+	defer blockStore.Close()
+	for h := uint32(0); h <= height; h++ {
+		blockHash, err := blockStore.GetBlockHash(h)
+		if err != nil {
+			return fmt.Errorf("GetBlockHash height:%d error %s", h, err)
+		}
+		if blockHash != this.GetBlockHash(h) {
+			return fmt.Errorf("block hash of height %d mismatches the header", h)
+		}
+	}
+	header, err := blockStore.GetHeader(this.GetBlockHash(height))
+	if err != nil {
+		return fmt.Errorf("GetHeader error %s", err)
+	}
+
+	// the state tree is rebuilt from the loaded states when the store is opened
+	stateStore, err := NewStateStore(fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), DBDirState),
+		fmt.Sprintf("%s%s%s", dir, string(os.PathSeparator), MerkleTreeStorePath), this.stateHashCheckHeight)
+	if err != nil {
+		return fmt.Errorf("NewStateStore error %s", err)
+	}
+	defer stateStore.Close()
+	if stateStore.merkleTree.Root() != header.BlockRoot {
+		return fmt.Errorf("block merkle root mismatches the header")
+	}
+	stateRoot, err := stateStore.GetStateTreeRoot(height)
+	if err != nil {
+		return fmt.Errorf("GetStateTreeRoot error %s", err)
+	}
+	if stateRoot != committedRoot {
+		return fmt.Errorf("state root %s mismatches the committed %s", stateRoot.ToHexString(), committedRoot.ToHexString())
+	}
+	return nil
+}
@@ -0,0 +1,204 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/states"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+// saveTestSnapshot writes the snapshot of current block to the file of the name in the ledger dir
+func (self *testLedger) saveTestSnapshot(t *testing.T, name string) string {
+	path := filepath.Join(self.dir, name)
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+	defer file.Close()
+	height, err := self.SaveSnapshot(file)
+	assert.Nil(t, err)
+	assert.Equal(t, self.GetCurrentBlockHeight(), height)
+	return path
+}
+
+func (self *testLedger) headers(t *testing.T, start, end uint32) []*types.Header {
+	headers := make([]*types.Header, 0, end-start+1)
+	for h := start; h <= end; h++ {
+		header, err := self.GetHeaderByHeight(h)
+		assert.Nil(t, err)
+		headers = append(headers, header)
+	}
+	return headers
+}
+
+// hookWriter calls the hook before the first write
+type hookWriter struct {
+	io.Writer
+	hook func()
+}
+
+func (self *hookWriter) Write(p []byte) (int, error) {
+	if self.hook != nil {
+		self.hook()
+		self.hook = nil
+	}
+	return self.Writer.Write(p)
+}
+
+func TestSaveSnapshot(t *testing.T) {
+	source := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer source.close()
+	source.addBlocks(t, 3)
+	path := filepath.Join(source.dir, "test.snapshot")
+	file, err := os.Create(path)
+	assert.Nil(t, err)
+
+	// the blocks are saved while the snapshot is being written, which doesn't change the snapshot
+	code := []byte{byte(0x51)}
+	height, err := source.SaveSnapshot(&hookWriter{Writer: file, hook: func() {
+		source.addBlocks(t, 2, deployTestContract(t, code))
+	}})
+	assert.Nil(t, err)
+	assert.Nil(t, file.Close())
+	assert.Equal(t, uint32(3), height)
+	assert.Equal(t, uint32(5), source.GetCurrentBlockHeight())
+
+	dir, err := ioutil.TempDir("", "ledgerstore")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	file, err = os.Open(path)
+	assert.Nil(t, err)
+	height, err = LoadSnapshot(dir, file)
+	file.Close()
+	assert.Nil(t, err)
+	assert.Equal(t, uint32(3), height)
+
+	loaded := &testLedger{dir: dir, account: source.account}
+	loaded.reopen(t)
+	defer loaded.Close()
+	assert.Equal(t, uint32(3), loaded.GetCurrentBlockHeight())
+	assert.Equal(t, source.GetBlockHash(3), loaded.GetCurrentBlockHash())
+	root, err := loaded.stateStore.GetStateTreeRoot(3)
+	assert.Nil(t, err)
+	expected, err := source.stateStore.GetStateTreeRoot(3)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, root)
+	_, err = loaded.GetContractState(common.AddressFromVmCode(code))
+	assert.Equal(t, scom.ErrNotFound, err)
+
+	// the headers of all the blocks are loaded, the transactions of the blocks below the height are pruned
+	for h := uint32(0); h <= 3; h++ {
+		header, err := loaded.GetHeaderByHeight(h)
+		assert.Nil(t, err)
+		assert.Equal(t, source.GetBlockHash(h), header.Hash())
+	}
+	assert.Equal(t, uint32(3), loaded.prunedHeight)
+
+	// the ledger continues from the snapshot
+	for h := uint32(4); h <= 5; h++ {
+		block, err := source.GetBlockByHash(source.GetBlockHash(h))
+		assert.Nil(t, err)
+		result, err := loaded.ExecuteBlock(block)
+		assert.Nil(t, err)
+		assert.Nil(t, loaded.SubmitBlock(block, result))
+	}
+	_, err = loaded.GetContractState(common.AddressFromVmCode(code))
+	assert.Nil(t, err)
+}
+
+func TestApplySnapshot(t *testing.T) {
+	source := newTestLedger(t, leveldbstore.BACKEND_LEVELDB)
+	defer source.close()
+	source.addBlocks(t, 3)
+	good := source.saveTestSnapshot(t, "good.snapshot")
+
+	// a snapshot of the same blocks with a forged state, whose checksum is still valid
+	contract, key := source.firstStorageKey(t)
+	stateKey := append(append([]byte{byte(scom.ST_STORAGE)}, contract[:]...), key...)
+	value, err := source.stateStore.store.Get(stateKey)
+	assert.Nil(t, err)
+	assert.Nil(t, source.stateStore.store.Put(stateKey, append(append([]byte{}, value...), 1)))
+	forged := source.saveTestSnapshot(t, "forged.snapshot")
+	assert.Nil(t, source.stateStore.store.Put(stateKey, value))
+	source.addBlocks(t, 2)
+
+	target := newTestLedgerWithAccount(t, leveldbstore.BACKEND_LEVELDB, source.account)
+	defer target.close()
+	assert.Equal(t, source.GetBlockHash(0), target.GetBlockHash(0))
+
+	// the state root of the snapshot is committed by the header STATE_ROOT_DELAY blocks above
+	assert.Nil(t, target.AddHeaders(source.headers(t, 1, 3+types.STATE_ROOT_DELAY-1)))
+	err = target.ApplySnapshot(good, 3)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "not synced")
+	assert.Nil(t, target.AddHeaders(source.headers(t, 3+types.STATE_ROOT_DELAY, 5)))
+	root, err := target.GetCommittedStateRoot(3)
+	assert.Nil(t, err)
+	expected, err := source.stateStore.GetStateTreeRoot(3)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, root)
+
+	err = target.ApplySnapshot(forged, 3)
+	assert.NotNil(t, err)
+	assert.Contains(t, err.Error(), "mismatches the committed")
+	assert.Equal(t, uint32(0), target.GetCurrentBlockHeight())
+	err = target.ApplySnapshot(good, 2)
+	assert.NotNil(t, err)
+	assert.Equal(t, uint32(0), target.GetCurrentBlockHeight())
+
+	assert.Nil(t, target.ApplySnapshot(good, 3))
+	assert.Equal(t, uint32(3), target.GetCurrentBlockHeight())
+	assert.Equal(t, source.GetBlockHash(3), target.GetCurrentBlockHash())
+	assert.Equal(t, uint32(5), target.GetCurrentHeaderHeight())
+	storageKey := &states.StorageKey{ContractAddress: contract, Key: key}
+	synced, err := target.GetStorageItem(storageKey)
+	assert.Nil(t, err)
+	item, err := source.GetStorageItem(storageKey)
+	assert.Nil(t, err)
+	assert.Equal(t, item, synced)
+
+	// the blocks after the snapshot are executed as usual
+	for h := uint32(4); h <= 5; h++ {
+		block, err := source.GetBlockByHash(source.GetBlockHash(h))
+		assert.Nil(t, err)
+		result, err := target.ExecuteBlock(block)
+		assert.Nil(t, err)
+		assert.Nil(t, target.SubmitBlock(block, result))
+	}
+	assert.Equal(t, uint32(5), target.GetCurrentBlockHeight())
+	for h := uint32(3); h <= 5; h++ {
+		expected, err := source.stateStore.GetStateTreeRoot(h)
+		assert.Nil(t, err)
+		root, err := target.stateStore.GetStateTreeRoot(h)
+		assert.Nil(t, err)
+		assert.Equal(t, expected, root, "height %d", h)
+	}
+
+	// only an empty ledger applies a snapshot
+	err = target.ApplySnapshot(good, 3)
+	assert.NotNil(t, err)
+}
@@ -0,0 +1,428 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/states"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/overlaydb"
+)
+
+// In archive mode each state written by a block is saved again with the block
+// height, as ST_ARCHIVE + escaped state key + inverted height => state value, an
+// empty value means the state is deleted at the height. The escaping keeps the
+// versions of a key together and in key order, and the inverted height sorts the
+// versions from the newest, so the state at a height is the first version after
+// seeking to the height.
+
+//ARCHIVE_INIT_BATCH_SIZE is the max writes of a batch when the archive is initialized, so that the
+//states are copied without holding all of them in one batch
+const ARCHIVE_INIT_BATCH_SIZE = 10000
+
+var errArchiveReadOnly = errors.New("archive state is read only")
+
+//initArchive load the first archived block height, and saves all of the states
+//as the versions of current block if the archive is just enabled. The states are
+//committed in bounded batches and the archive height is saved by the last one, an
+//interrupted initialization is started over on next open
+func (self *StateStore) initArchive(currBlockHeight uint32, hasBlock bool) error {
+	value, err := self.store.Get(genArchiveHeightKey())
+	if err != nil && err != scom.ErrNotFound {
+		return err
+	}
+	if !self.archive {
+		if err == nil {
+			// the archive is broken once a block is saved without it
+			log.Warnf("archive mode is disabled, the archived states are dropped")
+			return self.store.Delete(genArchiveHeightKey())
+		}
+		return nil
+	}
+	if err == nil {
+		if len(value) != 4 {
+			return fmt.Errorf("invalid archive height")
+		}
+		self.archiveHeight = binary.LittleEndian.Uint32(value)
+		return nil
+	}
+
+	batch := &archiveBatch{stateStore: self}
+	self.NewBatch()
+	iter := self.store.NewIterator([]byte{byte(scom.ST_ARCHIVE)})
+	for iter.Next() {
+		self.store.BatchDelete(iter.Key())
+		if err := batch.add(); err != nil {
+			iter.Release()
+			return err
+		}
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	if hasBlock {
+		log.Infof("archive states at height:%d", currBlockHeight)
+		for _, prefix := range []scom.DataEntryPrefix{scom.ST_CONTRACT, scom.ST_STORAGE} {
+			iter := self.store.NewIterator([]byte{byte(prefix)})
+			for iter.Next() {
+				self.store.BatchPut(genArchiveKey(iter.Key(), currBlockHeight), iter.Value())
+				if err := batch.add(); err != nil {
+					iter.Release()
+					return err
+				}
+			}
+			iter.Release()
+			if err := iter.Error(); err != nil {
+				return err
+			}
+		}
+		self.archiveHeight = currBlockHeight
+	}
+	self.saveArchiveHeight()
+	return self.CommitTo()
+}
+
+//archiveBatch commits the batch of state store once it holds ARCHIVE_INIT_BATCH_SIZE writes
+type archiveBatch struct {
+	stateStore *StateStore
+	size       int
+}
+
+func (self *archiveBatch) add() error {
+	self.size++
+	if self.size < ARCHIVE_INIT_BATCH_SIZE {
+		return nil
+	}
+	self.size = 0
+	if err := self.stateStore.CommitTo(); err != nil {
+		return err
+	}
+	self.stateStore.NewBatch()
+	return nil
+}
+
+func (self *StateStore) saveArchiveHeight() {
+	value := make([]byte, 4)
+	binary.LittleEndian.PutUint32(value, self.archiveHeight)
+	self.store.BatchPut(genArchiveHeightKey(), value)
+}
+
+//AddArchiveStates save the write set of the block as the versions of the block height to the batch
+func (self *StateStore) AddArchiveStates(blockHeight uint32, writeSet *overlaydb.MemDB) {
+	if !self.archive {
+		return
+	}
+	writeSet.ForEach(func(key, val []byte) {
+		self.store.BatchPut(genArchiveKey(key, blockHeight), val)
+	})
+}
+
+//archiveState save a state changed outside of block execution to the batch
+func (self *StateStore) archiveState(blockHeight uint32, key, val []byte) {
+	if self.archive {
+		self.store.BatchPut(genArchiveKey(key, blockHeight), val)
+	}
+}
+
+//PruneArchive delete the versions which are overwritten at the block height, so that the states
+//are archived from the height
+func (self *StateStore) PruneArchive(height uint32) error {
+	if !self.archive || height <= self.archiveHeight {
+		return nil
+	}
+	self.NewBatch()
+	var lastKey []byte
+	var found bool //whether the version at the height of the last key is found
+	iter := self.store.NewIterator([]byte{byte(scom.ST_ARCHIVE)})
+	for iter.Next() {
+		key, err := decodeArchiveKey(iter.Key())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		if !bytes.Equal(key, lastKey) {
+			lastKey, found = key, false
+		}
+		if archiveKeyHeight(iter.Key()) > height {
+			continue
+		}
+		if !found {
+			found = true
+			if len(iter.Value()) != 0 {
+				continue
+			}
+		}
+		self.store.BatchDelete(iter.Key())
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+	self.archiveHeight = height
+	self.saveArchiveHeight()
+	return self.CommitTo()
+}
+
+//checkArchiveHeight return error if the state of the block height is not archived
+func (self *StateStore) checkArchiveHeight(height uint32) error {
+	if !self.archive {
+		return fmt.Errorf("archive mode is disabled")
+	}
+	if height < self.archiveHeight {
+		return fmt.Errorf("state of height %d is not archived, the archive starts at %d", height, self.archiveHeight)
+	}
+	return nil
+}
+
+//GetStateAt return the value of the state key at the block height
+func (self *StateStore) GetStateAt(key []byte, height uint32) ([]byte, error) {
+	if err := self.checkArchiveHeight(height); err != nil {
+		return nil, err
+	}
+	prefix := encodeArchiveKey(key)
+	iter := self.store.NewIterator(prefix)
+	defer iter.Release()
+	var has bool
+	if seeker, ok := iter.(interface {
+		Seek(key []byte) bool
+	}); ok {
+		has = seeker.Seek(genArchiveKey(key, height))
+	} else {
+		for has = iter.First(); has; has = iter.Next() {
+			if archiveKeyHeight(iter.Key()) <= height {
+				break
+			}
+		}
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	if !has || len(iter.Value()) == 0 {
+		return nil, scom.ErrNotFound
+	}
+	return append([]byte{}, iter.Value()...), nil
+}
+
+//GetStorageStateAt return the storage value of the key in smart contract at the block height
+func (self *StateStore) GetStorageStateAt(key *states.StorageKey, height uint32) (*states.StorageItem, error) {
+	storeKey, err := self.getStorageKey(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := self.GetStateAt(storeKey, height)
+	if err != nil {
+		return nil, err
+	}
+	storageState := new(states.StorageItem)
+	err = storageState.Deserialize(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return storageState, nil
+}
+
+//NewOverlayDBAt return an overlay db reading the states at the block height
+func (self *StateStore) NewOverlayDBAt(height uint32) (*overlaydb.OverlayDB, error) {
+	if err := self.checkArchiveHeight(height); err != nil {
+		return nil, err
+	}
+	return overlaydb.NewOverlayDB(&archiveStore{stateStore: self, height: height}), nil
+}
+
+//archiveStore is a read only PersistStore of the states at a block height
+type archiveStore struct {
+	stateStore *StateStore
+	height     uint32
+}
+
+func (self *archiveStore) Put(key []byte, value []byte) error {
+	return errArchiveReadOnly
+}
+
+func (self *archiveStore) Get(key []byte) ([]byte, error) {
+	return self.stateStore.GetStateAt(key, self.height)
+}
+
+func (self *archiveStore) Has(key []byte) (bool, error) {
+	_, err := self.Get(key)
+	if err == scom.ErrNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+func (self *archiveStore) Delete(key []byte) error {
+	return errArchiveReadOnly
+}
+
+func (self *archiveStore) NewBatch() {}
+
+func (self *archiveStore) BatchPut(key []byte, value []byte) {}
+
+func (self *archiveStore) BatchDelete(key []byte) {}
+
+func (self *archiveStore) BatchCommit() error {
+	return errArchiveReadOnly
+}
+
+func (self *archiveStore) Close() error {
+	return nil
+}
+
+func (self *archiveStore) NewIterator(prefix []byte) scom.StoreIterator {
+	return &archiveIterator{
+		iter:   self.stateStore.store.NewIterator(encodeArchivePrefix(prefix)),
+		height: self.height,
+	}
+}
+
+//archiveIterator iterates the states at a block height, by picking the newest
+//version not above the height of each key and skipping the deleted ones
+type archiveIterator struct {
+	iter   scom.StoreIterator
+	height uint32
+	key    []byte
+	value  []byte
+	err    error
+}
+
+func (self *archiveIterator) First() bool {
+	self.key = nil
+	if !self.iter.First() {
+		return false
+	}
+	return self.next(true)
+}
+
+func (self *archiveIterator) Next() bool {
+	return self.next(self.iter.Next())
+}
+
+func (self *archiveIterator) next(has bool) bool {
+	for ; has; has = self.iter.Next() {
+		key, err := decodeArchiveKey(self.iter.Key())
+		if err != nil {
+			self.err = err
+			return false
+		}
+		if self.key != nil && bytes.Equal(key, self.key) {
+			continue
+		}
+		if archiveKeyHeight(self.iter.Key()) > self.height {
+			continue
+		}
+		self.key = key
+		if len(self.iter.Value()) == 0 {
+			continue
+		}
+		self.value = self.iter.Value()
+		return true
+	}
+	self.key, self.value = nil, nil
+	return false
+}
+
+func (self *archiveIterator) Key() []byte {
+	return self.key
+}
+
+func (self *archiveIterator) Value() []byte {
+	return self.value
+}
+
+func (self *archiveIterator) Release() {
+	self.iter.Release()
+}
+
+func (self *archiveIterator) Error() error {
+	if self.err != nil {
+		return self.err
+	}
+	return self.iter.Error()
+}
+
+//encodeArchivePrefix escapes each 0x00 of the key as 0x00 0xff, so that the
+//escaped key keeps the order of keys and is the prefix of escaped longer keys
+func encodeArchivePrefix(key []byte) []byte {
+	buf := make([]byte, 0, 1+len(key)+8)
+	buf = append(buf, byte(scom.ST_ARCHIVE))
+	for _, b := range key {
+		buf = append(buf, b)
+		if b == 0x00 {
+			buf = append(buf, 0xff)
+		}
+	}
+	return buf
+}
+
+//encodeArchiveKey escapes the key and terminates it with 0x00 0x01
+func encodeArchiveKey(key []byte) []byte {
+	return append(encodeArchivePrefix(key), 0x00, 0x01)
+}
+
+func decodeArchiveKey(archiveKey []byte) ([]byte, error) {
+	if len(archiveKey) < 1+2+4 {
+		return nil, fmt.Errorf("invalid archive key")
+	}
+	encoded := archiveKey[1 : len(archiveKey)-4]
+	key := make([]byte, 0, len(encoded))
+	for i := 0; i < len(encoded); i++ {
+		if encoded[i] != 0x00 {
+			key = append(key, encoded[i])
+			continue
+		}
+		if i+1 >= len(encoded) {
+			return nil, fmt.Errorf("invalid archive key")
+		}
+		i++
+		switch encoded[i] {
+		case 0xff:
+			key = append(key, 0x00)
+		case 0x01:
+			if i+1 != len(encoded) {
+				return nil, fmt.Errorf("invalid archive key")
+			}
+			return key, nil
+		default:
+			return nil, fmt.Errorf("invalid archive key")
+		}
+	}
+	return nil, fmt.Errorf("invalid archive key")
+}
+
+func archiveKeyHeight(archiveKey []byte) uint32 {
+	return ^binary.BigEndian.Uint32(archiveKey[len(archiveKey)-4:])
+}
+
+func genArchiveKey(key []byte, height uint32) []byte {
+	buf := encodeArchiveKey(key)
+	var inverted [4]byte
+	binary.BigEndian.PutUint32(inverted[:], ^height)
+	return append(buf, inverted[:]...)
+}
+
+func genArchiveHeightKey() []byte {
+	return []byte{byte(scom.SYS_ARCHIVE_HEIGHT)}
+}
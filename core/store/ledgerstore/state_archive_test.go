@@ -0,0 +1,196 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"encoding/binary"
+	"testing"
+
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/memstore"
+	"github.com/stretchr/testify/assert"
+)
+
+func newArchiveStateStore() *StateStore {
+	store := memstore.NewMemStore()
+	return &StateStore{store: store, archive: true, inMemory: true}
+}
+
+func storageKey(key string) []byte {
+	return append([]byte{byte(scom.ST_STORAGE)}, key...)
+}
+
+func archiveStates(t *testing.T, store *StateStore, height uint32, kvs map[string]string) {
+	store.NewBatch()
+	for k, v := range kvs {
+		var val []byte
+		if v != "" {
+			val = []byte(v)
+		}
+		store.archiveState(height, storageKey(k), val)
+	}
+	assert.Nil(t, store.CommitTo())
+}
+
+func TestInitArchive(t *testing.T) {
+	store := newArchiveStateStore()
+	stale := genArchiveKey(storageKey("stale"), 3)
+	assert.Nil(t, store.store.Put(stale, []byte("stale")))
+	count := ARCHIVE_INIT_BATCH_SIZE + 5
+	for i := 0; i < count; i++ {
+		key := make([]byte, 4)
+		binary.BigEndian.PutUint32(key, uint32(i))
+		assert.Nil(t, store.store.Put(storageKey(string(key)), key))
+	}
+
+	assert.Nil(t, store.initArchive(10, true))
+	assert.Equal(t, uint32(10), store.archiveHeight)
+	_, err := store.store.Get(stale)
+	assert.Equal(t, scom.ErrNotFound, err)
+
+	archived := 0
+	iter := store.store.NewIterator([]byte{byte(scom.ST_ARCHIVE)})
+	for iter.Next() {
+		archived++
+		assert.Equal(t, uint32(10), archiveKeyHeight(iter.Key()))
+	}
+	iter.Release()
+	assert.Equal(t, count, archived)
+
+	key := make([]byte, 4)
+	binary.BigEndian.PutUint32(key, uint32(count-1))
+	value, err := store.GetStateAt(storageKey(string(key)), 10)
+	assert.Nil(t, err)
+	assert.Equal(t, key, value)
+	_, err = store.GetStateAt(storageKey(string(key)), 9)
+	assert.NotNil(t, err)
+
+	// the saved archive height is loaded on next open
+	reopened := &StateStore{store: store.store, archive: true}
+	assert.Nil(t, reopened.initArchive(20, true))
+	assert.Equal(t, uint32(10), reopened.archiveHeight)
+
+	// the archive is dropped once it is disabled
+	disabled := &StateStore{store: store.store}
+	assert.Nil(t, disabled.initArchive(20, true))
+	_, err = store.store.Get(genArchiveHeightKey())
+	assert.Equal(t, scom.ErrNotFound, err)
+}
+
+func TestGetStateAt(t *testing.T) {
+	store := newArchiveStateStore()
+	assert.Nil(t, store.initArchive(0, false))
+	archiveStates(t, store, 10, map[string]string{"a": "a10", "a\x00": "a0-10", "ab": "ab10"})
+	archiveStates(t, store, 12, map[string]string{"a": "a12"})
+	archiveStates(t, store, 15, map[string]string{"a": "", "ab": "ab15"})
+
+	cases := []struct {
+		key    string
+		height uint32
+		value  string
+	}{
+		{"a", 9, ""},
+		{"a", 10, "a10"},
+		{"a", 11, "a10"},
+		{"a", 12, "a12"},
+		{"a", 14, "a12"},
+		{"a", 15, ""},
+		{"a", 100, ""},
+		{"a\x00", 14, "a0-10"},
+		{"ab", 14, "ab10"},
+		{"ab", 15, "ab15"},
+		{"b", 15, ""},
+	}
+	for _, c := range cases {
+		value, err := store.GetStateAt(storageKey(c.key), c.height)
+		if c.value == "" {
+			assert.Equal(t, scom.ErrNotFound, err, "key %q height %d", c.key, c.height)
+			continue
+		}
+		assert.Nil(t, err, "key %q height %d", c.key, c.height)
+		assert.Equal(t, c.value, string(value), "key %q height %d", c.key, c.height)
+	}
+
+	archive := &archiveStore{stateStore: store, height: 12}
+	iter := archive.NewIterator(storageKey("a"))
+	var keys, values []string
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()[1:]))
+		values = append(values, string(iter.Value()))
+	}
+	iter.Release()
+	assert.Nil(t, iter.Error())
+	assert.Equal(t, []string{"a", "a\x00", "ab"}, keys)
+	assert.Equal(t, []string{"a12", "a0-10", "ab10"}, values)
+
+	archive.height = 15
+	iter = archive.NewIterator(storageKey("a"))
+	keys = nil
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()[1:]))
+	}
+	iter.Release()
+	assert.Equal(t, []string{"a\x00", "ab"}, keys)
+}
+
+func TestPruneArchive(t *testing.T) {
+	store := newArchiveStateStore()
+	assert.Nil(t, store.initArchive(0, false))
+	archiveStates(t, store, 10, map[string]string{"a": "a10", "b": "b10", "c": "c10"})
+	archiveStates(t, store, 12, map[string]string{"a": "a12", "b": ""})
+	archiveStates(t, store, 14, map[string]string{"a": "a14"})
+
+	assert.Nil(t, store.PruneArchive(13))
+	assert.Equal(t, uint32(13), store.archiveHeight)
+	_, err := store.GetStateAt(storageKey("a"), 12)
+	assert.NotNil(t, err)
+
+	value, err := store.GetStateAt(storageKey("a"), 13)
+	assert.Nil(t, err)
+	assert.Equal(t, "a12", string(value))
+	value, err = store.GetStateAt(storageKey("a"), 14)
+	assert.Nil(t, err)
+	assert.Equal(t, "a14", string(value))
+	value, err = store.GetStateAt(storageKey("c"), 13)
+	assert.Nil(t, err)
+	assert.Equal(t, "c10", string(value))
+	_, err = store.GetStateAt(storageKey("b"), 13)
+	assert.Equal(t, scom.ErrNotFound, err)
+
+	// only the versions still visible from the pruned height are kept
+	versions := 0
+	iter := store.store.NewIterator([]byte{byte(scom.ST_ARCHIVE)})
+	for iter.Next() {
+		versions++
+	}
+	iter.Release()
+	assert.Equal(t, 3, versions)
+}
+
+func TestArchiveKey(t *testing.T) {
+	for _, key := range [][]byte{{}, {0x00}, {0x00, 0xff}, {0x01, 0x00, 0x01}, []byte("storage")} {
+		archiveKey := genArchiveKey(key, 7)
+		decoded, err := decodeArchiveKey(archiveKey)
+		assert.Nil(t, err)
+		assert.Equal(t, key, decoded)
+		assert.Equal(t, uint32(7), archiveKeyHeight(archiveKey))
+	}
+	_, err := decodeArchiveKey([]byte{byte(scom.ST_ARCHIVE), 0x00, 0x02, 0, 0, 0, 0})
+	assert.NotNil(t, err)
+}
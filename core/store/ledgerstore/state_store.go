@@ -26,12 +26,13 @@ import (
 	"io"
 
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/common/serialization"
 	"github.com/dnaproject2/DNA/core/payload"
 	"github.com/dnaproject2/DNA/core/states"
 	scom "github.com/dnaproject2/DNA/core/store/common"
-	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	"github.com/dnaproject2/DNA/core/store/memstore"
 	"github.com/dnaproject2/DNA/core/store/overlaydb"
 	"github.com/dnaproject2/DNA/merkle"
 	"github.com/dnaproject2/DNA/smartcontract/service/native/ontid"
@@ -51,12 +52,19 @@ type StateStore struct {
 	deltaMerkleTree      *merkle.CompactMerkleTree //Merkle tree of delta state root
 	merkleHashStore      merkle.HashStore
 	stateHashCheckHeight uint32
+	stateTree            *merkle.SparseMerkleTree //Authenticated tree of contract and storage states
+	stateTreeRoot        common.Uint256           //State tree root of current block
+	archive              bool                     //Whether keep the states of every block height
+	archiveHeight        uint32                   //First block height of archived states
+	undoBlocks           uint32                   //Count of the latest blocks keeping the undo states
+	inMemory             bool                     //Whether the db backend keeps nothing on disk
+	readOnly             bool                     //Whether the store is opened read only
 }
 
 //NewStateStore return state store instance
 func NewStateStore(dbDir, merklePath string, stateHashCheckHeight uint32) (*StateStore, error) {
 	var err error
-	store, err := leveldbstore.NewLevelDBStore(dbDir)
+	store, err := openStore(dbDir)
 	if err != nil {
 		return nil, err
 	}
@@ -65,26 +73,49 @@ func NewStateStore(dbDir, merklePath string, stateHashCheckHeight uint32) (*Stat
 		store:                store,
 		merklePath:           merklePath,
 		stateHashCheckHeight: stateHashCheckHeight,
+		stateTree:            merkle.NewSparseMerkleTree(&stateTreeStore{store: store}),
+		archive:              config.DefConfig.Common.EnableArchive,
+		undoBlocks:           config.DefConfig.Common.RollbackBlocks,
+		inMemory:             isInMemoryBackend(),
 	}
 	_, height, err := stateStore.GetCurrentBlock()
 	if err != nil && err != scom.ErrNotFound {
 		return nil, fmt.Errorf("GetCurrentBlock error %s", err)
 	}
+	hasBlock := err == nil
 	err = stateStore.init(height)
 	if err != nil {
 		return nil, fmt.Errorf("init error %s", err)
 	}
+	if hasBlock {
+		err = stateStore.initStateTree(height)
+		if err != nil {
+			return nil, fmt.Errorf("initStateTree error %s", err)
+		}
+		// the undo states out of the rollback window, which may be narrowed since last run
+		if height+1 > stateStore.undoBlocks {
+			err = stateStore.PruneUndoStates(height + 1 - stateStore.undoBlocks)
+			if err != nil {
+				return nil, fmt.Errorf("PruneUndoStates error %s", err)
+			}
+		}
+	}
+	err = stateStore.initArchive(height, hasBlock)
+	if err != nil {
+		return nil, fmt.Errorf("initArchive error %s", err)
+	}
 	return stateStore, nil
 }
 
 // for test
 func NewMemStateStore(stateHashHeight uint32) *StateStore {
-	store, _ := leveldbstore.NewMemLevelDBStore()
+	store := memstore.NewMemStore()
 	stateStore := &StateStore{
 		store:                store,
 		merkleTree:           merkle.NewTree(0, nil, nil),
 		deltaMerkleTree:      merkle.NewTree(0, nil, nil),
 		stateHashCheckHeight: stateHashHeight,
+		stateTree:            merkle.NewSparseMerkleTree(&stateTreeStore{store: store}),
 	}
 
 	return stateStore
@@ -111,9 +142,18 @@ func (self *StateStore) init(currBlockHeight uint32) error {
 	if treeSize > 0 && treeSize != currBlockHeight+1 {
 		return fmt.Errorf("merkle tree size is inconsistent with blockheight: %d", currBlockHeight+1)
 	}
-	self.merkleHashStore, err = merkle.NewFileHashStore(self.merklePath, treeSize)
-	if err != nil {
-		log.Warn("merkle store is inconsistent with ChainStore. persistence will be disabled")
+	if self.inMemory {
+		self.merkleHashStore = merkle.NewMemHashStore()
+	} else if self.readOnly {
+		self.merkleHashStore, err = merkle.NewReadOnlyFileHashStore(self.merklePath, treeSize)
+		if err != nil {
+			return fmt.Errorf("open merkle store error %s", err)
+		}
+	} else {
+		self.merkleHashStore, err = merkle.NewFileHashStore(self.merklePath, treeSize)
+		if err != nil {
+			log.Warn("merkle store is inconsistent with ChainStore. persistence will be disabled")
+		}
 	}
 	self.merkleTree = merkle.NewTree(treeSize, hashes, self.merkleHashStore)
 
@@ -192,17 +232,10 @@ func (self *StateStore) AddStateMerkleTreeRoot(blockHeight uint32, writeSetHash
 	key := self.genStateMerkleTreeKey()
 
 	self.deltaMerkleTree.AppendHash(writeSetHash)
-	treeSize := self.deltaMerkleTree.TreeSize()
-	hashes := self.deltaMerkleTree.Hashes()
-	value := common.NewZeroCopySink(make([]byte, 0, 4+len(hashes)*common.UINT256_SIZE))
-	value.WriteUint32(treeSize)
-	for _, hash := range hashes {
-		value.WriteHash(hash)
-	}
-	self.store.BatchPut(key, value.Bytes())
+	self.store.BatchPut(key, serializeMerkleTree(self.deltaMerkleTree))
 
 	key = self.genStateMerkleRootKey(blockHeight)
-	value.Reset()
+	value := common.NewZeroCopySink(make([]byte, 0, 2*common.UINT256_SIZE))
 	value.WriteHash(writeSetHash)
 	value.WriteHash(self.deltaMerkleTree.Root())
 	self.store.BatchPut(key, value.Bytes())
@@ -215,15 +248,18 @@ func (self *StateStore) AddBlockMerkleTreeRoot(txRoot common.Uint256) error {
 	key := self.genBlockMerkleTreeKey()
 
 	self.merkleTree.AppendHash(txRoot)
-	treeSize := self.merkleTree.TreeSize()
-	hashes := self.merkleTree.Hashes()
+	self.store.BatchPut(key, serializeMerkleTree(self.merkleTree))
+	return nil
+}
+
+func serializeMerkleTree(tree *merkle.CompactMerkleTree) []byte {
+	hashes := tree.Hashes()
 	value := common.NewZeroCopySink(make([]byte, 0, 4+len(hashes)*common.UINT256_SIZE))
-	value.WriteUint32(treeSize)
+	value.WriteUint32(tree.TreeSize())
 	for _, hash := range hashes {
 		value.WriteHash(hash)
 	}
-	self.store.BatchPut(key, value.Bytes())
-	return nil
+	return value.Bytes()
 }
 
 //GetMerkleProof return merkle proof of block
@@ -231,6 +267,14 @@ func (self *StateStore) GetMerkleProof(proofHeight, rootHeight uint32) ([]common
 	return self.merkleTree.InclusionProof(proofHeight, rootHeight+1)
 }
 
+//GetConsistencyProof return the proof that block merkle tree of old height is the prefix of the tree of new height
+func (self *StateStore) GetConsistencyProof(oldHeight, newHeight uint32) ([]common.Uint256, error) {
+	if oldHeight > newHeight || newHeight >= self.merkleTree.TreeSize() {
+		return nil, fmt.Errorf("wrong parameters")
+	}
+	return self.merkleTree.ConsistencyProof(oldHeight+1, newHeight+1), nil
+}
+
 func (self *StateStore) NewOverlayDB() *overlaydb.OverlayDB {
 	return overlaydb.NewOverlayDB(self.store)
 }
@@ -407,6 +451,12 @@ func (self *StateStore) ClearAll() error {
 		self.store.NewBatch() // reset the batch
 		return err
 	}
+	self.stateTree.Reset()
+	self.stateTreeRoot = merkle.EMPTY_HASH
+	if self.archive {
+		self.archiveHeight = 0
+		self.saveArchiveHeight()
+	}
 	return self.store.BatchCommit()
 }
 
@@ -437,16 +487,31 @@ func (self *StateStore) CheckStorage() error {
 
 	prefix1 := []byte{byte(scom.ST_STORAGE), 0x2a, 0x64, 0x69, 0x64} //prefix of old storage key
 
+	_, height, err := self.GetCurrentBlock()
+	if err != nil {
+		return err
+	}
+	// the moved states are updated in the state tree of current block too
+	root := self.stateTreeRoot
 	iter := db.NewIterator(prefix1)
 	db.NewBatch()
-	for ok := iter.First(); ok; ok = iter.Next() {
+	for ok := iter.First(); ok && err == nil; ok = iter.Next() {
 		key := append(prefix, iter.Key()[1:]...)
 		db.BatchPut(key, iter.Value())
 		db.BatchDelete(iter.Key())
+		self.archiveState(height, key, iter.Value())
+		self.archiveState(height, iter.Key(), nil)
+		root, err = self.updateStateTree(root, key, iter.Value())
+		if err == nil {
+			root, err = self.updateStateTree(root, iter.Key(), nil)
+		}
 	}
 	iter.Release()
-	err = iter.Error()
+	if err == nil {
+		err = iter.Error()
+	}
 	if err != nil {
+		self.stateTree.Reset()
 		return err
 	}
 
@@ -455,6 +520,13 @@ func (self *StateStore) CheckStorage() error {
 	buf := bytes.NewBuffer(nil)
 	tag.Serialize(buf)
 	db.BatchPut(flag, buf.Bytes())
+	self.archiveState(height, flag, buf.Bytes())
+	root, err = self.updateStateTree(root, flag, buf.Bytes())
+	if err != nil {
+		self.stateTree.Reset()
+		return err
+	}
+	self.commitStateTree(height, root)
 	err = db.BatchCommit()
 
 	return err
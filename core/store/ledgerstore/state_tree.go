@@ -0,0 +1,234 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package ledgerstore
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	scom "github.com/dnaproject2/DNA/core/store/common"
+	"github.com/dnaproject2/DNA/core/store/overlaydb"
+	"github.com/dnaproject2/DNA/merkle"
+)
+
+//stateTreeStore saves the nodes of the state tree in the batch of state store
+type stateTreeStore struct {
+	store scom.PersistStore
+}
+
+func (self *stateTreeStore) GetNode(hash common.Uint256) ([]byte, error) {
+	return self.store.Get(genStateTreeNodeKey(hash))
+}
+
+func (self *stateTreeStore) PutNode(hash common.Uint256, node []byte) {
+	self.store.BatchPut(genStateTreeNodeKey(hash), node)
+}
+
+//isStateTreeKey return whether the key of state store is authenticated by the state tree
+func isStateTreeKey(key []byte) bool {
+	return len(key) > 0 && (key[0] == byte(scom.ST_CONTRACT) || key[0] == byte(scom.ST_STORAGE))
+}
+
+//initStateTree load the state tree root of the current block, and builds the
+//state tree from all of the states if the store has no state tree yet
+func (self *StateStore) initStateTree(currBlockHeight uint32) error {
+	root, err := self.GetStateTreeRoot(currBlockHeight)
+	if err == nil {
+		self.stateTreeRoot = root
+		return nil
+	}
+	if err != scom.ErrNotFound {
+		return err
+	}
+
+	log.Infof("build state tree at height:%d", currBlockHeight)
+	self.NewBatch()
+	root, err = merkle.EMPTY_HASH, nil
+	for _, prefix := range []scom.DataEntryPrefix{scom.ST_CONTRACT, scom.ST_STORAGE} {
+		iter := self.store.NewIterator([]byte{byte(prefix)})
+		for has := iter.First(); has && err == nil; has = iter.Next() {
+			root, err = self.updateStateTree(root, iter.Key(), iter.Value())
+		}
+		iter.Release()
+		if err != nil {
+			self.stateTree.Reset()
+			return err
+		}
+		if err = iter.Error(); err != nil {
+			self.stateTree.Reset()
+			return err
+		}
+	}
+	self.commitStateTree(currBlockHeight, root)
+	return self.CommitTo()
+}
+
+//AddStateTreeRoot apply the write set of the block to the state tree, and save
+//the new nodes and the state tree root of the block to the batch
+func (self *StateStore) AddStateTreeRoot(blockHeight uint32, writeSet *overlaydb.MemDB) error {
+	root := self.stateTreeRoot
+	var err error
+	writeSet.ForEach(func(key, val []byte) {
+		if err == nil && isStateTreeKey(key) {
+			root, err = self.updateStateTree(root, key, val)
+		}
+	})
+	if err != nil {
+		self.stateTree.Reset()
+		return err
+	}
+	self.commitStateTree(blockHeight, root)
+	return nil
+}
+
+//updateStateTree set the value of the key in the state tree, empty value deletes the key
+func (self *StateStore) updateStateTree(root common.Uint256, key, val []byte) (common.Uint256, error) {
+	valueHash := merkle.EMPTY_HASH
+	if len(val) != 0 {
+		valueHash = sha256.Sum256(val)
+		self.store.BatchPut(genStateTreeValueKey(valueHash), val)
+	}
+	return self.stateTree.Update(root, sha256.Sum256(key), valueHash)
+}
+
+func (self *StateStore) commitStateTree(blockHeight uint32, root common.Uint256) {
+	self.stateTree.Commit(root)
+	self.store.BatchPut(genStateTreeRootKey(blockHeight), root[:])
+	self.stateTreeRoot = root
+}
+
+//GetStateTreeRoot return the state tree root of the block height
+func (self *StateStore) GetStateTreeRoot(height uint32) (common.Uint256, error) {
+	value, err := self.store.Get(genStateTreeRootKey(height))
+	if err != nil {
+		return common.Uint256{}, err
+	}
+	return common.Uint256ParseFromBytes(value)
+}
+
+//GetStateProof return the value of the state key at the block height and its proof against the state tree root
+func (self *StateStore) GetStateProof(key []byte, height uint32) (*scom.StorageProof, error) {
+	root, err := self.GetStateTreeRoot(height)
+	if err != nil {
+		if err == scom.ErrNotFound {
+			return nil, fmt.Errorf("state tree root of height %d not found", height)
+		}
+		return nil, err
+	}
+	keyHash := common.Uint256(sha256.Sum256(key))
+	// a standalone tree never touches the pending nodes of block saving
+	proof, err := merkle.NewSparseMerkleTree(&stateTreeStore{store: self.store}).Prove(root, keyHash)
+	if err != nil {
+		return nil, err
+	}
+	result := &scom.StorageProof{
+		Height: height,
+		Root:   root,
+		Key:    key,
+		Proof:  proof,
+	}
+	if proof.LeafKey == keyHash {
+		result.Value, err = self.store.Get(genStateTreeValueKey(proof.LeafValue))
+		if err != nil {
+			return nil, fmt.Errorf("get state value %s error %s", proof.LeafValue.ToHexString(), err)
+		}
+	}
+	return result, nil
+}
+
+//PruneStateTree delete the state tree roots below the block height, with the nodes and
+//the values which are not reachable from the remaining roots
+func (self *StateStore) PruneStateTree(height uint32) error {
+	self.NewBatch()
+	roots := make([]common.Uint256, 0)
+	iter := self.store.NewIterator([]byte{byte(scom.DATA_STATE_TREE_ROOT)})
+	for iter.Next() {
+		key := iter.Key()
+		if len(key) != 5 {
+			continue
+		}
+		if binary.LittleEndian.Uint32(key[1:]) < height {
+			self.store.BatchDelete(key)
+			continue
+		}
+		root, err := common.Uint256ParseFromBytes(iter.Value())
+		if err != nil {
+			iter.Release()
+			return err
+		}
+		roots = append(roots, root)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		return err
+	}
+
+	// the remaining roots share most of their nodes, which are walked only once
+	nodes := make(map[common.Uint256]bool)
+	values := make(map[common.Uint256]bool)
+	tree := merkle.NewSparseMerkleTree(&stateTreeStore{store: self.store})
+	for _, root := range roots {
+		err := tree.Walk(root, func(hash, valueHash common.Uint256) bool {
+			if nodes[hash] {
+				return false
+			}
+			nodes[hash] = true
+			if valueHash != merkle.EMPTY_HASH {
+				values[valueHash] = true
+			}
+			return true
+		})
+		if err != nil {
+			return err
+		}
+	}
+	for prefix, live := range map[scom.DataEntryPrefix]map[common.Uint256]bool{
+		scom.ST_STATE_TREE_NODE: nodes, scom.ST_STATE_TREE_VALUE: values} {
+		iter := self.store.NewIterator([]byte{byte(prefix)})
+		for iter.Next() {
+			hash, err := common.Uint256ParseFromBytes(iter.Key()[1:])
+			if err != nil || !live[hash] {
+				self.store.BatchDelete(iter.Key())
+			}
+		}
+		iter.Release()
+		if err := iter.Error(); err != nil {
+			return err
+		}
+	}
+	return self.CommitTo()
+}
+
+func genStateTreeNodeKey(hash common.Uint256) []byte {
+	return append([]byte{byte(scom.ST_STATE_TREE_NODE)}, hash[:]...)
+}
+
+func genStateTreeValueKey(hash common.Uint256) []byte {
+	return append([]byte{byte(scom.ST_STATE_TREE_VALUE)}, hash[:]...)
+}
+
+func genStateTreeRootKey(height uint32) []byte {
+	key := make([]byte, 5, 5)
+	key[0] = byte(scom.DATA_STATE_TREE_ROOT)
+	binary.LittleEndian.PutUint32(key[1:], height)
+	return key
+}
@@ -242,13 +242,16 @@ func (self *StateStore) HandleInvokeTransaction(store store.LedgerStore, overlay
 	return nil
 }
 
-func SaveNotify(eventStore scommon.EventStore, txHash common.Uint256, notify *event.ExecuteNotify) error {
+func SaveNotify(eventStore scommon.EventStore, height uint32, txHash common.Uint256, notify *event.ExecuteNotify) error {
 	if !config.DefConfig.Common.EnableEventLog {
 		return nil
 	}
 	if err := eventStore.SaveEventNotifyByTx(txHash, notify); err != nil {
 		return fmt.Errorf("SaveEventNotifyByTx error %s", err)
 	}
+	if err := eventStore.SaveEventNotifyIndex(height, notify); err != nil {
+		return fmt.Errorf("SaveEventNotifyIndex error %s", err)
+	}
 	event.PushSmartCodeEvent(txHash, 0, event.EVENT_NOTIFY, notify)
 	return nil
 }
@@ -35,6 +35,21 @@ type LevelDBStore struct {
 	batch *leveldb.Batch
 }
 
+//BACKEND_LEVELDB is the name of leveldb backend
+const BACKEND_LEVELDB = "leveldb"
+
+func init() {
+	common.RegisterBackend(BACKEND_LEVELDB, &common.Backend{Open: openLevelDBStore})
+}
+
+func openLevelDBStore(file string) (common.PersistStore, error) {
+	store, err := NewLevelDBStore(file)
+	if err != nil {
+		return nil, err
+	}
+	return store, nil
+}
+
 // used to compute the size of bloom filter bits array .
 // too small will lead to high false positive rate.
 const BITSPERKEY = 10
@@ -74,6 +89,24 @@ func NewLevelDBStore(file string) (*LevelDBStore, error) {
 	}, nil
 }
 
+//NewReadOnlyLevelDBStore return LevelDBStore instance which can only be read
+func NewReadOnlyLevelDBStore(file string) (*LevelDBStore, error) {
+	o := opt.Options{
+		ReadOnly:       true,
+		ErrorIfMissing: true,
+		Filter:         filter.NewBloomFilter(BITSPERKEY),
+	}
+	db, err := leveldb.OpenFile(file, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	return &LevelDBStore{
+		db:    db,
+		batch: nil,
+	}, nil
+}
+
 func NewMemLevelDBStore() (*LevelDBStore, error) {
 	store := storage.NewMemStorage()
 	// default Options
@@ -150,6 +183,11 @@ func (self *LevelDBStore) Close() error {
 	return err
 }
 
+//Compact the underlying storage of the keys with the prefix, nil prefix compacts the whole leveldb
+func (self *LevelDBStore) Compact(prefix []byte) error {
+	return self.db.CompactRange(*util.BytesPrefix(prefix))
+}
+
 //NewIterator return a iterator of leveldb with the key prefix
 func (self *LevelDBStore) NewIterator(prefix []byte) common.StoreIterator {
 
@@ -157,3 +195,39 @@ func (self *LevelDBStore) NewIterator(prefix []byte) common.StoreIterator {
 
 	return iter
 }
+
+//GetSnapshot return the snapshot of current leveldb, which is not changed by the later writes
+func (self *LevelDBStore) GetSnapshot() (common.StoreSnapshot, error) {
+	snapshot, err := self.db.GetSnapshot()
+	if err != nil {
+		return nil, err
+	}
+	return &levelDBSnapshot{snapshot: snapshot}, nil
+}
+
+//levelDBSnapshot is a read only snapshot of leveldb
+type levelDBSnapshot struct {
+	snapshot *leveldb.Snapshot
+}
+
+//Get the value of a key from snapshot
+func (self *levelDBSnapshot) Get(key []byte) ([]byte, error) {
+	dat, err := self.snapshot.Get(key, nil)
+	if err != nil {
+		if err == leveldb.ErrNotFound {
+			return nil, common.ErrNotFound
+		}
+		return nil, err
+	}
+	return dat, nil
+}
+
+//NewIterator return a iterator of snapshot with the key prefix
+func (self *levelDBSnapshot) NewIterator(prefix []byte) common.StoreIterator {
+	return self.snapshot.NewIterator(util.BytesPrefix(prefix), nil)
+}
+
+//Release snapshot
+func (self *levelDBSnapshot) Release() {
+	self.snapshot.Release()
+}
@@ -0,0 +1,206 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package memstore
+
+import (
+	"sync"
+
+	"github.com/dnaproject2/DNA/core/store/common"
+	"github.com/syndtr/goleveldb/leveldb/comparer"
+	"github.com/syndtr/goleveldb/leveldb/memdb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+)
+
+//BACKEND_MEMORY is the name of in-memory backend
+const BACKEND_MEMORY = "memory"
+
+const (
+	INIT_CAPACITY    = 4 * 1024 * 1024  //Initial capacity of the key-value buffer
+	COMPACT_MIN_SIZE = 64 * 1024 * 1024 //Key-value buffer size to start compacting the stale data
+)
+
+func init() {
+	common.RegisterBackend(BACKEND_MEMORY, &common.Backend{Open: openMemStore, InMemory: true})
+}
+
+func openMemStore(path string) (common.PersistStore, error) {
+	return NewMemStore(), nil
+}
+
+type batchOp struct {
+	key    []byte
+	value  []byte
+	delete bool
+}
+
+//MemStore is a PersistStore which keeps the sorted key-value pairs in memory. The overwritten and
+//deleted data stay in the buffer until the stale data exceeds the live data and the buffer is compacted
+type MemStore struct {
+	lock     sync.RWMutex
+	db       *memdb.DB
+	liveSize int //Size of the live keys and values in buffer
+	batch    []batchOp
+}
+
+//NewMemStore return an empty MemStore
+func NewMemStore() *MemStore {
+	return &MemStore{
+		db: memdb.New(comparer.DefaultComparer, INIT_CAPACITY),
+	}
+}
+
+//Put a key-value pair to store
+func (self *MemStore) Put(key []byte, value []byte) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.put(key, value)
+	self.compact()
+	return nil
+}
+
+//Get the value of a key from store
+func (self *MemStore) Get(key []byte) ([]byte, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	value, err := self.db.Get(key)
+	if err != nil {
+		if err == memdb.ErrNotFound {
+			return nil, common.ErrNotFound
+		}
+		return nil, err
+	}
+	return append([]byte{}, value...), nil
+}
+
+//Has return whether the key is exist in store
+func (self *MemStore) Has(key []byte) (bool, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.db.Contains(key), nil
+}
+
+//Delete the key in store
+func (self *MemStore) Delete(key []byte) error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.delete(key)
+	self.compact()
+	return nil
+}
+
+//NewBatch start commit batch
+func (self *MemStore) NewBatch() {
+	self.batch = make([]batchOp, 0)
+}
+
+//BatchPut put a key-value pair to batch
+func (self *MemStore) BatchPut(key []byte, value []byte) {
+	self.batch = append(self.batch, batchOp{key: append([]byte{}, key...), value: append([]byte{}, value...)})
+}
+
+//BatchDelete delete a key to batch
+func (self *MemStore) BatchDelete(key []byte) {
+	self.batch = append(self.batch, batchOp{key: append([]byte{}, key...), delete: true})
+}
+
+//BatchCommit commit batch to store, the batch is applied atomically to the readers
+func (self *MemStore) BatchCommit() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	for _, op := range self.batch {
+		if op.delete {
+			self.delete(op.key)
+		} else {
+			self.put(op.key, op.value)
+		}
+	}
+	self.batch = nil
+	self.compact()
+	return nil
+}
+
+//Close store, the data are dropped
+func (self *MemStore) Close() error {
+	self.lock.Lock()
+	defer self.lock.Unlock()
+	self.db.Reset()
+	self.liveSize = 0
+	return nil
+}
+
+//NewIterator return a iterator of store with the key prefix. Like leveldb the iterator is a snapshot,
+//the pairs with the prefix are copied when it is created so the later writes are not seen by it
+func (self *MemStore) NewIterator(prefix []byte) common.StoreIterator {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	return self.copyRange(util.BytesPrefix(prefix), 0).NewIterator(nil)
+}
+
+//GetSnapshot return a copy of current store, which is not changed by the later writes
+func (self *MemStore) GetSnapshot() (common.StoreSnapshot, error) {
+	self.lock.RLock()
+	defer self.lock.RUnlock()
+	db := self.copyRange(nil, self.liveSize)
+	return &memSnapshot{MemStore: &MemStore{db: db, liveSize: self.liveSize}}, nil
+}
+
+//memSnapshot is a read only copy of MemStore
+type memSnapshot struct {
+	*MemStore
+}
+
+//Release snapshot
+func (self *memSnapshot) Release() {
+	self.Close()
+}
+
+func (self *MemStore) put(key []byte, value []byte) {
+	if old, err := self.db.Get(key); err == nil {
+		self.liveSize -= len(key) + len(old)
+	}
+	self.db.Put(key, value)
+	self.liveSize += len(key) + len(value)
+}
+
+func (self *MemStore) delete(key []byte) {
+	if old, err := self.db.Get(key); err == nil {
+		self.liveSize -= len(key) + len(old)
+		self.db.Delete(key)
+	}
+}
+
+//copyRange copy the key-value pairs in the range to a new buffer of the capacity, nil range copies all
+func (self *MemStore) copyRange(slice *util.Range, capacity int) *memdb.DB {
+	db := memdb.New(comparer.DefaultComparer, capacity)
+	iter := self.db.NewIterator(slice)
+	for iter.Next() {
+		db.Put(iter.Key(), iter.Value())
+	}
+	iter.Release()
+	return db
+}
+
+//compact copy the live data to a new buffer when the stale data exceeds the live data
+func (self *MemStore) compact() {
+	size := self.db.Size()
+	if size < COMPACT_MIN_SIZE || size < 2*self.liveSize {
+		return
+	}
+	self.db = self.copyRange(nil, 2*self.liveSize)
+}
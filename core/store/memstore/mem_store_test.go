@@ -0,0 +1,165 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package memstore
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/dnaproject2/DNA/core/store/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func keys(iter common.StoreIterator) []string {
+	keys := make([]string, 0)
+	for iter.Next() {
+		keys = append(keys, string(iter.Key()))
+	}
+	iter.Release()
+	return keys
+}
+
+func TestMemStore(t *testing.T) {
+	store := NewMemStore()
+	assert.Nil(t, store.Put([]byte("a1"), []byte("v1")))
+	assert.Nil(t, store.Put([]byte("a2"), []byte("v2")))
+	assert.Nil(t, store.Put([]byte("b1"), []byte("v3")))
+
+	value, err := store.Get([]byte("a1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), value)
+	// the value returned is a copy
+	value[0] = 'x'
+	value, err = store.Get([]byte("a1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), value)
+	_, err = store.Get([]byte("c1"))
+	assert.Equal(t, common.ErrNotFound, err)
+
+	has, err := store.Has([]byte("a2"))
+	assert.Nil(t, err)
+	assert.True(t, has)
+	assert.Nil(t, store.Delete([]byte("a2")))
+	has, err = store.Has([]byte("a2"))
+	assert.Nil(t, err)
+	assert.False(t, has)
+	assert.Nil(t, store.Delete([]byte("a2")))
+	assert.Equal(t, len("a1v1b1v3"), store.liveSize)
+
+	assert.Equal(t, []string{"a1"}, keys(store.NewIterator([]byte("a"))))
+	assert.Equal(t, []string{"a1", "b1"}, keys(store.NewIterator(nil)))
+
+	assert.Nil(t, store.Close())
+	assert.Equal(t, []string{}, keys(store.NewIterator(nil)))
+	assert.Equal(t, 0, store.liveSize)
+}
+
+func TestMemStoreBatch(t *testing.T) {
+	store := NewMemStore()
+	assert.Nil(t, store.Put([]byte("k1"), []byte("v1")))
+
+	store.NewBatch()
+	key := []byte("k2")
+	store.BatchPut(key, []byte("v2"))
+	// the batch keeps a copy of the key
+	key[1] = '3'
+	store.BatchDelete([]byte("k1"))
+	store.BatchPut([]byte("k1"), []byte("v11"))
+	has, err := store.Has([]byte("k2"))
+	assert.Nil(t, err)
+	assert.False(t, has)
+
+	assert.Nil(t, store.BatchCommit())
+	value, err := store.Get([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v11"), value)
+	value, err = store.Get([]byte("k2"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v2"), value)
+	assert.Equal(t, len("k1v11k2v2"), store.liveSize)
+}
+
+func TestMemStoreIterator(t *testing.T) {
+	store := NewMemStore()
+	for i := 0; i < 5; i++ {
+		assert.Nil(t, store.Put([]byte(fmt.Sprintf("k%d", i)), []byte{byte(i)}))
+	}
+
+	// the writes after the iterator is created are not seen by it
+	iter := store.NewIterator([]byte("k"))
+	assert.Nil(t, store.Put([]byte("k5"), []byte{5}))
+	assert.Nil(t, store.Delete([]byte("k0")))
+	assert.Nil(t, store.Put([]byte("k1"), []byte{11}))
+	assert.True(t, iter.First())
+	assert.Equal(t, []byte("k0"), iter.Key())
+	assert.True(t, iter.Next())
+	assert.Equal(t, []byte{1}, iter.Value())
+	assert.Equal(t, []string{"k2", "k3", "k4"}, keys(iter))
+
+	seekable, ok := store.NewIterator(nil).(common.SeekableIterator)
+	assert.True(t, ok)
+	assert.True(t, seekable.Seek([]byte("k3")))
+	assert.Equal(t, []byte("k3"), seekable.Key())
+	seekable.Release()
+
+	reversible, ok := store.NewIterator(nil).(common.ReversibleIterator)
+	assert.True(t, ok)
+	assert.True(t, reversible.Last())
+	assert.Equal(t, []byte("k5"), reversible.Key())
+	assert.True(t, reversible.Prev())
+	assert.Equal(t, []byte("k4"), reversible.Key())
+	reversible.Release()
+}
+
+func TestMemStoreSnapshot(t *testing.T) {
+	store := NewMemStore()
+	assert.Nil(t, store.Put([]byte("k1"), []byte("v1")))
+	assert.Nil(t, store.Put([]byte("k2"), []byte("v2")))
+
+	snapshot, err := store.GetSnapshot()
+	assert.Nil(t, err)
+	defer snapshot.Release()
+	assert.Nil(t, store.Put([]byte("k1"), []byte("v11")))
+	assert.Nil(t, store.Delete([]byte("k2")))
+	assert.Nil(t, store.Put([]byte("k3"), []byte("v3")))
+
+	value, err := snapshot.Get([]byte("k1"))
+	assert.Nil(t, err)
+	assert.Equal(t, []byte("v1"), value)
+	_, err = snapshot.Get([]byte("k3"))
+	assert.Equal(t, common.ErrNotFound, err)
+	assert.Equal(t, []string{"k1", "k2"}, keys(snapshot.NewIterator(nil)))
+	assert.Equal(t, []string{"k1", "k3"}, keys(store.NewIterator(nil)))
+}
+
+func TestMemStoreBackend(t *testing.T) {
+	backend, err := common.GetBackend(BACKEND_MEMORY)
+	assert.Nil(t, err)
+	assert.True(t, backend.InMemory)
+
+	// each open returns a new empty store
+	store, err := common.OpenStore(BACKEND_MEMORY, "path")
+	assert.Nil(t, err)
+	assert.Nil(t, store.Put([]byte("k"), []byte("v")))
+	other, err := common.OpenStore(BACKEND_MEMORY, "path")
+	assert.Nil(t, err)
+	has, err := other.Has([]byte("k"))
+	assert.Nil(t, err)
+	assert.False(t, has)
+}
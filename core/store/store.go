@@ -19,9 +19,12 @@
 package store
 
 import (
+	"io"
+
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/core/payload"
 	"github.com/dnaproject2/DNA/core/states"
+	scom "github.com/dnaproject2/DNA/core/store/common"
 	"github.com/dnaproject2/DNA/core/store/overlaydb"
 	"github.com/dnaproject2/DNA/core/types"
 	"github.com/dnaproject2/DNA/smartcontract/event"
@@ -45,6 +48,7 @@ type LedgerStore interface {
 	ExecuteBlock(b *types.Block) (ExecuteResult, error)   // called by consensus
 	SubmitBlock(b *types.Block, exec ExecuteResult) error // called by consensus
 	GetStateMerkleRoot(height uint32) (result common.Uint256, err error)
+	GetHeaderStateRoot(height uint32) (common.Uint256, error)
 	GetCurrentBlockHash() common.Uint256
 	GetCurrentBlockHeight() uint32
 	GetCurrentHeaderHeight() uint32
@@ -56,14 +60,29 @@ type LedgerStore interface {
 	GetBlockByHash(blockHash common.Uint256) (*types.Block, error)
 	GetBlockByHeight(height uint32) (*types.Block, error)
 	GetTransaction(txHash common.Uint256) (*types.Transaction, uint32, error)
+	GetTransactionProof(txHash common.Uint256) (*types.Transaction, *scom.TransactionProof, error)
 	IsContainBlock(blockHash common.Uint256) (bool, error)
 	IsContainTransaction(txHash common.Uint256) (bool, error)
 	GetBlockRootWithNewTxRoots(startHeight uint32, txRoots []common.Uint256) common.Uint256
 	GetMerkleProof(m, n uint32) ([]common.Uint256, error)
+	GetConsistencyProof(oldHeight, newHeight uint32) ([]common.Uint256, error)
 	GetContractState(contractHash common.Address) (*payload.DeployCode, error)
 	GetBookkeeperState() (*states.BookkeeperState, error)
 	GetStorageItem(key *states.StorageKey) (*states.StorageItem, error)
+	GetStorageItemAtHeight(key *states.StorageKey, height uint32) (*states.StorageItem, error)
+	GetStorageProof(contract common.Address, key []byte, height uint32) (*scom.StorageProof, error)
 	PreExecuteContract(tx *types.Transaction) (*cstates.PreExecResult, error)
+	PreExecuteContractAtHeight(tx *types.Transaction, height uint32) (*cstates.PreExecResult, error)
 	GetEventNotifyByTx(tx common.Uint256) (*event.ExecuteNotify, error)
 	GetEventNotifyByBlock(height uint32) ([]*event.ExecuteNotify, error)
+	GetEventNotifyByContract(contract common.Address, topic string, startHeight, endHeight, offset, limit uint32) ([]*event.ContractNotify, error)
+	GetAddressTxs(addr common.Address, offset, limit uint32, desc bool) ([]*scom.AddressTx, error)
+	RebuildAddressTxIndex(progress func(height uint32)) error
+	GetPrunedHeight() uint32
+	SaveSnapshot(w io.Writer) (uint32, error)
+	GetSnapshotInfo(height uint32) (*scom.SnapshotInfo, error)
+	GetCommittedStateRoot(height uint32) (common.Uint256, error)
+	ApplySnapshot(file string, height uint32) error
+	Rollback(height uint32) error
+	VerifyBlocks(snapshot string, endHeight uint32, progress func(height uint32)) (*scom.LedgerDivergence, error)
 }
@@ -23,6 +23,7 @@ import (
 	"io"
 
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/ontio/ontology-crypto/keypair"
 )
 
@@ -40,6 +41,20 @@ func (hd *Header) GetRawHeader() *RawHeader {
 	}
 }
 
+const (
+	HEADER_VERSION_STATE_ROOT uint32 = 1 //Headers since the version commit the state root
+	STATE_ROOT_DELAY          uint32 = 2 //The header of height H commits the state root of height H-STATE_ROOT_DELAY
+)
+
+//HeaderVersion return the version of the header made by consensus at the height, the headers commit the
+//state root above the check height of the network
+func HeaderVersion(height uint32) uint32 {
+	if height > config.GetStateRootCheckHeight(config.DefConfig.P2PNode.NetworkId) {
+		return HEADER_VERSION_STATE_ROOT
+	}
+	return 0
+}
+
 type Header struct {
 	Version          uint32
 	PrevBlockHash    common.Uint256
@@ -50,6 +65,7 @@ type Header struct {
 	ConsensusData    uint64
 	ConsensusPayload []byte
 	NextBookkeeper   common.Address
+	StateRoot        common.Uint256 //State tree root of height Height-STATE_ROOT_DELAY, empty below the delay
 
 	//Program *program.Program
 	Bookkeepers []keypair.PublicKey
@@ -83,6 +99,9 @@ func (bd *Header) serializationUnsigned(sink *common.ZeroCopySink) {
 	sink.WriteUint64(bd.ConsensusData)
 	sink.WriteVarBytes(bd.ConsensusPayload)
 	sink.WriteBytes(bd.NextBookkeeper[:])
+	if bd.Version >= HEADER_VERSION_STATE_ROOT {
+		sink.WriteBytes(bd.StateRoot[:])
+	}
 }
 
 func HeaderFromRawBytes(raw []byte) (*Header, error) {
@@ -166,9 +185,24 @@ func (bd *Header) deserializationUnsigned(source *common.ZeroCopySource) error {
 	if eof {
 		return io.ErrUnexpectedEOF
 	}
+	if bd.Version >= HEADER_VERSION_STATE_ROOT {
+		bd.StateRoot, eof = source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+	}
 	return nil
 }
 
+//StateRootHeight return the block height whose state root is committed by the header, false if
+//the header commits no state root
+func (bd *Header) StateRootHeight() (uint32, bool) {
+	if bd.Version < HEADER_VERSION_STATE_ROOT || bd.Height < STATE_ROOT_DELAY {
+		return 0, false
+	}
+	return bd.Height - STATE_ROOT_DELAY, true
+}
+
 func (bd *Header) Hash() common.Uint256 {
 	if bd.hash != nil {
 		return *bd.hash
@@ -52,6 +52,9 @@ const (
 	ErrNetVerifyFail        ErrCode = 45019
 	ErrGasPrice             ErrCode = 45020
 	ErrVerifySignature      ErrCode = 45021
+	ErrPolicyDenied         ErrCode = 45022
+	ErrReplaceUnderpriced   ErrCode = 45023
+	ErrPayerLimit           ErrCode = 45024
 )
 
 func (err ErrCode) Error() string {
@@ -100,6 +103,12 @@ func (err ErrCode) Error() string {
 		return "invalid gas price"
 	case ErrVerifySignature:
 		return "transaction verify signature fail"
+	case ErrPolicyDenied:
+		return "transaction payer denied by policy"
+	case ErrReplaceUnderpriced:
+		return "replacement transaction underpriced"
+	case ErrPayerLimit:
+		return "too many pending transactions of the payer"
 
 	}
 
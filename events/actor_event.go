@@ -23,6 +23,7 @@ import (
 
 	"github.com/ontio/ontology-eventbus/actor"
 	"github.com/ontio/ontology-eventbus/eventhub"
+	"github.com/orcaman/concurrent-map"
 )
 
 var DefEvtHub *eventhub.EventHub
@@ -41,6 +42,12 @@ func Init() {
 	DefActorPublisher = NewActorPublisher(DefPublisherPID)
 }
 
+//NewEventHub return an event hub separated from the default one, which only supports PublishPolicyAll.
+//It's used to run several ledgers in one process without mixing up their events
+func NewEventHub() *eventhub.EventHub {
+	return &eventhub.EventHub{Subscribers: cmap.New()}
+}
+
 func NewActorPublisher(publisher *actor.PID, evtHub ...*eventhub.EventHub) *ActorPublisher {
 	var hub *eventhub.EventHub
 	if len(evtHub) == 0 {
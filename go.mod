@@ -12,6 +12,7 @@ require (
 	github.com/ontio/ontology v1.7.1 // indirect
 	github.com/ontio/ontology-crypto v1.0.5
 	github.com/ontio/ontology-eventbus v0.9.1
+	github.com/orcaman/concurrent-map v0.0.0-20190314100340-2693aad1ed75
 	github.com/pborman/uuid v1.2.0
 	github.com/stretchr/testify v1.3.0
 	github.com/syndtr/goleveldb v1.0.0
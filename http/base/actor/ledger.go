@@ -20,8 +20,10 @@ package actor
 
 import (
 	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/core/ledger"
 	"github.com/dnaproject2/DNA/core/payload"
+	scom "github.com/dnaproject2/DNA/core/store/common"
 	"github.com/dnaproject2/DNA/core/types"
 	"github.com/dnaproject2/DNA/smartcontract/event"
 	cstate "github.com/dnaproject2/DNA/smartcontract/states"
@@ -57,6 +59,11 @@ func GetBlockFromStore(hash common.Uint256) (*types.Block, error) {
 	return ledger.DefLedger.GetBlockByHash(hash)
 }
 
+//GetHeaderFromStore from ledger
+func GetHeaderFromStore(hash common.Uint256) (*types.Header, error) {
+	return ledger.DefLedger.GetHeaderByHash(hash)
+}
+
 //GetCurrentBlockHeight from ledger
 func GetCurrentBlockHeight() uint32 {
 	return ledger.DefLedger.GetCurrentBlockHeight()
@@ -67,6 +74,11 @@ func GetTransaction(hash common.Uint256) (*types.Transaction, error) {
 	return ledger.DefLedger.GetTransaction(hash)
 }
 
+//GetStorageProof from ledger
+func GetStorageProof(address common.Address, key []byte, height uint32) (*scom.StorageProof, error) {
+	return ledger.DefLedger.GetStorageProof(address, key, height)
+}
+
 //GetStorageItem from ledger
 func GetStorageItem(address common.Address, key []byte) ([]byte, error) {
 	return ledger.DefLedger.GetStorageItem(address, key)
@@ -80,10 +92,23 @@ func GetContractStateFromStore(hash common.Address) (*payload.DeployCode, error)
 
 //GetTxnWithHeightByTxHash from ledger
 func GetTxnWithHeightByTxHash(hash common.Uint256) (uint32, *types.Transaction, error) {
+	if config.DefConfig.Common.LightMode {
+		return FetchTransaction(hash)
+	}
 	tx, height, err := ledger.DefLedger.GetTransactionWithHeight(hash)
 	return height, tx, err
 }
 
+//GetStorageItemAtHeight from ledger
+func GetStorageItemAtHeight(address common.Address, key []byte, height uint32) ([]byte, error) {
+	return ledger.DefLedger.GetStorageItemAtHeight(address, key, height)
+}
+
+//PreExecuteContractAtHeight from ledger
+func PreExecuteContractAtHeight(tx *types.Transaction, height uint32) (*cstate.PreExecResult, error) {
+	return ledger.DefLedger.PreExecuteContractAtHeight(tx, height)
+}
+
 //PreExecuteContract from ledger
 func PreExecuteContract(tx *types.Transaction) (*cstate.PreExecResult, error) {
 	return ledger.DefLedger.PreExecuteContract(tx)
@@ -99,7 +124,22 @@ func GetEventNotifyByHeight(height uint32) ([]*event.ExecuteNotify, error) {
 	return ledger.DefLedger.GetEventNotifyByBlock(height)
 }
 
+//GetEventNotifyByContract from ledger
+func GetEventNotifyByContract(contract common.Address, topic string, startHeight, endHeight, offset, limit uint32) ([]*event.ContractNotify, error) {
+	return ledger.DefLedger.GetEventNotifyByContract(contract, topic, startHeight, endHeight, offset, limit)
+}
+
+//GetAddressTxs from ledger
+func GetAddressTxs(addr common.Address, offset, limit uint32, desc bool) ([]*scom.AddressTx, error) {
+	return ledger.DefLedger.GetAddressTxs(addr, offset, limit, desc)
+}
+
 //GetMerkleProof from ledger
 func GetMerkleProof(proofHeight uint32, rootHeight uint32) ([]common.Uint256, error) {
 	return ledger.DefLedger.GetMerkleProof(proofHeight, rootHeight)
 }
+
+//GetConsistencyProof from ledger
+func GetConsistencyProof(oldHeight uint32, newHeight uint32) ([]common.Uint256, error) {
+	return ledger.DefLedger.GetConsistencyProof(oldHeight, newHeight)
+}
@@ -22,7 +22,9 @@ import (
 	"errors"
 	"time"
 
+	comm "github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/types"
 	ac "github.com/dnaproject2/DNA/p2pserver/actor/server"
 	"github.com/dnaproject2/DNA/p2pserver/common"
 	"github.com/ontio/ontology-eventbus/actor"
@@ -204,3 +206,21 @@ func GetNodeType() (uint64, error) {
 	}
 	return r.NodeType, nil
 }
+
+//FetchTransaction from netSever actor, the transaction is fetched from full nodes in light mode
+func FetchTransaction(hash comm.Uint256) (uint32, *types.Transaction, error) {
+	if netServerPid == nil {
+		return 0, nil, errors.New("net server not started")
+	}
+	future := netServerPid.RequestFuture(&ac.GetTxProofReq{TxHash: hash}, REQ_TIMEOUT*time.Second)
+	result, err := future.Result()
+	if err != nil {
+		log.Errorf(ERR_ACTOR_COMM, err)
+		return 0, nil, err
+	}
+	r, ok := result.(*ac.GetTxProofRsp)
+	if !ok {
+		return 0, nil, errors.New("fail")
+	}
+	return r.Height, r.Tx, r.Error
+}
@@ -128,3 +128,18 @@ func GetTxnCount() ([]uint32, error) {
 	}
 	return txnCnt.Count, nil
 }
+
+//GetTxnStats from txpool actor
+func GetTxnStats() ([]uint64, error) {
+	future := txnPid.RequestFuture(&tcomn.GetTxnStats{}, REQ_TIMEOUT*time.Second)
+	result, err := future.Result()
+	if err != nil {
+		log.Errorf(ERR_ACTOR_COMM, err)
+		return []uint64{}, err
+	}
+	txnStats, ok := result.(*tcomn.GetTxnStatsRsp)
+	if !ok {
+		return []uint64{}, errors.New("fail")
+	}
+	return txnStats.Count, nil
+}
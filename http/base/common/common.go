@@ -23,6 +23,8 @@ import (
 	"bytes"
 	"encoding/hex"
 	"fmt"
+	"math"
+
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/constants"
 	"github.com/dnaproject2/DNA/common/log"
@@ -36,7 +38,9 @@ import (
 	"github.com/dnaproject2/DNA/smartcontract/event"
 	"github.com/dnaproject2/DNA/smartcontract/service/native/ont"
 	"github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	nvm "github.com/dnaproject2/DNA/smartcontract/service/neovm"
 	cstate "github.com/dnaproject2/DNA/smartcontract/states"
+	tcomn "github.com/dnaproject2/DNA/txnpool/common"
 	"github.com/dnaproject2/DNA/vm/neovm"
 	"github.com/ontio/ontology-crypto/keypair"
 	"strings"
@@ -44,8 +48,13 @@ import (
 )
 
 const MAX_SEARCH_HEIGHT uint32 = 100
+const MAX_SEARCH_EVENT_LIMIT uint32 = 1000
+const MAX_SEARCH_ADDRESS_TX_LIMIT uint32 = 1000
 const MAX_REQUEST_BODY_SIZE = 1 << 20
 
+//LATEST_HEIGHT queries the latest states instead of the archived states of a block height
+const LATEST_HEIGHT uint32 = math.MaxUint32
+
 type BalanceOfRsp struct {
 	Ont string `json:"ont"`
 	Ong string `json:"ong"`
@@ -60,6 +69,15 @@ type MerkleProof struct {
 	TargetHashes     []string
 }
 
+type ConsistencyProof struct {
+	Type           string
+	OldBlockRoot   string
+	OldBlockHeight uint32
+	NewBlockRoot   string
+	NewBlockHeight uint32
+	TargetHashes   []string
+}
+
 type LogEventArgs struct {
 	TxHash          string
 	ContractAddress string
@@ -73,6 +91,27 @@ type ExecuteNotify struct {
 	Notify      []NotifyEventInfo
 }
 
+type ContractNotifyInfo struct {
+	Height          uint32
+	TxHash          string
+	ContractAddress string
+	States          interface{}
+}
+
+type StorageProofInfo struct {
+	Height       uint32
+	StateRoot    string
+	CommitHeight uint32 //Height of the block header committing StateRoot, 0 if not committed yet
+	Key          string
+	Value        string
+	Proof        string
+}
+
+type AddressTxInfo struct {
+	Height uint32
+	TxHash string
+}
+
 type PreExecuteResult struct {
 	State  byte
 	Gas    uint64
@@ -129,6 +168,7 @@ type BlockHead struct {
 	ConsensusData    uint64
 	ConsensusPayload string
 	NextBookkeeper   string
+	StateRoot        string
 
 	Bookkeepers []string
 	SigData     []string
@@ -170,6 +210,37 @@ type TXNEntryInfo struct {
 	State []TXNAttrInfo // the result from each validator
 }
 
+type TXNStatsInfo struct {
+	Received  uint64
+	Success   uint64
+	Failure   uint64
+	Duplicate uint64
+	SigErr    uint64
+	StateErr  uint64
+	Evicted   uint64
+	Expired   uint64
+}
+
+func GetTxnStatsInfo() (*TXNStatsInfo, error) {
+	count, err := bactor.GetTxnStats()
+	if err != nil {
+		return nil, err
+	}
+	if len(count) < int(tcomn.MaxStats-1) {
+		return nil, fmt.Errorf("invalid tx stats length %d", len(count))
+	}
+	return &TXNStatsInfo{
+		Received:  count[tcomn.RcvStats-1],
+		Success:   count[tcomn.SuccessStats-1],
+		Failure:   count[tcomn.FailureStats-1],
+		Duplicate: count[tcomn.DuplicateStats-1],
+		SigErr:    count[tcomn.SigErrStats-1],
+		StateErr:  count[tcomn.StateErrStats-1],
+		Evicted:   count[tcomn.EvictedStats-1],
+		Expired:   count[tcomn.ExpiredStats-1],
+	}, nil
+}
+
 func GetLogEvent(obj *event.LogEventArgs) (map[string]bool, LogEventArgs) {
 	hash := obj.TxHash
 	addr := obj.ContractAddress.ToHexString()
@@ -188,6 +259,122 @@ func GetExecuteNotify(obj *event.ExecuteNotify) (map[string]bool, ExecuteNotify)
 	return contractAddrs, ExecuteNotify{txhash, obj.State, obj.GasConsumed, evts}
 }
 
+//GetSmartCodeEvents return the event notifies of the contract with the topic in the height range,
+//the end height defaults to the current height and the limit is capped by MAX_SEARCH_EVENT_LIMIT
+func GetSmartCodeEvents(contract common.Address, topic string, startHeight, endHeight, offset, limit uint32) ([]ContractNotifyInfo, error) {
+	if endHeight == 0 {
+		endHeight = bactor.GetCurrentBlockHeight()
+	}
+	if limit == 0 || limit > MAX_SEARCH_EVENT_LIMIT {
+		limit = MAX_SEARCH_EVENT_LIMIT
+	}
+	notifies, err := bactor.GetEventNotifyByContract(contract, topic, startHeight, endHeight, offset, limit)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ContractNotifyInfo, 0, len(notifies))
+	for _, v := range notifies {
+		infos = append(infos, ContractNotifyInfo{
+			Height:          v.Height,
+			TxHash:          v.TxHash.ToHexString(),
+			ContractAddress: v.Notify.ContractAddress.ToHexString(),
+			States:          v.Notify.States,
+		})
+	}
+	return infos, nil
+}
+
+//GetStorageProof return the storage item of the contract at the block height with its proof,
+//Key and Value are the state key and the serialized storage item committed to the state tree.
+//The state root is signed by consensus in the header of CommitHeight, the proof is trustless
+//only if the client checks StateRoot against that header. CommitHeight is 0 until the header
+//is produced, types.STATE_ROOT_DELAY blocks after the height
+func GetStorageProof(contract common.Address, key []byte, height uint32) (*StorageProofInfo, error) {
+	proof, err := bactor.GetStorageProof(contract, key, height)
+	if err != nil {
+		return nil, err
+	}
+	commitHeight := uint32(0)
+	header, err := bactor.GetHeaderByHeight(proof.Height + types.STATE_ROOT_DELAY)
+	if err == nil && header != nil {
+		if h, ok := header.StateRootHeight(); ok && h == proof.Height && header.StateRoot == proof.Root {
+			commitHeight = header.Height
+		}
+	}
+	sink := common.NewZeroCopySink(nil)
+	proof.Proof.Serialization(sink)
+	return &StorageProofInfo{
+		Height:       proof.Height,
+		StateRoot:    proof.Root.ToHexString(),
+		CommitHeight: commitHeight,
+		Key:          common.ToHexString(proof.Key),
+		Value:        common.ToHexString(proof.Value),
+		Proof:        common.ToHexString(sink.Bytes()),
+	}, nil
+}
+
+//GetConsistencyProof return the proof that the block root at old height is consistent with the block root at new height,
+//that is the blocks up to old height are not changed at new height
+func GetConsistencyProof(oldHeight, newHeight uint32) (*ConsistencyProof, error) {
+	if oldHeight > newHeight || newHeight > bactor.GetCurrentBlockHeight() {
+		return nil, fmt.Errorf("invalid height range [%d, %d]", oldHeight, newHeight)
+	}
+	oldHeader, err := bactor.GetHeaderByHeight(oldHeight)
+	if err != nil {
+		return nil, err
+	}
+	newHeader, err := bactor.GetHeaderByHeight(newHeight)
+	if err != nil {
+		return nil, err
+	}
+	proof, err := bactor.GetConsistencyProof(oldHeight, newHeight)
+	if err != nil {
+		return nil, err
+	}
+	hashes := make([]string, 0, len(proof))
+	for _, v := range proof {
+		hashes = append(hashes, v.ToHexString())
+	}
+	oldBlockRoot, newBlockRoot := getBlockRoot(oldHeader), getBlockRoot(newHeader)
+	return &ConsistencyProof{
+		Type:           "ConsistencyProof",
+		OldBlockRoot:   oldBlockRoot.ToHexString(),
+		OldBlockHeight: oldHeight,
+		NewBlockRoot:   newBlockRoot.ToHexString(),
+		NewBlockHeight: newHeight,
+		TargetHashes:   hashes,
+	}, nil
+}
+
+//getBlockRoot return the root of block merkle tree at the header. The block root of genesis header is empty,
+//while the tree of genesis has the transactions root as the only leaf
+func getBlockRoot(header *types.Header) common.Uint256 {
+	if header.Height == 0 {
+		return header.TransactionsRoot
+	}
+	return header.BlockRoot
+}
+
+//GetAddressTransactions return the transactions which involve the address, the latest first if desc,
+//the limit is capped by MAX_SEARCH_ADDRESS_TX_LIMIT
+func GetAddressTransactions(addr common.Address, offset, limit uint32, desc bool) ([]AddressTxInfo, error) {
+	if limit == 0 || limit > MAX_SEARCH_ADDRESS_TX_LIMIT {
+		limit = MAX_SEARCH_ADDRESS_TX_LIMIT
+	}
+	txs, err := bactor.GetAddressTxs(addr, offset, limit, desc)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]AddressTxInfo, 0, len(txs))
+	for _, v := range txs {
+		infos = append(infos, AddressTxInfo{
+			Height: v.Height,
+			TxHash: v.TxHash.ToHexString(),
+		})
+	}
+	return infos, nil
+}
+
 func ConvertPreExecuteResult(obj *cstate.PreExecResult) PreExecuteResult {
 	evts := []NotifyEventInfo{}
 	for _, v := range obj.Notify {
@@ -196,6 +383,34 @@ func ConvertPreExecuteResult(obj *cstate.PreExecResult) PreExecuteResult {
 	return PreExecuteResult{obj.State, obj.Gas, obj.Result, evts}
 }
 
+//GetTxContract returns the address of the contract deployed or invoked by the tx
+func GetTxContract(tx *types.Transaction) (common.Address, bool) {
+	switch pl := tx.Payload.(type) {
+	case *payload.DeployCode:
+		return pl.Address(), true
+	case *payload.InvokeCode:
+		code := pl.Code
+		// neovm contract: APPCALL <address>
+		start := len(code) - common.ADDR_LEN
+		if start > 0 && code[start-1] == byte(neovm.APPCALL) {
+			addr, err := common.AddressParseFromBytes(code[start:])
+			return addr, err == nil
+		}
+		// native contract: PUSHBYTES20 <address> <version> SYSCALL PUSHBYTES <name>
+		name := []byte(nvm.NATIVE_INVOKE_NAME)
+		suffix := append([]byte{byte(neovm.SYSCALL), byte(len(name))}, name...)
+		if bytes.HasSuffix(code, suffix) {
+			end := len(code) - len(suffix) - 1
+			start := end - common.ADDR_LEN
+			if start > 0 && code[start-1] == byte(common.ADDR_LEN) {
+				addr, err := common.AddressParseFromBytes(code[start:end])
+				return addr, err == nil
+			}
+		}
+	}
+	return common.ADDRESS_EMPTY, false
+}
+
 func TransArryByteToHexString(ptx *types.Transaction) *Transactions {
 	trans := new(Transactions)
 	trans.TxType = ptx.TxType
@@ -235,32 +450,7 @@ func SendTxToPool(txn *types.Transaction) (ontErrors.ErrCode, string) {
 
 func GetBlockInfo(block *types.Block) BlockInfo {
 	hash := block.Hash()
-	var bookkeepers = []string{}
-	var sigData = []string{}
-	for i := 0; i < len(block.Header.SigData); i++ {
-		s := common.ToHexString(block.Header.SigData[i])
-		sigData = append(sigData, s)
-	}
-	for i := 0; i < len(block.Header.Bookkeepers); i++ {
-		e := block.Header.Bookkeepers[i]
-		key := keypair.SerializePublicKey(e)
-		bookkeepers = append(bookkeepers, common.ToHexString(key))
-	}
-
-	blockHead := &BlockHead{
-		Version:          block.Header.Version,
-		PrevBlockHash:    block.Header.PrevBlockHash.ToHexString(),
-		TransactionsRoot: block.Header.TransactionsRoot.ToHexString(),
-		BlockRoot:        block.Header.BlockRoot.ToHexString(),
-		Timestamp:        block.Header.Timestamp,
-		Height:           block.Header.Height,
-		ConsensusData:    block.Header.ConsensusData,
-		ConsensusPayload: common.ToHexString(block.Header.ConsensusPayload),
-		NextBookkeeper:   block.Header.NextBookkeeper.ToBase58(),
-		Bookkeepers:      bookkeepers,
-		SigData:          sigData,
-		Hash:             hash.ToHexString(),
-	}
+	blockHead := GetBlockHead(block.Header)
 
 	trans := make([]*Transactions, len(block.Transactions))
 	for i := 0; i < len(block.Transactions); i++ {
@@ -278,12 +468,53 @@ func GetBlockInfo(block *types.Block) BlockInfo {
 	return b
 }
 
-func GetBalance(address common.Address) (*BalanceOfRsp, error) {
-	ont, err := GetContractBalance(0, utils.OntContractAddress, address)
+//GetBlockHead return the readable block header
+func GetBlockHead(header *types.Header) *BlockHead {
+	hash := header.Hash()
+	var bookkeepers = []string{}
+	var sigData = []string{}
+	for i := 0; i < len(header.SigData); i++ {
+		s := common.ToHexString(header.SigData[i])
+		sigData = append(sigData, s)
+	}
+	for i := 0; i < len(header.Bookkeepers); i++ {
+		e := header.Bookkeepers[i]
+		key := keypair.SerializePublicKey(e)
+		bookkeepers = append(bookkeepers, common.ToHexString(key))
+	}
+
+	return &BlockHead{
+		Version:          header.Version,
+		PrevBlockHash:    header.PrevBlockHash.ToHexString(),
+		TransactionsRoot: header.TransactionsRoot.ToHexString(),
+		BlockRoot:        header.BlockRoot.ToHexString(),
+		Timestamp:        header.Timestamp,
+		Height:           header.Height,
+		ConsensusData:    header.ConsensusData,
+		ConsensusPayload: common.ToHexString(header.ConsensusPayload),
+		NextBookkeeper:   header.NextBookkeeper.ToBase58(),
+		StateRoot:        header.StateRoot.ToHexString(),
+		Bookkeepers:      bookkeepers,
+		SigData:          sigData,
+		Hash:             hash.ToHexString(),
+	}
+}
+
+//PreExecuteContract pre-executes the transaction on the states of the block height,
+//or on the latest states if the height is LATEST_HEIGHT
+func PreExecuteContract(tx *types.Transaction, height uint32) (*cstate.PreExecResult, error) {
+	if height == LATEST_HEIGHT {
+		return bactor.PreExecuteContract(tx)
+	}
+	return bactor.PreExecuteContractAtHeight(tx, height)
+}
+
+func GetBalance(address common.Address, height uint32) (*BalanceOfRsp, error) {
+	ont, err := GetContractBalance(0, utils.OntContractAddress, address, height)
 	if err != nil {
 		return nil, fmt.Errorf("get ont balance error:%s", err)
 	}
-	ong, err := GetContractBalance(0, utils.OngContractAddress, address)
+	ong, err := GetContractBalance(0, utils.OngContractAddress, address, height)
 	if err != nil {
 		return nil, fmt.Errorf("get ont balance error:%s", err)
 	}
@@ -303,7 +534,7 @@ func GetGrantOng(addr common.Address) (string, error) {
 	if err != nil {
 		return fmt.Sprintf("%v", 0), err
 	}
-	ont, err := GetContractBalance(0, utils.OntContractAddress, addr)
+	ont, err := GetContractBalance(0, utils.OntContractAddress, addr, LATEST_HEIGHT)
 	if err != nil {
 		return fmt.Sprintf("%v", 0), err
 	}
@@ -311,7 +542,7 @@ func GetGrantOng(addr common.Address) (string, error) {
 	return fmt.Sprintf("%v", boundong), nil
 }
 
-func GetAllowance(asset string, from, to common.Address) (string, error) {
+func GetAllowance(asset string, from, to common.Address, height uint32) (string, error) {
 	var contractAddr common.Address
 	switch strings.ToLower(asset) {
 	case "ont":
@@ -321,14 +552,14 @@ func GetAllowance(asset string, from, to common.Address) (string, error) {
 	default:
 		return "", fmt.Errorf("unsupport asset")
 	}
-	allowance, err := GetContractAllowance(0, contractAddr, from, to)
+	allowance, err := GetContractAllowance(0, contractAddr, from, to, height)
 	if err != nil {
 		return "", fmt.Errorf("get allowance error:%s", err)
 	}
 	return fmt.Sprintf("%v", allowance), nil
 }
 
-func GetContractBalance(cVersion byte, contractAddr, accAddr common.Address) (uint64, error) {
+func GetContractBalance(cVersion byte, contractAddr, accAddr common.Address, height uint32) (uint64, error) {
 	mutable, err := NewNativeInvokeTransaction(0, 0, contractAddr, cVersion, "balanceOf", []interface{}{accAddr[:]})
 	if err != nil {
 		return 0, fmt.Errorf("NewNativeInvokeTransaction error:%s", err)
@@ -337,7 +568,7 @@ func GetContractBalance(cVersion byte, contractAddr, accAddr common.Address) (ui
 	if err != nil {
 		return 0, err
 	}
-	result, err := bactor.PreExecuteContract(tx)
+	result, err := PreExecuteContract(tx, height)
 	if err != nil {
 		return 0, fmt.Errorf("PrepareInvokeContract error:%s", err)
 	}
@@ -353,7 +584,7 @@ func GetContractBalance(cVersion byte, contractAddr, accAddr common.Address) (ui
 	return balance.Uint64(), nil
 }
 
-func GetContractAllowance(cVersion byte, contractAddr, fromAddr, toAddr common.Address) (uint64, error) {
+func GetContractAllowance(cVersion byte, contractAddr, fromAddr, toAddr common.Address, height uint32) (uint64, error) {
 	type allowanceStruct struct {
 		From common.Address
 		To   common.Address
@@ -372,7 +603,7 @@ func GetContractAllowance(cVersion byte, contractAddr, fromAddr, toAddr common.A
 		return 0, err
 	}
 
-	result, err := bactor.PreExecuteContract(tx)
+	result, err := PreExecuteContract(tx, height)
 	if err != nil {
 		return 0, fmt.Errorf("PrepareInvokeContract error:%s", err)
 	}
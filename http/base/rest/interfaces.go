@@ -264,7 +264,11 @@ func SendRawTransaction(cmd map[string]interface{}) map[string]interface{} {
 	log.Debugf("SendRawTransaction recv %s", hash.ToHexString())
 	if txn.TxType == types.Invoke || txn.TxType == types.Deploy {
 		if preExec, ok := cmd["PreExec"].(string); ok && preExec == "1" {
-			rst, err := bactor.PreExecuteContract(txn)
+			height, ok := getHeightParam(cmd, bcomn.LATEST_HEIGHT)
+			if !ok {
+				return ResponsePack(berr.INVALID_PARAMS)
+			}
+			rst, err := bcomn.PreExecuteContract(txn, height)
 			if err != nil {
 				log.Infof("PreExec: ", err)
 				resp = ResponsePack(berr.SMARTCODE_ERROR)
@@ -350,6 +354,98 @@ func GetSmartCodeEventByTxHash(cmd map[string]interface{}) map[string]interface{
 	return resp
 }
 
+//get smartcontract events by contract address, topic and height range
+func GetSmartCodeEvents(cmd map[string]interface{}) map[string]interface{} {
+	if !config.DefConfig.Common.EnableEventLog {
+		return ResponsePack(berr.INVALID_METHOD)
+	}
+
+	resp := ResponsePack(berr.SUCCESS)
+	str, ok := cmd["Contract"].(string)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	contract, err := bcomn.GetAddress(str)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	topic, _ := cmd["Topic"].(string)
+	// start height, end height, offset, limit
+	keys := []string{"StartHeight", "EndHeight", "Offset", "Limit"}
+	args := make([]uint32, len(keys))
+	for i, key := range keys {
+		if args[i], ok = getUint32Param(cmd, key); !ok {
+			return ResponsePack(berr.INVALID_PARAMS)
+		}
+	}
+	notifies, err := bcomn.GetSmartCodeEvents(contract, topic, args[0], args[1], args[2], args[3])
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = notifies
+	return resp
+}
+
+//get the transactions which involve the address
+func GetAddressTransactions(cmd map[string]interface{}) map[string]interface{} {
+	if !config.DefConfig.Common.EnableAddressIndex {
+		return ResponsePack(berr.INVALID_METHOD)
+	}
+
+	resp := ResponsePack(berr.SUCCESS)
+	str, ok := cmd["Addr"].(string)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	addr, err := bcomn.GetAddress(str)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	offset, ok := getUint32Param(cmd, "Offset")
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	limit, ok := getUint32Param(cmd, "Limit")
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	desc := false
+	if str, ok := cmd["Desc"].(string); ok && str == "1" {
+		desc = true
+	}
+	txs, err := bcomn.GetAddressTransactions(addr, offset, limit, desc)
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = txs
+	return resp
+}
+
+//get an optional uint32 param in string or number, which defaults to 0
+func getUint32Param(cmd map[string]interface{}, key string) (uint32, bool) {
+	switch v := cmd[key].(type) {
+	case nil:
+		return 0, true
+	case float64:
+		return uint32(v), v >= 0
+	case string:
+		if v == "" {
+			return 0, true
+		}
+		n, err := strconv.ParseUint(v, 10, 32)
+		return uint32(n), err == nil
+	}
+	return 0, false
+}
+
+//getHeightParam parse the optional block height param, which defaults to the given height
+func getHeightParam(cmd map[string]interface{}, defHeight uint32) (uint32, bool) {
+	if h, ok := cmd["Height"]; !ok || h == nil || h == "" {
+		return defHeight, true
+	}
+	return getUint32Param(cmd, "Height")
+}
+
 //get contract state
 func GetContractState(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(berr.SUCCESS)
@@ -394,7 +490,16 @@ func GetStorage(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	value, err := bactor.GetStorageItem(address, item)
+	height, ok := getHeightParam(cmd, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	var value []byte
+	if height == bcomn.LATEST_HEIGHT {
+		value, err = bactor.GetStorageItem(address, item)
+	} else {
+		value, err = bactor.GetStorageItemAtHeight(address, item, height)
+	}
 	if err != nil {
 		if err == scom.ErrNotFound {
 			return ResponsePack(berr.SUCCESS)
@@ -405,6 +510,37 @@ func GetStorage(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+//get storage value with its proof against the state tree root
+func GetStorageProof(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(berr.SUCCESS)
+	str, ok := cmd["Hash"].(string)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	address, err := bcomn.GetAddress(str)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	str, ok = cmd["Key"].(string)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	key, err := common.HexToBytes(str)
+	if err != nil {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	height, ok := getHeightParam(cmd, bactor.GetCurrentBlockHeight())
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	proof, err := bcomn.GetStorageProof(address, key, height)
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = proof
+	return resp
+}
+
 //get balance of address
 func GetBalance(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(berr.SUCCESS)
@@ -416,7 +552,11 @@ func GetBalance(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	balance, err := bcomn.GetBalance(address)
+	height, ok := getHeightParam(cmd, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	balance, err := bcomn.GetBalance(address, height)
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
@@ -465,6 +605,34 @@ func GetMerkleProof(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+//get consistency proof of block merkle tree between two heights
+func GetConsistencyProof(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(berr.SUCCESS)
+	if h, ok := cmd["OldHeight"]; !ok || h == nil || h == "" {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	oldHeight, ok := getUint32Param(cmd, "OldHeight")
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	newHeight := bactor.GetCurrentBlockHeight()
+	if h, ok := cmd["NewHeight"]; ok && h != nil && h != "" {
+		newHeight, ok = getUint32Param(cmd, "NewHeight")
+		if !ok {
+			return ResponsePack(berr.INVALID_PARAMS)
+		}
+	}
+	if oldHeight > newHeight || newHeight > bactor.GetCurrentBlockHeight() {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	proof, err := bcomn.GetConsistencyProof(oldHeight, newHeight)
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = proof
+	return resp
+}
+
 //get avg gas price in block
 func GetGasPrice(cmd map[string]interface{}) map[string]interface{} {
 	result, err := bcomn.GetGasPrice()
@@ -499,7 +667,11 @@ func GetAllowance(cmd map[string]interface{}) map[string]interface{} {
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
-	rsp, err := bcomn.GetAllowance(asset, fromAddr, toAddr)
+	height, ok := getHeightParam(cmd, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return ResponsePack(berr.INVALID_PARAMS)
+	}
+	rsp, err := bcomn.GetAllowance(asset, fromAddr, toAddr, height)
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
@@ -519,7 +691,7 @@ func GetUnboundOng(cmd map[string]interface{}) map[string]interface{} {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
 	fromAddr := utils.OntContractAddress
-	rsp, err := bcomn.GetAllowance("ong", fromAddr, toAddr)
+	rsp, err := bcomn.GetAllowance("ong", fromAddr, toAddr, bcomn.LATEST_HEIGHT)
 	if err != nil {
 		return ResponsePack(berr.INVALID_PARAMS)
 	}
@@ -558,6 +730,17 @@ func GetMemPoolTxCount(cmd map[string]interface{}) map[string]interface{} {
 	return resp
 }
 
+//get memory pool transaction statistics
+func GetMemPoolTxStats(cmd map[string]interface{}) map[string]interface{} {
+	resp := ResponsePack(berr.SUCCESS)
+	stats, err := bcomn.GetTxnStatsInfo()
+	if err != nil {
+		return ResponsePack(berr.INTERNAL_ERROR)
+	}
+	resp["Result"] = stats
+	return resp
+}
+
 //get memory poll transaction state
 func GetMemPoolTxState(cmd map[string]interface{}) map[string]interface{} {
 	resp := ResponsePack(berr.SUCCESS)
@@ -86,6 +86,51 @@ func GetBlock(params []interface{}) map[string]interface{} {
 	return responseSuccess(common.ToHexString(block.ToArray()))
 }
 
+//get block header by height or hash
+// A JSON example for getblockheader method as following:
+//   {"jsonrpc": "2.0", "method": "getblockheader", "params": [1, 1], "id": 0}
+func GetBlockHeader(params []interface{}) map[string]interface{} {
+	if len(params) < 1 {
+		return responsePack(berr.INVALID_PARAMS, nil)
+	}
+	var err error
+	var hash common.Uint256
+	switch (params[0]).(type) {
+	// block height
+	case float64:
+		index := uint32(params[0].(float64))
+		hash = bactor.GetBlockHashFromStore(index)
+		if hash == common.UINT256_EMPTY {
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+		// block hash
+	case string:
+		str := params[0].(string)
+		hash, err = common.Uint256FromHexString(str)
+		if err != nil {
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+	default:
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	header, err := bactor.GetHeaderFromStore(hash)
+	if err != nil || header == nil {
+		return responsePack(berr.UNKNOWN_BLOCK, "unknown block")
+	}
+	if len(params) >= 2 {
+		switch (params[1]).(type) {
+		case float64:
+			json := uint32(params[1].(float64))
+			if json == 1 {
+				return responseSuccess(bcomn.GetBlockHead(header))
+			}
+		default:
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+	}
+	return responseSuccess(common.ToHexString(header.ToArray()))
+}
+
 //get block height
 func GetBlockCount(params []interface{}) map[string]interface{} {
 	height := bactor.GetCurrentBlockHeight()
@@ -143,6 +188,15 @@ func GetMemPoolTxCount(params []interface{}) map[string]interface{} {
 	return responseSuccess(count)
 }
 
+//get memory pool transaction statistics
+func GetMemPoolTxStats(params []interface{}) map[string]interface{} {
+	stats, err := bcomn.GetTxnStatsInfo()
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, nil)
+	}
+	return responseSuccess(stats)
+}
+
 //get memory pool transaction state
 func GetMemPoolTxState(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
@@ -217,7 +271,7 @@ func GetRawTransaction(params []interface{}) map[string]interface{} {
 //get storage from contract
 //   {"jsonrpc": "2.0", "method": "getstorage", "params": ["code hash", "key"], "id": 0}
 func GetStorage(params []interface{}) map[string]interface{} {
-	if len(params) < 2 {
+	if len(params) < 2 || len(params) > 3 {
 		return responsePack(berr.INVALID_PARAMS, nil)
 	}
 
@@ -246,7 +300,17 @@ func GetStorage(params []interface{}) map[string]interface{} {
 	default:
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
-	value, err := bactor.GetStorageItem(address, key)
+	height, ok := getHeightParam(params, 2, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	var value []byte
+	var err error
+	if height == bcomn.LATEST_HEIGHT {
+		value, err = bactor.GetStorageItem(address, key)
+	} else {
+		value, err = bactor.GetStorageItemAtHeight(address, key, height)
+	}
 	if err != nil {
 		if err == scom.ErrNotFound {
 			return responseSuccess(nil)
@@ -281,7 +345,11 @@ func SendRawTransaction(params []interface{}) map[string]interface{} {
 			if len(params) > 1 {
 				preExec, ok := params[1].(float64)
 				if ok && preExec == 1 {
-					result, err := bactor.PreExecuteContract(txn)
+					height, ok := getHeightParam(params, 2, bcomn.LATEST_HEIGHT)
+					if !ok {
+						return responsePack(berr.INVALID_PARAMS, "")
+					}
+					result, err := bcomn.PreExecuteContract(txn, height)
 					if err != nil {
 						log.Infof("PreExec: ", err)
 						return responsePack(berr.SMARTCODE_ERROR, err.Error())
@@ -398,6 +466,121 @@ func GetSmartCodeEvent(params []interface{}) map[string]interface{} {
 	return responsePack(berr.INVALID_PARAMS, "")
 }
 
+//get smartconstract events by contract address, topic and height range
+//params: contract address, [topic, start height, end height, offset, limit]
+func GetSmartCodeEvents(params []interface{}) map[string]interface{} {
+	if !config.DefConfig.Common.EnableEventLog {
+		return responsePack(berr.INVALID_METHOD, "")
+	}
+	if len(params) < 1 || len(params) > 6 {
+		return responsePack(berr.INVALID_PARAMS, nil)
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	contract, err := bcomn.GetAddress(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	topic := ""
+	if len(params) > 1 {
+		if topic, ok = params[1].(string); !ok {
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+	}
+	// start height, end height, offset, limit
+	args := make([]uint32, 4)
+	for i := 2; i < len(params); i++ {
+		v, ok := params[i].(float64)
+		if !ok || v < 0 {
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+		args[i-2] = uint32(v)
+	}
+	notifies, err := bcomn.GetSmartCodeEvents(contract, topic, args[0], args[1], args[2], args[3])
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, "")
+	}
+	return responseSuccess(notifies)
+}
+
+//get the transactions which involve the address
+func GetAddressTransactions(params []interface{}) map[string]interface{} {
+	if !config.DefConfig.Common.EnableAddressIndex {
+		return responsePack(berr.INVALID_METHOD, "")
+	}
+	if len(params) < 1 || len(params) > 4 {
+		return responsePack(berr.INVALID_PARAMS, nil)
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	addr, err := bcomn.GetAddress(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	// offset, limit, desc
+	args := make([]uint32, 3)
+	for i := 1; i < len(params); i++ {
+		v, ok := params[i].(float64)
+		if !ok || v < 0 {
+			return responsePack(berr.INVALID_PARAMS, "")
+		}
+		args[i-1] = uint32(v)
+	}
+	txs, err := bcomn.GetAddressTransactions(addr, args[0], args[1], args[2] == 1)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, "")
+	}
+	return responseSuccess(txs)
+}
+
+//get storage value with its proof against the state tree root
+func GetStorageProof(params []interface{}) map[string]interface{} {
+	if len(params) < 2 || len(params) > 3 {
+		return responsePack(berr.INVALID_PARAMS, nil)
+	}
+	str, ok := params[0].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	address, err := bcomn.GetAddress(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	str, ok = params[1].(string)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	key, err := hex.DecodeString(str)
+	if err != nil {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	height, ok := getHeightParam(params, 2, bactor.GetCurrentBlockHeight())
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	proof, err := bcomn.GetStorageProof(address, key, height)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, "")
+	}
+	return responseSuccess(proof)
+}
+
+//getHeightParam parse the optional block height param at the index, which defaults to the given height
+func getHeightParam(params []interface{}, index int, defHeight uint32) (uint32, bool) {
+	if len(params) <= index {
+		return defHeight, true
+	}
+	h, ok := params[index].(float64)
+	if !ok || h < 0 {
+		return 0, false
+	}
+	return uint32(h), true
+}
+
 //get block height by transaction hash
 func GetBlockHeightByTxHash(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
@@ -436,7 +619,11 @@ func GetBalance(params []interface{}) map[string]interface{} {
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
-	rsp, err := bcomn.GetBalance(address)
+	height, ok := getHeightParam(params, 1, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	rsp, err := bcomn.GetBalance(address, height)
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
@@ -468,7 +655,11 @@ func GetAllowance(params []interface{}) map[string]interface{} {
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
-	rsp, err := bcomn.GetAllowance(asset, fromAddr, toAddr)
+	height, ok := getHeightParam(params, 3, bcomn.LATEST_HEIGHT)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	rsp, err := bcomn.GetAllowance(asset, fromAddr, toAddr, height)
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
@@ -514,6 +705,26 @@ func GetMerkleProof(params []interface{}) map[string]interface{} {
 		curHeader.BlockRoot.ToHexString(), curHeight, hashes})
 }
 
+//get consistency proof of block merkle tree between two heights
+func GetConsistencyProof(params []interface{}) map[string]interface{} {
+	if len(params) < 1 || len(params) > 2 {
+		return responsePack(berr.INVALID_PARAMS, nil)
+	}
+	oldHeight, ok := getHeightParam(params, 0, 0)
+	if !ok {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	newHeight, ok := getHeightParam(params, 1, bactor.GetCurrentBlockHeight())
+	if !ok || oldHeight > newHeight || newHeight > bactor.GetCurrentBlockHeight() {
+		return responsePack(berr.INVALID_PARAMS, "")
+	}
+	proof, err := bcomn.GetConsistencyProof(oldHeight, newHeight)
+	if err != nil {
+		return responsePack(berr.INTERNAL_ERROR, "")
+	}
+	return responseSuccess(proof)
+}
+
 //get block transactions by height
 func GetBlockTxsByHeight(params []interface{}) map[string]interface{} {
 	if len(params) < 1 {
@@ -559,7 +770,7 @@ func GetUnboundOng(params []interface{}) map[string]interface{} {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
 	fromAddr := utils.OntContractAddress
-	rsp, err := bcomn.GetAllowance("ong", fromAddr, toAddr)
+	rsp, err := bcomn.GetAllowance("ong", fromAddr, toAddr, bcomn.LATEST_HEIGHT)
 	if err != nil {
 		return responsePack(berr.INVALID_PARAMS, "")
 	}
@@ -34,31 +34,41 @@ func StartRPCServer() error {
 	http.HandleFunc("/", rpc.Handle)
 
 	rpc.HandleFunc("getbestblockhash", rpc.GetBestBlockHash)
-	rpc.HandleFunc("getblock", rpc.GetBlock)
 	rpc.HandleFunc("getblockcount", rpc.GetBlockCount)
 	rpc.HandleFunc("getblockhash", rpc.GetBlockHash)
+	rpc.HandleFunc("getblockheader", rpc.GetBlockHeader)
 	rpc.HandleFunc("getconnectioncount", rpc.GetConnectionCount)
 	//HandleFunc("getrawmempool", GetRawMemPool)
 
 	rpc.HandleFunc("getrawtransaction", rpc.GetRawTransaction)
-	rpc.HandleFunc("sendrawtransaction", rpc.SendRawTransaction)
-	rpc.HandleFunc("getstorage", rpc.GetStorage)
 	rpc.HandleFunc("getversion", rpc.GetNodeVersion)
 	rpc.HandleFunc("getnetworkid", rpc.GetNetworkId)
-
-	rpc.HandleFunc("getcontractstate", rpc.GetContractState)
-	rpc.HandleFunc("getmempooltxcount", rpc.GetMemPoolTxCount)
-	rpc.HandleFunc("getmempooltxstate", rpc.GetMemPoolTxState)
-	rpc.HandleFunc("getsmartcodeevent", rpc.GetSmartCodeEvent)
 	rpc.HandleFunc("getblockheightbytxhash", rpc.GetBlockHeightByTxHash)
-
-	rpc.HandleFunc("getbalance", rpc.GetBalance)
-	rpc.HandleFunc("getallowance", rpc.GetAllowance)
 	rpc.HandleFunc("getmerkleproof", rpc.GetMerkleProof)
-	rpc.HandleFunc("getblocktxsbyheight", rpc.GetBlockTxsByHeight)
-	rpc.HandleFunc("getgasprice", rpc.GetGasPrice)
-	rpc.HandleFunc("getunboundong", rpc.GetUnboundOng)
-	rpc.HandleFunc("getgrantong", rpc.GetGrantOng)
+	rpc.HandleFunc("getconsistencyproof", rpc.GetConsistencyProof)
+
+	//light node only serves the block headers and the verified transactions
+	if !cfg.DefConfig.Common.LightMode {
+		rpc.HandleFunc("getblock", rpc.GetBlock)
+		rpc.HandleFunc("sendrawtransaction", rpc.SendRawTransaction)
+		rpc.HandleFunc("getstorage", rpc.GetStorage)
+		rpc.HandleFunc("getstorageproof", rpc.GetStorageProof)
+
+		rpc.HandleFunc("getcontractstate", rpc.GetContractState)
+		rpc.HandleFunc("getmempooltxcount", rpc.GetMemPoolTxCount)
+		rpc.HandleFunc("getmempooltxstate", rpc.GetMemPoolTxState)
+		rpc.HandleFunc("getmempooltxstats", rpc.GetMemPoolTxStats)
+		rpc.HandleFunc("getsmartcodeevent", rpc.GetSmartCodeEvent)
+		rpc.HandleFunc("getsmartcodeevents", rpc.GetSmartCodeEvents)
+		rpc.HandleFunc("getaddresstransactions", rpc.GetAddressTransactions)
+
+		rpc.HandleFunc("getbalance", rpc.GetBalance)
+		rpc.HandleFunc("getallowance", rpc.GetAllowance)
+		rpc.HandleFunc("getblocktxsbyheight", rpc.GetBlockTxsByHeight)
+		rpc.HandleFunc("getgasprice", rpc.GetGasPrice)
+		rpc.HandleFunc("getunboundong", rpc.GetUnboundOng)
+		rpc.HandleFunc("getgrantong", rpc.GetGrantOng)
+	}
 
 	err := http.ListenAndServe(":"+strconv.Itoa(int(cfg.DefConfig.Rpc.HttpJsonPort)), nil)
 	if err != nil {
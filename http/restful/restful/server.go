@@ -61,18 +61,23 @@ const (
 	GET_BLK_HASH          = "/api/v1/block/hash/:height"
 	GET_TX                = "/api/v1/transaction/:hash"
 	GET_STORAGE           = "/api/v1/storage/:hash/:key"
+	GET_STORAGE_PROOF     = "/api/v1/storageproof/:hash/:key"
 	GET_BALANCE           = "/api/v1/balance/:addr"
 	GET_CONTRACT_STATE    = "/api/v1/contract/:hash"
 	GET_SMTCOCE_EVT_TXS   = "/api/v1/smartcode/event/transactions/:height"
 	GET_SMTCOCE_EVTS      = "/api/v1/smartcode/event/txhash/:hash"
+	GET_SMTCOCE_EVTS_CTR  = "/api/v1/smartcode/events/:contract"
+	GET_ADDRESS_TXS       = "/api/v1/address/:addr/transactions"
 	GET_BLK_HGT_BY_TXHASH = "/api/v1/block/height/txhash/:hash"
 	GET_MERKLE_PROOF      = "/api/v1/merkleproof/:hash"
+	GET_CONSISTENCY_PROOF = "/api/v1/consistencyproof/:old/:new"
 	GET_GAS_PRICE         = "/api/v1/gasprice"
 	GET_ALLOWANCE         = "/api/v1/allowance/:asset/:from/:to"
 	GET_UNBOUNDONG        = "/api/v1/unboundong/:addr"
 	GET_GRANTONG          = "/api/v1/grantong/:addr"
 	GET_MEMPOOL_TXCOUNT   = "/api/v1/mempool/txcount"
 	GET_MEMPOOL_TXSTATE   = "/api/v1/mempool/txstate/:hash"
+	GET_MEMPOOL_TXSTATS   = "/api/v1/mempool/txstats"
 	GET_VERSION           = "/api/v1/version"
 	GET_NETWORKID         = "/api/v1/networkid"
 
@@ -143,16 +148,21 @@ func (this *restServer) registryMethod() {
 		GET_CONTRACT_STATE:    {name: "getcontract", handler: rest.GetContractState},
 		GET_SMTCOCE_EVT_TXS:   {name: "getsmartcodeeventbyheight", handler: rest.GetSmartCodeEventTxsByHeight},
 		GET_SMTCOCE_EVTS:      {name: "getsmartcodeeventbyhash", handler: rest.GetSmartCodeEventByTxHash},
+		GET_SMTCOCE_EVTS_CTR:  {name: "getsmartcodeevents", handler: rest.GetSmartCodeEvents},
+		GET_ADDRESS_TXS:       {name: "getaddresstransactions", handler: rest.GetAddressTransactions},
 		GET_BLK_HGT_BY_TXHASH: {name: "getblockheightbytxhash", handler: rest.GetBlockHeightByTxHash},
 		GET_STORAGE:           {name: "getstorage", handler: rest.GetStorage},
+		GET_STORAGE_PROOF:     {name: "getstorageproof", handler: rest.GetStorageProof},
 		GET_BALANCE:           {name: "getbalance", handler: rest.GetBalance},
 		GET_ALLOWANCE:         {name: "getallowance", handler: rest.GetAllowance},
 		GET_MERKLE_PROOF:      {name: "getmerkleproof", handler: rest.GetMerkleProof},
+		GET_CONSISTENCY_PROOF: {name: "getconsistencyproof", handler: rest.GetConsistencyProof},
 		GET_GAS_PRICE:         {name: "getgasprice", handler: rest.GetGasPrice},
 		GET_UNBOUNDONG:        {name: "getunboundong", handler: rest.GetUnboundOng},
 		GET_GRANTONG:          {name: "getgrantong", handler: rest.GetGrantOng},
 		GET_MEMPOOL_TXCOUNT:   {name: "getmempooltxcount", handler: rest.GetMemPoolTxCount},
 		GET_MEMPOOL_TXSTATE:   {name: "getmempooltxstate", handler: rest.GetMemPoolTxState},
+		GET_MEMPOOL_TXSTATS:   {name: "getmempooltxstats", handler: rest.GetMemPoolTxStats},
 		GET_VERSION:           {name: "getversion", handler: rest.GetNodeVersion},
 		GET_NETWORKID:         {name: "getnetworkid", handler: rest.GetNetworkId},
 	}
@@ -181,14 +191,22 @@ func (this *restServer) getPath(url string) string {
 		return GET_SMTCOCE_EVT_TXS
 	} else if strings.Contains(url, strings.TrimRight(GET_SMTCOCE_EVTS, ":hash")) {
 		return GET_SMTCOCE_EVTS
+	} else if strings.Contains(url, strings.TrimRight(GET_SMTCOCE_EVTS_CTR, ":contract")) {
+		return GET_SMTCOCE_EVTS_CTR
+	} else if strings.HasPrefix(url, "/api/v1/address/") && strings.HasSuffix(url, "/transactions") {
+		return GET_ADDRESS_TXS
 	} else if strings.Contains(url, strings.TrimRight(GET_BLK_HGT_BY_TXHASH, ":hash")) {
 		return GET_BLK_HGT_BY_TXHASH
+	} else if strings.Contains(url, strings.TrimRight(GET_STORAGE_PROOF, ":hash/:key")) {
+		return GET_STORAGE_PROOF
 	} else if strings.Contains(url, strings.TrimRight(GET_STORAGE, ":hash/:key")) {
 		return GET_STORAGE
 	} else if strings.Contains(url, strings.TrimRight(GET_BALANCE, ":addr")) {
 		return GET_BALANCE
 	} else if strings.Contains(url, strings.TrimRight(GET_MERKLE_PROOF, ":hash")) {
 		return GET_MERKLE_PROOF
+	} else if strings.Contains(url, strings.TrimRight(GET_CONSISTENCY_PROOF, ":old/:new")) {
+		return GET_CONSISTENCY_PROOF
 	} else if strings.Contains(url, strings.TrimRight(GET_ALLOWANCE, ":asset/:from/:to")) {
 		return GET_ALLOWANCE
 	} else if strings.Contains(url, strings.TrimRight(GET_UNBOUNDONG, ":addr")) {
@@ -219,22 +237,37 @@ func (this *restServer) getParams(r *http.Request, url string, req map[string]in
 	case GET_CONTRACT_STATE:
 		req["Hash"], req["Raw"] = getParam(r, "hash"), r.FormValue("raw")
 	case POST_RAW_TX:
-		req["PreExec"] = r.FormValue("preExec")
+		req["PreExec"], req["Height"] = r.FormValue("preExec"), r.FormValue("height")
 	case GET_STORAGE:
 		req["Hash"], req["Key"] = getParam(r, "hash"), getParam(r, "key")
+		req["Height"] = r.FormValue("height")
+	case GET_STORAGE_PROOF:
+		req["Hash"], req["Key"] = getParam(r, "hash"), getParam(r, "key")
+		req["Height"] = r.FormValue("height")
 	case GET_SMTCOCE_EVT_TXS:
 		req["Height"] = getParam(r, "height")
 	case GET_SMTCOCE_EVTS:
 		req["Hash"] = getParam(r, "hash")
+	case GET_SMTCOCE_EVTS_CTR:
+		req["Contract"], req["Topic"] = getParam(r, "contract"), r.FormValue("topic")
+		req["StartHeight"], req["EndHeight"] = r.FormValue("start"), r.FormValue("end")
+		req["Offset"], req["Limit"] = r.FormValue("offset"), r.FormValue("limit")
+	case GET_ADDRESS_TXS:
+		req["Addr"] = getParam(r, "addr")
+		req["Offset"], req["Limit"] = r.FormValue("offset"), r.FormValue("limit")
+		req["Desc"] = r.FormValue("desc")
 	case GET_BLK_HGT_BY_TXHASH:
 		req["Hash"] = getParam(r, "hash")
 	case GET_BALANCE:
-		req["Addr"] = getParam(r, "addr")
+		req["Addr"], req["Height"] = getParam(r, "addr"), r.FormValue("height")
 	case GET_MERKLE_PROOF:
 		req["Hash"] = getParam(r, "hash")
+	case GET_CONSISTENCY_PROOF:
+		req["OldHeight"], req["NewHeight"] = getParam(r, "old"), getParam(r, "new")
 	case GET_ALLOWANCE:
 		req["Asset"] = getParam(r, "asset")
 		req["From"], req["To"] = getParam(r, "from"), getParam(r, "to")
+		req["Height"] = r.FormValue("height")
 	case GET_UNBOUNDONG:
 		req["Addr"] = getParam(r, "addr")
 	case GET_GRANTONG:
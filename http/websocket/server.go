@@ -38,6 +38,7 @@ var ws *websocket.WsServer
 func StartServer() {
 	bactor.SubscribeEvent(message.TOPIC_SAVE_BLOCK_COMPLETE, sendBlock2WSclient)
 	bactor.SubscribeEvent(message.TOPIC_SMART_CODE_EVENT, pushSmartCodeEvent)
+	bactor.SubscribeEvent(message.TOPIC_PENDING_TX, pushPendingTx)
 	go func() {
 		ws = websocket.InitWsServer()
 		ws.Start()
@@ -126,3 +127,23 @@ func pushBlockTransactions(v interface{}) {
 		ws.BroadcastToSubscribers(nil, websocket.WSTOPIC_TXHASHS, resp)
 	}
 }
+
+func pushPendingTx(v interface{}) {
+	if ws == nil {
+		return
+	}
+	tx, ok := v.(*types.Transaction)
+	if !ok {
+		return
+	}
+	go func() {
+		var contract *common.Address
+		if addr, ok := bcomn.GetTxContract(tx); ok {
+			contract = &addr
+		}
+		resp := rest.ResponsePack(Err.SUCCESS)
+		resp["Action"] = "sendpendingtx"
+		resp["Result"] = bcomn.TransArryByteToHexString(tx)
+		ws.BroadcastPendingTx(tx.Payer, contract, resp)
+	}()
+}
@@ -0,0 +1,55 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package websocket
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	bcomn "github.com/dnaproject2/DNA/http/base/common"
+	"github.com/dnaproject2/DNA/vm/neovm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetTxContract(t *testing.T) {
+	contract := common.Address{1, 2, 3}
+
+	mutable, err := bcomn.NewNativeInvokeTransaction(0, 0, contract, 0, "transfer", []interface{}{"a"})
+	assert.Nil(t, err)
+	tx, err := mutable.IntoImmutable()
+	assert.Nil(t, err)
+	addr, ok := bcomn.GetTxContract(tx)
+	assert.True(t, ok)
+	assert.Equal(t, contract, addr)
+
+	mutable, err = bcomn.NewNeovmInvokeTransaction(0, 0, contract, []interface{}{"a"})
+	assert.Nil(t, err)
+	tx, err = mutable.IntoImmutable()
+	assert.Nil(t, err)
+	addr, ok = bcomn.GetTxContract(tx)
+	assert.True(t, ok)
+	assert.Equal(t, contract, addr)
+
+	mutable, err = bcomn.NewSmartContractTransaction(0, 0, []byte{byte(neovm.PUSH1)})
+	assert.Nil(t, err)
+	tx, err = mutable.IntoImmutable()
+	assert.Nil(t, err)
+	_, ok = bcomn.GetTxContract(tx)
+	assert.False(t, ok)
+}
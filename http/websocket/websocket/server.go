@@ -32,6 +32,7 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	cfg "github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
+	bcomn "github.com/dnaproject2/DNA/http/base/common"
 	Err "github.com/dnaproject2/DNA/http/base/error"
 	"github.com/dnaproject2/DNA/http/base/rest"
 	"github.com/dnaproject2/DNA/http/websocket/session"
@@ -43,6 +44,7 @@ const (
 	WSTOPIC_JSON_BLOCK = 2
 	WSTOPIC_RAW_BLOCK  = 3
 	WSTOPIC_TXHASHS    = 4
+	WSTOPIC_PENDING_TX = 5
 )
 
 type handler func(map[string]interface{}) map[string]interface{}
@@ -53,11 +55,17 @@ type Handler struct {
 
 //subscribe event for client
 type subscribe struct {
-	ContractsFilter       []string `json:"ContractsFilter"`
-	SubscribeEvent        bool     `json:"SubscribeEvent"`
-	SubscribeJsonBlock    bool     `json:"SubscribeJsonBlock"`
-	SubscribeRawBlock     bool     `json:"SubscribeRawBlock"`
-	SubscribeBlockTxHashs bool     `json:"SubscribeBlockTxHashs"`
+	ContractsFilter        []string `json:"ContractsFilter"`
+	SubscribeEvent         bool     `json:"SubscribeEvent"`
+	SubscribeJsonBlock     bool     `json:"SubscribeJsonBlock"`
+	SubscribeRawBlock      bool     `json:"SubscribeRawBlock"`
+	SubscribeBlockTxHashs  bool     `json:"SubscribeBlockTxHashs"`
+	SubscribePendingTx     bool     `json:"SubscribePendingTx"`
+	PayersFilter           []string `json:"PayersFilter"`
+	PendingContractsFilter []string `json:"PendingContractsFilter"`
+
+	payers           []common.Address //parsed PayersFilter
+	pendingContracts []common.Address //parsed PendingContractsFilter
 }
 type WsServer struct {
 	sync.RWMutex
@@ -157,13 +165,19 @@ func (self *WsServer) registryMethod() {
 		if b, ok := cmd["SubscribeBlockTxHashs"].(bool); ok {
 			sub.SubscribeBlockTxHashs = b
 		}
+		if b, ok := cmd["SubscribePendingTx"].(bool); ok {
+			sub.SubscribePendingTx = b
+		}
 		if ctsf, ok := cmd["ContractsFilter"].([]interface{}); ok {
-			sub.ContractsFilter = []string{}
-			for _, v := range ctsf {
-				if addr, k := v.(string); k {
-					sub.ContractsFilter = append(sub.ContractsFilter, addr)
-				}
-			}
+			sub.ContractsFilter = getFilter(ctsf)
+		}
+		if pf, ok := cmd["PayersFilter"].([]interface{}); ok {
+			sub.PayersFilter = getFilter(pf)
+			sub.payers = parseAddresses(sub.PayersFilter)
+		}
+		if pctsf, ok := cmd["PendingContractsFilter"].([]interface{}); ok {
+			sub.PendingContractsFilter = getFilter(pctsf)
+			sub.pendingContracts = parseAddresses(sub.PendingContractsFilter)
 		}
 		self.SubscribeMap[sessionId] = sub
 
@@ -181,6 +195,8 @@ func (self *WsServer) registryMethod() {
 		"getblockheightbytxhash":    {handler: rest.GetBlockHeightByTxHash},
 		"getsmartcodeeventbyhash":   {handler: rest.GetSmartCodeEventByTxHash},
 		"getsmartcodeeventbyheight": {handler: rest.GetSmartCodeEventTxsByHeight},
+		"getsmartcodeevents":        {handler: rest.GetSmartCodeEvents},
+		"getaddresstransactions":    {handler: rest.GetAddressTransactions},
 		"getcontract":               {handler: rest.GetContractState},
 		"getbalance":                {handler: rest.GetBalance},
 		"getconnectioncount":        {handler: rest.GetConnectionCount},
@@ -193,14 +209,17 @@ func (self *WsServer) registryMethod() {
 		"heartbeat":                 {handler: heartbeat},
 		"subscribe":                 {handler: subscribe},
 		"getstorage":                {handler: rest.GetStorage},
+		"getstorageproof":           {handler: rest.GetStorageProof},
 		"getallowance":              {handler: rest.GetAllowance},
 		"getmerkleproof":            {handler: rest.GetMerkleProof},
+		"getconsistencyproof":       {handler: rest.GetConsistencyProof},
 		"getblocktxsbyheight":       {handler: rest.GetBlockTxsByHeight},
 		"getgasprice":               {handler: rest.GetGasPrice},
 		"getunboundong":             {handler: rest.GetUnboundOng},
 		"getgrantong":               {handler: rest.GetGrantOng},
 		"getmempooltxcount":         {handler: rest.GetMemPoolTxCount},
 		"getmempooltxstate":         {handler: rest.GetMemPoolTxState},
+		"getmempooltxstats":         {handler: rest.GetMemPoolTxStats},
 		"getversion":                {handler: rest.GetNodeVersion},
 		"getnetworkid":              {handler: rest.GetNetworkId},
 
@@ -444,6 +463,69 @@ func (self *WsServer) BroadcastToSubscribers(contractAddrs map[string]bool, sub
 	}
 }
 
+//broadcast a pending tx to the subscribers whose payers and contracts filter match
+func (self *WsServer) BroadcastPendingTx(payer common.Address, contract *common.Address, resp map[string]interface{}) {
+	self.Lock()
+	defer self.Unlock()
+	data := marshalResp(resp)
+	for sid, v := range self.SubscribeMap {
+		if !v.matchPendingTx(payer, contract) {
+			continue
+		}
+		if s := self.SessionList.GetSessionById(sid); s != nil {
+			s.Send(data)
+		}
+	}
+}
+
+//matchPendingTx check whether the pending tx is subscribed, an empty filter matches any address
+func (self *subscribe) matchPendingTx(payer common.Address, contract *common.Address) bool {
+	if !self.SubscribePendingTx {
+		return false
+	}
+	if len(self.PayersFilter) != 0 && !matchAddress(self.payers, &payer) {
+		return false
+	}
+	if len(self.PendingContractsFilter) != 0 && !matchAddress(self.pendingContracts, contract) {
+		return false
+	}
+	return true
+}
+
+func getFilter(list []interface{}) []string {
+	filter := []string{}
+	for _, v := range list {
+		if addr, ok := v.(string); ok {
+			filter = append(filter, addr)
+		}
+	}
+	return filter
+}
+
+//parse the addresses of the filter in either hex or base58, the invalid ones are skipped
+func parseAddresses(filter []string) []common.Address {
+	addrs := make([]common.Address, 0, len(filter))
+	for _, v := range filter {
+		if addr, err := bcomn.GetAddress(v); err == nil {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
+
+//match the address with the parsed filter
+func matchAddress(filter []common.Address, addr *common.Address) bool {
+	if addr == nil {
+		return false
+	}
+	for _, v := range filter {
+		if v == *addr {
+			return true
+		}
+	}
+	return false
+}
+
 func (self *WsServer) initTlsListen() (net.Listener, error) {
 
 	certPath := cfg.DefConfig.Ws.HttpCertPath
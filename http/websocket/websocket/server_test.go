@@ -0,0 +1,64 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package websocket
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSubscribePendingTx(t *testing.T) {
+	ws := InitWsServer()
+	ws.registryMethod()
+	payer := common.Address{1}
+	contract := common.Address{2}
+	other := common.Address{3}
+
+	resp := ws.ActionMap["subscribe"].handler(map[string]interface{}{
+		"SessionId":              "s1",
+		"SubscribePendingTx":     true,
+		"PayersFilter":           []interface{}{payer.ToBase58(), "invalid"},
+		"PendingContractsFilter": []interface{}{contract.ToHexString()},
+	})
+	sub := resp["Result"].(subscribe)
+	assert.Equal(t, []common.Address{payer}, sub.payers)
+	assert.Equal(t, []common.Address{contract}, sub.pendingContracts)
+
+	assert.True(t, sub.matchPendingTx(payer, &contract))
+	assert.False(t, sub.matchPendingTx(other, &contract))
+	assert.False(t, sub.matchPendingTx(payer, &other))
+	assert.False(t, sub.matchPendingTx(payer, nil))
+
+	// an empty filter matches any address
+	sub = subscribe{SubscribePendingTx: true}
+	assert.True(t, sub.matchPendingTx(other, nil))
+	sub.SubscribePendingTx = false
+	assert.False(t, sub.matchPendingTx(payer, &contract))
+
+	// the filters of only invalid addresses match nothing
+	resp = ws.ActionMap["subscribe"].handler(map[string]interface{}{
+		"SessionId":          "s2",
+		"SubscribePendingTx": true,
+		"PayersFilter":       []interface{}{"invalid"},
+	})
+	sub = resp["Result"].(subscribe)
+	assert.False(t, sub.matchPendingTx(payer, &contract))
+}
@@ -23,6 +23,7 @@ import (
 	"fmt"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"runtime"
 	"strings"
 	"syscall"
@@ -36,8 +37,11 @@ import (
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/consensus"
+	"github.com/dnaproject2/DNA/consensus/fault"
+	"github.com/dnaproject2/DNA/consensus/policy"
 	"github.com/dnaproject2/DNA/core/genesis"
 	"github.com/dnaproject2/DNA/core/ledger"
+	scom "github.com/dnaproject2/DNA/core/store/common"
 	"github.com/dnaproject2/DNA/events"
 	bactor "github.com/dnaproject2/DNA/http/base/actor"
 	hserver "github.com/dnaproject2/DNA/http/base/actor"
@@ -52,6 +56,7 @@ import (
 	"github.com/dnaproject2/DNA/txnpool"
 	tc "github.com/dnaproject2/DNA/txnpool/common"
 	"github.com/dnaproject2/DNA/txnpool/proc"
+	"github.com/dnaproject2/DNA/validator/plugin"
 	"github.com/dnaproject2/DNA/validator/stateful"
 	"github.com/dnaproject2/DNA/validator/stateless"
 	"github.com/ethereum/go-ethereum/common/fdlimit"
@@ -73,6 +78,10 @@ func setupAPP() *cli.App {
 		cmd.ContractCommand,
 		cmd.ImportCommand,
 		cmd.ExportCommand,
+		cmd.RebuildAddressIndexCommand,
+		cmd.SnapshotCommand,
+		cmd.RollbackCommand,
+		cmd.VerifyLedgerCommand,
 		cmd.TxCommond,
 		cmd.SigTxCommand,
 		cmd.MultiSigAddrCommand,
@@ -86,6 +95,12 @@ func setupAPP() *cli.App {
 		utils.LogLevelFlag,
 		utils.DisableLogFileFlag,
 		utils.DisableEventLogFlag,
+		utils.EnableAddressIndexFlag,
+		utils.EnableArchiveFlag,
+		utils.PruneBlocksFlag,
+		utils.RollbackBlocksFlag,
+		utils.DBBackendFlag,
+		utils.LightModeFlag,
 		utils.DataDirFlag,
 		//account setting
 		utils.ExecutorFileFlag,
@@ -98,6 +113,13 @@ func setupAPP() *cli.App {
 		utils.GasPriceFlag,
 		utils.GasLimitFlag,
 		utils.TxpoolPreExecDisableFlag,
+		utils.TxpoolJournalDisableFlag,
+		utils.TxpoolValidatorsFileFlag,
+		utils.TxpoolMaxTxPerPayerFlag,
+		utils.TxpoolMinReplaceBumpFlag,
+		utils.TxpoolMaxTxsFlag,
+		utils.TxpoolMaxBytesFlag,
+		utils.TxpoolTxTTLFlag,
 		utils.DisableSyncVerifyTxFlag,
 		utils.DisableBroadcastNetTxFlag,
 		//p2p setting
@@ -109,6 +131,8 @@ func setupAPP() *cli.App {
 		utils.MaxConnInBoundFlag,
 		utils.MaxConnOutBoundFlag,
 		utils.MaxConnInBoundForSingleIPFlag,
+		utils.SeedAnnounceAddrFlag,
+		utils.FastSyncFlag,
 		//test mode setting
 		utils.EnableTestModeFlag,
 		utils.TestModeGenBlockTimeFlag,
@@ -125,6 +149,8 @@ func setupAPP() *cli.App {
 		utils.WsEnabledFlag,
 		utils.WsPortFlag,
 	}
+	//fault injection setting, only defined by the binary built with the faults tag
+	app.Flags = append(app.Flags, utils.FaultFlags...)
 	app.Before = func(context *cli.Context) error {
 		runtime.GOMAXPROCS(runtime.NumCPU())
 		return nil
@@ -267,12 +293,17 @@ func initLedger(ctx *cli.Context, stateHashHeight uint32) (*ledger.Ledger, error
 	if err != nil {
 		return nil, fmt.Errorf("Init ledger error:%s", err)
 	}
+	policy.InitPolicy()
 
 	log.Infof("Ledger init success")
 	return ledger.DefLedger, nil
 }
 
 func initTxPool(ctx *cli.Context) (*proc.TXPoolServer, error) {
+	//light node doesn't accept transactions
+	if config.DefConfig.Common.LightMode {
+		return nil, nil
+	}
 	disablePreExec := ctx.GlobalBool(utils.GetFlagName(utils.TxpoolPreExecDisableFlag))
 	bactor.DisableSyncVerifyTx = ctx.GlobalBool(utils.GetFlagName(utils.DisableSyncVerifyTxFlag))
 	disableBroadcastNetTx := ctx.GlobalBool(utils.GetFlagName(utils.DisableBroadcastNetTxFlag))
@@ -286,6 +317,26 @@ func initTxPool(ctx *cli.Context) (*proc.TXPoolServer, error) {
 	stlValidator2.Register(txPoolServer.GetPID(tc.VerifyRspActor))
 	stfValidator, _ := stateful.NewValidator("stateful_validator")
 	stfValidator.Register(txPoolServer.GetPID(tc.VerifyRspActor))
+	pluginValidators, err := plugin.NewValidators(config.DefConfig.TxPool.Validators)
+	if err != nil {
+		return nil, fmt.Errorf("Init validator plugins error:%s", err)
+	}
+	for _, v := range pluginValidators {
+		v.Register(txPoolServer.GetPID(tc.VerifyRspActor))
+	}
+
+	// the journal is useless when the ledger is not kept on restart
+	backend, err := scom.GetBackend(config.DefConfig.Common.DBBackend)
+	if err != nil {
+		return nil, err
+	}
+	if !ctx.GlobalBool(utils.GetFlagName(utils.TxpoolJournalDisableFlag)) && !backend.InMemory {
+		dbDir := utils.GetStoreDirPath(config.DefConfig.Common.DataDir, config.DefConfig.P2PNode.NetworkName)
+		err = txPoolServer.LoadJournal(filepath.Join(dbDir, tc.JOURNAL_DIR))
+		if err != nil {
+			return nil, fmt.Errorf("Load txpool journal error:%s", err)
+		}
+	}
 
 	hserver.SetTxnPoolPid(txPoolServer.GetPID(tc.TxPoolActor))
 	hserver.SetTxPid(txPoolServer.GetPID(tc.TxActor))
@@ -301,6 +352,7 @@ func initP2PNode(ctx *cli.Context, txpoolSvr *proc.TXPoolServer, acc *account.Ac
 	p2p := p2pserver.NewServer()
 	if acc != nil {
 		p2p.SetAddr(acc.Address.ToBase58())
+		p2p.SetAccount(acc)
 	}
 
 	p2pActor := p2pactor.NewP2PActor(p2p)
@@ -313,8 +365,10 @@ func initP2PNode(ctx *cli.Context, txpoolSvr *proc.TXPoolServer, acc *account.Ac
 	if err != nil {
 		return nil, nil, fmt.Errorf("p2p service start error %s", err)
 	}
-	netreqactor.SetTxnPoolPid(txpoolSvr.GetPID(tc.TxActor))
-	txpoolSvr.RegisterActor(tc.NetActor, p2pPID)
+	if txpoolSvr != nil {
+		netreqactor.SetTxnPoolPid(txpoolSvr.GetPID(tc.TxActor))
+		txpoolSvr.RegisterActor(tc.NetActor, p2pPID)
+	}
 	hserver.SetNetServerPID(p2pPID)
 	p2p.WaitForPeersStart()
 	log.Infof("P2P init success")
@@ -327,6 +381,12 @@ func initConsensus(ctx *cli.Context, p2pPid *actor.PID, txpoolSvr *proc.TXPoolSe
 	}
 	pool := txpoolSvr.GetPID(tc.TxPoolActor)
 
+	if scenario := config.DefConfig.Consensus.FaultScenario; scenario != "" {
+		if err := fault.Init(scenario); err != nil {
+			return nil, fmt.Errorf("fault.Init error:%s", err)
+		}
+		log.Warnf("Consensus runs with the faults of scenario %s, never do it in production", scenario)
+	}
 	consensusType := strings.ToLower(config.DefConfig.Genesis.ConsensusType)
 	consensusService, err := consensus.NewConsensusService(consensusType, acc, pool, nil, p2pPid)
 	if err != nil {
@@ -369,6 +429,10 @@ func initLocalRpc(ctx *cli.Context) error {
 	if !ctx.GlobalBool(utils.GetFlagName(utils.RPCLocalEnableFlag)) {
 		return nil
 	}
+	if config.DefConfig.Common.LightMode {
+		log.Infof("Local rpc is disabled in light mode")
+		return nil
+	}
 	var err error
 	exitCh := make(chan interface{}, 0)
 	go func() {
@@ -394,6 +458,10 @@ func initRestful(ctx *cli.Context) {
 	if !config.DefConfig.Restful.EnableHttpRestful {
 		return
 	}
+	if config.DefConfig.Common.LightMode {
+		log.Infof("Restful is disabled in light mode")
+		return
+	}
 	go restful.StartServer()
 
 	log.Infof("Restful init success")
@@ -403,6 +471,10 @@ func initWs(ctx *cli.Context) {
 	if !config.DefConfig.Ws.EnableHttpWs {
 		return
 	}
+	if config.DefConfig.Common.LightMode {
+		log.Infof("Ws is disabled in light mode")
+		return
+	}
 	websocket.StartServer()
 
 	log.Infof("Ws init success")
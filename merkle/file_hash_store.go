@@ -32,6 +32,7 @@ type HashStore interface {
 	Flush() error
 	Close()
 	GetHash(pos uint32) (common.Uint256, error)
+	Truncate(tree_size uint32) error
 }
 
 type fileHashStore struct {
@@ -41,7 +42,17 @@ type fileHashStore struct {
 
 // NewFileHashStore returns a HashStore implement in file
 func NewFileHashStore(name string, tree_size uint32) (HashStore, error) {
-	f, err := os.OpenFile(name, os.O_RDWR|os.O_CREATE, 0755)
+	return openFileHashStore(name, os.O_RDWR|os.O_CREATE, tree_size)
+}
+
+// NewReadOnlyFileHashStore returns a HashStore implement in the existing file,
+// which can't be appended
+func NewReadOnlyFileHashStore(name string, tree_size uint32) (HashStore, error) {
+	return openFileHashStore(name, os.O_RDONLY, tree_size)
+}
+
+func openFileHashStore(name string, flag int, tree_size uint32) (HashStore, error) {
+	f, err := os.OpenFile(name, flag, 0755)
 	if err != nil {
 		return nil, err
 	}
@@ -127,6 +138,17 @@ func (self *fileHashStore) GetHash(pos uint32) (common.Uint256, error) {
 	return hash, nil
 }
 
+// Truncate drops the hashes of the leaves after tree_size by moving the write offset back,
+// the stale hashes in file are overwritten by the following appends
+func (self *fileHashStore) Truncate(tree_size uint32) error {
+	if self == nil {
+		return nil
+	}
+	size := getStoredHashNum(tree_size) * int64(common.UINT256_SIZE)
+	_, err := self.file.Seek(size, io.SeekStart)
+	return err
+}
+
 type memHashStore struct {
 	hashes []common.Uint256
 }
@@ -150,3 +172,12 @@ func (self *memHashStore) Flush() error {
 }
 
 func (self *memHashStore) Close() {}
+
+func (self *memHashStore) Truncate(tree_size uint32) error {
+	num := getStoredHashNum(tree_size)
+	if num > int64(len(self.hashes)) {
+		return errors.New("stored hashes are less than expected")
+	}
+	self.hashes = self.hashes[:num]
+	return nil
+}
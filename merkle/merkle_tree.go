@@ -184,6 +184,50 @@ func (self *CompactMerkleTree) AppendHash(leaf common.Uint256) []common.Uint256
 	return auditPath
 }
 
+// Truncate shrinks the merkle tree to the first tree_size leaves, the hashes of the
+// smaller tree are loaded from HashStore
+func (self *CompactMerkleTree) Truncate(tree_size uint32) error {
+	if tree_size > self.treeSize {
+		return fmt.Errorf("truncate size %d is larger than tree size %d", tree_size, self.treeSize)
+	}
+	if tree_size == self.treeSize {
+		return nil
+	}
+	hashes, err := self.SubTreeHashes(tree_size)
+	if err != nil {
+		return err
+	}
+	if err := self.hashStore.Truncate(tree_size); err != nil {
+		return err
+	}
+	self._update(tree_size, hashes)
+	return nil
+}
+
+// SubTreeHashes returns the compact hashes of the merkle tree of the first tree_size leaves,
+// which are loaded from HashStore
+func (self *CompactMerkleTree) SubTreeHashes(tree_size uint32) ([]common.Uint256, error) {
+	if tree_size > self.treeSize {
+		return nil, fmt.Errorf("sub tree size %d is larger than tree size %d", tree_size, self.treeSize)
+	}
+	if tree_size == self.treeSize {
+		return append([]common.Uint256{}, self.hashes...), nil
+	}
+	if self.hashStore == nil {
+		return nil, errors.New("merkle tree has no hash store")
+	}
+	hashespos := getSubTreePos(tree_size)
+	hashes := make([]common.Uint256, len(hashespos))
+	for i, pos := range hashespos {
+		hash, err := self.hashStore.GetHash(pos - 1)
+		if err != nil {
+			return nil, err
+		}
+		hashes[i] = hash
+	}
+	return hashes, nil
+}
+
 func (self *CompactMerkleTree) DumpStatus() {
 	log.Errorf("tree root: %x \n", self.rootHash)
 	log.Errorf("tree size: %d \n", self.treeSize)
@@ -564,3 +608,13 @@ func (self *MerkleVerifier) VerifyConsistency(old_tree_size,
 
 	return nil
 }
+
+// VerifyBlockRootConsistency verifies the consistency proof of the block merkle tree, that is the
+// block root checkpointed at old height is the root of a prefix of the tree whose root is the block
+// root at new height. The block root of height h is the root of the tree of h+1 leaves, and the new
+// block root should be checked with the header of new height by the caller
+func VerifyBlockRootConsistency(oldHeight, newHeight uint32, oldBlockRoot, newBlockRoot common.Uint256,
+	proof []common.Uint256) error {
+	verifier := NewMerkleVerifier()
+	return verifier.VerifyConsistency(oldHeight+1, newHeight+1, oldBlockRoot, newBlockRoot, proof)
+}
@@ -21,6 +21,9 @@ package merkle
 import (
 	"crypto/sha256"
 	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/dnaproject2/DNA/common"
@@ -154,6 +157,68 @@ func TestMerkleRoot(t *testing.T) {
 
 }
 
+func TestMerkleTruncate(t *testing.T) {
+	n := 100
+	roots := make([]common.Uint256, n, n)
+	tree := NewTree(0, nil, NewMemHashStore())
+	for i := 0; i < n; i++ {
+		tree.Append([]byte{byte(i + 1)})
+		roots[i] = tree.Root()
+	}
+
+	if err := tree.Truncate(uint32(n) + 1); err == nil {
+		t.Fatal("truncate to larger size should fail")
+	}
+	for _, size := range []int{64, 37, 1} {
+		if err := tree.Truncate(uint32(size)); err != nil {
+			t.Fatal(err)
+		}
+		if tree.TreeSize() != uint32(size) || tree.Root() != roots[size-1] {
+			t.Fatalf("error merkle root after truncate to %d", size)
+		}
+		for i := size; i < n; i++ {
+			tree.Append([]byte{byte(i + 1)})
+			if tree.Root() != roots[i] {
+				t.Fatalf("error merkle root at %d after truncate to %d", i, size)
+			}
+		}
+	}
+}
+
+func TestReadOnlyFileHashStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merkle")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "merkletree.db")
+
+	// the read only store never creates the file
+	_, err = NewReadOnlyFileHashStore(name, 0)
+	assert.NotNil(t, err)
+	_, err = os.Stat(name)
+	assert.True(t, os.IsNotExist(err))
+
+	store, err := NewFileHashStore(name, 0)
+	assert.Nil(t, err)
+	tree := NewTree(0, nil, store)
+	for i := 0; i < 5; i++ {
+		tree.Append([]byte{byte(i + 1)})
+	}
+	expected, err := tree.InclusionProof(1, 5)
+	assert.Nil(t, err)
+	store.Close()
+
+	readOnly, err := NewReadOnlyFileHashStore(name, tree.TreeSize())
+	assert.Nil(t, err)
+	defer readOnly.Close()
+	loaded := NewTree(tree.TreeSize(), tree.Hashes(), readOnly)
+	proof, err := loaded.InclusionProof(1, 5)
+	assert.Nil(t, err)
+	assert.Equal(t, expected, proof)
+	assert.NotNil(t, readOnly.Append([]common.Uint256{{1}}))
+	_, err = NewReadOnlyFileHashStore(name, tree.TreeSize()+10)
+	assert.NotNil(t, err)
+}
+
 func TestGetSubTreeSize(t *testing.T) {
 	sizes := getSubTreeSize(7)
 	fmt.Println("sub tree size", sizes)
@@ -221,6 +286,43 @@ func TestMerkleConsistencyProof(t *testing.T) {
 	}
 }
 
+func TestVerifyBlockRootConsistency(t *testing.T) {
+	dir, err := ioutil.TempDir("", "merkle")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	store, err := NewFileHashStore(filepath.Join(dir, "merkletree.db"), 0)
+	assert.Nil(t, err)
+	defer store.Close()
+
+	n := uint32(20)
+	roots := make([]common.Uint256, n)
+	tree := NewTree(0, nil, store)
+	for i := uint32(0); i < n; i++ {
+		tree.Append([]byte{byte(i + 1)})
+		roots[i] = tree.Root()
+	}
+
+	// the block root of height h is the root of the tree of h+1 leaves
+	for old := uint32(0); old < n; old++ {
+		for new := old; new < n; new++ {
+			proof := tree.ConsistencyProof(old+1, new+1)
+			assert.Nil(t, VerifyBlockRootConsistency(old, new, roots[old], roots[new], proof))
+			if old == new {
+				continue
+			}
+			oldRoot, newRoot := roots[old], roots[new]
+			oldRoot[0] ^= 1
+			newRoot[0] ^= 1
+			assert.NotNil(t, VerifyBlockRootConsistency(old, new, oldRoot, roots[new], proof))
+			assert.NotNil(t, VerifyBlockRootConsistency(old, new, roots[old], newRoot, proof))
+			if len(proof) != 0 {
+				proof[0][0] ^= 1
+				assert.NotNil(t, VerifyBlockRootConsistency(old, new, roots[old], roots[new], proof))
+			}
+		}
+	}
+}
+
 //~70w
 func BenchmarkMerkleInsert(b *testing.B) {
 	store, _ := NewFileHashStore("merkletree.db", 0)
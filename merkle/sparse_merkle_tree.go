@@ -0,0 +1,393 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/dnaproject2/DNA/common"
+)
+
+// The sparse merkle tree places each key at the path of the sha256 hash of
+// the key. A subtree holding a single key is shortened to its leaf, and an
+// empty subtree hashes to EMPTY_HASH. Leaf node is 0x00 + key hash + value hash,
+// inner node is 0x01 + left hash + right hash, and the node hash is the sha256
+// of the node, so that the root is independent of the order of the updates.
+const (
+	sparseLeafNode  byte = 0
+	sparseInnerNode byte = 1
+
+	SPARSE_NODE_SIZE = 1 + 2*common.UINT256_SIZE
+	SPARSE_MAX_DEPTH = common.UINT256_SIZE * 8
+)
+
+// SparseNodeStore is an interface for persist the nodes of sparse merkle tree
+type SparseNodeStore interface {
+	GetNode(hash common.Uint256) ([]byte, error)
+	PutNode(hash common.Uint256, node []byte)
+}
+
+// SparseMerkleTree updates and proves the sparse merkle trees saved in SparseNodeStore,
+// the nodes are immutable so that every committed root keeps readable
+type SparseMerkleTree struct {
+	store   SparseNodeStore
+	pending map[common.Uint256][]byte
+}
+
+// NewSparseMerkleTree returns a SparseMerkleTree instance
+func NewSparseMerkleTree(store SparseNodeStore) *SparseMerkleTree {
+	return &SparseMerkleTree{
+		store:   store,
+		pending: make(map[common.Uint256][]byte),
+	}
+}
+
+// Update sets the value hash of the key hash in the tree of root and returns
+// the new root, EMPTY_HASH value hash deletes the key. The new nodes are kept
+// in memory until Commit.
+func (self *SparseMerkleTree) Update(root, keyHash, valueHash common.Uint256) (common.Uint256, error) {
+	return self.update(root, keyHash, valueHash, 0)
+}
+
+// Commit persists the pending nodes reachable from root and drops the others
+func (self *SparseMerkleTree) Commit(root common.Uint256) {
+	self.commit(root)
+	self.pending = make(map[common.Uint256][]byte)
+}
+
+// Reset drops the pending nodes
+func (self *SparseMerkleTree) Reset() {
+	self.pending = make(map[common.Uint256][]byte)
+}
+
+// Prove returns the proof of the key hash in the tree of root, which proves
+// the value hash of the key or the absence of the key
+func (self *SparseMerkleTree) Prove(root, keyHash common.Uint256) (*SparseMerkleProof, error) {
+	proof := &SparseMerkleProof{}
+	hash := root
+	for depth := 0; hash != EMPTY_HASH; depth++ {
+		node, err := self.getNode(hash)
+		if err != nil {
+			return nil, err
+		}
+		left, right := decodeSparseNode(node)
+		if node[0] == sparseLeafNode {
+			proof.LeafKey, proof.LeafValue = left, right
+			break
+		}
+		if depth >= SPARSE_MAX_DEPTH {
+			return nil, fmt.Errorf("sparse merkle tree is deeper than %d", SPARSE_MAX_DEPTH)
+		}
+		if sparseKeyBit(keyHash, depth) == 0 {
+			proof.Siblings = append(proof.Siblings, right)
+			hash = left
+		} else {
+			proof.Siblings = append(proof.Siblings, left)
+			hash = right
+		}
+	}
+	return proof, nil
+}
+
+// Get returns the value hash of the key hash in the tree of root, EMPTY_HASH if absent
+func (self *SparseMerkleTree) Get(root, keyHash common.Uint256) (common.Uint256, error) {
+	proof, err := self.Prove(root, keyHash)
+	if err != nil {
+		return EMPTY_HASH, err
+	}
+	if proof.LeafKey != keyHash {
+		return EMPTY_HASH, nil
+	}
+	return proof.LeafValue, nil
+}
+
+// Walk visits the nodes of the tree of root in depth first order, with the value
+// hash for the leaf nodes and EMPTY_HASH for the inner nodes. The children of a
+// node are skipped if visit returns false.
+func (self *SparseMerkleTree) Walk(root common.Uint256, visit func(hash, valueHash common.Uint256) bool) error {
+	if root == EMPTY_HASH {
+		return nil
+	}
+	node, err := self.getNode(root)
+	if err != nil {
+		return err
+	}
+	left, right := decodeSparseNode(node)
+	if node[0] == sparseLeafNode {
+		visit(root, right)
+		return nil
+	}
+	if !visit(root, EMPTY_HASH) {
+		return nil
+	}
+	if err = self.Walk(left, visit); err != nil {
+		return err
+	}
+	return self.Walk(right, visit)
+}
+
+func (self *SparseMerkleTree) update(hash, keyHash, valueHash common.Uint256, depth int) (common.Uint256, error) {
+	if hash == EMPTY_HASH {
+		if valueHash == EMPTY_HASH {
+			return EMPTY_HASH, nil
+		}
+		return self.putNode(sparseLeafNode, keyHash, valueHash), nil
+	}
+	node, err := self.getNode(hash)
+	if err != nil {
+		return EMPTY_HASH, err
+	}
+	left, right := decodeSparseNode(node)
+	if node[0] == sparseLeafNode {
+		if left == keyHash {
+			if valueHash == EMPTY_HASH {
+				return EMPTY_HASH, nil
+			}
+			return self.putNode(sparseLeafNode, keyHash, valueHash), nil
+		}
+		if valueHash == EMPTY_HASH {
+			return hash, nil
+		}
+		leaf := self.putNode(sparseLeafNode, keyHash, valueHash)
+		return self.split(hash, left, leaf, keyHash, depth)
+	}
+
+	if depth >= SPARSE_MAX_DEPTH {
+		return EMPTY_HASH, fmt.Errorf("sparse merkle tree is deeper than %d", SPARSE_MAX_DEPTH)
+	}
+	if sparseKeyBit(keyHash, depth) == 0 {
+		left, err = self.update(left, keyHash, valueHash, depth+1)
+	} else {
+		right, err = self.update(right, keyHash, valueHash, depth+1)
+	}
+	if err != nil {
+		return EMPTY_HASH, err
+	}
+	// a subtree left with a single leaf is shortened to the leaf
+	if left == EMPTY_HASH || right == EMPTY_HASH {
+		child := left
+		if child == EMPTY_HASH {
+			child = right
+		}
+		if child == EMPTY_HASH {
+			return EMPTY_HASH, nil
+		}
+		node, err := self.getNode(child)
+		if err != nil {
+			return EMPTY_HASH, err
+		}
+		if node[0] == sparseLeafNode {
+			return child, nil
+		}
+	}
+	return self.putNode(sparseInnerNode, left, right), nil
+}
+
+// split builds the subtree at depth holding two leaves of different key hashes
+func (self *SparseMerkleTree) split(leafA, keyA, leafB, keyB common.Uint256, depth int) (common.Uint256, error) {
+	if depth >= SPARSE_MAX_DEPTH {
+		return EMPTY_HASH, fmt.Errorf("sparse merkle tree is deeper than %d", SPARSE_MAX_DEPTH)
+	}
+	bitA, bitB := sparseKeyBit(keyA, depth), sparseKeyBit(keyB, depth)
+	if bitA != bitB {
+		if bitA == 0 {
+			return self.putNode(sparseInnerNode, leafA, leafB), nil
+		}
+		return self.putNode(sparseInnerNode, leafB, leafA), nil
+	}
+	child, err := self.split(leafA, keyA, leafB, keyB, depth+1)
+	if err != nil {
+		return EMPTY_HASH, err
+	}
+	if bitA == 0 {
+		return self.putNode(sparseInnerNode, child, EMPTY_HASH), nil
+	}
+	return self.putNode(sparseInnerNode, EMPTY_HASH, child), nil
+}
+
+func (self *SparseMerkleTree) commit(hash common.Uint256) {
+	node, ok := self.pending[hash]
+	if !ok {
+		return
+	}
+	self.store.PutNode(hash, node)
+	delete(self.pending, hash)
+	if node[0] == sparseInnerNode {
+		left, right := decodeSparseNode(node)
+		self.commit(left)
+		self.commit(right)
+	}
+}
+
+func (self *SparseMerkleTree) putNode(kind byte, left, right common.Uint256) common.Uint256 {
+	node := encodeSparseNode(kind, left, right)
+	hash := common.Uint256(sha256.Sum256(node))
+	self.pending[hash] = node
+	return hash
+}
+
+func (self *SparseMerkleTree) getNode(hash common.Uint256) ([]byte, error) {
+	if node, ok := self.pending[hash]; ok {
+		return node, nil
+	}
+	node, err := self.store.GetNode(hash)
+	if err != nil {
+		return nil, fmt.Errorf("get sparse merkle node %s error %s", hash.ToHexString(), err)
+	}
+	if len(node) != SPARSE_NODE_SIZE || node[0] > sparseInnerNode {
+		return nil, fmt.Errorf("invalid sparse merkle node %s", hash.ToHexString())
+	}
+	return node, nil
+}
+
+func encodeSparseNode(kind byte, left, right common.Uint256) []byte {
+	node := make([]byte, 0, SPARSE_NODE_SIZE)
+	node = append(node, kind)
+	node = append(node, left[:]...)
+	return append(node, right[:]...)
+}
+
+func decodeSparseNode(node []byte) (left, right common.Uint256) {
+	copy(left[:], node[1:1+common.UINT256_SIZE])
+	copy(right[:], node[1+common.UINT256_SIZE:])
+	return
+}
+
+func sparseKeyBit(keyHash common.Uint256, depth int) byte {
+	return (keyHash[depth/8] >> uint(7-depth%8)) & 1
+}
+
+func (self TreeHasher) hash_sparse_node(kind byte, left, right common.Uint256) common.Uint256 {
+	return sha256.Sum256(encodeSparseNode(kind, left, right))
+}
+
+// SparseMerkleProof proves the value or the absence of a key in a sparse merkle tree.
+// Siblings are the sibling hashes along the path of the key from the root, and
+// LeafKey and LeafValue are the key hash and value hash of the leaf which ends
+// the path, both EMPTY_HASH if the path ends in an empty subtree.
+type SparseMerkleProof struct {
+	Siblings  []common.Uint256
+	LeafKey   common.Uint256
+	LeafValue common.Uint256
+}
+
+func (self *SparseMerkleProof) Serialization(sink *common.ZeroCopySink) {
+	sink.WriteVarUint(uint64(len(self.Siblings)))
+	for _, sibling := range self.Siblings {
+		sink.WriteHash(sibling)
+	}
+	sink.WriteHash(self.LeafKey)
+	sink.WriteHash(self.LeafValue)
+}
+
+func (self *SparseMerkleProof) Deserialization(source *common.ZeroCopySource) error {
+	count, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return common.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if count > SPARSE_MAX_DEPTH {
+		return fmt.Errorf("too many siblings: %d", count)
+	}
+	self.Siblings = make([]common.Uint256, 0, count)
+	for i := uint64(0); i < count; i++ {
+		sibling, eof := source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+		self.Siblings = append(self.Siblings, sibling)
+	}
+	self.LeafKey, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	self.LeafValue, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// VerifySparseMerkleProof checks the proof that the key has the value in the
+// sparse merkle tree of root, a nil value checks the absence of the key
+func VerifySparseMerkleProof(root common.Uint256, key, value []byte, proof *SparseMerkleProof) error {
+	if len(proof.Siblings) > SPARSE_MAX_DEPTH {
+		return fmt.Errorf("too many siblings: %d", len(proof.Siblings))
+	}
+	hasher := TreeHasher{}
+	keyHash := common.Uint256(sha256.Sum256(key))
+	hash := EMPTY_HASH
+	if value != nil {
+		if proof.LeafKey != keyHash {
+			return errors.New("the proof is not for the key")
+		}
+		if proof.LeafValue != sha256.Sum256(value) {
+			return errors.New("the proof is not for the value")
+		}
+		hash = hasher.hash_sparse_node(sparseLeafNode, proof.LeafKey, proof.LeafValue)
+	} else if proof.LeafKey != EMPTY_HASH {
+		if proof.LeafKey == keyHash {
+			return errors.New("the key exists in the proof")
+		}
+		// the other leaf must be placed on the path of the key
+		for depth := range proof.Siblings {
+			if sparseKeyBit(proof.LeafKey, depth) != sparseKeyBit(keyHash, depth) {
+				return errors.New("the leaf of the proof is off the path of the key")
+			}
+		}
+		hash = hasher.hash_sparse_node(sparseLeafNode, proof.LeafKey, proof.LeafValue)
+	}
+	for depth := len(proof.Siblings) - 1; depth >= 0; depth-- {
+		if sparseKeyBit(keyHash, depth) == 0 {
+			hash = hasher.hash_sparse_node(sparseInnerNode, hash, proof.Siblings[depth])
+		} else {
+			hash = hasher.hash_sparse_node(sparseInnerNode, proof.Siblings[depth], hash)
+		}
+	}
+	if hash != root {
+		return fmt.Errorf("root hash mismatch: expected %s, got %s", root.ToHexString(), hash.ToHexString())
+	}
+	return nil
+}
+
+type memSparseNodeStore struct {
+	nodes map[common.Uint256][]byte
+}
+
+// NewMemSparseNodeStore returns a SparseNodeStore implement in memory
+func NewMemSparseNodeStore() SparseNodeStore {
+	return &memSparseNodeStore{nodes: make(map[common.Uint256][]byte)}
+}
+
+func (self *memSparseNodeStore) GetNode(hash common.Uint256) ([]byte, error) {
+	node, ok := self.nodes[hash]
+	if !ok {
+		return nil, errors.New("node not found")
+	}
+	return node, nil
+}
+
+func (self *memSparseNodeStore) PutNode(hash common.Uint256, node []byte) {
+	self.nodes[hash] = node
+}
@@ -0,0 +1,150 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package merkle
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func sparseUpdate(t *testing.T, tree *SparseMerkleTree, root common.Uint256, key, value []byte) common.Uint256 {
+	valueHash := EMPTY_HASH
+	if value != nil {
+		valueHash = sha256.Sum256(value)
+	}
+	root, err := tree.Update(root, sha256.Sum256(key), valueHash)
+	assert.Nil(t, err)
+	return root
+}
+
+func TestSparseMerkleTreeOrder(t *testing.T) {
+	N := 200
+	tree1 := NewSparseMerkleTree(NewMemSparseNodeStore())
+	tree2 := NewSparseMerkleTree(NewMemSparseNodeStore())
+	root1, root2 := EMPTY_HASH, EMPTY_HASH
+	for i := 0; i < N; i++ {
+		root1 = sparseUpdate(t, tree1, root1, []byte(fmt.Sprint(i)), []byte{byte(i)})
+		root2 = sparseUpdate(t, tree2, root2, []byte(fmt.Sprint(N-1-i)), []byte{byte(N - 1 - i)})
+	}
+	assert.Equal(t, root1, root2)
+
+	// deleting all of the keys empties the tree
+	for i := 0; i < N; i++ {
+		root1 = sparseUpdate(t, tree1, root1, []byte(fmt.Sprint(i)), nil)
+	}
+	assert.Equal(t, EMPTY_HASH, root1)
+}
+
+func TestSparseMerkleTreeDelete(t *testing.T) {
+	tree := NewSparseMerkleTree(NewMemSparseNodeStore())
+	root := EMPTY_HASH
+	for i := 0; i < 10; i++ {
+		root = sparseUpdate(t, tree, root, []byte(fmt.Sprint(i)), []byte{byte(i)})
+	}
+	withoutKey := root
+	root = sparseUpdate(t, tree, root, []byte("key"), []byte("value"))
+	assert.NotEqual(t, withoutKey, root)
+	root = sparseUpdate(t, tree, root, []byte("key"), nil)
+	assert.Equal(t, withoutKey, root)
+}
+
+func TestSparseMerkleProof(t *testing.T) {
+	store := NewMemSparseNodeStore()
+	tree := NewSparseMerkleTree(store)
+	root := EMPTY_HASH
+	for i := 0; i < 100; i++ {
+		root = sparseUpdate(t, tree, root, []byte(fmt.Sprint(i)), []byte{byte(i)})
+	}
+	tree.Commit(root)
+	oldRoot := root
+	root = sparseUpdate(t, tree, root, []byte("1"), []byte("updated"))
+	tree.Commit(root)
+
+	prover := NewSparseMerkleTree(store)
+	for i := 0; i < 100; i++ {
+		key := []byte(fmt.Sprint(i))
+		proof, err := prover.Prove(oldRoot, sha256.Sum256(key))
+		assert.Nil(t, err)
+
+		sink := common.NewZeroCopySink(nil)
+		proof.Serialization(sink)
+		decoded := &SparseMerkleProof{}
+		assert.Nil(t, decoded.Deserialization(common.NewZeroCopySource(sink.Bytes())))
+		assert.Nil(t, VerifySparseMerkleProof(oldRoot, key, []byte{byte(i)}, decoded))
+		assert.NotNil(t, VerifySparseMerkleProof(oldRoot, key, []byte("wrong"), decoded))
+		assert.NotNil(t, VerifySparseMerkleProof(oldRoot, key, nil, decoded))
+	}
+
+	proof, err := prover.Prove(root, sha256.Sum256([]byte("1")))
+	assert.Nil(t, err)
+	assert.Nil(t, VerifySparseMerkleProof(root, []byte("1"), []byte("updated"), proof))
+	assert.NotNil(t, VerifySparseMerkleProof(oldRoot, []byte("1"), []byte("updated"), proof))
+
+	// absence of the keys
+	for i := 100; i < 200; i++ {
+		key := []byte(fmt.Sprint(i))
+		proof, err := prover.Prove(root, sha256.Sum256(key))
+		assert.Nil(t, err)
+		assert.Nil(t, VerifySparseMerkleProof(root, key, nil, proof))
+		assert.NotNil(t, VerifySparseMerkleProof(root, key, []byte{byte(i)}, proof))
+	}
+
+	proof, err = prover.Prove(EMPTY_HASH, sha256.Sum256([]byte("1")))
+	assert.Nil(t, err)
+	assert.Nil(t, VerifySparseMerkleProof(EMPTY_HASH, []byte("1"), nil, proof))
+}
+
+func TestSparseMerkleTreeWalk(t *testing.T) {
+	store := NewMemSparseNodeStore()
+	tree := NewSparseMerkleTree(store)
+	root := EMPTY_HASH
+	values := make(map[common.Uint256]bool)
+	for i := 0; i < 50; i++ {
+		root = sparseUpdate(t, tree, root, []byte(fmt.Sprint(i)), []byte{byte(i)})
+		values[sha256.Sum256([]byte{byte(i)})] = true
+	}
+	tree.Commit(root)
+
+	leaves, inners := 0, 0
+	err := NewSparseMerkleTree(store).Walk(root, func(hash, valueHash common.Uint256) bool {
+		if valueHash == EMPTY_HASH {
+			inners++
+			return true
+		}
+		assert.True(t, values[valueHash])
+		leaves++
+		return true
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 50, leaves)
+	// keys sharing a path prefix are split by inner nodes with an empty child
+	assert.True(t, inners >= 49)
+
+	visited := 0
+	err = NewSparseMerkleTree(store).Walk(root, func(hash, valueHash common.Uint256) bool {
+		visited++
+		return false
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, visited)
+}
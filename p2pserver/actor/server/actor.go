@@ -24,6 +24,7 @@ import (
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/p2pserver"
 	"github.com/dnaproject2/DNA/p2pserver/common"
+	ptypes "github.com/dnaproject2/DNA/p2pserver/message/types"
 	"github.com/ontio/ontology-eventbus/actor"
 )
 
@@ -82,14 +83,27 @@ func (this *P2PActor) Receive(ctx actor.Context) {
 		this.handleGetNodeTypeReq(ctx, msg)
 	case *TransmitConsensusMsgReq:
 		this.handleTransmitConsensusMsgReq(ctx, msg)
+	case *GetTxProofReq:
+		this.handleGetTxProofReq(ctx, msg)
 	case *common.AppendPeerID:
 		this.server.OnAddNode(msg.ID)
 	case *common.RemovePeerID:
 		this.server.OnDelNode(msg.ID)
+	case *common.EstablishedPeerID:
+		this.server.OnEstablishNode(msg.ID)
 	case *common.AppendHeaders:
 		this.server.OnHeaderReceive(msg.FromID, msg.Headers)
 	case *common.AppendBlock:
 		this.server.OnBlockReceive(msg.FromID, msg.BlockSize, msg.Block, msg.MerkleRoot)
+	case *ptypes.SeedAnnounce:
+		this.server.OnSeedAnnounce(msg)
+	case *ptypes.MsgPayload:
+		switch msg.Payload.(type) {
+		case *ptypes.TxProofReq, *ptypes.TxProof:
+			this.server.OnTxProofMsg(msg)
+		default:
+			this.server.OnSnapshotMsg(msg)
+		}
 	default:
 		err := this.server.Xmit(ctx.Message())
 		if nil != err {
@@ -214,3 +228,15 @@ func (this *P2PActor) handleTransmitConsensusMsgReq(ctx actor.Context, req *Tran
 		log.Warnf("[p2p]can`t transmit consensus msg:no valid neighbor peer: %d\n", req.Target)
 	}
 }
+
+//tx proof handler, the fetch doesn't block the actor
+func (this *P2PActor) handleGetTxProofReq(ctx actor.Context, req *GetTxProofReq) {
+	sender, self := ctx.Sender(), ctx.Self()
+	if sender == nil {
+		return
+	}
+	go func() {
+		tx, height, err := this.server.FetchTransaction(req.TxHash)
+		sender.Request(&GetTxProofRsp{Tx: tx, Height: height, Error: err}, self)
+	}()
+}
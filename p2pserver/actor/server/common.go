@@ -19,6 +19,8 @@
 package server
 
 import (
+	"github.com/dnaproject2/DNA/common"
+	ctypes "github.com/dnaproject2/DNA/core/types"
 	types "github.com/dnaproject2/DNA/p2pserver/common"
 	ptypes "github.com/dnaproject2/DNA/p2pserver/message/types"
 )
@@ -113,3 +115,15 @@ type TransmitConsensusMsgReq struct {
 	Target uint64
 	Msg    ptypes.Message
 }
+
+//fetch transaction with merkle proof from full nodes in light mode
+type GetTxProofReq struct {
+	TxHash common.Uint256
+}
+
+//response of the verified transaction
+type GetTxProofRsp struct {
+	Tx     *ctypes.Transaction
+	Height uint32
+	Error  error
+}
@@ -233,6 +233,7 @@ type BlockSyncMgr struct {
 	ledger         *ledger.Ledger                       //ledger
 	lock           sync.RWMutex                         //lock
 	nodeWeights    map[uint64]*NodeWeight               //Map NodeID => NodeStatus, using for getNextNode
+	stateSync      *StateSyncMgr                        //Fast sync of state snapshot
 }
 
 //NewBlockSyncMgr return a BlockSyncMgr instance
@@ -245,6 +246,7 @@ func NewBlockSyncMgr(server *P2PServer) *BlockSyncMgr {
 		ledger:        server.ledger,
 		exitCh:        make(chan interface{}, 1),
 		nodeWeights:   make(map[uint64]*NodeWeight, 0),
+		stateSync:     NewStateSyncMgr(server),
 	}
 }
 
@@ -340,6 +342,11 @@ func (this *BlockSyncMgr) checkTimeout() {
 
 func (this *BlockSyncMgr) sync() {
 	this.syncHeader()
+	//blocks are synced after the state snapshot
+	if this.stateSync.IsSyncing() {
+		this.stateSync.sync()
+		return
+	}
 	this.syncBlock()
 }
 
@@ -358,8 +365,8 @@ func (this *BlockSyncMgr) syncHeader() {
 	curBlockHeight := this.ledger.GetCurrentBlockHeight()
 
 	curHeaderHeight := this.ledger.GetCurrentHeaderHeight()
-	//Waiting for block catch up header
-	if curHeaderHeight-curBlockHeight >= SYNC_MAX_HEADER_FORWARD_SIZE {
+	//Waiting for block catch up header, except that headers are synced for state snapshot
+	if curHeaderHeight-curBlockHeight >= SYNC_MAX_HEADER_FORWARD_SIZE && !this.stateSync.IsSyncing() {
 		return
 	}
 	NextHeaderId := curHeaderHeight + 1
@@ -621,6 +628,9 @@ func (this *BlockSyncMgr) saveBlock() {
 		return
 	}
 	defer this.releaseSaveBlockLock()
+	if this.stateSync.IsSyncing() {
+		return
+	}
 	curBlockHeight := this.ledger.GetCurrentBlockHeight()
 	nextBlockHeight := curBlockHeight + 1
 	this.lock.Lock()
@@ -802,6 +812,10 @@ func (this *BlockSyncMgr) getNextNode(nextBlockHeight uint32) *peer.Peer {
 		if n.GetState() != p2pComm.ESTABLISH {
 			continue
 		}
+		//light node has no block to serve
+		if n.GetServices() == p2pComm.LIGHT_NODE {
+			continue
+		}
 		nodeBlockHeight := n.GetHeight()
 		if nextBlockHeight <= uint32(nodeBlockHeight) {
 			return n
@@ -31,6 +31,7 @@ import (
 const (
 	VERIFY_NODE  = 1 //peer involved in consensus
 	SERVICE_NODE = 2 //peer only sync with consensus peer
+	LIGHT_NODE   = 3 //peer only sync block headers
 )
 
 //link and concurrent const
@@ -103,6 +104,33 @@ const (
 	RECENT_LIMIT     = 10 //recent contact list limit
 )
 
+//seed announce const
+const (
+	SEED_FILE_NAME         = "peers.seeds"
+	SEED_ANNOUNCE_INTERVAL = 600       //time to re-announce self as seed in sec
+	SEED_ANNOUNCE_MAX_SKEW = 600       //max announce timestamp ahead of local time in sec
+	SEED_EXPIRE_TIME       = 86400 * 7 //discovered seed older than the newest one expires in sec
+	SEED_LIMIT             = 64        //discovered seed table limit
+)
+
+//state snapshot const
+const (
+	SNAPSHOT_FILE_NAME      = "snapshot.serve" //snapshot file served to peers in the ledger dir
+	SNAPSHOT_SYNC_FILE      = "snapshot.sync"  //snapshot file downloaded from peers in the ledger dir
+	SNAPSHOT_CHUNK_SIZE     = 1024 * 1024      //byte size of a snapshot chunk
+	SNAPSHOT_MAX_CHUNK_CNT  = 512 * 1024       //the maximum chunk count of a snapshot
+	SNAPSHOT_INTERVAL       = 10000            //block count before the served snapshot is renewed
+	SNAPSHOT_CHECK_INTERVAL = 60               //time to check whether the served snapshot is to renew in sec
+	SNAPSHOT_PEER_REQ_LIMIT = 16               //snapshot and chunk requests served for a peer in a second
+)
+
+//light node const
+const (
+	TX_PROOF_TIMEOUT      = 3  //tx proof request timeout in sec
+	TX_PROOF_PEER_CNT     = 3  //count of peers the tx proof is requested from
+	MAX_TX_PROOF_PATH_LEN = 32 //the maximum merkle path length of tx proof
+)
+
 //PeerAddr represent peer`s net information
 type PeerAddr struct {
 	Time     int64    //latest timestamp
@@ -116,22 +144,29 @@ type PeerAddr struct {
 
 //const channel msg id and type
 const (
-	VERSION_TYPE     = "version"    //peer`s information
-	VERACK_TYPE      = "verack"     //ack msg after version recv
-	GetADDR_TYPE     = "getaddr"    //req nbr address from peer
-	ADDR_TYPE        = "addr"       //nbr address
-	PING_TYPE        = "ping"       //ping  sync height
-	PONG_TYPE        = "pong"       //pong  recv nbr height
-	GET_HEADERS_TYPE = "getheaders" //req blk hdr
-	HEADERS_TYPE     = "headers"    //blk hdr
-	INV_TYPE         = "inv"        //inv payload
-	GET_DATA_TYPE    = "getdata"    //req data from peer
-	BLOCK_TYPE       = "block"      //blk payload
-	TX_TYPE          = "tx"         //transaction
-	CONSENSUS_TYPE   = "consensus"  //consensus payload
-	GET_BLOCKS_TYPE  = "getblocks"  //req blks from peer
-	NOT_FOUND_TYPE   = "notfound"   //peer can`t find blk according to the hash
-	DISCONNECT_TYPE  = "disconnect" //peer disconnect info raise by link
+	VERSION_TYPE       = "version"      //peer`s information
+	VERACK_TYPE        = "verack"       //ack msg after version recv
+	GetADDR_TYPE       = "getaddr"      //req nbr address from peer
+	ADDR_TYPE          = "addr"         //nbr address
+	PING_TYPE          = "ping"         //ping  sync height
+	PONG_TYPE          = "pong"         //pong  recv nbr height
+	GET_HEADERS_TYPE   = "getheaders"   //req blk hdr
+	HEADERS_TYPE       = "headers"      //blk hdr
+	INV_TYPE           = "inv"          //inv payload
+	GET_DATA_TYPE      = "getdata"      //req data from peer
+	BLOCK_TYPE         = "block"        //blk payload
+	TX_TYPE            = "tx"           //transaction
+	CONSENSUS_TYPE     = "consensus"    //consensus payload
+	GET_BLOCKS_TYPE    = "getblocks"    //req blks from peer
+	NOT_FOUND_TYPE     = "notfound"     //peer can`t find blk according to the hash
+	DISCONNECT_TYPE    = "disconnect"   //peer disconnect info raise by link
+	SEED_ANNOUNCE_TYPE = "seedannounce" //signed seed address broadcast
+	GET_SNAPSHOT_TYPE  = "getsnapshot"  //req state snapshot info
+	SNAPSHOT_TYPE      = "snapshot"     //state snapshot info
+	GET_CHUNK_TYPE     = "getchunk"     //req state snapshot chunk
+	CHUNK_TYPE         = "chunk"        //state snapshot chunk
+	GET_TX_PROOF_TYPE  = "gettxproof"   //req tx with merkle proof
+	TX_PROOF_TYPE      = "txproof"      //tx with merkle proof
 )
 
 type AppendPeerID struct {
@@ -142,6 +177,10 @@ type RemovePeerID struct {
 	ID uint64 // The peer id
 }
 
+type EstablishedPeerID struct {
+	ID uint64 // The peer id
+}
+
 type AppendHeaders struct {
 	FromID  uint64          // The peer id
 	Headers []*types.Header // Headers to be added to the ledger
@@ -24,6 +24,7 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/signature"
 	ct "github.com/dnaproject2/DNA/core/types"
 	msgCommon "github.com/dnaproject2/DNA/p2pserver/common"
 	mt "github.com/dnaproject2/DNA/p2pserver/message/types"
@@ -130,6 +131,24 @@ func NewPongMsg(height uint64) *mt.Pong {
 	return &pong
 }
 
+//seed announce package of the network, signed by the seed's key
+func NewSeedAnnounce(addr string, netID uint32, signer signature.Signer) (*mt.SeedAnnounce, error) {
+	log.Trace()
+	announce := &mt.SeedAnnounce{
+		Addr:      addr,
+		Timestamp: uint32(time.Now().Unix()),
+		NetID:     netID,
+		PubKey:    signer.PubKey(),
+	}
+	sig, err := signature.Sign(signer, announce.GetSignData())
+	if err != nil {
+		return nil, err
+	}
+	announce.Signature = sig
+
+	return announce, nil
+}
+
 //Transaction package
 func NewTxn(txn *ct.Transaction) mt.Message {
 	log.Trace()
@@ -179,6 +179,20 @@ func MakeEmptyMessage(cmdType string) (Message, error) {
 		return &Disconnected{}, nil
 	case common.GET_BLOCKS_TYPE:
 		return &BlocksReq{}, nil
+	case common.SEED_ANNOUNCE_TYPE:
+		return &SeedAnnounce{}, nil
+	case common.GET_SNAPSHOT_TYPE:
+		return &SnapshotReq{}, nil
+	case common.SNAPSHOT_TYPE:
+		return &Snapshot{}, nil
+	case common.GET_CHUNK_TYPE:
+		return &ChunkReq{}, nil
+	case common.CHUNK_TYPE:
+		return &Chunk{}, nil
+	case common.GET_TX_PROOF_TYPE:
+		return &TxProofReq{}, nil
+	case common.TX_PROOF_TYPE:
+		return &TxProof{}, nil
 	default:
 		return nil, errors.New("unsupported cmd type:" + cmdType)
 	}
@@ -0,0 +1,114 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/signature"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+// SeedAnnounce is broadcast by a seed node to publish its address,
+// signed by the seed's key
+type SeedAnnounce struct {
+	Addr      string // seed address, host:port
+	Timestamp uint32 // announce time, a newer announce replaces the old one
+	NetID     uint32 // network magic of the seed, so the announce is not replayed to other networks
+	PubKey    keypair.PublicKey
+	Signature []byte
+}
+
+// Serialize message payload
+func (this *SeedAnnounce) Serialization(sink *comm.ZeroCopySink) {
+	this.serializationUnsigned(sink)
+	sink.WriteVarBytes(keypair.SerializePublicKey(this.PubKey))
+	sink.WriteVarBytes(this.Signature)
+}
+
+func (this *SeedAnnounce) serializationUnsigned(sink *comm.ZeroCopySink) {
+	sink.WriteString(this.Addr)
+	sink.WriteUint32(this.Timestamp)
+	sink.WriteUint32(this.NetID)
+}
+
+func (this *SeedAnnounce) CmdType() string {
+	return common.SEED_ANNOUNCE_TYPE
+}
+
+// Deserialize message payload
+func (this *SeedAnnounce) Deserialization(source *comm.ZeroCopySource) error {
+	var irregular, eof bool
+	this.Addr, _, irregular, eof = source.NextString()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	this.Timestamp, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	this.NetID, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	buf, _, irregular, eof := source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	pubKey, err := keypair.DeserializePublicKey(buf)
+	if err != nil {
+		return err
+	}
+	this.PubKey = pubKey
+
+	this.Signature, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+
+	return nil
+}
+
+// GetSignData returns the unsigned data of the announce
+func (this *SeedAnnounce) GetSignData() []byte {
+	sink := comm.NewZeroCopySink(nil)
+	this.serializationUnsigned(sink)
+	return sink.Bytes()
+}
+
+// Verify checks the signature of the announce
+func (this *SeedAnnounce) Verify() error {
+	if this.PubKey == nil {
+		return errors.New("seed announce without public key")
+	}
+	return signature.Verify(this.PubKey, this.GetSignData(), this.Signature)
+}
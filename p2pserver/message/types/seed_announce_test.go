@@ -0,0 +1,49 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"testing"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/core/signature"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedAnnounceSerializationDeserialization(t *testing.T) {
+	acc := account.NewAccount("")
+	msg := &SeedAnnounce{
+		Addr:      "seed1.dna.io:20338",
+		Timestamp: 12345678,
+		NetID:     1,
+		PubKey:    acc.PublicKey,
+	}
+	sig, err := signature.Sign(acc, msg.GetSignData())
+	assert.Nil(t, err)
+	msg.Signature = sig
+	assert.Nil(t, msg.Verify())
+
+	MessageTest(t, msg)
+
+	msg.NetID = 2
+	assert.NotNil(t, msg.Verify())
+	msg.NetID = 1
+	msg.Addr = "seed2.dna.io:20338"
+	assert.NotNil(t, msg.Verify())
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+)
+
+// SnapshotReq requests the state snapshot served by peer if Height is 0,
+// or the state roots of the block height otherwise
+type SnapshotReq struct {
+	Height uint32
+}
+
+// Serialize message payload
+func (this *SnapshotReq) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+}
+
+func (this *SnapshotReq) CmdType() string {
+	return common.GET_SNAPSHOT_TYPE
+}
+
+// Deserialize message payload
+func (this *SnapshotReq) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Snapshot describes the state snapshot of a block height. Size and ChunkHashes are
+// empty if the peer doesn't serve the snapshot of the height, and Height is 0 if the
+// peer has no state of the requested height
+type Snapshot struct {
+	Height          uint32
+	BlockHash       comm.Uint256
+	StateRoot       comm.Uint256
+	StateMerkleRoot comm.Uint256
+	Size            uint64
+	ChunkHashes     []comm.Uint256
+}
+
+// Serialize message payload
+func (this *Snapshot) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteHash(this.BlockHash)
+	sink.WriteHash(this.StateRoot)
+	sink.WriteHash(this.StateMerkleRoot)
+	sink.WriteUint64(this.Size)
+	sink.WriteVarUint(uint64(len(this.ChunkHashes)))
+	for _, hash := range this.ChunkHashes {
+		sink.WriteHash(hash)
+	}
+}
+
+func (this *Snapshot) CmdType() string {
+	return common.SNAPSHOT_TYPE
+}
+
+// Deserialize message payload
+func (this *Snapshot) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.BlockHash, eof = source.NextHash()
+	this.StateRoot, eof = source.NextHash()
+	this.StateMerkleRoot, eof = source.NextHash()
+	this.Size, eof = source.NextUint64()
+	count, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if count > common.SNAPSHOT_MAX_CHUNK_CNT {
+		return errors.New("too many snapshot chunks")
+	}
+	if (this.Size+common.SNAPSHOT_CHUNK_SIZE-1)/common.SNAPSHOT_CHUNK_SIZE != count {
+		return errors.New("snapshot chunk count mismatches the size")
+	}
+	this.ChunkHashes = make([]comm.Uint256, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hash, eof := source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+		this.ChunkHashes = append(this.ChunkHashes, hash)
+	}
+	return nil
+}
+
+// ChunkReq requests a chunk of the state snapshot served by peer
+type ChunkReq struct {
+	Height uint32
+	Index  uint32
+}
+
+// Serialize message payload
+func (this *ChunkReq) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteUint32(this.Index)
+}
+
+func (this *ChunkReq) CmdType() string {
+	return common.GET_CHUNK_TYPE
+}
+
+// Deserialize message payload
+func (this *ChunkReq) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.Height, eof = source.NextUint32()
+	this.Index, eof = source.NextUint32()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// Chunk is a chunk of the state snapshot, verified by the chunk hash in Snapshot
+type Chunk struct {
+	Height uint32
+	Index  uint32
+	Data   []byte
+}
+
+// Serialize message payload
+func (this *Chunk) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteUint32(this.Height)
+	sink.WriteUint32(this.Index)
+	sink.WriteVarBytes(this.Data)
+}
+
+func (this *Chunk) CmdType() string {
+	return common.CHUNK_TYPE
+}
+
+// Deserialize message payload
+func (this *Chunk) Deserialization(source *comm.ZeroCopySource) error {
+	var eof, irregular bool
+	this.Height, eof = source.NextUint32()
+	this.Index, eof = source.NextUint32()
+	this.Data, _, irregular, eof = source.NextVarBytes()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotReqSerializationDeserialization(t *testing.T) {
+	MessageTest(t, &SnapshotReq{Height: 10000})
+}
+
+func TestSnapshotSerializationDeserialization(t *testing.T) {
+	msg := &Snapshot{
+		Height:          10000,
+		BlockHash:       sha256.Sum256([]byte("block")),
+		StateRoot:       sha256.Sum256([]byte("state")),
+		StateMerkleRoot: sha256.Sum256([]byte("merkle")),
+		Size:            common.SNAPSHOT_CHUNK_SIZE + 1,
+		ChunkHashes:     []comm.Uint256{sha256.Sum256([]byte("chunk0")), sha256.Sum256([]byte("chunk1"))},
+	}
+	MessageTest(t, msg)
+
+	msg.Size = common.SNAPSHOT_CHUNK_SIZE
+	sink := comm.NewZeroCopySink(nil)
+	msg.Serialization(sink)
+	assert.NotNil(t, (&Snapshot{}).Deserialization(comm.NewZeroCopySource(sink.Bytes())))
+}
+
+func TestChunkSerializationDeserialization(t *testing.T) {
+	MessageTest(t, &ChunkReq{Height: 10000, Index: 3})
+	MessageTest(t, &Chunk{Height: 10000, Index: 3, Data: []byte("chunk data")})
+}
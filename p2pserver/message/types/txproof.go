@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"errors"
+	"io"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+)
+
+// TxProofReq requests a transaction with its merkle proof from a full node
+type TxProofReq struct {
+	TxHash comm.Uint256
+}
+
+// Serialize message payload
+func (this *TxProofReq) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteHash(this.TxHash)
+}
+
+func (this *TxProofReq) CmdType() string {
+	return common.GET_TX_PROOF_TYPE
+}
+
+// Deserialize message payload
+func (this *TxProofReq) Deserialization(source *comm.ZeroCopySource) error {
+	var eof bool
+	this.TxHash, eof = source.NextHash()
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
+
+// TxProof is the transaction with the merkle path to the transactions root of the
+// block header of Height. Tx is nil if the peer doesn't have the transaction
+type TxProof struct {
+	TxHash comm.Uint256
+	Tx     *types.Transaction
+	Height uint32
+	Index  uint32
+	Path   []comm.Uint256
+}
+
+// Serialize message payload
+func (this *TxProof) Serialization(sink *comm.ZeroCopySink) {
+	sink.WriteHash(this.TxHash)
+	sink.WriteBool(this.Tx != nil)
+	if this.Tx == nil {
+		return
+	}
+	this.Tx.Serialization(sink)
+	sink.WriteUint32(this.Height)
+	sink.WriteUint32(this.Index)
+	sink.WriteVarUint(uint64(len(this.Path)))
+	for _, hash := range this.Path {
+		sink.WriteHash(hash)
+	}
+}
+
+func (this *TxProof) CmdType() string {
+	return common.TX_PROOF_TYPE
+}
+
+// Deserialize message payload
+func (this *TxProof) Deserialization(source *comm.ZeroCopySource) error {
+	var eof, irregular, found bool
+	this.TxHash, eof = source.NextHash()
+	found, irregular, eof = source.NextBool()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if !found {
+		return nil
+	}
+	tx := &types.Transaction{}
+	err := tx.Deserialization(source)
+	if err != nil {
+		return err
+	}
+	this.Tx = tx
+	this.Height, eof = source.NextUint32()
+	this.Index, eof = source.NextUint32()
+	count, _, irregular, eof := source.NextVarUint()
+	if irregular {
+		return comm.ErrIrregularData
+	}
+	if eof {
+		return io.ErrUnexpectedEOF
+	}
+	if count > common.MAX_TX_PROOF_PATH_LEN {
+		return errors.New("tx proof path too long")
+	}
+	this.Path = make([]comm.Uint256, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hash, eof := source.NextHash()
+		if eof {
+			return io.ErrUnexpectedEOF
+		}
+		this.Path = append(this.Path, hash)
+	}
+	return nil
+}
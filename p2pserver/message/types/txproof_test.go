@@ -0,0 +1,51 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package types
+
+import (
+	"crypto/sha256"
+	"testing"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/payload"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxProofSerializationDeserialization(t *testing.T) {
+	MessageTest(t, &TxProofReq{TxHash: sha256.Sum256([]byte("tx"))})
+
+	mutable := &types.MutableTransaction{
+		TxType:   types.Invoke,
+		Nonce:    1,
+		GasLimit: 20000,
+		Payload:  &payload.InvokeCode{Code: []byte{0x51}},
+		Sigs:     []types.Sig{},
+	}
+	tx, err := mutable.IntoImmutable()
+	assert.Nil(t, err)
+	MessageTest(t, &TxProof{
+		TxHash: tx.Hash(),
+		Tx:     tx,
+		Height: 100,
+		Index:  2,
+		Path:   []comm.Uint256{sha256.Sum256([]byte("left")), sha256.Sum256([]byte("right"))},
+	})
+	MessageTest(t, &TxProof{TxHash: tx.Hash()})
+}
@@ -182,7 +182,7 @@ func BlkHeaderHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID,
 func BlockHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
 	log.Trace("[p2p]receive block message from ", data.Addr, data.Id)
 
-	if pid != nil {
+	if pid != nil && !config.DefConfig.Common.LightMode {
 		var block = data.Payload.(*msgTypes.Block)
 		stateHashHeight := config.GetStateHashCheckHeight(config.DefConfig.P2PNode.NetworkId)
 		if block.Blk.Header.Height >= stateHashHeight && block.MerkleRoot == common.UINT256_EMPTY {
@@ -229,6 +229,10 @@ func NotFoundHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, a
 // TransactionHandle handles the transaction message from peer
 func TransactionHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
 	log.Trace("[p2p]receive transaction message", data.Addr, data.Id)
+	//light node has no tx pool
+	if config.DefConfig.Common.LightMode {
+		return
+	}
 
 	var trn = data.Payload.(*msgTypes.Trn)
 
@@ -481,6 +485,11 @@ func VerAckHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, arg
 	msg := msgpack.NewAddrReq()
 	go p2p.Send(remotePeer, msg)
 
+	if pid != nil {
+		pid.Tell(&msgCommon.EstablishedPeerID{
+			ID: data.Id,
+		})
+	}
 }
 
 // AddrHandle handles the neighbor address response message from peer
@@ -516,6 +525,38 @@ func AddrHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args
 	}
 }
 
+// SeedAnnounceHandle handles the seed announce from peer
+func SeedAnnounceHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
+	log.Trace("[p2p]receive seed announce message", data.Addr, data.Id)
+
+	var announce = data.Payload.(*msgTypes.SeedAnnounce)
+	if err := announce.Verify(); err != nil {
+		log.Warnf("[p2p]seed announce %s from %s verify failed: %s", announce.Addr, data.Addr, err)
+		return
+	}
+	if pid != nil {
+		pid.Tell(announce)
+	}
+}
+
+// SnapshotHandle handles the state snapshot request and response from peer
+func SnapshotHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
+	log.Trace("[p2p]receive state snapshot message", data.Addr, data.Id)
+
+	if pid != nil {
+		pid.Tell(data)
+	}
+}
+
+// TxProofHandle handles the tx proof request from light node and the response from full node
+func TxProofHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
+	log.Trace("[p2p]receive tx proof message", data.Addr, data.Id)
+
+	if pid != nil {
+		pid.Tell(data)
+	}
+}
+
 // DataReqHandle handles the data req(block/Transaction) from peer
 func DataReqHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
 	log.Trace("[p2p]receive data req message", data.Addr, data.Id)
@@ -600,6 +641,10 @@ func DataReqHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, ar
 // transaction and consensus) from peer.
 func InvHandle(data *msgTypes.MsgPayload, p2p p2p.P2P, pid *evtActor.PID, args ...interface{}) {
 	log.Trace("[p2p]receive inv message", data.Addr, data.Id)
+	//light node only syncs block headers
+	if config.DefConfig.Common.LightMode {
+		return
+	}
 	var inv = data.Payload.(*msgTypes.Inv)
 
 	remotePeer := p2p.GetPeer(data.Id)
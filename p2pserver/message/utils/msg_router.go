@@ -69,6 +69,13 @@ func (this *MessageRouter) init(p2p p2p.P2P) {
 	this.RegisterMsgHandler(msgCommon.NOT_FOUND_TYPE, NotFoundHandle)
 	this.RegisterMsgHandler(msgCommon.TX_TYPE, TransactionHandle)
 	this.RegisterMsgHandler(msgCommon.DISCONNECT_TYPE, DisconnectHandle)
+	this.RegisterMsgHandler(msgCommon.SEED_ANNOUNCE_TYPE, SeedAnnounceHandle)
+	this.RegisterMsgHandler(msgCommon.GET_SNAPSHOT_TYPE, SnapshotHandle)
+	this.RegisterMsgHandler(msgCommon.SNAPSHOT_TYPE, SnapshotHandle)
+	this.RegisterMsgHandler(msgCommon.GET_CHUNK_TYPE, SnapshotHandle)
+	this.RegisterMsgHandler(msgCommon.CHUNK_TYPE, SnapshotHandle)
+	this.RegisterMsgHandler(msgCommon.GET_TX_PROOF_TYPE, TxProofHandle)
+	this.RegisterMsgHandler(msgCommon.TX_PROOF_TYPE, TxProofHandle)
 }
 
 // RegisterMsgHandler registers msg handler with the msg type
@@ -95,6 +95,8 @@ func (this *NetServer) init() error {
 
 	if config.DefConfig.Consensus.EnableConsensus {
 		this.base.SetServices(uint64(common.VERIFY_NODE))
+	} else if config.DefConfig.Common.LightMode {
+		this.base.SetServices(uint64(common.LIGHT_NODE))
 	} else {
 		this.base.SetServices(uint64(common.SERVICE_NODE))
 	}
@@ -19,18 +19,21 @@
 package p2pserver
 
 import (
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"io/ioutil"
 	"math/rand"
 	"net"
 	"os"
+	"path/filepath"
 	"reflect"
 	"strconv"
 	"strings"
 	"sync"
 	"time"
 
+	"github.com/dnaproject2/DNA/account"
 	comm "github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
@@ -43,6 +46,7 @@ import (
 	"github.com/dnaproject2/DNA/p2pserver/net/netserver"
 	p2pnet "github.com/dnaproject2/DNA/p2pserver/net/protocol"
 	"github.com/dnaproject2/DNA/p2pserver/peer"
+	"github.com/ontio/ontology-crypto/keypair"
 	evtActor "github.com/ontio/ontology-eventbus/actor"
 )
 
@@ -52,9 +56,15 @@ type P2PServer struct {
 	msgRouter *utils.MessageRouter
 	pid       *evtActor.PID
 	blockSync *BlockSyncMgr
+	snapshots *snapshotServer
+	txFetch   *txFetcher
 	ledger    *ledger.Ledger
 	ReconnectAddrs
 	recentPeers    map[uint32][]string
+	seeds          *seedTable
+	seedRetry      int
+	lastAnnounce   time.Time
+	account        *account.Account
 	quitSyncRecent chan bool
 	quitOnline     chan bool
 	quitHeartBeat  chan bool
@@ -77,7 +87,10 @@ func NewServer() *P2PServer {
 
 	p.msgRouter = utils.NewMsgRouter(p.network)
 	p.blockSync = NewBlockSyncMgr(p)
+	p.snapshots = newSnapshotServer(p.ledger)
+	p.txFetch = newTxFetcher(p)
 	p.recentPeers = make(map[uint32][]string)
+	p.seeds = newSeedTable()
 	p.quitSyncRecent = make(chan bool)
 	p.quitOnline = make(chan bool)
 	p.quitHeartBeat = make(chan bool)
@@ -102,11 +115,15 @@ func (this *P2PServer) Start() error {
 		return errors.New("[p2p]msg router invalid")
 	}
 	this.tryRecentPeers()
+	this.loadSeeds()
 	go this.connectSeedService()
 	go this.syncUpRecentPeers()
 	go this.keepOnlineService()
 	go this.heartBeatService()
 	go this.blockSync.Start()
+	if !config.DefConfig.Common.LightMode {
+		go this.snapshots.start()
+	}
 	return nil
 }
 
@@ -118,6 +135,9 @@ func (this *P2PServer) Stop() {
 	this.quitHeartBeat <- true
 	this.msgRouter.Stop()
 	this.blockSync.Close()
+	if !config.DefConfig.Common.LightMode {
+		this.snapshots.stop()
+	}
 }
 
 // GetNetWork returns the low level netserver
@@ -189,6 +209,11 @@ func (this *P2PServer) OnAddNode(id uint64) {
 	this.blockSync.OnAddNode(id)
 }
 
+// OnEstablishNode sends the discovered seeds to the new neighbor
+func (this *P2PServer) OnEstablishNode(id uint64) {
+	this.sendSeeds(id)
+}
+
 // OnDelNode removes the peer id from the block sync mgr
 func (this *P2PServer) OnDelNode(id uint64) {
 	this.blockSync.OnDelNode(id)
@@ -205,6 +230,102 @@ func (this *P2PServer) OnBlockReceive(fromID uint64, blockSize uint32,
 	this.blockSync.OnBlockReceive(fromID, blockSize, block, merkleRoot)
 }
 
+// OnSnapshotMsg serves the state snapshot requests of peer, and passes the
+// responses to the state sync
+func (this *P2PServer) OnSnapshotMsg(data *msgtypes.MsgPayload) {
+	switch msg := data.Payload.(type) {
+	case *msgtypes.SnapshotReq:
+		if !config.DefConfig.Common.LightMode && this.snapshots.allow(data.Id) {
+			go this.sendSnapshot(data.Id, msg.Height)
+		}
+	case *msgtypes.ChunkReq:
+		if !config.DefConfig.Common.LightMode && this.snapshots.allow(data.Id) {
+			go this.sendChunk(data.Id, msg.Height, msg.Index)
+		}
+	case *msgtypes.Snapshot:
+		this.blockSync.stateSync.OnSnapshotReceive(data.Id, msg)
+	case *msgtypes.Chunk:
+		this.blockSync.stateSync.OnChunkReceive(data.Id, msg)
+	}
+}
+
+//sendSnapshot send the served snapshot if height is 0, or the state roots of the height
+func (this *P2PServer) sendSnapshot(id uint64, height uint32) {
+	p := this.network.GetPeer(id)
+	if p == nil {
+		return
+	}
+	snapshot := &msgtypes.Snapshot{}
+	if height == 0 {
+		if served := this.snapshots.getSnapshot(); served != nil {
+			snapshot = served
+		}
+	} else if info, err := this.ledger.GetSnapshotInfo(height); err == nil {
+		snapshot.Height = info.Height
+		snapshot.BlockHash = info.BlockHash
+		snapshot.StateRoot = info.StateRoot
+		snapshot.StateMerkleRoot = info.StateMerkleRoot
+	}
+	if err := this.Send(p, snapshot, false); err != nil {
+		log.Warn(err)
+	}
+}
+
+//sendChunk send the chunk of the served snapshot
+func (this *P2PServer) sendChunk(id uint64, height, index uint32) {
+	p := this.network.GetPeer(id)
+	if p == nil {
+		return
+	}
+	data, err := this.snapshots.readChunk(height, index)
+	if err != nil {
+		log.Debugf("[p2p]read snapshot chunk error:%s", err)
+		return
+	}
+	msg := &msgtypes.Chunk{Height: height, Index: index, Data: data}
+	if err = this.Send(p, msg, false); err != nil {
+		log.Warn(err)
+	}
+}
+
+// OnTxProofMsg serves the tx proof requests of light node, and passes the
+// responses to the tx fetcher
+func (this *P2PServer) OnTxProofMsg(data *msgtypes.MsgPayload) {
+	switch msg := data.Payload.(type) {
+	case *msgtypes.TxProofReq:
+		if !config.DefConfig.Common.LightMode {
+			go this.sendTxProof(data.Id, msg.TxHash)
+		}
+	case *msgtypes.TxProof:
+		this.txFetch.onTxProofReceive(data.Id, msg)
+	}
+}
+
+//sendTxProof send the transaction with merkle proof, or an empty proof if not found
+func (this *P2PServer) sendTxProof(id uint64, txHash comm.Uint256) {
+	p := this.network.GetPeer(id)
+	if p == nil {
+		return
+	}
+	msg := &msgtypes.TxProof{TxHash: txHash}
+	tx, proof, err := this.ledger.GetTransactionProof(txHash)
+	if err == nil {
+		msg.Tx = tx
+		msg.Height = proof.Height
+		msg.Index = proof.Index
+		msg.Path = proof.Path
+	}
+	if err = this.Send(p, msg, false); err != nil {
+		log.Warn(err)
+	}
+}
+
+// FetchTransaction fetches the transaction with merkle proof from full nodes in light mode,
+// and returns it with the block height after verified by the block header
+func (this *P2PServer) FetchTransaction(txHash comm.Uint256) (*types.Transaction, uint32, error) {
+	return this.txFetch.fetch(txHash)
+}
+
 // Todo: remove it if no use
 func (this *P2PServer) GetConnectionState() uint32 {
 	return common.INIT
@@ -277,10 +398,10 @@ func (this *P2PServer) WaitForPeersStart() {
 	}
 }
 
-//connectSeeds connect the seeds in seedlist and call for nbr list
-func (this *P2PServer) connectSeeds() {
-	seedNodes := make([]string, 0)
-	for _, n := range config.DefConfig.Genesis.SeedList {
+//resolveSeeds resolve the host of seed addresses
+func resolveSeeds(addrs []string) []string {
+	seedNodes := make([]string, 0, len(addrs))
+	for _, n := range addrs {
 		ip, err := common.ParseIPAddr(n)
 		if err != nil {
 			log.Warnf("[p2p]seed peer %s address format is wrong", n)
@@ -298,6 +419,13 @@ func (this *P2PServer) connectSeeds() {
 		}
 		seedNodes = append(seedNodes, ns[0]+port)
 	}
+	return seedNodes
+}
+
+//connectSeeds connect the seeds in seedlist and call for nbr list,
+//fall back to the discovered seeds if the seedlist is unreachable
+func (this *P2PServer) connectSeeds() {
+	seedNodes := resolveSeeds(config.DefConfig.Genesis.SeedList)
 
 	connPeers := make(map[string]*peer.Peer)
 	np := this.network.GetNp()
@@ -328,6 +456,7 @@ func (this *P2PServer) connectSeeds() {
 	}
 
 	if len(seedConnList) > 0 {
+		this.seedRetry = 0
 		rand.Seed(time.Now().UnixNano())
 		index := rand.Intn(len(seedConnList))
 		this.reqNbrList(seedConnList[index])
@@ -339,6 +468,32 @@ func (this *P2PServer) connectSeeds() {
 		for _, nodeAddr := range seedNodes {
 			go this.network.Connect(nodeAddr)
 		}
+		//the configured seeds failed in last round, try the discovered seeds
+		if this.seedRetry > 0 || len(seedNodes) == 0 {
+			this.connectDiscoveredSeeds(connPeers)
+		}
+		this.seedRetry++
+	}
+}
+
+//connectDiscoveredSeeds connect the seeds learned from seed announce
+func (this *P2PServer) connectDiscoveredSeeds(connPeers map[string]*peer.Peer) {
+	seedConnList := make([]*peer.Peer, 0)
+	for _, nodeAddr := range resolveSeeds(this.seeds.addrs()) {
+		if this.network.IsOwnAddress(nodeAddr) {
+			continue
+		}
+		if p, ok := connPeers[nodeAddr]; ok {
+			seedConnList = append(seedConnList, p)
+			continue
+		}
+		log.Debugf("[p2p]connect discovered seed %s", nodeAddr)
+		go this.network.Connect(nodeAddr)
+	}
+	if len(seedConnList) > 0 {
+		rand.Seed(time.Now().UnixNano())
+		index := rand.Intn(len(seedConnList))
+		this.reqNbrList(seedConnList[index])
 	}
 }
 
@@ -359,6 +514,8 @@ func (this *P2PServer) reachMinConnection() bool {
 		minCount = config.SOLO_MIN_NODE_NUM
 	case "vbft":
 		minCount = config.VBFT_MIN_NODE_NUM
+	case "sbft":
+		minCount = config.SBFT_MIN_NODE_NUM
 
 	}
 	return int(this.GetConnectionCnt())+1 >= minCount
@@ -446,6 +603,7 @@ func (this *P2PServer) connectSeedService() {
 		select {
 		case <-t.C:
 			this.connectSeeds()
+			this.announceSeed()
 			t.Stop()
 			if this.reachMinConnection() {
 				t.Reset(time.Second * time.Duration(10*common.CONN_MONITOR))
@@ -659,6 +817,114 @@ func (this *P2PServer) syncPeerAddr() {
 	}
 }
 
+//getSeedFile return the file of discovered seeds in the data dir of the network
+func getSeedFile() string {
+	return filepath.Join(getLedgerDir(), common.SEED_FILE_NAME)
+}
+
+//loadSeeds load the discovered seeds persisted last time
+func (this *P2PServer) loadSeeds() {
+	netID := config.DefConfig.P2PNode.NetworkMagic
+	if err := this.seeds.load(getSeedFile(), netID, isTrustedSeedKey); err != nil {
+		log.Warnf("[p2p]load seeds from %s fail: %s", getSeedFile(), err)
+	}
+}
+
+//isTrustedSeedKey check whether the key is allowed to announce seed
+func isTrustedSeedKey(pubKey keypair.PublicKey) bool {
+	keys := config.DefConfig.Genesis.SeedPubKeys
+	if len(keys) == 0 {
+		bookkeepers, err := config.DefConfig.GetBookkeepers()
+		if err != nil {
+			return false
+		}
+		for _, k := range bookkeepers {
+			if types.AddressFromPubKey(k) == types.AddressFromPubKey(pubKey) {
+				return true
+			}
+		}
+		return false
+	}
+
+	key := hex.EncodeToString(keypair.SerializePublicKey(pubKey))
+	for _, k := range keys {
+		if strings.EqualFold(k, key) {
+			return true
+		}
+	}
+	return false
+}
+
+// OnSeedAnnounce adds the verified seed announce to the seed table,
+// and relays it to the neighbors if it is new
+func (this *P2PServer) OnSeedAnnounce(announce *msgtypes.SeedAnnounce) {
+	netID := config.DefConfig.P2PNode.NetworkMagic
+	if announce.NetID != netID {
+		log.Debugf("[p2p]seed announce %s of network %d", announce.Addr, announce.NetID)
+		return
+	}
+	if !isTrustedSeedKey(announce.PubKey) {
+		log.Debugf("[p2p]seed announce %s from untrusted key", announce.Addr)
+		return
+	}
+	now := uint32(time.Now().Unix())
+	if announce.Timestamp > now+common.SEED_ANNOUNCE_MAX_SKEW ||
+		announce.Timestamp+common.SEED_EXPIRE_TIME < now {
+		log.Debugf("[p2p]seed announce %s with invalid timestamp %d", announce.Addr, announce.Timestamp)
+		return
+	}
+	if _, err := common.ParseIPPort(announce.Addr); err != nil {
+		log.Debugf("[p2p]seed announce with invalid address %s", announce.Addr)
+		return
+	}
+	if !this.seeds.update(announce) {
+		return
+	}
+	log.Infof("[p2p]discovered seed %s", announce.Addr)
+
+	if err := this.seeds.save(getSeedFile(), netID); err != nil {
+		log.Warnf("[p2p]write seeds fail: %s", err)
+	}
+	this.network.Xmit(announce)
+}
+
+//sendSeeds send the discovered seeds to the new neighbor
+func (this *P2PServer) sendSeeds(id uint64) {
+	p := this.network.GetPeer(id)
+	if p == nil {
+		return
+	}
+	for _, announce := range this.seeds.announces() {
+		go this.Send(p, announce, false)
+	}
+}
+
+//announceSeed broadcast self as seed periodically if configured
+func (this *P2PServer) announceSeed() {
+	addr := config.DefConfig.P2PNode.SeedAnnounceAddr
+	if addr == "" || this.account == nil {
+		return
+	}
+	if time.Since(this.lastAnnounce) < time.Second*common.SEED_ANNOUNCE_INTERVAL ||
+		this.GetConnectionCnt() == 0 {
+		return
+	}
+	announce, err := msgpack.NewSeedAnnounce(addr, config.DefConfig.P2PNode.NetworkMagic, this.account)
+	if err != nil {
+		log.Warnf("[p2p]sign seed announce fail: %s", err)
+		return
+	}
+	this.lastAnnounce = time.Now()
+	log.Infof("[p2p]announce seed %s", addr)
+	this.seeds.update(announce)
+	this.network.Xmit(announce)
+}
+
+// SetAccount sets the account to sign the seed announce
+func (this *P2PServer) SetAccount(acc *account.Account) {
+	this.account = acc
+}
+
 // SetAddr sets base58 formatted account address
 func (this *P2PServer) SetAddr(addr string) {
 	this.network.SetAddr(addr)
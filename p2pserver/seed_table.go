@@ -0,0 +1,208 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package p2pserver
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+	msgtypes "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/ontio/ontology-crypto/keypair"
+)
+
+//seedEntry is a discovered seed, keeps the announce to relay it
+type seedEntry struct {
+	Addr      string
+	PubKey    string
+	Timestamp uint32
+	NetID     uint32
+	Signature string
+}
+
+//toAnnounce rebuilds the signed announce of the entry
+func (this *seedEntry) toAnnounce() (*msgtypes.SeedAnnounce, error) {
+	buf, err := hex.DecodeString(this.PubKey)
+	if err != nil {
+		return nil, err
+	}
+	pubKey, err := keypair.DeserializePublicKey(buf)
+	if err != nil {
+		return nil, err
+	}
+	sig, err := hex.DecodeString(this.Signature)
+	if err != nil {
+		return nil, err
+	}
+	return &msgtypes.SeedAnnounce{
+		Addr:      this.Addr,
+		Timestamp: this.Timestamp,
+		NetID:     this.NetID,
+		PubKey:    pubKey,
+		Signature: sig,
+	}, nil
+}
+
+//seedTable contains the seeds discovered by announce, one per seed key
+type seedTable struct {
+	sync.RWMutex
+	seeds map[string]*seedEntry
+}
+
+func newSeedTable() *seedTable {
+	return &seedTable{
+		seeds: make(map[string]*seedEntry),
+	}
+}
+
+//update adds the announce if it is newer than the known one of the key
+func (this *seedTable) update(announce *msgtypes.SeedAnnounce) bool {
+	key := hex.EncodeToString(keypair.SerializePublicKey(announce.PubKey))
+	this.Lock()
+	defer this.Unlock()
+
+	if old, ok := this.seeds[key]; ok && old.Timestamp >= announce.Timestamp {
+		return false
+	}
+	this.seeds[key] = &seedEntry{
+		Addr:      announce.Addr,
+		PubKey:    key,
+		Timestamp: announce.Timestamp,
+		NetID:     announce.NetID,
+		Signature: hex.EncodeToString(announce.Signature),
+	}
+	this.expire()
+	return true
+}
+
+//expire drops the entries expired relative to the newest one and the oldest ones over the limit.
+//The age is not counted by local time, so the table loaded after a long outage is still usable
+func (this *seedTable) expire() {
+	entries := this.sorted()
+	if len(entries) == 0 {
+		return
+	}
+	newest := entries[0].Timestamp
+	for i, entry := range entries {
+		if i >= common.SEED_LIMIT || entry.Timestamp+common.SEED_EXPIRE_TIME < newest {
+			delete(this.seeds, entry.PubKey)
+		}
+	}
+}
+
+//sorted returns the entries, newest first
+func (this *seedTable) sorted() []*seedEntry {
+	entries := make([]*seedEntry, 0, len(this.seeds))
+	for _, entry := range this.seeds {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Timestamp > entries[j].Timestamp
+	})
+	return entries
+}
+
+//addrs returns the discovered seed addresses, newest first
+func (this *seedTable) addrs() []string {
+	this.RLock()
+	defer this.RUnlock()
+	addrs := make([]string, 0, len(this.seeds))
+	for _, entry := range this.sorted() {
+		addrs = append(addrs, entry.Addr)
+	}
+	return addrs
+}
+
+//announces returns the signed announces of the discovered seeds
+func (this *seedTable) announces() []*msgtypes.SeedAnnounce {
+	this.RLock()
+	defer this.RUnlock()
+	announces := make([]*msgtypes.SeedAnnounce, 0, len(this.seeds))
+	for _, entry := range this.sorted() {
+		announce, err := entry.toAnnounce()
+		if err != nil {
+			continue
+		}
+		announces = append(announces, announce)
+	}
+	return announces
+}
+
+//load reads the seed table of the network from file, drops invalid entries and the ones
+//not signed by a trusted key
+func (this *seedTable) load(fileName string, netID uint32, trusted func(keypair.PublicKey) bool) error {
+	if !comm.FileExisted(fileName) {
+		return nil
+	}
+	buf, err := ioutil.ReadFile(fileName)
+	if err != nil {
+		return err
+	}
+	tables := make(map[uint32][]*seedEntry)
+	if err := json.Unmarshal(buf, &tables); err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	for _, entry := range tables[netID] {
+		announce, err := entry.toAnnounce()
+		if err != nil || announce.NetID != netID || announce.Verify() != nil {
+			log.Warnf("[p2p]drop invalid seed %s in %s", entry.Addr, fileName)
+			continue
+		}
+		if !trusted(announce.PubKey) {
+			log.Warnf("[p2p]drop seed %s of untrusted key in %s", entry.Addr, fileName)
+			continue
+		}
+		this.seeds[entry.PubKey] = entry
+	}
+	this.expire()
+	return nil
+}
+
+//save persists the seed table of the network, keeps other networks
+func (this *seedTable) save(fileName string, netID uint32) error {
+	tables := make(map[uint32][]*seedEntry)
+	if comm.FileExisted(fileName) {
+		if buf, err := ioutil.ReadFile(fileName); err == nil {
+			json.Unmarshal(buf, &tables)
+		}
+	}
+
+	this.RLock()
+	tables[netID] = this.sorted()
+	this.RUnlock()
+
+	buf, err := json.Marshal(tables)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(fileName), os.ModePerm); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(fileName, buf, os.ModePerm)
+}
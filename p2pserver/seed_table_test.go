@@ -0,0 +1,111 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package p2pserver
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/p2pserver/common"
+	msgpack "github.com/dnaproject2/DNA/p2pserver/message/msg_pack"
+	"github.com/ontio/ontology-crypto/keypair"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSeedTable(t *testing.T) {
+	acc1 := account.NewAccount("")
+	acc2 := account.NewAccount("")
+
+	table := newSeedTable()
+	announce1, err := msgpack.NewSeedAnnounce("127.0.0.1:20338", 1, acc1)
+	assert.Nil(t, err)
+	assert.True(t, table.update(announce1))
+	assert.False(t, table.update(announce1))
+
+	announce2, err := msgpack.NewSeedAnnounce("127.0.0.2:20338", 1, acc2)
+	assert.Nil(t, err)
+	assert.True(t, table.update(announce2))
+	assert.Equal(t, 2, len(table.addrs()))
+
+	// a newer announce of the same key replaces the old address
+	announce3, err := msgpack.NewSeedAnnounce("127.0.0.3:20338", 1, acc1)
+	assert.Nil(t, err)
+	announce3.Timestamp = uint32(time.Now().Unix()) + 1
+	assert.False(t, table.update(announce1))
+	assert.True(t, table.update(announce3))
+	assert.Equal(t, []string{"127.0.0.3:20338", "127.0.0.2:20338"}, table.addrs())
+
+	dir, err := ioutil.TempDir("", "seeds")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+	fileName := filepath.Join(dir, "net", "peers.seeds")
+	assert.Nil(t, table.save(fileName, 1))
+
+	trustAll := func(keypair.PublicKey) bool { return true }
+	loaded := newSeedTable()
+	assert.Nil(t, loaded.load(fileName, 1, trustAll))
+	// announce3 was modified after signing, dropped on load
+	assert.Equal(t, []string{"127.0.0.2:20338"}, loaded.addrs())
+
+	// the entries of keys no longer trusted are dropped on load
+	untrusted := newSeedTable()
+	assert.Nil(t, untrusted.load(fileName, 1, func(pubKey keypair.PublicKey) bool {
+		return types.AddressFromPubKey(pubKey) != acc2.Address
+	}))
+	assert.Equal(t, 0, len(untrusted.addrs()))
+
+	other := newSeedTable()
+	assert.Nil(t, other.load(fileName, 2, trustAll))
+	assert.Equal(t, 0, len(other.addrs()))
+
+	// the announces signed for another network are dropped on load
+	assert.Nil(t, table.save(fileName, 2))
+	assert.Nil(t, other.load(fileName, 2, trustAll))
+	assert.Equal(t, 0, len(other.addrs()))
+}
+
+func TestSeedTableExpire(t *testing.T) {
+	table := newSeedTable()
+	now := uint32(time.Now().Unix())
+	// the table saved long ago is kept while the entries are close to the newest one
+	for i, timestamp := range []uint32{now - 3*common.SEED_EXPIRE_TIME,
+		now - 3*common.SEED_EXPIRE_TIME - 1, now - 5*common.SEED_EXPIRE_TIME} {
+		announce, err := msgpack.NewSeedAnnounce(fmt.Sprintf("127.0.0.%d:20338", i+1), 1, account.NewAccount(""))
+		assert.Nil(t, err)
+		announce.Timestamp = timestamp
+		assert.True(t, table.update(announce))
+	}
+	assert.Equal(t, []string{"127.0.0.1:20338", "127.0.0.2:20338"}, table.addrs())
+
+	for i := 0; i < common.SEED_LIMIT; i++ {
+		announce, err := msgpack.NewSeedAnnounce("127.0.1.1:20338", 1, account.NewAccount(""))
+		assert.Nil(t, err)
+		table.update(announce)
+	}
+	// the old entries expire relative to the new ones
+	addrs := table.addrs()
+	assert.Equal(t, common.SEED_LIMIT, len(addrs))
+	assert.NotContains(t, addrs, "127.0.0.1:20338")
+}
@@ -0,0 +1,538 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package p2pserver
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	p2pComm "github.com/dnaproject2/DNA/p2pserver/common"
+	msgtypes "github.com/dnaproject2/DNA/p2pserver/message/types"
+)
+
+const (
+	SYNC_SNAPSHOT_WAIT_TIME        = 5  //s, Time to collect the snapshot responses of peers
+	SYNC_SNAPSHOT_MAX_RETRY        = 60 //Max times of requesting snapshots, if reaches, fall back to block sync
+	SYNC_SNAPSHOT_MAX_FLIGHT_CHUNK = 8  //Number of snapshot chunks on flight
+	SYNC_SNAPSHOT_CHUNK_TIMEOUT    = 10 //s, Request chunk timeout time. If chunk haven't received after SYNC_SNAPSHOT_CHUNK_TIMEOUT second, retry
+)
+
+//Phases of state sync
+const (
+	STATE_SYNC_HEADER   = iota //Syncing headers until catching up the peers
+	STATE_SYNC_SNAPSHOT        //Collecting the snapshots served by peers
+	STATE_SYNC_CHUNK           //Downloading the chunks of the chosen snapshot
+	STATE_SYNC_APPLY           //Applying the downloaded snapshot to ledger
+	STATE_SYNC_DONE            //Fast sync finished or disabled
+)
+
+//getLedgerDir return the dir of ledger stores, where the snapshot files are saved
+func getLedgerDir() string {
+	return config.DefConfig.Common.DataDir + string(os.PathSeparator) + config.DefConfig.P2PNode.NetworkName
+}
+
+//StateSyncMgr syncs the state snapshot of a recent block from peers for an empty ledger.
+//The headers are synced first, then a snapshot whose state root is committed by the synced
+//header STATE_ROOT_DELAY blocks above it is downloaded in chunks and applied to ledger, and
+//the blocks after the snapshot are synced by BlockSyncMgr as usual
+type StateSyncMgr struct {
+	server    *P2PServer
+	ledger    *ledger.Ledger
+	lock      sync.RWMutex
+	phase     int
+	retry     int                           //Times of requesting snapshots
+	deadline  time.Time                     //Deadline of collecting the responses of peers
+	snapshots map[uint64]*msgtypes.Snapshot //Map NodeID => served snapshot
+	rejected  map[comm.Uint256]bool         //Block hashes of the snapshots failed to apply
+	target    *msgtypes.Snapshot            //Snapshot to download
+	chunks    []bool                        //Whether the chunk of target is received
+	received  int                           //Count of received chunks
+	flights   map[uint32]*SyncFlightInfo    //Map chunk index => SyncFlightInfo
+	file      *os.File                      //Snapshot file being downloaded
+}
+
+//NewStateSyncMgr return a StateSyncMgr instance, fast sync is enabled only if the ledger has no block but genesis
+func NewStateSyncMgr(server *P2PServer) *StateSyncMgr {
+	phase := STATE_SYNC_DONE
+	if config.DefConfig.P2PNode.EnableFastSync {
+		if config.DefConfig.Common.LightMode {
+			log.Warnf("[p2p]fast sync is disabled in light mode")
+		} else if server.ledger.GetCurrentBlockHeight() == 0 {
+			phase = STATE_SYNC_HEADER
+		} else {
+			log.Warnf("[p2p]fast sync is disabled for a ledger with blocks")
+		}
+	}
+	return &StateSyncMgr{
+		server:    server,
+		ledger:    server.ledger,
+		phase:     phase,
+		snapshots: make(map[uint64]*msgtypes.Snapshot),
+		rejected:  make(map[comm.Uint256]bool),
+		flights:   make(map[uint32]*SyncFlightInfo),
+	}
+}
+
+//IsSyncing return whether the state snapshot is being synced, blocks shouldn't be saved meanwhile
+func (this *StateSyncMgr) IsSyncing() bool {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.phase != STATE_SYNC_DONE
+}
+
+func (this *StateSyncMgr) sync() {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	switch this.phase {
+	case STATE_SYNC_HEADER:
+		if this.headerSynced() {
+			this.requestSnapshots()
+		}
+	case STATE_SYNC_SNAPSHOT:
+		if time.Now().After(this.deadline) {
+			this.chooseSnapshot()
+		}
+	case STATE_SYNC_CHUNK:
+		this.syncChunks()
+	}
+}
+
+//headerSynced return whether the header height catches up the peers
+func (this *StateSyncMgr) headerSynced() bool {
+	maxHeight := uint32(0)
+	for _, p := range this.server.network.GetNeighbors() {
+		if uint32(p.GetHeight()) > maxHeight {
+			maxHeight = uint32(p.GetHeight())
+		}
+	}
+	return maxHeight > 0 && this.ledger.GetCurrentHeaderHeight()+SYNC_MAX_HEIGHT_OFFSET >= maxHeight
+}
+
+func (this *StateSyncMgr) requestSnapshots() {
+	if this.retry >= SYNC_SNAPSHOT_MAX_RETRY {
+		log.Warnf("[p2p]no state snapshot available, fall back to block sync")
+		this.phase = STATE_SYNC_DONE
+		return
+	}
+	this.retry++
+	this.snapshots = make(map[uint64]*msgtypes.Snapshot)
+	this.phase = STATE_SYNC_SNAPSHOT
+	this.deadline = time.Now().Add(time.Second * SYNC_SNAPSHOT_WAIT_TIME)
+	this.server.network.Xmit(&msgtypes.SnapshotReq{})
+	log.Infof("[p2p]request state snapshots, times:%d", this.retry)
+}
+
+//chooseSnapshot choose the highest snapshot which matches the synced headers and the committed state root.
+//The snapshots whose committing header isn't synced yet are chosen after the headers catch up
+func (this *StateSyncMgr) chooseSnapshot() {
+	var target *msgtypes.Snapshot
+	for _, snapshot := range this.snapshots {
+		if len(snapshot.ChunkHashes) == 0 || this.rejected[snapshot.BlockHash] {
+			continue
+		}
+		if snapshot.BlockHash != this.ledger.GetBlockHash(snapshot.Height) {
+			continue
+		}
+		stateRoot, err := this.ledger.GetCommittedStateRoot(snapshot.Height)
+		if err != nil || snapshot.StateRoot != stateRoot {
+			continue
+		}
+		if target == nil || snapshot.Height > target.Height {
+			target = snapshot
+		}
+	}
+	if target == nil {
+		this.phase = STATE_SYNC_HEADER
+		return
+	}
+	this.target = target
+
+	path := getLedgerDir() + string(os.PathSeparator) + p2pComm.SNAPSHOT_SYNC_FILE
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664)
+	if err == nil {
+		err = file.Truncate(int64(target.Size))
+	}
+	if err != nil {
+		log.Errorf("[p2p]create snapshot file error:%s", err)
+		this.phase = STATE_SYNC_DONE
+		return
+	}
+	this.file = file
+	this.chunks = make([]bool, len(target.ChunkHashes))
+	this.received = 0
+	this.flights = make(map[uint32]*SyncFlightInfo)
+	this.phase = STATE_SYNC_CHUNK
+	log.Infof("[p2p]start download state snapshot of height:%d, size:%d", target.Height, target.Size)
+	this.syncChunks()
+}
+
+//chunkNodes return the peers serving the target snapshot
+func (this *StateSyncMgr) chunkNodes() []uint64 {
+	nodes := make([]uint64, 0)
+	for id, snapshot := range this.snapshots {
+		if snapshot.Height != this.target.Height || snapshot.Size != this.target.Size ||
+			len(snapshot.ChunkHashes) != len(this.target.ChunkHashes) {
+			continue
+		}
+		same := true
+		for i, hash := range snapshot.ChunkHashes {
+			if hash != this.target.ChunkHashes[i] {
+				same = false
+				break
+			}
+		}
+		if same && this.server.getNode(id) != nil {
+			nodes = append(nodes, id)
+		}
+	}
+	return nodes
+}
+
+func (this *StateSyncMgr) syncChunks() {
+	nodes := this.chunkNodes()
+	if len(nodes) == 0 {
+		log.Warnf("[p2p]no peer serves the state snapshot of height:%d", this.target.Height)
+		this.resetChunks()
+		return
+	}
+	now := time.Now()
+	for index, flightInfo := range this.flights {
+		if int(now.Sub(flightInfo.GetStartTime()).Seconds()) >= SYNC_SNAPSHOT_CHUNK_TIMEOUT {
+			this.server.blockSync.addTimeoutCnt(flightInfo.GetNodeId())
+			delete(this.flights, index)
+		}
+	}
+	next := 0
+	for index := range this.chunks {
+		if len(this.flights) >= SYNC_SNAPSHOT_MAX_FLIGHT_CHUNK {
+			return
+		}
+		if this.chunks[index] || this.flights[uint32(index)] != nil {
+			continue
+		}
+		var nodeId uint64
+		next, nodeId = getNextNodeId(next, nodes)
+		reqNode := this.server.getNode(nodeId)
+		if reqNode == nil {
+			continue
+		}
+		msg := &msgtypes.ChunkReq{Height: this.target.Height, Index: uint32(index)}
+		if err := this.server.Send(reqNode, msg, false); err != nil {
+			log.Warnf("[p2p]syncChunks index:%d error:%s", index, err)
+			continue
+		}
+		this.flights[uint32(index)] = NewSyncFlightInfo(uint32(index), nodeId)
+	}
+}
+
+//resetChunks drop the download of target, and choose a snapshot again
+func (this *StateSyncMgr) resetChunks() {
+	if this.file != nil {
+		this.file.Close()
+		os.Remove(this.file.Name())
+		this.file = nil
+	}
+	this.flights = make(map[uint32]*SyncFlightInfo)
+	this.rejected[this.target.BlockHash] = true
+	this.phase = STATE_SYNC_HEADER
+}
+
+//OnSnapshotReceive receive the snapshot served by peer
+func (this *StateSyncMgr) OnSnapshotReceive(fromID uint64, snapshot *msgtypes.Snapshot) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	switch this.phase {
+	case STATE_SYNC_SNAPSHOT:
+		if snapshot.Height > 0 {
+			this.snapshots[fromID] = snapshot
+		}
+	}
+}
+
+//OnChunkReceive receive the snapshot chunk from peer
+func (this *StateSyncMgr) OnChunkReceive(fromID uint64, chunk *msgtypes.Chunk) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	if this.phase != STATE_SYNC_CHUNK || chunk.Height != this.target.Height ||
+		int(chunk.Index) >= len(this.chunks) || this.chunks[chunk.Index] {
+		return
+	}
+	size := uint64(p2pComm.SNAPSHOT_CHUNK_SIZE)
+	offset := uint64(chunk.Index) * size
+	if offset+size > this.target.Size {
+		size = this.target.Size - offset
+	}
+	if uint64(len(chunk.Data)) != size || sha256.Sum256(chunk.Data) != this.target.ChunkHashes[chunk.Index] {
+		log.Warnf("[p2p]receive invalid snapshot chunk index:%d from:%d", chunk.Index, fromID)
+		this.server.blockSync.addErrorRespCnt(fromID)
+		delete(this.flights, chunk.Index)
+		return
+	}
+	if flightInfo := this.flights[chunk.Index]; flightInfo != nil {
+		t := (time.Now().UnixNano() - flightInfo.GetStartTime().UnixNano()) / int64(time.Millisecond)
+		this.server.blockSync.addNewSpeed(fromID, float32(size)/float32(t+1)*1000.0/1024.0)
+		delete(this.flights, chunk.Index)
+	}
+	if _, err := this.file.WriteAt(chunk.Data, int64(offset)); err != nil {
+		log.Errorf("[p2p]write snapshot chunk error:%s", err)
+		return
+	}
+	this.chunks[chunk.Index] = true
+	this.received++
+	if this.received%100 == 0 {
+		log.Infof("[p2p]state snapshot chunks received:%d/%d", this.received, len(this.chunks))
+	}
+	if this.received < len(this.chunks) {
+		this.syncChunks()
+		return
+	}
+	this.phase = STATE_SYNC_APPLY
+	go this.applySnapshot()
+}
+
+func (this *StateSyncMgr) applySnapshot() {
+	this.lock.RLock()
+	file, target := this.file, this.target
+	this.lock.RUnlock()
+
+	err := file.Sync()
+	file.Close()
+	if err == nil {
+		err = this.ledger.ApplySnapshot(file.Name(), target.Height)
+	}
+	os.Remove(file.Name())
+
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.file = nil
+	if err != nil {
+		log.Errorf("[p2p]apply state snapshot of height:%d error:%s", target.Height, err)
+		this.rejected[target.BlockHash] = true
+		if this.ledger.GetCurrentBlockHeight() != 0 {
+			this.phase = STATE_SYNC_DONE
+		} else {
+			this.phase = STATE_SYNC_HEADER
+		}
+		return
+	}
+	log.Infof("[p2p]fast sync to height:%d finished", target.Height)
+	this.phase = STATE_SYNC_DONE
+}
+
+//snapshotServer serves the state snapshot of ledger to peers. The snapshot is renewed in background
+//every SNAPSHOT_INTERVAL blocks, the requests of peers never trigger creating one, and the requests
+//served for a peer are limited to SNAPSHOT_PEER_REQ_LIMIT in a second
+type snapshotServer struct {
+	lock      sync.RWMutex
+	ledger    *ledger.Ledger
+	snapshot  *msgtypes.Snapshot //Served snapshot, nil if not created
+	limitLock sync.Mutex
+	window    time.Time      //Start time of the current rate limit window
+	requests  map[uint64]int //Map NodeID => count of requests served in the window
+	quit      chan bool
+}
+
+func newSnapshotServer(ledger *ledger.Ledger) *snapshotServer {
+	return &snapshotServer{
+		ledger:   ledger,
+		requests: make(map[uint64]int),
+		quit:     make(chan bool),
+	}
+}
+
+func (this *snapshotServer) path() string {
+	return getLedgerDir() + string(os.PathSeparator) + p2pComm.SNAPSHOT_FILE_NAME
+}
+
+//start renew the served snapshot in background until stopped
+func (this *snapshotServer) start() {
+	t := time.NewTicker(time.Second * p2pComm.SNAPSHOT_CHECK_INTERVAL)
+	defer t.Stop()
+	for {
+		this.renew()
+		select {
+		case <-t.C:
+		case <-this.quit:
+			return
+		}
+	}
+}
+
+func (this *snapshotServer) stop() {
+	close(this.quit)
+}
+
+//renew create the snapshot of current block if the served one is SNAPSHOT_INTERVAL blocks older than ledger
+func (this *snapshotServer) renew() {
+	height := this.ledger.GetCurrentBlockHeight()
+	this.lock.RLock()
+	snapshot := this.snapshot
+	this.lock.RUnlock()
+	if height == 0 || (snapshot != nil && height < snapshot.Height+p2pComm.SNAPSHOT_INTERVAL) {
+		return
+	}
+	snapshot, err := this.save()
+	if err != nil {
+		log.Errorf("[p2p]create state snapshot error:%s", err)
+		return
+	}
+	log.Infof("[p2p]create state snapshot of height:%d, size:%d", snapshot.Height, snapshot.Size)
+}
+
+//getSnapshot return the served snapshot, nil if not created yet
+func (this *snapshotServer) getSnapshot() *msgtypes.Snapshot {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	return this.snapshot
+}
+
+//allow return whether the request of the peer is served, which is rate limited
+func (this *snapshotServer) allow(id uint64) bool {
+	this.limitLock.Lock()
+	defer this.limitLock.Unlock()
+	now := time.Now()
+	if now.Sub(this.window) >= time.Second {
+		this.window = now
+		this.requests = make(map[uint64]int)
+	}
+	if this.requests[id] >= p2pComm.SNAPSHOT_PEER_REQ_LIMIT {
+		return false
+	}
+	this.requests[id]++
+	return true
+}
+
+//save write the snapshot to a temp file, which replaces the served one
+func (this *snapshotServer) save() (*msgtypes.Snapshot, error) {
+	path := this.path() + ".tmp"
+	file, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(path)
+	hasher := &chunkHasher{hasher: sha256.New()}
+	writer := bufio.NewWriter(io.MultiWriter(file, hasher))
+	height, err := this.ledger.SaveSnapshot(writer)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	file.Close()
+	if err != nil {
+		return nil, err
+	}
+	info, err := this.ledger.GetSnapshotInfo(height)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshot := &msgtypes.Snapshot{
+		Height:          info.Height,
+		BlockHash:       info.BlockHash,
+		StateRoot:       info.StateRoot,
+		StateMerkleRoot: info.StateMerkleRoot,
+		Size:            hasher.size,
+		ChunkHashes:     hasher.Sum(),
+	}
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	err = os.Rename(path, this.path())
+	if err != nil {
+		return nil, err
+	}
+	this.snapshot = snapshot
+	return snapshot, nil
+}
+
+//readChunk read the chunk of the served snapshot
+func (this *snapshotServer) readChunk(height, index uint32) ([]byte, error) {
+	this.lock.RLock()
+	defer this.lock.RUnlock()
+	snapshot := this.snapshot
+	if snapshot == nil || snapshot.Height != height || int(index) >= len(snapshot.ChunkHashes) {
+		return nil, fmt.Errorf("snapshot chunk of height:%d index:%d not found", height, index)
+	}
+	file, err := os.Open(this.path())
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	size := uint64(p2pComm.SNAPSHOT_CHUNK_SIZE)
+	offset := uint64(index) * size
+	if offset+size > snapshot.Size {
+		size = snapshot.Size - offset
+	}
+	data := make([]byte, size)
+	_, err = file.ReadAt(data, int64(offset))
+	if err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+//chunkHasher calculates the hashes of the chunks of written data
+type chunkHasher struct {
+	hasher hash.Hash
+	size   uint64
+	hashes []comm.Uint256
+}
+
+func (this *chunkHasher) Write(data []byte) (int, error) {
+	n := len(data)
+	for len(data) > 0 {
+		left := p2pComm.SNAPSHOT_CHUNK_SIZE - int(this.size%p2pComm.SNAPSHOT_CHUNK_SIZE)
+		if left > len(data) {
+			left = len(data)
+		}
+		this.hasher.Write(data[:left])
+		this.size += uint64(left)
+		data = data[left:]
+		if this.size%p2pComm.SNAPSHOT_CHUNK_SIZE == 0 {
+			this.hashes = append(this.hashes, this.sum())
+		}
+	}
+	return n, nil
+}
+
+func (this *chunkHasher) sum() comm.Uint256 {
+	var hash comm.Uint256
+	copy(hash[:], this.hasher.Sum(nil))
+	this.hasher.Reset()
+	return hash
+}
+
+//Sum return the hashes of all of the chunks
+func (this *chunkHasher) Sum() []comm.Uint256 {
+	if this.size%p2pComm.SNAPSHOT_CHUNK_SIZE != 0 {
+		this.hashes = append(this.hashes, this.sum())
+	}
+	return this.hashes
+}
@@ -0,0 +1,57 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package p2pserver
+
+import (
+	"crypto/sha256"
+	"testing"
+	"time"
+
+	p2pComm "github.com/dnaproject2/DNA/p2pserver/common"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotServerLimit(t *testing.T) {
+	server := newSnapshotServer(nil)
+	assert.Nil(t, server.getSnapshot())
+	for i := 0; i < p2pComm.SNAPSHOT_PEER_REQ_LIMIT; i++ {
+		assert.True(t, server.allow(1))
+	}
+	assert.False(t, server.allow(1))
+	assert.True(t, server.allow(2))
+
+	// the limit is reset in the next window
+	server.window = time.Now().Add(-time.Second)
+	assert.True(t, server.allow(1))
+}
+
+func TestChunkHasher(t *testing.T) {
+	data := make([]byte, p2pComm.SNAPSHOT_CHUNK_SIZE+10)
+	for i := range data {
+		data[i] = byte(i)
+	}
+	hasher := &chunkHasher{hasher: sha256.New()}
+	hasher.Write(data[:100])
+	hasher.Write(data[100:])
+	hashes := hasher.Sum()
+	assert.Equal(t, uint64(len(data)), hasher.size)
+	assert.Equal(t, 2, len(hashes))
+	assert.Equal(t, sha256.Sum256(data[:p2pComm.SNAPSHOT_CHUNK_SIZE]), [32]byte(hashes[0]))
+	assert.Equal(t, sha256.Sum256(data[p2pComm.SNAPSHOT_CHUNK_SIZE:]), [32]byte(hashes[1]))
+}
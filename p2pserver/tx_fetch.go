@@ -0,0 +1,170 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package p2pserver
+
+import (
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+
+	comm "github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/types"
+	p2pComm "github.com/dnaproject2/DNA/p2pserver/common"
+	msgtypes "github.com/dnaproject2/DNA/p2pserver/message/types"
+	"github.com/dnaproject2/DNA/p2pserver/peer"
+)
+
+var (
+	errNoFullPeer = errors.New("no full node peer to fetch transaction")
+	errTxNotFound = errors.New("transaction not found by peers")
+)
+
+//txProofResp is the tx proof received from peer
+type txProofResp struct {
+	id    uint64
+	proof *msgtypes.TxProof
+}
+
+//txFetcher fetches the transactions with merkle proofs from full nodes in light mode,
+//the transactions are verified by the transactions root of the synced headers
+type txFetcher struct {
+	server  *P2PServer
+	lock    sync.Mutex
+	pending map[comm.Uint256][]chan *txProofResp //Map TxHash => response channels of fetches
+}
+
+func newTxFetcher(server *P2PServer) *txFetcher {
+	return &txFetcher{
+		server:  server,
+		pending: make(map[comm.Uint256][]chan *txProofResp),
+	}
+}
+
+//fetch request the transaction from several full nodes, and return the first verified one
+func (this *txFetcher) fetch(txHash comm.Uint256) (*types.Transaction, uint32, error) {
+	peers := this.fullPeers()
+	if len(peers) == 0 {
+		return nil, 0, errNoFullPeer
+	}
+	ch := make(chan *txProofResp, len(peers))
+	this.addPending(txHash, ch)
+	defer this.delPending(txHash, ch)
+
+	requested := make(map[uint64]bool)
+	for _, p := range peers {
+		if err := this.server.Send(p, &msgtypes.TxProofReq{TxHash: txHash}, false); err != nil {
+			log.Debugf("[p2p]send tx proof request to peer %d error:%s", p.GetID(), err)
+			continue
+		}
+		requested[p.GetID()] = true
+	}
+	timer := time.NewTimer(time.Second * p2pComm.TX_PROOF_TIMEOUT)
+	defer timer.Stop()
+	for len(requested) > 0 {
+		select {
+		case resp := <-ch:
+			if !requested[resp.id] {
+				continue
+			}
+			delete(requested, resp.id)
+			if resp.proof.Tx == nil {
+				continue
+			}
+			if err := this.verify(txHash, resp.proof); err != nil {
+				log.Warnf("[p2p]invalid tx proof of %s from peer %d:%s", txHash.ToHexString(), resp.id, err)
+				this.server.blockSync.addErrorRespCnt(resp.id)
+				continue
+			}
+			return resp.proof.Tx, resp.proof.Height, nil
+		case <-timer.C:
+			return nil, 0, errTxNotFound
+		}
+	}
+	return nil, 0, errTxNotFound
+}
+
+//verify check the transaction is included in the block header of proof height
+func (this *txFetcher) verify(txHash comm.Uint256, proof *msgtypes.TxProof) error {
+	if proof.Tx.Hash() != txHash {
+		return errors.New("transaction hash mismatch")
+	}
+	header, err := this.server.ledger.GetHeaderByHeight(proof.Height)
+	if err != nil {
+		return err
+	}
+	if !comm.VerifyMerklePath(txHash, proof.Index, proof.Path, header.TransactionsRoot) {
+		return errors.New("merkle path mismatches the transactions root")
+	}
+	return nil
+}
+
+//onTxProofReceive pass the tx proof to the pending fetches
+func (this *txFetcher) onTxProofReceive(id uint64, proof *msgtypes.TxProof) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	for _, ch := range this.pending[proof.TxHash] {
+		select {
+		case ch <- &txProofResp{id: id, proof: proof}:
+		default:
+		}
+	}
+}
+
+//fullPeers return the established peers which keep full blocks in random order
+func (this *txFetcher) fullPeers() []*peer.Peer {
+	peers := make([]*peer.Peer, 0)
+	for _, p := range this.server.network.GetNeighbors() {
+		if p.GetState() != p2pComm.ESTABLISH || p.GetServices() == p2pComm.LIGHT_NODE {
+			continue
+		}
+		peers = append(peers, p)
+	}
+	rand.Shuffle(len(peers), func(i, j int) {
+		peers[i], peers[j] = peers[j], peers[i]
+	})
+	if len(peers) > p2pComm.TX_PROOF_PEER_CNT {
+		peers = peers[:p2pComm.TX_PROOF_PEER_CNT]
+	}
+	return peers
+}
+
+func (this *txFetcher) addPending(txHash comm.Uint256, ch chan *txProofResp) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	this.pending[txHash] = append(this.pending[txHash], ch)
+}
+
+func (this *txFetcher) delPending(txHash comm.Uint256, ch chan *txProofResp) {
+	this.lock.Lock()
+	defer this.lock.Unlock()
+	chs := this.pending[txHash]
+	for i, c := range chs {
+		if c == ch {
+			chs = append(chs[:i], chs[i+1:]...)
+			break
+		}
+	}
+	if len(chs) == 0 {
+		delete(this.pending, txHash)
+	} else {
+		this.pending[txHash] = chs
+	}
+}
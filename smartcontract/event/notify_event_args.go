@@ -40,6 +40,28 @@ type NotifyEventInfo struct {
 	States          interface{}
 }
 
+// Topic returns the first state of the notify if it is a string
+func (n *NotifyEventInfo) Topic() (string, bool) {
+	switch states := n.States.(type) {
+	case string:
+		return states, true
+	case []interface{}:
+		if len(states) > 0 {
+			topic, ok := states[0].(string)
+			return topic, ok
+		}
+	}
+	return "", false
+}
+
+// ContractNotify describe an event notify with the block height and
+// the transaction hash where it is generated
+type ContractNotify struct {
+	Height uint32
+	TxHash common.Uint256
+	Notify *NotifyEventInfo
+}
+
 type ExecuteNotify struct {
 	TxHash      common.Uint256
 	State       byte
@@ -31,6 +31,7 @@ import (
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/constants"
 	"github.com/dnaproject2/DNA/common/serialization"
+	vbftconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
 	cstates "github.com/dnaproject2/DNA/core/states"
 	"github.com/dnaproject2/DNA/smartcontract/service/native"
 	"github.com/dnaproject2/DNA/smartcontract/service/native/global_params"
@@ -77,6 +78,7 @@ const (
 	SET_PROMISE_POS                  = "setPromisePos"
 	SET_GAS_ADDRESS                  = "setGasAddress"
 	DESTROY_CONTRACT                 = "destroyContract"
+	REPORT_EQUIVOCATION              = "reportEquivocation"
 
 	//key prefix
 	GLOBAL_PARAM      = "globalParam"
@@ -96,12 +98,14 @@ const (
 	PROMISE_POS       = "promisePos"
 	PRE_CONFIG        = "preConfig"
 	GAS_ADDRESS       = "gasAddress"
+	EVIDENCE          = "evidence"
 
 	//global
 	PRECISE            = 1000000
 	NEW_VERSION_VIEW   = 6
 	NEW_VERSION_BLOCK  = 414100
 	NEW_WITHDRAW_BLOCK = 2800000
+	EVIDENCE_MAX_AGE   = 100000 //block count, the equivocation older than it can not be reported
 )
 
 // candidate fee must >= 1 ONG
@@ -139,6 +143,7 @@ func RegisterGovernanceContract(native *native.NativeService) {
 	native.Register(WITHDRAW_FEE, WithdrawFee)
 	native.Register(ADD_INIT_POS, AddInitPos)
 	native.Register(REDUCE_INIT_POS, ReduceInitPos)
+	native.Register(REPORT_EQUIVOCATION, ReportEquivocation)
 
 	native.Register(INIT_CONFIG, InitConfig)
 	native.Register(APPROVE_CANDIDATE, ApproveCandidate)
@@ -624,32 +629,13 @@ func BlackNode(native *native.NativeService) ([]byte, error) {
 	}
 	commit := false
 	for _, peerPubkey := range params.PeerPubkeyList {
-		peerPubkeyPrefix, err := hex.DecodeString(peerPubkey)
+		consensus, err := blackPeer(native, contract, peerPoolMap, peerPubkey)
 		if err != nil {
-			return utils.BYTE_FALSE, fmt.Errorf("hex.DecodeString, peerPubkey format error: %v", err)
-		}
-		peerPoolItem, ok := peerPoolMap.PeerPoolMap[peerPubkey]
-		if !ok {
-			return utils.BYTE_FALSE, fmt.Errorf("blackNode, peerPubkey is not in peerPoolMap")
-		}
-
-		blackListItem := &BlackListItem{
-			PeerPubkey: peerPoolItem.PeerPubkey,
-			Address:    peerPoolItem.Address,
-			InitPos:    peerPoolItem.InitPos,
-		}
-		bf := new(bytes.Buffer)
-		if err := blackListItem.Serialize(bf); err != nil {
-			return utils.BYTE_FALSE, fmt.Errorf("serialize, serialize blackListItem error: %v", err)
+			return utils.BYTE_FALSE, fmt.Errorf("blackNode, %v", err)
 		}
-		//put peer into black list
-		native.CacheDB.Put(utils.ConcatKey(contract, []byte(BLACK_LIST), peerPubkeyPrefix), cstates.GenRawStorageItem(bf.Bytes()))
-		//change peerPool status
-		if peerPoolItem.Status == ConsensusStatus {
+		if consensus {
 			commit = true
 		}
-		peerPoolItem.Status = BlackStatus
-		peerPoolMap.PeerPoolMap[peerPubkey] = peerPoolItem
 	}
 	err = putPeerPoolMap(native, contract, view, peerPoolMap)
 	if err != nil {
@@ -666,6 +652,101 @@ func BlackNode(native *native.NativeService) ([]byte, error) {
 	return utils.BYTE_TRUE, nil
 }
 
+//Report the equivocation of a node with two conflicting statements signed by it in vbft consensus,
+//the node is put into black list and its stake is punished right away, as blackNode does at next commitDpos.
+//No witness is needed, the evidence is verified by the signatures of the node, and each equivocation
+//is punished once only if it's reported within EVIDENCE_MAX_AGE blocks.
+func ReportEquivocation(native *native.NativeService) ([]byte, error) {
+	params := new(EquivocationEvidenceParam)
+	if err := params.Deserialize(bytes.NewBuffer(native.Input)); err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("deserialize, contract params deserialize error: %v", err)
+	}
+	pubkey, err := vbftconfig.Pubkey(params.PeerPubkey)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("vbftconfig.Pubkey, peerPubkey format error: %v", err)
+	}
+
+	//verify evidence
+	stmt1, err := verifyStatement(pubkey, params.Statement1, params.Sig1)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, verify statement1 error: %v", err)
+	}
+	stmt2, err := verifyStatement(pubkey, params.Statement2, params.Sig2)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, verify statement2 error: %v", err)
+	}
+	if !stmt1.ConflictWith(stmt2) {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, statements are not conflicting")
+	}
+	if stmt1.BlockNum > native.Height {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, statements of future block %d", stmt1.BlockNum)
+	}
+	if native.Height-stmt1.BlockNum > EVIDENCE_MAX_AGE {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, evidence of block %d expired", stmt1.BlockNum)
+	}
+	contract := native.ContextRef.CurrentContext().ContractAddress
+
+	//check processed evidence
+	evidenceKey := utils.ConcatKey(contract, []byte(EVIDENCE), evidenceHash(params.PeerPubkey, stmt1))
+	evidenceBytes, err := native.CacheDB.Get(evidenceKey)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("native.CacheDB.Get, get evidence error: %v", err)
+	}
+	if evidenceBytes != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, evidence is already processed")
+	}
+	heightBytes, err := GetUint32Bytes(native.Height)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("GetUint32Bytes, get heightBytes error: %v", err)
+	}
+	native.CacheDB.Put(evidenceKey, cstates.GenRawStorageItem(heightBytes))
+
+	//get current view
+	view, err := GetView(native, contract)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getView, get view error: %v", err)
+	}
+	//get peerPoolMap
+	peerPoolMap, err := GetPeerPoolMap(native, contract, view)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getPeerPoolMap, get peerPoolMap error: %v", err)
+	}
+	peerPoolItem, ok := peerPoolMap.PeerPoolMap[params.PeerPubkey]
+	if !ok {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, peerPubkey is not in peerPoolMap")
+	}
+	if peerPoolItem.Status == BlackStatus {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, peer is already in black list")
+	}
+	commit, err := blackPeer(native, contract, peerPoolMap, params.PeerPubkey)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("reportEquivocation, %v", err)
+	}
+	//punish the peer now, and remove it from the pool as commitDpos does to black peers
+	err = blackQuit(native, contract, peerPoolItem)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("blackQuit, blackQuit error: %v", err)
+	}
+	delete(peerPoolMap.PeerPoolMap, params.PeerPubkey)
+	err = putPeerPoolMap(native, contract, view, peerPoolMap)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("putPeerPoolMap, put peerPoolMap error: %v", err)
+	}
+
+	//commitDpos, unless it's done in this block, then the peer leaves consensus at next commitDpos
+	governanceView, err := GetGovernanceView(native, contract)
+	if err != nil {
+		return utils.BYTE_FALSE, fmt.Errorf("getGovernanceView, get GovernanceView error: %v", err)
+	}
+	if commit && governanceView.Height != native.Height {
+		err = executeCommitDpos(native, contract)
+		if err != nil {
+			return utils.BYTE_FALSE, fmt.Errorf("executeCommitDpos, executeCommitDpos error: %v", err)
+		}
+	}
+	return utils.BYTE_TRUE, nil
+}
+
 //Remove a node from black list, allow it to be registered
 func WhiteNode(native *native.NativeService) ([]byte, error) {
 	params := new(WhiteNodeParam)
@@ -20,6 +20,7 @@ package governance
 
 import (
 	"bytes"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"math/big"
@@ -27,9 +28,12 @@ import (
 
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/constants"
+	vbftconfig "github.com/dnaproject2/DNA/consensus/vbft/config"
+	"github.com/dnaproject2/DNA/core/signature"
 	cstates "github.com/dnaproject2/DNA/core/states"
 	"github.com/dnaproject2/DNA/smartcontract/service/native"
 	"github.com/dnaproject2/DNA/smartcontract/service/native/utils"
+	"github.com/ontio/ontology-crypto/keypair"
 )
 
 func registerCandidate(native *native.NativeService, flag string) error {
@@ -314,6 +318,35 @@ func normalQuit(native *native.NativeService, contract common.Address, peerPoolI
 	return nil
 }
 
+//put peer into black list and change its status in peerPoolMap, return true if it was a consensus peer
+func blackPeer(native *native.NativeService, contract common.Address, peerPoolMap *PeerPoolMap, peerPubkey string) (bool, error) {
+	peerPubkeyPrefix, err := hex.DecodeString(peerPubkey)
+	if err != nil {
+		return false, fmt.Errorf("hex.DecodeString, peerPubkey format error: %v", err)
+	}
+	peerPoolItem, ok := peerPoolMap.PeerPoolMap[peerPubkey]
+	if !ok {
+		return false, fmt.Errorf("peerPubkey is not in peerPoolMap")
+	}
+
+	blackListItem := &BlackListItem{
+		PeerPubkey: peerPoolItem.PeerPubkey,
+		Address:    peerPoolItem.Address,
+		InitPos:    peerPoolItem.InitPos,
+	}
+	bf := new(bytes.Buffer)
+	if err := blackListItem.Serialize(bf); err != nil {
+		return false, fmt.Errorf("serialize, serialize blackListItem error: %v", err)
+	}
+	//put peer into black list
+	native.CacheDB.Put(utils.ConcatKey(contract, []byte(BLACK_LIST), peerPubkeyPrefix), cstates.GenRawStorageItem(bf.Bytes()))
+	//change peerPool status
+	consensus := peerPoolItem.Status == ConsensusStatus
+	peerPoolItem.Status = BlackStatus
+	peerPoolMap.PeerPoolMap[peerPubkey] = peerPoolItem
+	return consensus, nil
+}
+
 func blackQuit(native *native.NativeService, contract common.Address, peerPoolItem *PeerPoolItem) error {
 	// ont transfer to trigger unboundong
 	err := appCallTransferOnt(native, utils.GovernanceContractAddress, utils.GovernanceContractAddress, peerPoolItem.InitPos)
@@ -1368,3 +1401,26 @@ func splitNodeFee(native *native.NativeService, contract common.Address, peerPub
 	}
 	return nil
 }
+
+//evidenceHash identifies the equivocation of the peer, the evidences of one height are punished once
+func evidenceHash(peerPubkey string, stmt *vbftconfig.ConsensusStatement) []byte {
+	sink := common.NewZeroCopySink(nil)
+	sink.WriteString(peerPubkey)
+	sink.WriteUint32(stmt.NetworkId)
+	sink.WriteUint8(stmt.Type)
+	sink.WriteUint32(stmt.BlockNum)
+	hash := sha256.Sum256(sink.Bytes())
+	return hash[:]
+}
+
+//verify the vbft consensus statement is signed by the peer
+func verifyStatement(pubkey keypair.PublicKey, data []byte, sig []byte) (*vbftconfig.ConsensusStatement, error) {
+	if err := signature.Verify(pubkey, data, sig); err != nil {
+		return nil, fmt.Errorf("signature.Verify, verify statement signature error: %v", err)
+	}
+	stmt := new(vbftconfig.ConsensusStatement)
+	if err := stmt.Deserialize(data); err != nil {
+		return nil, fmt.Errorf("deserialize, deserialize statement error: %v", err)
+	}
+	return stmt, nil
+}
@@ -1040,3 +1040,59 @@ func (this *DestroyContractParam) Deserialize(r io.Reader) error {
 	this.ContractAddress = contractAddress
 	return nil
 }
+
+type EquivocationEvidenceParam struct {
+	PeerPubkey string
+	Statement1 []byte
+	Sig1       []byte
+	Statement2 []byte
+	Sig2       []byte
+}
+
+func (this *EquivocationEvidenceParam) Serialize(w io.Writer) error {
+	if err := serialization.WriteString(w, this.PeerPubkey); err != nil {
+		return fmt.Errorf("serialization.WriteString, serialize peerPubkey error: %v", err)
+	}
+	if err := serialization.WriteVarBytes(w, this.Statement1); err != nil {
+		return fmt.Errorf("serialization.WriteVarBytes, serialize statement1 error: %v", err)
+	}
+	if err := serialization.WriteVarBytes(w, this.Sig1); err != nil {
+		return fmt.Errorf("serialization.WriteVarBytes, serialize sig1 error: %v", err)
+	}
+	if err := serialization.WriteVarBytes(w, this.Statement2); err != nil {
+		return fmt.Errorf("serialization.WriteVarBytes, serialize statement2 error: %v", err)
+	}
+	if err := serialization.WriteVarBytes(w, this.Sig2); err != nil {
+		return fmt.Errorf("serialization.WriteVarBytes, serialize sig2 error: %v", err)
+	}
+	return nil
+}
+
+func (this *EquivocationEvidenceParam) Deserialize(r io.Reader) error {
+	peerPubkey, err := serialization.ReadString(r)
+	if err != nil {
+		return fmt.Errorf("serialization.ReadString, deserialize peerPubkey error: %v", err)
+	}
+	statement1, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return fmt.Errorf("serialization.ReadVarBytes, deserialize statement1 error: %v", err)
+	}
+	sig1, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return fmt.Errorf("serialization.ReadVarBytes, deserialize sig1 error: %v", err)
+	}
+	statement2, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return fmt.Errorf("serialization.ReadVarBytes, deserialize statement2 error: %v", err)
+	}
+	sig2, err := serialization.ReadVarBytes(r)
+	if err != nil {
+		return fmt.Errorf("serialization.ReadVarBytes, deserialize sig2 error: %v", err)
+	}
+	this.PeerPubkey = peerPubkey
+	this.Statement1 = statement1
+	this.Sig1 = sig1
+	this.Statement2 = statement2
+	this.Sig2 = sig2
+	return nil
+}
@@ -20,6 +20,8 @@
 package common
 
 import (
+	"container/heap"
+	"math/big"
 	"sort"
 	"sync"
 
@@ -48,7 +50,12 @@ type TXEntry struct {
 // in the ledger.
 type TXPool struct {
 	sync.RWMutex
-	txList map[common.Uint256]*TXEntry // Transactions which have been verified
+	txList   map[common.Uint256]*TXEntry                  // Transactions which have been verified
+	payerTxs map[common.Address]map[uint32]common.Uint256 // The nonce queue of each payer
+	txHeight map[common.Uint256]uint32                    // The block height when a tx entered the pool
+	prices   priceHeap                                    // The transactions ordered by the gas price
+	bytes    uint64                                       // The total size of the transactions
+	height   uint32                                       // The current block height
 }
 
 // Init creates a new transaction pool to gather.
@@ -56,24 +63,228 @@ func (tp *TXPool) Init() {
 	tp.Lock()
 	defer tp.Unlock()
 	tp.txList = make(map[common.Uint256]*TXEntry)
+	tp.payerTxs = make(map[common.Address]map[uint32]common.Uint256)
+	tp.txHeight = make(map[common.Uint256]uint32)
+	tp.prices = newPriceHeap()
+	tp.bytes = 0
+}
+
+// SetHeight sets the current block height to age the transactions
+func (tp *TXPool) SetHeight(height uint32) {
+	tp.Lock()
+	defer tp.Unlock()
+	tp.height = height
 }
 
 // AddTxList adds a valid transaction to the transaction pool. If the
-// transaction is already in the pool, just return false. Parameter
-// txEntry includes transaction, fee, and verified information(height,
-// validator, error code).
+// transaction is already in the pool, or it's rejected by the payer's
+// queue or the pool capacity, just return false. Parameter txEntry
+// includes transaction, fee, and verified information(height, validator,
+// error code).
 func (tp *TXPool) AddTxList(txEntry *TXEntry) bool {
+	_, _, ret := tp.AddTxEntry(txEntry)
+	return ret == errors.ErrNoError
+}
+
+// AddTxEntry adds a valid transaction to the transaction pool and returns
+// the transaction replaced by it and the transactions evicted for it, or
+// the reason if it is rejected. A
+// transaction with the same payer and nonce as a pooled one replaces it
+// only if the gas price is bumped by the configured percentage, and a
+// payer can't hold more than the configured number of transactions in
+// the pool. When the pool is full, the transactions with the lowest gas
+// price are evicted if the new one pays more.
+func (tp *TXPool) AddTxEntry(txEntry *TXEntry) (*types.Transaction, []*types.Transaction, errors.ErrCode) {
 	tp.Lock()
 	defer tp.Unlock()
 	txHash := txEntry.Tx.Hash()
 	if _, ok := tp.txList[txHash]; ok {
 		log.Infof("AddTxList: transaction %x is already in the pool",
 			txHash)
-		return false
+		return nil, nil, errors.ErrDuplicateInput
+	}
+
+	payer, nonce := txEntry.Tx.Payer, txEntry.Tx.Nonce
+	queue := tp.payerTxs[payer]
+	oldHash, replace := queue[nonce]
+	var oldTx *types.Transaction
+	if replace {
+		oldTx = tp.txList[oldHash].Tx
+		if !isReplaceBumped(txEntry.Tx.GasPrice, oldTx.GasPrice, getMinReplaceBump()) {
+			log.Debugf("AddTxList: transaction %x gas price %d can't replace %x gas price %d",
+				txHash, txEntry.Tx.GasPrice, oldHash, oldTx.GasPrice)
+			return nil, nil, errors.ErrReplaceUnderpriced
+		}
+	} else if limit := getMaxTxPerPayer(); limit > 0 && len(queue) >= limit {
+		log.Debugf("AddTxList: payer %s has %d transactions in the pool",
+			payer.ToBase58(), len(queue))
+		return nil, nil, errors.ErrPayerLimit
+	}
+
+	var exclude *common.Uint256
+	if replace {
+		exclude = &oldHash
+	}
+	victims, ok := tp.evictCandidates(txEntry.Tx, exclude)
+	if !ok {
+		log.Debugf("AddTxList: transaction pool is full for tx %x", txHash)
+		return nil, nil, errors.ErrTxPoolFull
+	}
+
+	if replace {
+		log.Debugf("AddTxList: transaction %x replaces %x", txHash, oldHash)
+		tp.delTx(oldHash)
+		delete(tp.txHeight, oldHash)
+	}
+	evicted := make([]*types.Transaction, 0, len(victims))
+	for _, v := range victims {
+		log.Debugf("AddTxList: transaction %x gas price %d is evicted",
+			v.Tx.Hash(), v.Tx.GasPrice)
+		tp.delTx(v.Tx.Hash())
+		delete(tp.txHeight, v.Tx.Hash())
+		evicted = append(evicted, v.Tx)
 	}
 
 	tp.txList[txHash] = txEntry
-	return true
+	tp.bytes += uint64(len(txEntry.Tx.Raw))
+	if tp.payerTxs[payer] == nil {
+		tp.payerTxs[payer] = make(map[uint32]common.Uint256)
+	}
+	tp.payerTxs[payer][nonce] = txHash
+	heap.Push(&tp.prices, txEntry)
+	// A re-verified tx keeps the height when it entered the pool at first
+	if _, ok := tp.txHeight[txHash]; !ok && getTxTTL() > 0 {
+		tp.txHeight[txHash] = tp.height
+	}
+	return oldTx, evicted, errors.ErrNoError
+}
+
+// isFull checks whether the pool with the given tx number and size
+// exceeds the configured capacity
+func isFull(count int, bytes uint64) bool {
+	maxTxs, maxBytes := getMaxTxs(), getMaxBytes()
+	return (maxTxs > 0 && count > maxTxs) ||
+		(maxBytes > 0 && bytes > maxBytes)
+}
+
+// evictCandidates returns the transactions with the lowest gas price to
+// be evicted to make room for the tx, and false if the tx doesn't pay
+// more than them. The transaction to be replaced by the tx is excluded.
+// The caller should hold the lock.
+func (tp *TXPool) evictCandidates(tx *types.Transaction,
+	exclude *common.Uint256) ([]*TXEntry, bool) {
+	count := len(tp.txList) + 1
+	bytes := tp.bytes + uint64(len(tx.Raw))
+	if exclude != nil {
+		count--
+		bytes -= uint64(len(tp.txList[*exclude].Tx.Raw))
+	}
+	if !isFull(count, bytes) {
+		return nil, true
+	}
+
+	// Pop the cheapest transactions and push them back after checking
+	popped := make([]*TXEntry, 0)
+	defer func() {
+		for _, txEntry := range popped {
+			heap.Push(&tp.prices, txEntry)
+		}
+	}()
+	victims := make([]*TXEntry, 0)
+	for tp.prices.Len() > 0 {
+		txEntry := heap.Pop(&tp.prices).(*TXEntry)
+		popped = append(popped, txEntry)
+		if exclude != nil && txEntry.Tx.Hash() == *exclude {
+			continue
+		}
+		if txEntry.Tx.GasPrice >= tx.GasPrice {
+			return nil, false
+		}
+		victims = append(victims, txEntry)
+		count--
+		bytes -= uint64(len(txEntry.Tx.Raw))
+		if !isFull(count, bytes) {
+			return victims, true
+		}
+	}
+	return nil, false
+}
+
+// IsUnderpriced checks whether the pool is full and the tx doesn't pay
+// more than the lowest gas price in the pool, so it can't be added.
+func (tp *TXPool) IsUnderpriced(tx *types.Transaction) bool {
+	tp.RLock()
+	defer tp.RUnlock()
+	if !isFull(len(tp.txList)+1, tp.bytes+uint64(len(tx.Raw))) {
+		return false
+	}
+	return tp.prices.Len() == 0 || tp.prices.entries[0].Tx.GasPrice >= tx.GasPrice
+}
+
+// ExpireTxs sets the current block height and drops the transactions
+// which have stayed in the pool for the configured blocks.
+func (tp *TXPool) ExpireTxs(height uint32) []*types.Transaction {
+	tp.Lock()
+	defer tp.Unlock()
+	tp.height = height
+
+	expired := make([]*types.Transaction, 0)
+	ttl := getTxTTL()
+	if ttl == 0 {
+		return expired
+	}
+	for hash, h := range tp.txHeight {
+		if h+ttl > height {
+			continue
+		}
+		if txEntry, ok := tp.txList[hash]; ok {
+			expired = append(expired, txEntry.Tx)
+			tp.delTx(hash)
+		}
+		delete(tp.txHeight, hash)
+	}
+	if len(expired) > 0 {
+		log.Debugf("ExpireTxs: %d transactions expired at height %d",
+			len(expired), height)
+	}
+	return expired
+}
+
+// GetTransactionBytes returns the total size of the transactions in the pool
+func (tp *TXPool) GetTransactionBytes() uint64 {
+	tp.RLock()
+	defer tp.RUnlock()
+	return tp.bytes
+}
+
+// delTx removes the transaction from the pool, the payer's queue and the
+// price heap, the caller should hold the lock. It keeps the height when
+// the transaction entered the pool for re-verification.
+func (tp *TXPool) delTx(txHash common.Uint256) {
+	txEntry, ok := tp.txList[txHash]
+	if !ok {
+		return
+	}
+	delete(tp.txList, txHash)
+	tp.bytes -= uint64(len(txEntry.Tx.Raw))
+	if i, ok := tp.prices.index[txHash]; ok {
+		heap.Remove(&tp.prices, i)
+	}
+
+	payer, nonce := txEntry.Tx.Payer, txEntry.Tx.Nonce
+	if queue := tp.payerTxs[payer]; queue != nil && queue[nonce] == txHash {
+		delete(queue, nonce)
+		if len(queue) == 0 {
+			delete(tp.payerTxs, payer)
+		}
+	}
+}
+
+// GetPayerTxCount returns the number of the payer's transactions in the pool
+func (tp *TXPool) GetPayerTxCount(payer common.Address) int {
+	tp.RLock()
+	defer tp.RUnlock()
+	return len(tp.payerTxs[payer])
 }
 
 // CleanTransactionList cleans the transaction list included in the ledger.
@@ -84,9 +295,10 @@ func (tp *TXPool) CleanTransactionList(txs []*types.Transaction) error {
 	defer tp.Unlock()
 	for _, tx := range txs {
 		if _, ok := tp.txList[tx.Hash()]; ok {
-			delete(tp.txList, tx.Hash())
+			tp.delTx(tx.Hash())
 			cleaned++
 		}
+		delete(tp.txHeight, tx.Hash())
 	}
 
 	log.Debugf("CleanTransactionList: transaction %d requested,%d cleaned, remains %d in TxPool",
@@ -94,15 +306,30 @@ func (tp *TXPool) CleanTransactionList(txs []*types.Transaction) error {
 	return nil
 }
 
-// DelTxList removes a single transaction from the pool.
+// DelTxList removes a single transaction from the pool, and forgets the
+// height when it entered the pool.
 func (tp *TXPool) DelTxList(tx *types.Transaction) bool {
 	tp.Lock()
 	defer tp.Unlock()
 	txHash := tx.Hash()
+	delete(tp.txHeight, txHash)
 	if _, ok := tp.txList[txHash]; !ok {
 		return false
 	}
-	delete(tp.txList, txHash)
+	tp.delTx(txHash)
+	return true
+}
+
+// RemainTx removes a single transaction from the pool to re-verify it,
+// it keeps the height when it entered the pool like Remain.
+func (tp *TXPool) RemainTx(tx *types.Transaction) bool {
+	tp.Lock()
+	defer tp.Unlock()
+	txHash := tx.Hash()
+	if _, ok := tp.txList[txHash]; !ok {
+		return false
+	}
+	tp.delTx(txHash)
 	return true
 }
 
@@ -122,16 +349,23 @@ func (tp *TXPool) compareTxHeight(txEntry *TXEntry, height uint32) bool {
 // GetTxPool gets the transaction lists from the pool for the consensus,
 // if the byCount is marked, return the configured number at most; if the
 // the byCount is not marked, return all of the current transaction pool.
+// The transactions of a payer are ordered by nonce, and the payers are
+// ordered by the gas price of their next transaction.
 func (tp *TXPool) GetTxPool(byCount bool, height uint32) ([]*TXEntry,
 	[]*types.Transaction) {
 	tp.RLock()
 	defer tp.RUnlock()
 
-	orderByFee := make([]*TXEntry, 0, len(tp.txList))
-	for _, txEntry := range tp.txList {
-		orderByFee = append(orderByFee, txEntry)
+	queues := make(payerQueues, 0, len(tp.payerTxs))
+	for _, nonces := range tp.payerTxs {
+		queue := make([]*TXEntry, 0, len(nonces))
+		for _, hash := range nonces {
+			queue = append(queue, tp.txList[hash])
+		}
+		sort.Sort(OrderByNonce(queue))
+		queues = append(queues, queue)
 	}
-	sort.Sort(OrderByNetWorkFee(orderByFee))
+	heap.Init(&queues)
 
 	count := int(config.DefConfig.Consensus.MaxTxInBlock)
 	if count <= 0 {
@@ -144,16 +378,21 @@ func (tp *TXPool) GetTxPool(byCount bool, height uint32) ([]*TXEntry,
 	var num int
 	txList := make([]*TXEntry, 0, count)
 	oldTxList := make([]*types.Transaction, 0)
-	for _, txEntry := range orderByFee {
+	for num < count && queues.Len() > 0 {
+		txEntry := queues[0][0]
+		if len(queues[0]) > 1 {
+			queues[0] = queues[0][1:]
+			heap.Fix(&queues, 0)
+		} else {
+			heap.Pop(&queues)
+		}
+
 		if !tp.compareTxHeight(txEntry, height) {
 			oldTxList = append(oldTxList, txEntry.Tx)
 			continue
 		}
 		txList = append(txList, txEntry)
 		num++
-		if num >= count {
-			break
-		}
 	}
 
 	return txList, oldTxList
@@ -214,7 +453,7 @@ func (tp *TXPool) GetUnverifiedTxs(txs []*types.Transaction,
 		}
 
 		if !tp.compareTxHeight(txEntry, height) {
-			delete(tp.txList, tx.Hash())
+			tp.delTx(tx.Hash())
 			res.OldTxs = append(res.OldTxs, txEntry.Tx)
 			continue
 		}
@@ -236,15 +475,36 @@ func (tp *TXPool) GetUnverifiedTxs(txs []*types.Transaction,
 	return res
 }
 
-// RemoveTxsBelowGasPrice drops all transactions below the gas price
-func (tp *TXPool) RemoveTxsBelowGasPrice(gasPrice uint64) {
+// RemoveTxsBelowGasPrice drops all transactions below the gas price,
+// and returns the removed ones
+func (tp *TXPool) RemoveTxsBelowGasPrice(gasPrice uint64) []*types.Transaction {
 	tp.Lock()
 	defer tp.Unlock()
+	removed := make([]*types.Transaction, 0)
 	for _, txEntry := range tp.txList {
 		if txEntry.Tx.GasPrice < gasPrice {
-			delete(tp.txList, txEntry.Tx.Hash())
+			tp.delTx(txEntry.Tx.Hash())
+			delete(tp.txHeight, txEntry.Tx.Hash())
+			removed = append(removed, txEntry.Tx)
 		}
 	}
+	return removed
+}
+
+// RemoveTxsNotAllowed drops all transactions rejected by the allowed check,
+// and returns the removed ones
+func (tp *TXPool) RemoveTxsNotAllowed(allowed func(*types.Transaction) bool) []*types.Transaction {
+	tp.Lock()
+	defer tp.Unlock()
+	removed := make([]*types.Transaction, 0)
+	for _, txEntry := range tp.txList {
+		if !allowed(txEntry.Tx) {
+			tp.delTx(txEntry.Tx.Hash())
+			delete(tp.txHeight, txEntry.Tx.Hash())
+			removed = append(removed, txEntry.Tx)
+		}
+	}
+	return removed
 }
 
 // Remain returns the remaining tx list to cleanup
@@ -255,8 +515,60 @@ func (tp *TXPool) Remain() []*types.Transaction {
 	txList := make([]*types.Transaction, 0, len(tp.txList))
 	for _, txEntry := range tp.txList {
 		txList = append(txList, txEntry.Tx)
-		delete(tp.txList, txEntry.Tx.Hash())
+		tp.delTx(txEntry.Tx.Hash())
 	}
 
 	return txList
 }
+
+// getMaxTxPerPayer returns the configured limit of a payer's transactions
+func getMaxTxPerPayer() int {
+	if config.DefConfig.TxPool == nil {
+		return config.DEFAULT_TXPOOL_MAX_TX_PER_PAYER
+	}
+	return int(config.DefConfig.TxPool.MaxTxPerPayer)
+}
+
+// getMinReplaceBump returns the configured gas price bump in percentage
+// to replace a transaction
+func getMinReplaceBump() uint {
+	if config.DefConfig.TxPool == nil {
+		return config.DEFAULT_TXPOOL_MIN_REPLACE_BUMP
+	}
+	return config.DefConfig.TxPool.MinReplaceBump
+}
+
+// isReplaceBumped checks whether the gas price is bumped from the old one
+// by the percentage, it's computed in big integers to avoid overflow
+func isReplaceBumped(gasPrice, oldGasPrice uint64, bump uint) bool {
+	price := new(big.Int).SetUint64(gasPrice)
+	price.Mul(price, big.NewInt(100))
+	min := new(big.Int).SetUint64(oldGasPrice)
+	min.Mul(min, new(big.Int).SetUint64(uint64(100+bump)))
+	return price.Cmp(min) >= 0
+}
+
+// getMaxTxs returns the configured max number of transactions in the pool
+func getMaxTxs() int {
+	if config.DefConfig.TxPool == nil {
+		return config.DEFAULT_TXPOOL_MAX_TXS
+	}
+	return int(config.DefConfig.TxPool.MaxTxs)
+}
+
+// getMaxBytes returns the configured max size of transactions in the pool
+func getMaxBytes() uint64 {
+	if config.DefConfig.TxPool == nil {
+		return config.DEFAULT_TXPOOL_MAX_BYTES
+	}
+	return config.DefConfig.TxPool.MaxBytes
+}
+
+// getTxTTL returns the configured blocks that a transaction can stay in
+// the pool
+func getTxTTL() uint32 {
+	if config.DefConfig.TxPool == nil {
+		return config.DEFAULT_TXPOOL_TX_TTL
+	}
+	return config.DefConfig.TxPool.TxTTL
+}
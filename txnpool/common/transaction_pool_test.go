@@ -19,12 +19,16 @@
 package common
 
 import (
+	"math"
 	"testing"
 	"time"
 
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
 	"github.com/dnaproject2/DNA/core/payload"
 	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -99,3 +103,230 @@ func TestTxPool(t *testing.T) {
 		return
 	}
 }
+
+func newPayerTx(payer common.Address, nonce uint32, gasPrice uint64) *TXEntry {
+	mutable := &types.MutableTransaction{
+		TxType:   types.Invoke,
+		Nonce:    nonce,
+		GasPrice: gasPrice,
+		Payer:    payer,
+		Payload:  &payload.InvokeCode{Code: []byte{}},
+	}
+	tx, _ := mutable.IntoImmutable()
+	return &TXEntry{
+		Tx:    tx,
+		Attrs: []*TXAttr{},
+	}
+}
+
+func addTxEntry(txPool *TXPool, txEntry *TXEntry) errors.ErrCode {
+	_, _, ret := txPool.AddTxEntry(txEntry)
+	return ret
+}
+
+func TestTxPoolPayerQueue(t *testing.T) {
+	txPool := &TXPool{}
+	txPool.Init()
+
+	payer1 := common.Address{1}
+	payer2 := common.Address{2}
+
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer1, 3, 500)))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer1, 1, 100)))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer1, 2, 300)))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer2, 1, 200)))
+	assert.Equal(t, 3, txPool.GetPayerTxCount(payer1))
+
+	// the txs of a payer are ordered by nonce, the payers by the next gas price
+	txList, _ := txPool.GetTxPool(false, 0)
+	assert.Equal(t, 4, len(txList))
+	assert.Equal(t, payer2, txList[0].Tx.Payer)
+	for i, nonce := range []uint32{1, 2, 3} {
+		assert.Equal(t, payer1, txList[i+1].Tx.Payer)
+		assert.Equal(t, nonce, txList[i+1].Tx.Nonce)
+	}
+
+	// replace-by-fee needs the min bump
+	assert.Equal(t, errors.ErrReplaceUnderpriced, addTxEntry(txPool, newPayerTx(payer1, 1, 105)))
+	replace := newPayerTx(payer1, 1, 110)
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, replace))
+	assert.Equal(t, 3, txPool.GetPayerTxCount(payer1))
+	assert.Equal(t, 4, txPool.GetTransactionCount())
+	assert.NotNil(t, txPool.GetTransaction(replace.Tx.Hash()))
+
+	txPool.DelTxList(replace.Tx)
+	assert.Equal(t, 2, txPool.GetPayerTxCount(payer1))
+
+	// the bump doesn't overflow with huge gas prices
+	huge := uint64(math.MaxUint64 / 2)
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer2, 2, huge)))
+	assert.Equal(t, errors.ErrReplaceUnderpriced, addTxEntry(txPool, newPayerTx(payer2, 2, huge+1)))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer2, 2, math.MaxUint64)))
+}
+
+func TestIsReplaceBumped(t *testing.T) {
+	assert.True(t, isReplaceBumped(110, 100, 10))
+	assert.False(t, isReplaceBumped(109, 100, 10))
+	assert.True(t, isReplaceBumped(100, 100, 0))
+	assert.False(t, isReplaceBumped(math.MaxUint64, math.MaxUint64/2+1, 100))
+	assert.True(t, isReplaceBumped(math.MaxUint64-1, math.MaxUint64/2, 100))
+}
+
+func TestTxPoolPayerLimit(t *testing.T) {
+	old := config.DefConfig.TxPool.MaxTxPerPayer
+	config.DefConfig.TxPool.MaxTxPerPayer = 2
+	defer func() { config.DefConfig.TxPool.MaxTxPerPayer = old }()
+
+	txPool := &TXPool{}
+	txPool.Init()
+
+	payer := common.Address{1}
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer, 1, 500)))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer, 2, 500)))
+	assert.Equal(t, errors.ErrPayerLimit, addTxEntry(txPool, newPayerTx(payer, 3, 500)))
+	// a replacement is not limited
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(payer, 2, 600)))
+
+	txPool.CleanTransactionList(nil)
+	txPool.Remain()
+	assert.Equal(t, 0, txPool.GetPayerTxCount(payer))
+}
+
+func TestTxPoolEviction(t *testing.T) {
+	old := config.DefConfig.TxPool.MaxTxs
+	config.DefConfig.TxPool.MaxTxs = 2
+	defer func() { config.DefConfig.TxPool.MaxTxs = old }()
+
+	txPool := &TXPool{}
+	txPool.Init()
+
+	low := newPayerTx(common.Address{1}, 1, 100)
+	high := newPayerTx(common.Address{2}, 1, 300)
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, low))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, high))
+
+	// not paying more than the lowest one is rejected
+	under := newPayerTx(common.Address{3}, 1, 100)
+	assert.True(t, txPool.IsUnderpriced(under.Tx))
+	assert.Equal(t, errors.ErrTxPoolFull, addTxEntry(txPool, under))
+
+	// the lowest one is evicted
+	mid := newPayerTx(common.Address{3}, 1, 200)
+	assert.False(t, txPool.IsUnderpriced(mid.Tx))
+	replaced, evicted, ret := txPool.AddTxEntry(mid)
+	assert.Equal(t, errors.ErrNoError, ret)
+	assert.Nil(t, replaced)
+	assert.Equal(t, 1, len(evicted))
+	assert.Equal(t, low.Tx.Hash(), evicted[0].Hash())
+	assert.Equal(t, 2, txPool.GetTransactionCount())
+	assert.Nil(t, txPool.GetTransaction(low.Tx.Hash()))
+	assert.Equal(t, uint64(len(high.Tx.Raw)+len(mid.Tx.Raw)), txPool.GetTransactionBytes())
+
+	// a replacement doesn't need room
+	replace := newPayerTx(common.Address{3}, 1, 250)
+	replaced, evicted, ret = txPool.AddTxEntry(replace)
+	assert.Equal(t, errors.ErrNoError, ret)
+	assert.Equal(t, mid.Tx.Hash(), replaced.Hash())
+	assert.Equal(t, 0, len(evicted))
+	assert.Equal(t, 2, txPool.GetTransactionCount())
+}
+
+func TestTxPoolPriceHeap(t *testing.T) {
+	old := config.DefConfig.TxPool.MaxTxs
+	config.DefConfig.TxPool.MaxTxs = 4
+	defer func() { config.DefConfig.TxPool.MaxTxs = old }()
+
+	txPool := &TXPool{}
+	txPool.Init()
+
+	entries := make([]*TXEntry, 0)
+	for i, price := range []uint64{400, 100, 300, 200} {
+		entry := newPayerTx(common.Address{byte(i + 1)}, 1, price)
+		assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, entry))
+		entries = append(entries, entry)
+	}
+
+	// the deleted tx is no longer the cheapest one
+	txPool.DelTxList(entries[1].Tx)
+	assert.Equal(t, 3, txPool.prices.Len())
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(common.Address{5}, 1, 250)))
+	assert.True(t, txPool.IsUnderpriced(newPayerTx(common.Address{6}, 1, 200).Tx))
+	assert.False(t, txPool.IsUnderpriced(newPayerTx(common.Address{6}, 1, 201).Tx))
+
+	// the txs are evicted by the gas price ascending
+	for _, price := range []uint64{200, 250, 300} {
+		_, evicted, ret := txPool.AddTxEntry(newPayerTx(common.Address{6}, uint32(price), 1000))
+		assert.Equal(t, errors.ErrNoError, ret)
+		assert.Equal(t, 1, len(evicted))
+		assert.Equal(t, price, evicted[0].GasPrice)
+	}
+	assert.Equal(t, errors.ErrTxPoolFull, addTxEntry(txPool, newPayerTx(common.Address{7}, 1, 400)))
+	assert.Equal(t, 4, txPool.GetTransactionCount())
+	assert.Equal(t, txPool.GetTransactionCount(), txPool.prices.Len())
+	for hash, i := range txPool.prices.index {
+		assert.Equal(t, hash, txPool.prices.entries[i].Tx.Hash())
+	}
+}
+
+func TestTxPoolAges(t *testing.T) {
+	old := config.DefConfig.TxPool.TxTTL
+	config.DefConfig.TxPool.TxTTL = 10
+	defer func() { config.DefConfig.TxPool.TxTTL = old }()
+
+	txPool := &TXPool{}
+	txPool.Init()
+
+	first := newPayerTx(common.Address{1}, 1, 100)
+	second := newPayerTx(common.Address{2}, 1, 100)
+	third := newPayerTx(common.Address{3}, 1, 500)
+	for _, entry := range []*TXEntry{first, second, third} {
+		assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, entry))
+	}
+	assert.Equal(t, 3, len(txPool.txHeight))
+
+	// a tx to be re-verified keeps its age until it's dropped
+	assert.True(t, txPool.RemainTx(first.Tx))
+	assert.Equal(t, 3, len(txPool.txHeight))
+	assert.False(t, txPool.DelTxList(first.Tx))
+	assert.Equal(t, 2, len(txPool.txHeight))
+
+	// the replaced tx forgets its age
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, newPayerTx(common.Address{2}, 1, 200)))
+	_, ok := txPool.txHeight[second.Tx.Hash()]
+	assert.False(t, ok)
+	assert.Equal(t, 2, len(txPool.txHeight))
+
+	assert.Equal(t, 1, len(txPool.RemoveTxsBelowGasPrice(300)))
+	assert.Equal(t, 1, len(txPool.txHeight))
+	assert.True(t, txPool.DelTxList(third.Tx))
+	assert.Equal(t, 0, len(txPool.txHeight))
+	assert.Equal(t, 0, txPool.prices.Len())
+}
+
+func TestTxPoolExpiry(t *testing.T) {
+	old := config.DefConfig.TxPool.TxTTL
+	config.DefConfig.TxPool.TxTTL = 10
+	defer func() { config.DefConfig.TxPool.TxTTL = old }()
+
+	txPool := &TXPool{}
+	txPool.Init()
+	txPool.SetHeight(100)
+
+	first := newPayerTx(common.Address{1}, 1, 500)
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, first))
+	assert.Equal(t, 0, len(txPool.ExpireTxs(105)))
+
+	second := newPayerTx(common.Address{1}, 2, 500)
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, second))
+
+	// a re-verified tx keeps its age
+	txPool.Remain()
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, first))
+	assert.Equal(t, errors.ErrNoError, addTxEntry(txPool, second))
+
+	expired := txPool.ExpireTxs(110)
+	assert.Equal(t, 1, len(expired))
+	assert.Equal(t, first.Tx.Hash(), expired[0].Hash())
+	assert.Equal(t, 1, len(txPool.ExpireTxs(115)))
+	assert.Equal(t, 0, txPool.GetTransactionCount())
+}
@@ -25,7 +25,6 @@ import (
 )
 
 const (
-	MAX_CAPACITY     = 100140                           // The tx pool's capacity that holds the verified txs
 	MAX_PENDING_TXN  = 4096 * 10                        // The max length of pending txs
 	MAX_WORKER_NUM   = 2                                // The max concurrent workers
 	MAX_RCV_TXN_LEN  = MAX_WORKER_NUM * MAX_PENDING_TXN // The max length of the queue that server can hold
@@ -37,6 +36,7 @@ const (
 	MAX_LIMITATION   = 10000                            // The length of pending tx from net and http
 	UPDATE_FREQUENCY = 100                              // The frequency to update gas price from global params
 	MAX_TX_SIZE      = 1024 * 1024                      // The max size of a transaction to prevent DOS attacks
+	JOURNAL_DIR      = "txpool"                         // The dir of the journal under the store dir
 )
 
 // ActorType enumerates the kind of actor
@@ -84,6 +84,8 @@ const (
 	DuplicateStats              // The count that the transactions are duplicated input
 	SigErrStats                 // The count that the transactions' signature error
 	StateErrStats               // The count that the transactions are invalid in database
+	EvictedStats                // The count that the transactions are evicted when the pool is full
+	ExpiredStats                // The count that the transactions are expired in the pool
 
 	MaxStats
 )
@@ -252,3 +254,82 @@ func (n OrderByNetWorkFee) Len() int { return len(n) }
 func (n OrderByNetWorkFee) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
 
 func (n OrderByNetWorkFee) Less(i, j int) bool { return n[j].Tx.GasPrice < n[i].Tx.GasPrice }
+
+type OrderByNonce []*TXEntry
+
+func (n OrderByNonce) Len() int { return len(n) }
+
+func (n OrderByNonce) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+
+func (n OrderByNonce) Less(i, j int) bool { return n[i].Tx.Nonce < n[j].Tx.Nonce }
+
+// OrderByGasPrice orders the transactions by the gas price ascending,
+// and the later nonce goes first with the same gas price
+type OrderByGasPrice []*TXEntry
+
+func (n OrderByGasPrice) Len() int { return len(n) }
+
+func (n OrderByGasPrice) Swap(i, j int) { n[i], n[j] = n[j], n[i] }
+
+func (n OrderByGasPrice) Less(i, j int) bool {
+	if n[i].Tx.GasPrice != n[j].Tx.GasPrice {
+		return n[i].Tx.GasPrice < n[j].Tx.GasPrice
+	}
+	return n[j].Tx.Nonce < n[i].Tx.Nonce
+}
+
+// priceHeap is a min heap of the transactions ordered by OrderByGasPrice,
+// it indexes the position of each transaction to remove it.
+type priceHeap struct {
+	entries []*TXEntry
+	index   map[common.Uint256]int
+}
+
+func newPriceHeap() priceHeap {
+	return priceHeap{index: make(map[common.Uint256]int)}
+}
+
+func (h *priceHeap) Len() int { return len(h.entries) }
+
+func (h *priceHeap) Less(i, j int) bool { return OrderByGasPrice(h.entries).Less(i, j) }
+
+func (h *priceHeap) Swap(i, j int) {
+	h.entries[i], h.entries[j] = h.entries[j], h.entries[i]
+	h.index[h.entries[i].Tx.Hash()] = i
+	h.index[h.entries[j].Tx.Hash()] = j
+}
+
+func (h *priceHeap) Push(x interface{}) {
+	txEntry := x.(*TXEntry)
+	h.index[txEntry.Tx.Hash()] = len(h.entries)
+	h.entries = append(h.entries, txEntry)
+}
+
+func (h *priceHeap) Pop() interface{} {
+	n := len(h.entries)
+	txEntry := h.entries[n-1]
+	h.entries[n-1] = nil
+	h.entries = h.entries[:n-1]
+	delete(h.index, txEntry.Tx.Hash())
+	return txEntry
+}
+
+// payerQueues is a max heap of the payers' nonce ordered queues by the
+// gas price of the queue head
+type payerQueues [][]*TXEntry
+
+func (q payerQueues) Len() int { return len(q) }
+
+func (q payerQueues) Swap(i, j int) { q[i], q[j] = q[j], q[i] }
+
+func (q payerQueues) Less(i, j int) bool { return q[j][0].Tx.GasPrice < q[i][0].Tx.GasPrice }
+
+func (q *payerQueues) Push(x interface{}) { *q = append(*q, x.([]*TXEntry)) }
+
+func (q *payerQueues) Pop() interface{} {
+	old := *q
+	n := len(old)
+	x := old[n-1]
+	*q = old[:n-1]
+	return x
+}
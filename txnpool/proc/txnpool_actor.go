@@ -27,6 +27,7 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/consensus/policy"
 	"github.com/dnaproject2/DNA/core/ledger"
 	tx "github.com/dnaproject2/DNA/core/types"
 	"github.com/dnaproject2/DNA/errors"
@@ -62,7 +63,7 @@ func NewVerifyRspActor(s *TXPoolServer) *VerifyRspActor {
 
 // isBalanceEnough checks if the tranactor has enough to cover gas cost
 func isBalanceEnough(address common.Address, gas uint64) bool {
-	balance, err := hComm.GetContractBalance(0, utils.OngContractAddress, address)
+	balance, err := hComm.GetContractBalance(0, utils.OngContractAddress, address, hComm.LATEST_HEIGHT)
 	if err != nil {
 		log.Debugf("failed to get contract balance %s err %v",
 			address.ToHexString(), err)
@@ -140,7 +141,7 @@ func (ta *TxActor) handleTransaction(sender tc.SenderType, self *actor.PID,
 			replyTxResult(txResultCh, txn.Hash(), errors.ErrDuplicateInput,
 				fmt.Sprintf("transaction %x is already in the tx pool", txn.Hash()))
 		}
-	} else if ta.server.getTransactionCount() >= tc.MAX_CAPACITY {
+	} else if ta.server.txPool.IsUnderpriced(txn) {
 		log.Debugf("handleTransaction: transaction pool is full for tx %x",
 			txn.Hash())
 
@@ -149,6 +150,15 @@ func (ta *TxActor) handleTransaction(sender tc.SenderType, self *actor.PID,
 			replyTxResult(txResultCh, txn.Hash(), errors.ErrTxPoolFull,
 				"transaction pool is full")
 		}
+	} else if !policy.CheckTransaction(txn) {
+		log.Debugf("handleTransaction: payer %s of tx %x denied by policy",
+			txn.Payer.ToBase58(), txn.Hash())
+
+		ta.server.increaseStats(tc.FailureStats)
+		if sender == tc.HttpSender && txResultCh != nil {
+			replyTxResult(txResultCh, txn.Hash(), errors.ErrPolicyDenied,
+				fmt.Sprintf("payer %s is denied by policy", txn.Payer.ToBase58()))
+		}
 	} else {
 		if _, overflow := common.SafeMul(txn.GasLimit, txn.GasPrice); overflow {
 			log.Debugf("handleTransaction: gasLimit %v, gasPrice %v overflow",
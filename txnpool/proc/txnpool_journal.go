@@ -0,0 +1,116 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proc
+
+import (
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/ledger"
+	"github.com/dnaproject2/DNA/core/store/leveldbstore"
+	tx "github.com/dnaproject2/DNA/core/types"
+)
+
+// txJournal keeps the raw transactions admitted to the tx pool in a
+// local leveldb, so that they can be replayed after the node restarts.
+// A nil journal ignores all of the operations.
+type txJournal struct {
+	store *leveldbstore.LevelDBStore
+}
+
+// newTxJournal opens the journal in the file
+func newTxJournal(file string) (*txJournal, error) {
+	store, err := leveldbstore.NewLevelDBStore(file)
+	if err != nil {
+		return nil, err
+	}
+	return &txJournal{store: store}, nil
+}
+
+// insert saves a transaction to the journal, a re-verified tx is
+// already in the journal
+func (j *txJournal) insert(t *tx.Transaction) {
+	if j == nil {
+		return
+	}
+	hash := t.Hash()
+	if ok, _ := j.store.Has(hash.ToArray()); ok {
+		return
+	}
+	if err := j.store.Put(hash.ToArray(), t.ToArray()); err != nil {
+		log.Warnf("txJournal: failed to insert tx %x: %s", hash, err)
+	}
+}
+
+// remove deletes a transaction from the journal
+func (j *txJournal) remove(hash common.Uint256) {
+	if j == nil {
+		return
+	}
+	if err := j.store.Delete(hash.ToArray()); err != nil {
+		log.Warnf("txJournal: failed to remove tx %x: %s", hash, err)
+	}
+}
+
+// load reads the transactions out of the journal, they are kept until the
+// pool rejects them or drops them later. The undecodable ones and those
+// already in the ledger are removed.
+func (j *txJournal) load() []*tx.Transaction {
+	if j == nil {
+		return nil
+	}
+	txs := make([]*tx.Transaction, 0)
+	stale := make([][]byte, 0)
+	iter := j.store.NewIterator(nil)
+	for iter.Next() {
+		t, err := tx.TransactionFromRawBytes(iter.Value())
+		if err != nil {
+			log.Warnf("txJournal: failed to decode tx %x: %s", iter.Key(), err)
+			stale = append(stale, append([]byte{}, iter.Key()...))
+			continue
+		}
+		if ledger.DefLedger != nil {
+			if ok, _ := ledger.DefLedger.IsContainTransaction(t.Hash()); ok {
+				stale = append(stale, append([]byte{}, iter.Key()...))
+				continue
+			}
+		}
+		txs = append(txs, t)
+	}
+	iter.Release()
+	if err := iter.Error(); err != nil {
+		log.Warnf("txJournal: failed to iterate: %s", err)
+	}
+
+	for _, key := range stale {
+		if err := j.store.Delete(key); err != nil {
+			log.Warnf("txJournal: failed to remove tx %x: %s", key, err)
+		}
+	}
+	return txs
+}
+
+// close closes the journal
+func (j *txJournal) close() {
+	if j == nil {
+		return
+	}
+	if err := j.store.Close(); err != nil {
+		log.Warnf("txJournal: failed to close: %s", err)
+	}
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package proc
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/payload"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTxJournal(t *testing.T) {
+	dir, err := ioutil.TempDir("", "txpool")
+	assert.Nil(t, err)
+	defer os.RemoveAll(dir)
+
+	journal, err := newTxJournal(dir)
+	assert.Nil(t, err)
+
+	txs := make([]*types.Transaction, 0)
+	for i := uint32(0); i < 3; i++ {
+		mutable := &types.MutableTransaction{
+			TxType:  types.Invoke,
+			Nonce:   i,
+			Payload: &payload.InvokeCode{Code: []byte("ont")},
+		}
+		tx, err := mutable.IntoImmutable()
+		assert.Nil(t, err)
+		journal.insert(tx)
+		txs = append(txs, tx)
+	}
+	journal.insert(txs[0])
+	journal.remove(txs[1].Hash())
+	journal.close()
+
+	// the journal survives a restart
+	journal, err = newTxJournal(dir)
+	assert.Nil(t, err)
+	defer journal.close()
+
+	loaded := journal.load()
+	assert.Equal(t, 2, len(loaded))
+	hashes := map[common.Uint256]bool{}
+	for _, tx := range loaded {
+		hashes[tx.Hash()] = true
+	}
+	assert.True(t, hashes[txs[0].Hash()])
+	assert.True(t, hashes[txs[2].Hash()])
+
+	// the loaded txs are kept until the pool handles them
+	assert.Equal(t, 2, len(journal.load()))
+	journal.remove(txs[0].Hash())
+	assert.Equal(t, 1, len(journal.load()))
+
+	// the undecodable ones are dropped
+	assert.Nil(t, journal.store.Put([]byte("bad"), []byte("bad tx")))
+	assert.Equal(t, 1, len(journal.load()))
+	ok, err := journal.store.Has([]byte("bad"))
+	assert.Nil(t, err)
+	assert.False(t, ok)
+
+	// a nil journal is a no-op
+	var nilJournal *txJournal
+	nilJournal.insert(txs[0])
+	assert.Nil(t, nilJournal.load())
+}
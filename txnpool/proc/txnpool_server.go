@@ -27,9 +27,12 @@ import (
 	"github.com/dnaproject2/DNA/common"
 	"github.com/dnaproject2/DNA/common/config"
 	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/consensus/policy"
 	"github.com/dnaproject2/DNA/core/ledger"
 	tx "github.com/dnaproject2/DNA/core/types"
 	"github.com/dnaproject2/DNA/errors"
+	"github.com/dnaproject2/DNA/events"
+	"github.com/dnaproject2/DNA/events/message"
 	httpcom "github.com/dnaproject2/DNA/http/base/common"
 	params "github.com/dnaproject2/DNA/smartcontract/service/native/global_params"
 	nutils "github.com/dnaproject2/DNA/smartcontract/service/native/utils"
@@ -39,6 +42,7 @@ import (
 	"sort"
 	"strconv"
 	"sync"
+	"time"
 )
 
 type txStats struct {
@@ -80,12 +84,14 @@ type TXPoolServer struct {
 	pendingBlock          *pendingBlock                       // The block that server is processing
 	actors                map[tc.ActorType]*actor.PID         // The actors running in the server
 	validators            *registerValidators                 // The registered validators
+	verifyMask            uint8                               // The mask of verify types a valid tx must pass
 	stats                 txStats                             // The transaction statstics
 	slots                 chan struct{}                       // The limited slots for the new transaction
 	height                uint32                              // The current block height
 	gasPrice              uint64                              // Gas price to enforce for acceptance into the pool
 	disablePreExec        bool                                // Disbale PreExecute a transaction
 	disableBroadcastNetTx bool                                // Disable broadcast tx from network
+	journal               *txJournal                          // The journal of the admitted transactions
 }
 
 // NewTxPoolServer creates a new tx pool server to schedule workers to
@@ -153,6 +159,9 @@ func (s *TXPoolServer) init(num uint8, disablePreExec, disableBroadcastNetTx boo
 	// Initial txnPool
 	s.txPool = &tc.TXPool{}
 	s.txPool.Init()
+	if ledger.DefLedger != nil {
+		s.txPool.SetHeight(ledger.DefLedger.GetCurrentBlockHeight())
+	}
 	s.allPendingTxs = make(map[common.Uint256]*serverPendingTx)
 	s.actors = make(map[tc.ActorType]*actor.PID)
 
@@ -163,6 +172,15 @@ func (s *TXPoolServer) init(num uint8, disablePreExec, disableBroadcastNetTx boo
 		},
 	}
 
+	// Each configured validator plugin has its own verify type, a tx is
+	// valid only if all of the configured types are passed
+	s.verifyMask = tc.VERIFY_MASK
+	if config.DefConfig.TxPool != nil {
+		for i := range config.DefConfig.TxPool.Validators {
+			s.verifyMask |= 0x1 << types.PluginVerifyType(i)
+		}
+	}
+
 	s.pendingBlock = &pendingBlock{
 		processedTxs:   make(map[common.Uint256]*tc.VerifyTxResult, 0),
 		unProcessedTxs: make(map[common.Uint256]*tx.Transaction, 0),
@@ -278,6 +296,14 @@ func (s *TXPoolServer) removePendingTx(hash common.Uint256,
 		replyTxResult(pt.ch, hash, err, err.Error())
 	}
 
+	// Notify the subscribers of a new tx admitted to the pool, the
+	// re-verified and replayed txs have been notified before
+	if err == errors.ErrNoError && pt.sender != tc.NilSender &&
+		events.DefActorPublisher != nil {
+		events.DefActorPublisher.Publish(message.TOPIC_PENDING_TX,
+			&message.PendingTxMsg{Tx: pt.tx})
+	}
+
 	delete(s.allPendingTxs, hash)
 
 	if len(s.allPendingTxs) < tc.MAX_LIMITATION {
@@ -290,6 +316,16 @@ func (s *TXPoolServer) removePendingTx(hash common.Uint256,
 
 	s.mu.Unlock()
 
+	if err != errors.ErrNoError && err != errors.ErrDuplicateInput {
+		s.delTransaction(pt.tx)
+	}
+
+	// The tx rejected by the pool's payer queue or capacity is still
+	// valid in a block
+	if err == errors.ErrReplaceUnderpriced || err == errors.ErrPayerLimit ||
+		err == errors.ErrTxPoolFull {
+		err = errors.ErrNoError
+	}
 	// Check if the tx is in the pending block and
 	// the pending block is verified
 	s.checkPendingBlockOk(hash, err)
@@ -485,6 +521,52 @@ func (s *TXPoolServer) Stop() {
 	if s.slots != nil {
 		close(s.slots)
 	}
+
+	s.journal.close()
+}
+
+// LoadJournal opens the journal of the admitted transactions in the file,
+// and replays the journaled transactions through the validators once they
+// are registered. The journal keeps a replayed transaction until the pool
+// rejects it, so the ones not handled yet survive another restart.
+func (s *TXPoolServer) LoadJournal(file string) error {
+	journal, err := newTxJournal(file)
+	if err != nil {
+		return err
+	}
+	s.journal = journal
+
+	txs := journal.load()
+	if len(txs) == 0 {
+		return nil
+	}
+	go func() {
+		for i := 0; i < tc.EXPIRE_INTERVAL*10 && !s.validatorsReady(); i++ {
+			time.Sleep(100 * time.Millisecond)
+		}
+		log.Infof("tx pool: replay %d transactions from the journal", len(txs))
+		pid := s.GetPID(tc.TxActor)
+		if pid == nil {
+			return
+		}
+		for _, t := range txs {
+			pid.Tell(&tc.TxReq{Tx: t, Sender: tc.NilSender})
+		}
+	}()
+	return nil
+}
+
+// validatorsReady checks whether each verify type required by the
+// verify mask has a registered validator
+func (s *TXPoolServer) validatorsReady() bool {
+	s.validators.RLock()
+	defer s.validators.RUnlock()
+	for t := types.VerifyType(0); t < types.MAX_VERIFY_TYPE; t++ {
+		if s.verifyMask&(0x1<<t) != 0 && len(s.validators.entries[t]) == 0 {
+			return false
+		}
+	}
+	return true
 }
 
 // getTransaction returns a transaction with the transaction hash.
@@ -498,8 +580,9 @@ func (s *TXPoolServer) getTxPool(byCount bool, height uint32) []*tc.TXEntry {
 
 	avlTxList, oldTxList := s.txPool.GetTxPool(byCount, height)
 
+	// The journal keeps the old txs until they're re-verified
 	for _, t := range oldTxList {
-		s.delTransaction(t)
+		s.txPool.RemainTx(t)
 		s.reVerifyStateful(t, tc.NilSender)
 	}
 
@@ -529,6 +612,17 @@ func (s *TXPoolServer) getPendingTxs(byCount bool) []*tx.Transaction {
 func (s *TXPoolServer) cleanTransactionList(txs []*tx.Transaction, height uint32) {
 	s.txPool.CleanTransactionList(txs)
 
+	for _, t := range txs {
+		s.journal.remove(t.Hash())
+	}
+
+	// Drop the txs staying in the pool for too long
+	expired := s.txPool.ExpireTxs(height)
+	for _, t := range expired {
+		s.increaseStats(tc.ExpiredStats)
+		s.journal.remove(t.Hash())
+	}
+
 	// Check whether to update the gas price and remove txs below the
 	// threshold
 	if height%tc.UPDATE_FREQUENCY == 0 {
@@ -543,15 +637,26 @@ func (s *TXPoolServer) cleanTransactionList(txs []*tx.Transaction, height uint32
 		}
 
 		if oldGasPrice < gasPrice {
-			s.txPool.RemoveTxsBelowGasPrice(gasPrice)
+			removed := s.txPool.RemoveTxsBelowGasPrice(gasPrice)
+			for _, t := range removed {
+				s.journal.remove(t.Hash())
+			}
 		}
 	}
+
+	// Reload the policy and remove txs from the denied payers
+	policy.RefreshTxPolicy()
+	removed := s.txPool.RemoveTxsNotAllowed(policy.CheckTransaction)
+	for _, t := range removed {
+		s.journal.remove(t.Hash())
+	}
 	// Cleanup tx pool
 	if !s.disablePreExec {
 		remain := s.txPool.Remain()
 		for _, t := range remain {
 			if ok, _ := preExecCheck(t); !ok {
 				log.Debugf("cleanTransactionList: preExecCheck tx %x failed", t.Hash())
+				s.journal.remove(t.Hash())
 				continue
 			}
 			s.reVerifyStateful(t, tc.NilSender)
@@ -559,16 +664,31 @@ func (s *TXPoolServer) cleanTransactionList(txs []*tx.Transaction, height uint32
 	}
 }
 
-// delTransaction deletes a transaction in the tx pool.
+// delTransaction deletes a rejected transaction in the tx pool and the
+// journal.
 func (s *TXPoolServer) delTransaction(t *tx.Transaction) {
 	s.txPool.DelTxList(t)
-}
-
-// addTxList adds a valid transaction to the tx pool.
-func (s *TXPoolServer) addTxList(txEntry *tc.TXEntry) bool {
-	ret := s.txPool.AddTxList(txEntry)
-	if !ret {
+	s.journal.remove(t.Hash())
+}
+
+// addTxList adds a valid transaction to the tx pool, returns the reason
+// if it's rejected.
+func (s *TXPoolServer) addTxList(txEntry *tc.TXEntry) errors.ErrCode {
+	replaced, evicted, ret := s.txPool.AddTxEntry(txEntry)
+	switch ret {
+	case errors.ErrNoError:
+		s.journal.insert(txEntry.Tx)
+		if replaced != nil {
+			s.journal.remove(replaced.Hash())
+		}
+		for _, t := range evicted {
+			s.increaseStats(tc.EvictedStats)
+			s.journal.remove(t.Hash())
+		}
+	case errors.ErrDuplicateInput:
 		s.increaseStats(tc.DuplicateStats)
+	default:
+		s.increaseStats(tc.FailureStats)
 	}
 	return ret
 }
@@ -703,6 +823,17 @@ func (s *TXPoolServer) verifyBlock(req *tc.VerifyBlockReq, sender *actor.PID) {
 			s.sendBlkResult2Consensus()
 			return
 		}
+		// Check whether the payer is denied by the policy
+		if !policy.CheckTransaction(t) {
+			entry := &tc.VerifyTxResult{
+				Height:  s.pendingBlock.height,
+				Tx:      t,
+				ErrCode: errors.ErrPolicyDenied,
+			}
+			s.pendingBlock.processedTxs[t.Hash()] = entry
+			s.sendBlkResult2Consensus()
+			return
+		}
 		// Check whether double spent
 		if _, ok := txs[t.Hash()]; ok {
 			entry := &tc.VerifyTxResult{
@@ -124,7 +124,7 @@ func (worker *txPoolWorker) handleRsp(rsp *types.CheckResponse) {
 		pt.ret = append(pt.ret, retAttr)
 	}
 
-	if pt.flag&0xf == tc.VERIFY_MASK {
+	if pt.flag&worker.server.verifyMask == worker.server.verifyMask {
 		worker.putTxPool(pt)
 		delete(worker.pendingTxList, rsp.Hash)
 	}
@@ -144,7 +144,7 @@ func (worker *txPoolWorker) handleTimeoutEvent() {
 	 * resend them to the validators
 	 */
 	for k, v := range worker.pendingTxList {
-		if v.flag&0xf != tc.VERIFY_MASK && (time.Now().Sub(v.valTime)/time.Second) >=
+		if v.flag&worker.server.verifyMask != worker.server.verifyMask && (time.Now().Sub(v.valTime)/time.Second) >=
 			tc.EXPIRE_INTERVAL {
 			if v.retries < tc.MAX_RETRIES {
 				worker.reVerifyTx(k)
@@ -167,9 +167,12 @@ func (worker *txPoolWorker) putTxPool(pt *pendingTx) bool {
 		Tx:    pt.tx,
 		Attrs: pt.ret,
 	}
-	worker.server.addTxList(txEntry)
-	worker.server.removePendingTx(pt.tx.Hash(), errors.ErrNoError)
-	return true
+	errCode := worker.server.addTxList(txEntry)
+	if errCode == errors.ErrDuplicateInput {
+		errCode = errors.ErrNoError
+	}
+	worker.server.removePendingTx(pt.tx.Hash(), errCode)
+	return errCode == errors.ErrNoError
 }
 
 // verifyTx prepares a check request and sends it to the validators.
@@ -216,7 +219,7 @@ func (worker *txPoolWorker) reVerifyTx(txHash common.Uint256) {
 		return
 	}
 
-	if pt.flag&0xf != tc.VERIFY_MASK {
+	if pt.flag&worker.server.verifyMask != worker.server.verifyMask {
 		worker.sendReq2Validator(pt.req)
 	}
 
@@ -277,16 +280,22 @@ func (worker *txPoolWorker) verifyStateful(tx *tx.Transaction) {
 		valTime: time.Now(),
 	}
 
-	retAttr := &tc.TXAttr{
-		Height:  0,
-		Type:    types.Stateless,
-		ErrCode: errors.ErrNoError,
+	// Since the signature and the plugins have been already verified,
+	// mark all of the types except stateful as true
+	for t := types.VerifyType(0); t < types.MAX_VERIFY_TYPE; t++ {
+		mask := uint8(0x1 << t)
+		if mask == tc.STATEFUL_MASK || worker.server.verifyMask&mask == 0 {
+			continue
+		}
+		retAttr := &tc.TXAttr{
+			Height:  0,
+			Type:    t,
+			ErrCode: errors.ErrNoError,
+		}
+		pt.ret = append(pt.ret, retAttr)
+		pt.flag |= mask
 	}
 
-	pt.ret = append(pt.ret, retAttr)
-	// Since the signature has been already verified, mark stateless as true
-	pt.flag |= tc.STATELESS_MASK
-
 	// Add it to the pending transaction list
 	worker.mu.Lock()
 	worker.pendingTxList[tx.Hash()] = pt
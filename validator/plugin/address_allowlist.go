@@ -0,0 +1,68 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+package plugin
+
+import (
+	"encoding/json"
+
+	"github.com/dnaproject2/DNA/common"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/errors"
+)
+
+const ADDRESS_ALLOWLIST = "address_allowlist"
+
+func init() {
+	Register(ADDRESS_ALLOWLIST, newAddressAllowlist)
+}
+
+// addressAllowlist only passes the transactions paid by the listed
+// addresses, e.g. the KYC verified accounts
+type addressAllowlist struct {
+	addrs map[common.Address]bool
+}
+
+type addressAllowlistConfig struct {
+	Addresses []string
+}
+
+func newAddressAllowlist(raw json.RawMessage) (Checker, error) {
+	cfg := &addressAllowlistConfig{}
+	if len(raw) > 0 {
+		if err := json.Unmarshal(raw, cfg); err != nil {
+			return nil, err
+		}
+	}
+	checker := &addressAllowlist{addrs: make(map[common.Address]bool, len(cfg.Addresses))}
+	for _, s := range cfg.Addresses {
+		addr, err := common.AddressFromBase58(s)
+		if err != nil {
+			return nil, err
+		}
+		checker.addrs[addr] = true
+	}
+	return checker, nil
+}
+
+func (self *addressAllowlist) Check(tx *types.Transaction) errors.ErrCode {
+	if !self.addrs[tx.Payer] {
+		return errors.ErrPolicyDenied
+	}
+	return errors.ErrNoError
+}
@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+
+// Package plugin provides the custom transaction validators of the txnpool
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	"github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/errors"
+	vatypes "github.com/dnaproject2/DNA/validator/types"
+	"github.com/ontio/ontology-eventbus/actor"
+)
+
+// Checker is the check of a validator plugin
+type Checker interface {
+	// Check returns ErrNoError if the transaction passes
+	Check(tx *types.Transaction) errors.ErrCode
+}
+
+// Factory creates a checker with the plugin config
+type Factory func(cfg json.RawMessage) (Checker, error)
+
+var factories = struct {
+	sync.RWMutex
+	entries map[string]Factory
+}{entries: make(map[string]Factory)}
+
+// Register registers a plugin factory with the name
+func Register(name string, factory Factory) {
+	factories.Lock()
+	defer factories.Unlock()
+	factories.entries[name] = factory
+}
+
+func getFactory(name string) Factory {
+	factories.RLock()
+	defer factories.RUnlock()
+	return factories.entries[name]
+}
+
+// Validator wraps validator actor's pid
+type Validator interface {
+	// Register send a register message to poolId
+	Register(poolId *actor.PID)
+	// UnRegister send an unregister message to poolId
+	UnRegister(poolId *actor.PID)
+	// VerifyType returns the type of validator
+	VerifyType() vatypes.VerifyType
+}
+
+type validator struct {
+	pid        *actor.PID
+	id         string
+	verifyType vatypes.VerifyType
+	checker    Checker
+}
+
+// NewValidator spawns a validator actor running the checker
+func NewValidator(id string, verifyType vatypes.VerifyType, checker Checker) (Validator, error) {
+	if verifyType < vatypes.Plugin || verifyType >= vatypes.MAX_VERIFY_TYPE {
+		return nil, fmt.Errorf("invalid plugin verify type %d", verifyType)
+	}
+	validator := &validator{
+		id:         id,
+		verifyType: verifyType,
+		checker:    checker,
+	}
+	props := actor.FromProducer(func() actor.Actor {
+		return validator
+	})
+
+	pid, err := actor.SpawnNamed(props, id)
+	validator.pid = pid
+	return validator, err
+}
+
+// NewValidators creates the validators configured for the txnpool, the
+// index-th plugin verifies with vatypes.PluginVerifyType(index)
+func NewValidators(cfgs []*config.ValidatorPluginConfig) ([]Validator, error) {
+	if len(cfgs) > int(vatypes.MAX_VERIFY_TYPE-vatypes.Plugin) {
+		return nil, fmt.Errorf("too many validator plugins %d", len(cfgs))
+	}
+	validators := make([]Validator, 0, len(cfgs))
+	for i, cfg := range cfgs {
+		factory := getFactory(cfg.Name)
+		if factory == nil {
+			return nil, fmt.Errorf("unknown validator plugin %s", cfg.Name)
+		}
+		checker, err := factory(cfg.Config)
+		if err != nil {
+			return nil, fmt.Errorf("validator plugin %s config error: %s", cfg.Name, err)
+		}
+		id := fmt.Sprintf("plugin_validator_%d_%s", i, cfg.Name)
+		v, err := NewValidator(id, vatypes.PluginVerifyType(i), checker)
+		if err != nil {
+			return nil, err
+		}
+		validators = append(validators, v)
+	}
+	return validators, nil
+}
+
+func (self *validator) Receive(context actor.Context) {
+	switch msg := context.Message().(type) {
+	case *actor.Started:
+		log.Infof("plugin-validator %s: started and be ready to receive txn", self.id)
+	case *actor.Stopping:
+		log.Infof("plugin-validator %s: stopping", self.id)
+	case *actor.Restarting:
+		log.Infof("plugin-validator %s: restarting", self.id)
+	case *actor.Stopped:
+		log.Infof("plugin-validator %s: stopped", self.id)
+	case *vatypes.CheckTx:
+		log.Debugf("plugin-validator %s receive tx %x", self.id, msg.Tx.Hash())
+		sender := context.Sender()
+		errCode := self.checker.Check(msg.Tx)
+
+		response := &vatypes.CheckResponse{
+			WorkerId: msg.WorkerId,
+			ErrCode:  errCode,
+			Hash:     msg.Tx.Hash(),
+			Type:     self.VerifyType(),
+			Height:   0,
+		}
+
+		sender.Tell(response)
+	case *vatypes.UnRegisterAck:
+		context.Self().Stop()
+	default:
+		log.Info("plugin-validator: unknown msg ", msg, "type", reflect.TypeOf(msg))
+	}
+}
+
+func (self *validator) VerifyType() vatypes.VerifyType {
+	return self.verifyType
+}
+
+// Register send RegisterValidator message to txpool
+func (self *validator) Register(poolId *actor.PID) {
+	poolId.Tell(&vatypes.RegisterValidator{
+		Sender: self.pid,
+		Type:   self.VerifyType(),
+		Id:     self.id,
+	})
+}
+
+// UnRegister send UnRegisterValidator message to txpool
+func (self *validator) UnRegister(poolId *actor.PID) {
+	poolId.Tell(&vatypes.UnRegisterValidator{
+		Id:   self.id,
+		Type: self.VerifyType(),
+	})
+}
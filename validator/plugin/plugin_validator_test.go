@@ -0,0 +1,83 @@
+/*
+ * Copyright (C) 2018 The DNA Authors
+ * This file is part of The DNA library.
+ *
+ * The DNA is free software: you can redistribute it and/or modify
+ * it under the terms of the GNU Lesser General Public License as published by
+ * the Free Software Foundation, either version 3 of the License, or
+ * (at your option) any later version.
+ *
+ * The DNA is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE.  See the
+ * GNU Lesser General Public License for more details.
+ *
+ * You should have received a copy of the GNU Lesser General Public License
+ * along with The DNA.  If not, see <http://www.gnu.org/licenses/>.
+ */
+package plugin
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/dnaproject2/DNA/account"
+	"github.com/dnaproject2/DNA/common/config"
+	"github.com/dnaproject2/DNA/common/log"
+	ctypes "github.com/dnaproject2/DNA/core/types"
+	"github.com/dnaproject2/DNA/core/utils"
+	"github.com/dnaproject2/DNA/errors"
+	types2 "github.com/dnaproject2/DNA/validator/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestTx(t *testing.T, payer *account.Account) *ctypes.Transaction {
+	mutable := utils.NewDeployTransaction([]byte{1, 2, 3}, "test", "1", "author", "author@123.com", "test desp", false)
+	mutable.Payer = payer.Address
+	tx, err := mutable.IntoImmutable()
+	assert.Nil(t, err)
+	return tx
+}
+
+func TestPluginValidator(t *testing.T) {
+	log.InitLog(log.InfoLog, log.Stdout)
+	allowed := account.NewAccount("")
+	denied := account.NewAccount("")
+
+	raw, _ := json.Marshal(&addressAllowlistConfig{Addresses: []string{allowed.Address.ToBase58()}})
+	validators, err := NewValidators([]*config.ValidatorPluginConfig{
+		{Name: ADDRESS_ALLOWLIST, Config: raw},
+	})
+	assert.Nil(t, err)
+	assert.Equal(t, 1, len(validators))
+	assert.Equal(t, types2.Plugin, validators[0].VerifyType())
+
+	pid := validators[0].(*validator).pid
+	check := func(tx *ctypes.Transaction) *types2.CheckResponse {
+		fut := pid.RequestFuture(&types2.CheckTx{WorkerId: 1, Tx: tx}, time.Second)
+		res, err := fut.Result()
+		assert.Nil(t, err)
+		return res.(*types2.CheckResponse)
+	}
+
+	result := check(newTestTx(t, allowed))
+	assert.Equal(t, errors.ErrNoError, result.ErrCode)
+	assert.Equal(t, types2.Plugin, result.Type)
+
+	result = check(newTestTx(t, denied))
+	assert.Equal(t, errors.ErrPolicyDenied, result.ErrCode)
+}
+
+func TestNewValidatorsError(t *testing.T) {
+	_, err := NewValidators([]*config.ValidatorPluginConfig{{Name: "not_exist"}})
+	assert.NotNil(t, err)
+
+	_, err = NewValidators([]*config.ValidatorPluginConfig{
+		{Name: ADDRESS_ALLOWLIST, Config: json.RawMessage(`{"Addresses":["invalid"]}`)},
+	})
+	assert.NotNil(t, err)
+
+	_, err = NewValidator("plugin_test", types2.Stateful, nil)
+	assert.NotNil(t, err)
+}
@@ -61,4 +61,15 @@ type VerifyType uint8
 const (
 	Stateless VerifyType = iota
 	Stateful  VerifyType = iota
+	// Plugin is the first type of the custom validators, each plugin gets
+	// its own type in the configured order
+	Plugin VerifyType = iota
 )
+
+// MAX_VERIFY_TYPE limits the verify types to the bits of the txnpool flag
+const MAX_VERIFY_TYPE = 8
+
+// PluginVerifyType returns the verify type of the index-th plugin
+func PluginVerifyType(index int) VerifyType {
+	return Plugin + VerifyType(index)
+}